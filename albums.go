@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// variousArtists is the artist shown for albums whose songs have different artists
+const variousArtists = "Various Artists"
+
+// Album is a group of library songs sharing an album tag, whatever playlists they're in
+type Album struct {
+	Title       string `json:"title"`
+	Artist      string `json:"artist"` // Various Artists for compilations
+	Year        int    `json:"year,omitempty"`
+	Genre       string `json:"genre,omitempty"`
+	CoverHash   string `json:"coverHash,omitempty"` // Most common cover of the songs, see GetCoverByHash
+	DurationSec int    `json:"durationSec"`
+	Songs       []Song `json:"songs"`
+}
+
+// Artist is a group of library songs sharing an artist tag
+type Artist struct {
+	Name        string   `json:"name"`
+	CoverHash   string   `json:"coverHash,omitempty"` // Most common cover of the songs, see GetCoverByHash
+	Albums      []string `json:"albums"`
+	DurationSec int      `json:"durationSec"`
+	Songs       []Song   `json:"songs"`
+}
+
+// commonCover returns the cover hash most of the songs have, "" when none has one
+func commonCover(songs []Song) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, song := range songs {
+		if song.CoverHash == "" {
+			continue
+		}
+		counts[song.CoverHash]++
+		if counts[song.CoverHash] > counts[best] {
+			best = song.CoverHash
+		}
+	}
+	return best
+}
+
+// albumOrderLess orders songs by folder, album, disc and track number. Songs without a
+// number go after the numbered ones of their album, ties keep their order.
+func albumOrderLess(first Song, second Song) bool {
+	if dirFirst, dirSecond := pathKey(filepath.Dir(first.FilePath)), pathKey(filepath.Dir(second.FilePath)); dirFirst != dirSecond {
+		return dirFirst < dirSecond
+	}
+	if albumFirst, albumSecond := strings.ToLower(first.Album), strings.ToLower(second.Album); albumFirst != albumSecond {
+		return albumFirst < albumSecond
+	}
+	if discFirst, discSecond := tagNumberOrder(first.DiscNumber), tagNumberOrder(second.DiscNumber); discFirst != discSecond {
+		return discFirst < discSecond
+	}
+	return tagNumberOrder(first.TrackNumber) < tagNumberOrder(second.TrackNumber)
+}
+
+// tagNumberOrder sorts missing disc and track numbers last
+func tagNumberOrder(number int) int {
+	if number <= 0 {
+		return math.MaxInt32
+	}
+	return number
+}
+
+// sortAlbumSongs orders the songs of an album by disc and track number, songs without
+// one by file name, which is where rips keep the track number
+func sortAlbumSongs(songs []Song) {
+	sort.SliceStable(songs, func(i, j int) bool {
+		return strings.ToLower(filepath.Base(songs[i].FilePath)) < strings.ToLower(filepath.Base(songs[j].FilePath))
+	})
+	sort.SliceStable(songs, func(i, j int) bool {
+		if discFirst, discSecond := tagNumberOrder(songs[i].DiscNumber), tagNumberOrder(songs[j].DiscNumber); discFirst != discSecond {
+			return discFirst < discSecond
+		}
+		return tagNumberOrder(songs[i].TrackNumber) < tagNumberOrder(songs[j].TrackNumber)
+	})
+}
+
+// albumKey groups songs into albums: the album tag within a folder, songs without one
+// are albums of their own
+func albumKey(song Song) string {
+	if song.Album == "" || song.Album == "Unknown Album" {
+		return "song|" + pathKey(song.FilePath)
+	}
+	return strings.ToLower(song.Album) + "|" + pathKey(filepath.Dir(song.FilePath))
+}
+
+// shuffleAlbums puts the albums of the songs in random order, each album's songs in
+// disc and track order
+func shuffleAlbums(songs []Song) []Song {
+	byKey := make(map[string][]Song)
+	var keys []string
+	for _, song := range songs {
+		key := albumKey(song)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], song)
+	}
+	rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+
+	shuffled := make([]Song, 0, len(songs))
+	for _, key := range keys {
+		album := byKey[key]
+		sortAlbumSongs(album)
+		shuffled = append(shuffled, album...)
+	}
+	return shuffled
+}
+
+// ShuffleQueueAlbums shuffles the albums queued after the current song, keeping each
+// album's songs together in track order. Frequently skipped songs are left out like
+// in ShuffleQueue.
+func (a *App) ShuffleQueueAlbums() QueueState {
+	excluded := make(map[string]bool)
+	for _, track := range a.GetShuffleExclusionSuggestions() {
+		excluded[track.FilePath] = true
+	}
+
+	a.queueMutex.Lock()
+	start := a.queueIndex + 1
+	var upcoming []Song
+	for _, song := range a.queue[start:] {
+		if !a.isExcludedFromShuffle(song.FilePath, excluded) {
+			upcoming = append(upcoming, song)
+		}
+	}
+	a.queue = append(a.queue[:start:start], shuffleAlbums(upcoming)...)
+	state := a.queueStateLocked()
+	a.queueMutex.Unlock()
+
+	a.emitEvent(eventQueueChanged, state)
+	return state
+}
+
+// PlayAlbumShuffle queues the whole library as albums in random order, each played in
+// track order, and starts with the first one
+func (a *App) PlayAlbumShuffle() (QueueState, error) {
+	songs, err := a.uniqueLibrarySongs()
+	if err != nil {
+		return QueueState{}, err
+	}
+	if len(songs) == 0 {
+		return QueueState{}, fmt.Errorf("library is empty")
+	}
+	if err := a.SetQueue(shuffleAlbums(songs), 0); err != nil {
+		return QueueState{}, err
+	}
+	return a.GetQueue(), nil
+}
+
+// GetAlbums groups the library's songs into albums, sorted by title. Songs with the same
+// album tag in one folder are one album, so two "Greatest Hits" in different folders stay
+// apart. Songs without an album tag are left out.
+func (a *App) GetAlbums() ([]Album, error) {
+	songs, err := a.uniqueLibrarySongs()
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*Album)
+	var keys []string
+	for _, song := range songs {
+		if song.Album == "" || song.Album == "Unknown Album" {
+			continue
+		}
+		key := albumKey(song)
+		album, ok := byKey[key]
+		if !ok {
+			album = &Album{Title: song.Album, Artist: song.Artist}
+			byKey[key] = album
+			keys = append(keys, key)
+		} else if !strings.EqualFold(album.Artist, song.Artist) {
+			album.Artist = variousArtists
+		}
+		if album.Year == 0 {
+			album.Year = song.Year
+		}
+		if album.Genre == "" {
+			album.Genre = song.Genre
+		}
+		album.DurationSec += song.DurationSec
+		album.Songs = append(album.Songs, song)
+	}
+
+	albums := make([]Album, 0, len(keys))
+	for _, key := range keys {
+		album := byKey[key]
+		sortAlbumSongs(album.Songs)
+		album.CoverHash = commonCover(album.Songs)
+		album.Songs = listSongs(album.Songs)
+		albums = append(albums, *album)
+	}
+	sort.SliceStable(albums, func(i, j int) bool {
+		return strings.ToLower(albums[i].Title) < strings.ToLower(albums[j].Title)
+	})
+	return albums, nil
+}
+
+// GetArtists groups the library's songs by artist, sorted by name. An artist's songs are
+// ordered by album, then as in GetAlbums. Songs without an artist tag are left out.
+func (a *App) GetArtists() ([]Artist, error) {
+	songs, err := a.uniqueLibrarySongs()
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*Artist)
+	var names []string
+	for _, song := range songs {
+		if song.Artist == "" || song.Artist == "Unknown Artist" {
+			continue
+		}
+		name := strings.ToLower(song.Artist)
+		artist, ok := byName[name]
+		if !ok {
+			artist = &Artist{Name: song.Artist, Albums: []string{}}
+			byName[name] = artist
+			names = append(names, name)
+		}
+		artist.DurationSec += song.DurationSec
+		artist.Songs = append(artist.Songs, song)
+	}
+
+	artists := make([]Artist, 0, len(names))
+	for _, name := range names {
+		artist := byName[name]
+		sortAlbumSongs(artist.Songs)
+		sort.SliceStable(artist.Songs, func(i, j int) bool {
+			return strings.ToLower(artist.Songs[i].Album) < strings.ToLower(artist.Songs[j].Album)
+		})
+		seen := make(map[string]bool)
+		for _, song := range artist.Songs {
+			if album := strings.ToLower(song.Album); song.Album != "" && song.Album != "Unknown Album" && !seen[album] {
+				seen[album] = true
+				artist.Albums = append(artist.Albums, song.Album)
+			}
+		}
+		artist.CoverHash = commonCover(artist.Songs)
+		artist.Songs = listSongs(artist.Songs)
+		artists = append(artists, *artist)
+	}
+	sort.SliceStable(artists, func(i, j int) bool {
+		return strings.ToLower(artists[i].Name) < strings.ToLower(artists[j].Name)
+	})
+	return artists, nil
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -18,7 +20,6 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,8 +29,8 @@ import (
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
 	"github.com/godbus/dbus/v5/prop"
-	"github.com/hugolgst/rich-go/client"
 	"github.com/tcolgate/mp3"
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // MPRIS interface constants
@@ -38,6 +39,7 @@ const (
 	mprisInterface = "org.mpris.MediaPlayer2"
 	playerInterface = "org.mpris.MediaPlayer2.Player"
 	busName        = "org.mpris.MediaPlayer2.Static"
+	staticInterface = "org.mpris.MediaPlayer2.Static.Playback" // Static-specific playback extensions
 )
 
 // App struct
@@ -46,20 +48,150 @@ type App struct {
 	currentSong   *Song
 	isPlaying     bool
 	discordActive bool
+	discord       CustomDiscordRPC // Raw IPC connection behind discordActive
+	discordRetry  discordReconnector
+	songCache     *lruCache // Recently used song metadata, in front of the library index
+	thumbCache    *lruCache // Recently used cover thumbnails
+	tracks        trackRegistry
+	covers        coverStore // Distinct covers by content hash, referenced by Song.CoverHash
 	dbusConn      *dbus.Conn
+	notifier      trackNotifier // Desktop notification replaced on each track change
 	mprisProps    *prop.Properties
 	settings      *Settings
-	songStartTime time.Time // Track when the current song started
+	songStartTime time.Time // When the current song started, moved on pauses and seeks
+	clock         playbackClock
 	
 	// Cover art web server
 	coverServer     *http.Server
 	coverServerPort int
+	coverServerToken string // Required on every request, generated when the server starts
 	coverMutex      sync.RWMutex
 	currentCoverURL string
 	
+	// Window placement, restored across launches
+	window windowStateStore
+	
 	// Cover art cache for uploaded images
-	coverCache map[string]string // hash -> imgur URL
-	cacheMutex sync.RWMutex
+	coverURLs     *coverURLStore
+	artwork       artworkStore
+	
+	// Media session sharing with other players
+	sessionYielded bool
+	sessionMutex   sync.RWMutex
+	
+	// Playback queue and modes
+	queue         []Song
+	queueIndex    int
+	queuePlaylist string // Folder of the playlist the queue was set to, "" for other queues
+	playbackModes PlaybackModes
+	queueMutex    sync.Mutex
+	
+	// Per-song listening statistics and play history
+	stats   *statsStore
+	history *historyStore
+	scrobblerLogMutex sync.Mutex
+	
+	// Songs played of each album and playlist, for continue listening cards
+	progress *progressStore
+	
+	// Non-Discord presence targets (Slack, Telegram)
+	presenceSinks []*sinkRunner
+	sinkMutex     sync.Mutex
+	
+	// Now playing sharing to Mastodon/Bluesky
+	tracksSinceShare int
+	shareMutex       sync.Mutex
+	
+	// Last.fm history import
+	lastfmImporting bool
+	lastfmMutex     sync.Mutex
+	
+	// Tempo cache for auto-mix
+	bpms *bpmStore
+	
+	// Song languages assigned by hand
+	languages *languageStore
+	
+	// Personal notes attached to songs
+	notes *noteStore
+	
+	// Star ratings and favorites
+	ratings *ratingStore
+	
+	// Today's listening time of playlists with a schedule
+	scheduleUsage *scheduleUsageStore
+	
+	// Background stem separation jobs
+	stems stemJobs
+	
+	// Cover thumbnails exported to the XDG cache
+	thumbnails *thumbnailIndex
+	
+	// Dock/taskbar progress
+	taskbar taskbarProgress
+	
+	// Effects applied to what's currently playing, for tempo-aware durations
+	activeEffects EffectChain
+	effectsMutex  sync.RWMutex
+	
+	// Per-device volume memory
+	deviceVolumes *deviceVolumeStore
+	outputDevice  string
+	volumeTarget  float64 // Where the limiter is ramping to
+	volumeRamping bool
+	volumeMutex   sync.Mutex
+	
+	// Ducking for voice chat and notification sounds
+	ducking duckingMonitor
+	
+	// Size limit of the processed audio cache
+	audioCache audioCacheJanitor
+	
+	// Background work held back while a game runs
+	gameMode gameModeMonitor
+	
+	// Web remote and party mode
+	remoteServer *http.Server
+	remoteMutex  sync.Mutex
+	party        partyQueue
+	uploads      uploadInbox // Guest song files waiting for approval
+	pairing      remotePairing // mDNS announcement and QR pairing for mobile clients
+	
+	// Persistent song metadata index
+	library        *libraryIndex
+	libraryOffline bool // Static folder's drive is unavailable
+	
+	// Library scan telemetry
+	scanStats    scanTracker
+	scanThrottle scanThrottle // Rate limit of scan reads, see ScanFilesPerSecond
+	
+	// External tag edits to the playing song
+	tagWatch tagWatcher
+	
+	// Problems found with the library on startup
+	preflight libraryPreflight
+	
+	// Local HTTP audio streaming
+	audio audioServer
+	
+	// Startup behavior and last-session saving
+	session sessionTracker
+	
+	// Reason announced for the next song change
+	transitions transitionTracker
+	
+	// Covers looked up online for songs without art
+	coverFetch coverFetcher
+	
+	// Audio formats the webview can decode
+	playback playbackSupport
+	
+	// Synced lyrics of the playing song, for lyric line events
+	lyrics lyricsTracker
+	
+	// Loaded playlists by folder path, used for paged song responses
+	playlistCache map[string]Playlist
+	playlistMutex sync.RWMutex
 }
 
 // Song represents a single song in a playlist
@@ -68,10 +200,24 @@ type Song struct {
 	Artist      string `json:"artist"`
 	Album       string `json:"album"`
 	FilePath    string `json:"filePath"`
+	TrackID     string `json:"trackId,omitempty"` // Content-derived ID accepted by the APIs in place of FilePath
 	Duration    string `json:"duration"`
-	CoverData   string `json:"coverData,omitempty"` // Base64 encoded cover from MP3
+	CoverData   string `json:"coverData,omitempty"` // Base64 encoded cover from MP3, left out of list responses
+	CoverHash   string `json:"coverHash,omitempty"` // Cover store key, see GetCoverByHash
+	HasCover    bool   `json:"hasCover,omitempty"`  // Set in list responses when GetSongCover will return a cover
 	DurationSec int    `json:"durationSec,omitempty"`
 	Position    int    `json:"position,omitempty"`   // Position in playlist (1-based)
+	Gapless     *GaplessInfo `json:"gapless,omitempty"` // Encoder delay/padding for gapless playback
+	Language    string `json:"language,omitempty"` // ISO 639-2 code from TLAN/LANGUAGE or assigned with SetSongLanguage
+	Note        string `json:"note,omitempty"`     // Personal note, see SetTrackNote
+	TrackNumber int    `json:"trackNumber,omitempty"`
+	DiscNumber  int    `json:"discNumber,omitempty"`
+	Year        int    `json:"year,omitempty"`
+	Genre       string `json:"genre,omitempty"`
+	Rating      int    `json:"rating,omitempty"`   // 1-5 stars, from SetRating or the file's POPM frame
+	Favorite    bool   `json:"favorite,omitempty"` // See ToggleFavorite
+	ReplayGain  *ReplayGainInfo `json:"replayGain,omitempty"` // From the file's tags, see GetPlaybackGain
+	HasLyrics   bool   `json:"hasLyrics,omitempty"` // Lyrics are embedded in the tags, see GetEmbeddedLyrics
 }
 
 // PlaylistConfig represents the playlist.toml structure (simplified)
@@ -81,6 +227,12 @@ type PlaylistConfig struct {
 	Cover       string                 `toml:"cover" json:"cover"` // Path to cover image relative to playlist folder
 	Position    int                    `toml:"position" json:"position"` // Current playback position in playlist (0-based)
 	Songs       map[string]int         `toml:"songs" json:"songs"` // filename -> position mapping
+	Include     []string               `toml:"include,omitempty" json:"include,omitempty"` // Glob patterns of song files to include (relative to musics/)
+	Exclude     []string               `toml:"exclude,omitempty" json:"exclude,omitempty"` // Glob patterns of files or folders to skip (relative to musics/)
+	Languages   []string               `toml:"languages,omitempty" json:"languages,omitempty"` // Only list songs in these languages, e.g. ["jpn"]
+	Tracks      []string               `toml:"tracks,omitempty" json:"tracks,omitempty"` // Explicit song order, paths relative to musics/ or absolute for songs kept elsewhere
+	Sources     []string               `toml:"sources,omitempty" json:"sources,omitempty"` // More folders scanned like musics/, e.g. on an external drive or NAS
+	Schedule    *PlaylistSchedule      `toml:"schedule,omitempty" json:"schedule,omitempty"` // Only playable in a time window and for so long a day
 }
 
 // Playlist represents a complete playlist with metadata
@@ -89,8 +241,12 @@ type Playlist struct {
 	Description string `json:"description"`
 	FolderPath  string `json:"folderPath"`
 	Songs       []Song `json:"songs"`
-	CoverData   string `json:"coverData,omitempty"` // Base64 encoded playlist cover
+	CoverData   string `json:"coverData,omitempty"` // Base64 encoded playlist cover, left out of list responses
+	HasCover    bool   `json:"hasCover,omitempty"`  // Set in list responses when GetPlaylistCover will return a cover
 	Position    int    `json:"position"`            // Current position in playlist (0-based)
+	Offline     bool   `json:"offline,omitempty"`   // Drive is unavailable, songs come from the library index
+	Schedule    *PlaylistSchedule `json:"schedule,omitempty"` // When and how long the playlist can be played
+	Virtual     bool   `json:"virtual,omitempty"`   // Built from ratings, FolderPath is an ID rather than a folder
 }
 
 // Settings represents user preferences
@@ -109,6 +265,72 @@ type Settings struct {
 	MinimizeToTray    bool    `json:"minimizeToTray"`    // Minimize to system tray
 	StartMinimized    bool    `json:"startMinimized"`    // Start application minimized
 	ShowLyrics        bool    `json:"showLyrics"`        // Show lyrics if available
+	MediaSessionPolicy string `json:"mediaSessionPolicy"` // "priority" or "yield" when another MPRIS player is active (Linux)
+	ExcludeSkippedFromShuffle bool `json:"excludeSkippedFromShuffle"` // Keep frequently skipped songs out of shuffle
+	ScanHiddenFiles   bool    `json:"scanHiddenFiles"`   // Include dotfiles and hidden folders in library scans
+	PresenceDetailsTemplate   string `json:"presenceDetailsTemplate"`   // Discord details line, e.g. "{title}"
+	PresenceStateTemplate     string `json:"presenceStateTemplate"`     // Discord state line, e.g. "by {artist}"
+	PresenceLargeTextTemplate string `json:"presenceLargeTextTemplate"` // Album art hover text, e.g. "{album}"
+	SlackPresence     bool    `json:"slackPresence"`     // Show the current track as Slack status
+	SlackToken        string  `json:"slackToken"`        // Slack user token (xoxp-) with users.profile:write
+	SlackStatusEmoji  string  `json:"slackStatusEmoji"`  // Status emoji, e.g. ":headphones:"
+	TelegramPresence  bool    `json:"telegramPresence"`  // Show the current track in the Telegram bio
+	TelegramBioCommand string `json:"telegramBioCommand"` // Helper command that sets the bio (receives the bio as last argument)
+	TelegramDefaultBio string `json:"telegramDefaultBio"` // Bio restored when nothing is playing
+	PresenceSinkSchedule     string `json:"presenceSinkSchedule"`     // "HH:MM-HH:MM" window for Slack/Telegram updates, empty for always
+	PresenceSinkWeekdaysOnly bool   `json:"presenceSinkWeekdaysOnly"` // Only update Slack/Telegram on weekdays
+	MastodonInstance   string `json:"mastodonInstance"`   // e.g. "mastodon.social"
+	MastodonToken      string `json:"mastodonToken"`      // Access token with write:statuses and write:media
+	BlueskyHandle      string `json:"blueskyHandle"`      // e.g. "user.bsky.social"
+	BlueskyAppPassword string `json:"blueskyAppPassword"` // Bluesky app password (not the account password)
+	ShareTemplate      string `json:"shareTemplate"`      // Post text, e.g. "#NowPlaying {title} by {artist}"
+	ShareIncludeCover  bool   `json:"shareIncludeCover"`  // Attach cover art to shared posts
+	AutoShareTarget    string `json:"autoShareTarget"`    // "", "mastodon" or "bluesky"
+	AutoShareEveryTracks int  `json:"autoShareEveryTracks"` // Auto-share every N tracks (0 disables)
+	LastfmAPIKey      string  `json:"lastfmAPIKey"`      // Last.fm API key, used to import scrobble history
+	LastfmUsername    string  `json:"lastfmUsername"`    // Last.fm account to import from
+	MaxVolume         float64 `json:"maxVolume"`         // Volume ceiling enforced by the backend, 0.0 to 1.0
+	VolumeLimiter     bool    `json:"volumeLimiter"`     // Raise the volume gradually instead of jumping
+	DuckOnVoiceChat   bool    `json:"duckOnVoiceChat"`   // Lower the volume during voice calls (Linux)
+	DuckOnNotifications bool  `json:"duckOnNotifications"` // Lower the volume while notification sounds play (Linux)
+	DuckingAmount     float64 `json:"duckingAmount"`     // How much to lower the volume by, 0.0 to 1.0
+	WebRemote         bool    `json:"webRemote"`         // Start the web remote on launch
+	WebRemotePort     int     `json:"webRemotePort"`     // Port the web remote listens on
+	WebRemoteToken    string  `json:"webRemoteToken"`    // Host token for playback control, generated on first start
+	PartyMode         bool    `json:"partyMode"`         // Let web remote guests search and request songs
+	PartyUploads      bool    `json:"partyUploads"`      // Let party guests upload song files for approval
+	AutoMix           bool    `json:"autoMix"`           // Beat-matched transitions between queued songs
+	AutoMixTransitionSec float64 `json:"autoMixTransitionSec"` // Length of each auto-mix transition
+	AcoustIDAPIKey    string  `json:"acoustIDAPIKey"`    // AcoustID application key, used to identify untagged songs
+	TaskbarProgress   bool    `json:"taskbarProgress"`   // Show song progress on the dock/taskbar icon
+	StartupBehavior   string  `json:"startupBehavior"`   // "nothing", "resume", "playlist" or "dailymix"
+	StartupPlaylist   string  `json:"startupPlaylist"`   // Playlist folder opened when StartupBehavior is "playlist"
+	MediaJobCPUFraction float64 `json:"mediaJobCPUFraction"` // Share of CPU cores FFmpeg jobs may use at once, 0.1 to 1.0
+	MetadataCacheSize   int     `json:"metadataCacheSize"`   // Songs kept in memory in front of the library index
+	ScanParallelism     int     `json:"scanParallelism"`     // Files read at once during scans, 0 uses every CPU core
+	ThumbnailCacheSize  int     `json:"thumbnailCacheSize"`  // Cover thumbnails kept in memory
+	NotifyDuringDND     bool    `json:"notifyDuringDND"`     // Show track notifications in Do Not Disturb mode and over fullscreen apps
+	GameMode            bool    `json:"gameMode"`            // Defer heavy background work and slow Discord updates while a game runs
+	AudioCacheMaxMB     int     `json:"audioCacheMaxMB"`     // Processed audio cache limit, least recently used entries are evicted
+	DiscordTimeDisplay  string  `json:"discordTimeDisplay"`  // "remaining" for a progress bar, "elapsed" for time since the song started
+	ScanFilesPerSecond  int     `json:"scanFilesPerSecond"`  // Files read from disk per second during scans, 0 for no limit
+	ScanLowPriority     bool    `json:"scanLowPriority"`     // Run scans at low CPU and IO priority (nice/ionice on Linux)
+	WebRemoteTokens     []RemoteAccessToken `json:"webRemoteTokens"` // Extra web remote tokens with limited roles
+	WriteRatingTags     bool    `json:"writeRatingTags"`     // Also write ratings to MP3 files as ID3 POPM frames
+	SmartGain           bool    `json:"smartGain"`           // Apply ReplayGain, album gain within album runs and track gain otherwise
+	PresenceBridge      bool    `json:"presenceBridge"`      // Write the listening status for RPC alternatives like arRPC
+	PresenceBridgeFile  string  `json:"presenceBridgeFile"`  // Status JSON path, "" for static-presence.json in the runtime directory
+	PresenceBridgeSocket string `json:"presenceBridgeSocket"` // IPC socket or pipe of an RPC bridge to send the activity to, "" for none
+	FetchMissingCovers  bool    `json:"fetchMissingCovers"`  // Download art for songs without any from the Cover Art Archive or iTunes
+	FetchedCoverLocation string `json:"fetchedCoverLocation"` // "config" to keep fetched covers in the config dir, "folder" to also save them next to the song
+	SkipQueuedDuplicates bool   `json:"skipQueuedDuplicates"` // Don't enqueue songs that are already coming up in the queue
+	FetchLyricsOnline   bool    `json:"fetchLyricsOnline"`   // Look lyrics up on LRCLIB for songs without .lrc files or embedded lyrics
+	ScrobblerLog        bool    `json:"scrobblerLog"`        // Append plays to a Rockbox-style .scrobbler.log for offline uploaders
+	ScrobblerLogPath    string  `json:"scrobblerLogPath"`    // Log file, "" for .scrobbler.log in the config dir
+	TranscodeCodec      string  `json:"transcodeCodec"`      // "mp3" or "opus" for songs the webview can't decode
+	TranscodeBitrate    int     `json:"transcodeBitrate"`    // kbps, 64 to 320
+	TranscodeCache      bool    `json:"transcodeCache"`      // Keep transcodes in the audio cache, otherwise they're deleted on exit
+	LogLevel            string  `json:"logLevel"`            // "debug", "info", "warn" or "error"
 }
 
 // MPRIS MediaPlayer2 interface implementation
@@ -131,58 +353,81 @@ type Player struct {
 
 func (p *Player) Next() *dbus.Error {
 	// This would be called from system media controls
-	fmt.Println("MPRIS: Next track requested")
+	logDebug("MPRIS: Next track requested")
 	return nil
 }
 
 func (p *Player) Previous() *dbus.Error {
 	// This would be called from system media controls
-	fmt.Println("MPRIS: Previous track requested")
+	logDebug("MPRIS: Previous track requested")
 	return nil
 }
 
 func (p *Player) Pause() *dbus.Error {
-	fmt.Println("MPRIS: Pause requested")
+	logDebug("MPRIS: Pause requested")
 	return nil
 }
 
 func (p *Player) PlayPause() *dbus.Error {
-	fmt.Println("MPRIS: PlayPause requested")
+	logDebug("MPRIS: PlayPause requested")
 	return nil
 }
 
 func (p *Player) Stop() *dbus.Error {
-	fmt.Println("MPRIS: Stop requested")
+	logDebug("MPRIS: Stop requested")
 	return nil
 }
 
 func (p *Player) Play() *dbus.Error {
-	fmt.Println("MPRIS: Play requested")
+	logDebug("MPRIS: Play requested")
 	return nil
 }
 
 func (p *Player) Seek(offset int64) *dbus.Error {
-	fmt.Printf("MPRIS: Seek requested: %d microseconds\n", offset)
+	logDebug("MPRIS: Seek requested: %d microseconds", offset)
 	return nil
 }
 
 func (p *Player) SetPosition(trackId dbus.ObjectPath, position int64) *dbus.Error {
-	fmt.Printf("MPRIS: SetPosition requested: %s, %d microseconds\n", trackId, position)
+	logDebug("MPRIS: SetPosition requested: %s, %d microseconds", trackId, position)
 	return nil
 }
 
 func (p *Player) OpenUri(uri string) *dbus.Error {
-	fmt.Printf("MPRIS: OpenUri requested: %s\n", uri)
+	logDebug("MPRIS: OpenUri requested: %s", uri)
 	return nil
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{
+	app := &App{
 		discordActive: false,
 		settings:      getDefaultSettings(),
-		coverCache:    make(map[string]string),
-	}
+		coverURLs:     newCoverURLStore(filepath.Join(getConfigDir(), "cover-cache.json")),
+		artwork:       artworkStore{paths: newLRUCache(artworkPathCache)},
+		queueIndex:    -1,
+		stats:         newStatsStore(filepath.Join(getConfigDir(), "stats.json")),
+		history:       newHistoryStore(filepath.Join(getConfigDir(), "history.jsonl")),
+		progress:      newProgressStore(filepath.Join(getConfigDir(), "progress.json")),
+		playlistCache: make(map[string]Playlist),
+		deviceVolumes: newDeviceVolumeStore(filepath.Join(getConfigDir(), "device_volumes.json")),
+		bpms:          newBPMStore(filepath.Join(getConfigDir(), "bpm.json")),
+		languages:     newLanguageStore(filepath.Join(getConfigDir(), "languages.json")),
+		notes:         newNoteStore(filepath.Join(getConfigDir(), "notes.json")),
+		ratings:       newRatingStore(filepath.Join(getConfigDir(), "ratings.json")),
+		scheduleUsage: newScheduleUsageStore(filepath.Join(getConfigDir(), "schedule_usage.json")),
+		thumbnails:    newThumbnailIndex(filepath.Join(getConfigDir(), "thumbnails.json")),
+		songCache:     newLRUCache(defaultMetadataCacheSize),
+		thumbCache:    newLRUCache(defaultThumbnailCacheSize),
+		covers:        coverStore{recent: newLRUCache(coverStoreCacheSize), folders: newLRUCache(folderCoverCacheSize)},
+	}
+	
+	// Register presence sinks
+	app.registerPresenceSink(&SlackSink{app: app})
+	app.registerPresenceSink(&TelegramSink{app: app})
+	app.registerPresenceSink(&PresenceBridgeSink{app: app})
+	
+	return app
 }
 
 // getDefaultSettings returns default application settings
@@ -202,6 +447,56 @@ func getDefaultSettings() *Settings {
 		MinimizeToTray:    false,
 		StartMinimized:    false,
 		ShowLyrics:        false,
+		MediaSessionPolicy: sessionPolicyPriority,
+		ExcludeSkippedFromShuffle: false,
+		ScanHiddenFiles:   false,
+		PresenceDetailsTemplate:   defaultPresenceDetailsTemplate,
+		PresenceStateTemplate:     defaultPresenceStateTemplate,
+		PresenceLargeTextTemplate: defaultPresenceLargeTextTemplate,
+		SlackPresence:     false,
+		SlackStatusEmoji:  defaultSlackStatusEmoji,
+		TelegramPresence:  false,
+		PresenceSinkSchedule:     "",
+		PresenceSinkWeekdaysOnly: false,
+		ShareTemplate:     defaultShareTemplate,
+		ShareIncludeCover: true,
+		AutoShareTarget:   "",
+		AutoShareEveryTracks: 0,
+		MaxVolume:         1.0,
+		VolumeLimiter:     false,
+		DuckOnVoiceChat:   false,
+		DuckOnNotifications: false,
+		DuckingAmount:     defaultDuckingAmount,
+		WebRemote:         false,
+		WebRemotePort:     defaultRemotePort,
+		PartyMode:         false,
+		PartyUploads:      false,
+		AutoMix:           false,
+		AutoMixTransitionSec: defaultTransitionSec,
+		TaskbarProgress:   true,
+		StartupBehavior:   startupNothing,
+		StartupPlaylist:   "",
+		MediaJobCPUFraction: defaultMediaJobCPUFraction,
+		MetadataCacheSize:   defaultMetadataCacheSize,
+		ThumbnailCacheSize:  defaultThumbnailCacheSize,
+		NotifyDuringDND:     false,
+		GameMode:            false,
+		AudioCacheMaxMB:     defaultAudioCacheMaxMB,
+		DiscordTimeDisplay:  presenceTimeRemaining,
+		ScanFilesPerSecond:  0,
+		ScanLowPriority:     true,
+		WriteRatingTags:     false,
+		SmartGain:           true,
+		PresenceBridge:      false,
+		FetchMissingCovers:  false,
+		FetchedCoverLocation: fetchedCoversConfig,
+		SkipQueuedDuplicates: false,
+		FetchLyricsOnline:   false,
+		ScrobblerLog:        false,
+		TranscodeCodec:      transcodeMP3,
+		TranscodeBitrate:    defaultTranscodeBitrate,
+		TranscodeCache:      true,
+		LogLevel:            logLevelInfo,
 	}
 }
 
@@ -210,12 +505,66 @@ func getDefaultSettings() *Settings {
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 	
+	// Put the window back where it was before the frontend loads
+	a.restoreWindowPosition()
+	
 	// Load settings
 	a.loadSettings()
 	
+	// Load listening statistics
+	if err := a.stats.load(); err != nil {
+		logError("Failed to load stats: %v", err)
+	}
+	if err := a.history.load(); err != nil {
+		logError("Failed to load history: %v", err)
+	}
+	if err := a.progress.load(); err != nil {
+		logError("Failed to load collection progress: %v", err)
+	}
+	if err := a.deviceVolumes.load(); err != nil {
+		logError("Failed to load device volumes: %v", err)
+	}
+	if err := a.bpms.load(); err != nil {
+		logError("Failed to load BPM cache: %v", err)
+	}
+	if err := a.languages.load(); err != nil {
+		logError("Failed to load song languages: %v", err)
+	}
+	if err := a.notes.load(); err != nil {
+		logError("Failed to load song notes: %v", err)
+	}
+	if err := a.ratings.load(); err != nil {
+		logError("Failed to load song ratings: %v", err)
+	}
+	if err := a.scheduleUsage.load(); err != nil {
+		logError("Failed to load schedule usage: %v", err)
+	}
+	if err := a.coverURLs.load(); err != nil {
+		logError("Failed to load cover cache: %v", err)
+	}
+	if err := a.thumbnails.load(); err != nil {
+		logError("Failed to load thumbnail index: %v", err)
+	}
+	if library, err := openLibraryIndex(filepath.Join(getConfigDir(), "library.db")); err != nil {
+		logWarn("Library index unavailable, scans will read every file: %v", err)
+	} else {
+		a.library = library
+	}
+	
+	// Guest uploads don't outlive the session that received them, nor do uncached transcodes
+	clearUploadQuarantine()
+	clearTranscodeSession()
+	
 	// Start cover art web server
 	go a.startCoverServer()
 	
+	// Start the web remote if enabled
+	if a.settings.WebRemote {
+		if _, err := a.StartWebRemote(); err != nil {
+			logError("%v", err)
+		}
+	}
+	
 	// Initialize Discord RPC if enabled
 	if a.settings.DiscordRPC {
 		go a.initDiscordRPC()
@@ -224,20 +573,49 @@ func (a *App) startup(ctx context.Context) {
 	// Initialize MPRIS for Linux
 	if runtime.GOOS == "linux" {
 		go a.initMPRIS()
+		go a.watchMediaSessions()
+		go a.watchOutputDevice()
+		go a.watchDucking()
 	}
+	
+	// Reattach playlists when a removable library drive comes back
+	go a.watchLibraryRoot()
+	
+	// Hold heavy background work back while a game runs
+	go a.watchGameMode()
+	
+	// Derive the position from songStartTime when the frontend stops reporting it
+	go a.watchPlaybackPosition()
+	
+	// Keep the processed audio cache within its size limit
+	go a.runAudioCacheJanitor()
+	go a.pruneArtwork()
 }
 
-// getSettingsPath returns the path to the settings file
-func (a *App) getSettingsPath() string {
+// emitEvent sends an event to the frontend once the Wails runtime is available
+func (a *App) emitEvent(name string, data ...interface{}) {
+	if a.ctx == nil {
+		return
+	}
+	wailsruntime.EventsEmit(a.ctx, name, data...)
+}
+
+// getConfigDir returns the directory holding settings and user data
+func getConfigDir() string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return "settings.json"
+		return "."
 	}
 	
 	configDir := filepath.Join(homeDir, ".config", "static")
 	os.MkdirAll(configDir, 0755)
 	
-	return filepath.Join(configDir, "settings.json")
+	return configDir
+}
+
+// getSettingsPath returns the path to the settings file
+func (a *App) getSettingsPath() string {
+	return filepath.Join(getConfigDir(), "settings.json")
 }
 
 // loadSettings loads settings from file
@@ -252,15 +630,29 @@ func (a *App) loadSettings() {
 		return
 	}
 	
-	var settings Settings
-	if err := json.Unmarshal(data, &settings); err != nil {
-		fmt.Printf("Error parsing settings: %v\n", err)
+	// Start from defaults so fields missing in older settings files keep sane values
+	settings := getDefaultSettings()
+	if err := json.Unmarshal(data, settings); err != nil {
+		logError("Error parsing settings: %v", err)
 		a.settings = getDefaultSettings()
 		return
 	}
 	
-	a.settings = &settings
-	fmt.Println("Settings loaded successfully")
+	// Never start above the volume cap
+	if settings.MaxVolume > 0 && settings.Volume > settings.MaxVolume {
+		settings.Volume = settings.MaxVolume
+	}
+	
+	if settings.MediaJobCPUFraction > 0 && settings.MediaJobCPUFraction <= 1 {
+		mediaJobs.setCPUFraction(settings.MediaJobCPUFraction)
+	}
+	a.resizeMemoryCaches(settings)
+	if err := setLogLevel(settings.LogLevel); err != nil {
+		logWarn("%v", err)
+	}
+	
+	a.settings = settings
+	logDebug("Settings loaded successfully")
 }
 
 // saveSettings saves current settings to file
@@ -277,7 +669,7 @@ func (a *App) saveSettings() error {
 		return fmt.Errorf("error writing settings file: %v", err)
 	}
 	
-	fmt.Println("Settings saved successfully")
+	logDebug("Settings saved successfully")
 	return nil
 }
 
@@ -288,6 +680,10 @@ func (a *App) GetSettings() (*Settings, error) {
 
 // UpdateSettings updates and saves settings
 func (a *App) UpdateSettings(newSettings Settings) error {
+	if newSettings.StaticFolder != "" {
+		newSettings.StaticFolder = normalizePath(newSettings.StaticFolder)
+	}
+	
 	// Validate settings
 	if newSettings.Volume < 0 || newSettings.Volume > 1 {
 		return fmt.Errorf("volume must be between 0 and 1")
@@ -301,20 +697,179 @@ func (a *App) UpdateSettings(newSettings Settings) error {
 		return fmt.Errorf("invalid repeat mode: %s", newSettings.Repeat)
 	}
 	
-	// Update settings
+	// Empty templates fall back to the defaults
+	if newSettings.PresenceDetailsTemplate == "" {
+		newSettings.PresenceDetailsTemplate = defaultPresenceDetailsTemplate
+	}
+	if newSettings.PresenceStateTemplate == "" {
+		newSettings.PresenceStateTemplate = defaultPresenceStateTemplate
+	}
+	if newSettings.PresenceLargeTextTemplate == "" {
+		newSettings.PresenceLargeTextTemplate = defaultPresenceLargeTextTemplate
+	}
+	
+	if newSettings.PresenceSinkSchedule != "" {
+		if _, _, ok := parseTimeWindow(newSettings.PresenceSinkSchedule); !ok {
+			return fmt.Errorf("invalid presence schedule: %s (expected HH:MM-HH:MM)", newSettings.PresenceSinkSchedule)
+		}
+	}
+	
+	if newSettings.AutoShareTarget != "" && newSettings.AutoShareTarget != shareTargetMastodon && newSettings.AutoShareTarget != shareTargetBluesky {
+		return fmt.Errorf("invalid auto-share target: %s", newSettings.AutoShareTarget)
+	}
+	if newSettings.AutoShareEveryTracks < 0 {
+		return fmt.Errorf("auto-share interval must not be negative")
+	}
+	if newSettings.MastodonInstance != "" {
+		if _, err := mastodonInstanceURL(newSettings.MastodonInstance); err != nil {
+			return err
+		}
+	}
+	
+	if newSettings.MediaSessionPolicy == "" {
+		newSettings.MediaSessionPolicy = sessionPolicyPriority
+	}
+	if newSettings.MediaSessionPolicy != sessionPolicyPriority && newSettings.MediaSessionPolicy != sessionPolicyYield {
+		return fmt.Errorf("invalid media session policy: %s", newSettings.MediaSessionPolicy)
+	}
+	
+	if newSettings.MaxVolume < 0 || newSettings.MaxVolume > 1 {
+		return fmt.Errorf("maximum volume must be between 0 and 1")
+	}
+	if newSettings.MaxVolume == 0 {
+		newSettings.MaxVolume = 1.0
+	}
+	
+	if newSettings.DuckingAmount < 0 || newSettings.DuckingAmount > 1 {
+		return fmt.Errorf("ducking amount must be between 0 and 1")
+	}
+	if newSettings.DuckingAmount == 0 {
+		newSettings.DuckingAmount = defaultDuckingAmount
+	}
+	
+	if newSettings.WebRemotePort < 0 || newSettings.WebRemotePort > 65535 {
+		return fmt.Errorf("invalid web remote port: %d", newSettings.WebRemotePort)
+	}
+	if newSettings.WebRemotePort == 0 {
+		newSettings.WebRemotePort = defaultRemotePort
+	}
+	if newSettings.AutoMixTransitionSec == 0 {
+		newSettings.AutoMixTransitionSec = defaultTransitionSec
+	}
+	if newSettings.AutoMixTransitionSec < minTransitionSec || newSettings.AutoMixTransitionSec > maxTransitionSec {
+		return fmt.Errorf("transition length must be between %.0f and %.0f seconds", minTransitionSec, maxTransitionSec)
+	}
+	
+	if newSettings.StartupBehavior == "" {
+		newSettings.StartupBehavior = startupNothing
+	}
+	if !isValidStartupBehavior(newSettings.StartupBehavior) {
+		return fmt.Errorf("invalid startup behavior: %s", newSettings.StartupBehavior)
+	}
+	
+	if newSettings.MediaJobCPUFraction == 0 {
+		newSettings.MediaJobCPUFraction = defaultMediaJobCPUFraction
+	}
+	if newSettings.MediaJobCPUFraction < 0.1 || newSettings.MediaJobCPUFraction > 1 {
+		return fmt.Errorf("FFmpeg CPU share must be between 0.1 and 1")
+	}
+	if newSettings.MetadataCacheSize == 0 {
+		newSettings.MetadataCacheSize = defaultMetadataCacheSize
+	}
+	if newSettings.ThumbnailCacheSize == 0 {
+		newSettings.ThumbnailCacheSize = defaultThumbnailCacheSize
+	}
+	if newSettings.MetadataCacheSize < 0 || newSettings.ThumbnailCacheSize < 0 {
+		return fmt.Errorf("cache sizes can't be negative")
+	}
+	if newSettings.ScanParallelism < 0 {
+		return fmt.Errorf("scan parallelism can't be negative")
+	}
+	if newSettings.ScanFilesPerSecond < 0 {
+		return fmt.Errorf("scan rate limit can't be negative")
+	}
+	if newSettings.AudioCacheMaxMB == 0 {
+		newSettings.AudioCacheMaxMB = defaultAudioCacheMaxMB
+	}
+	if newSettings.AudioCacheMaxMB < minAudioCacheMaxMB {
+		return fmt.Errorf("audio cache limit must be at least %d MB", minAudioCacheMaxMB)
+	}
+	if newSettings.DiscordTimeDisplay == "" {
+		newSettings.DiscordTimeDisplay = presenceTimeRemaining
+	}
+	if newSettings.DiscordTimeDisplay != presenceTimeRemaining && newSettings.DiscordTimeDisplay != presenceTimeElapsed {
+		return fmt.Errorf("invalid Discord time display: %s", newSettings.DiscordTimeDisplay)
+	}
+	if newSettings.FetchedCoverLocation == "" {
+		newSettings.FetchedCoverLocation = fetchedCoversConfig
+	}
+	if newSettings.FetchedCoverLocation != fetchedCoversConfig && newSettings.FetchedCoverLocation != fetchedCoversFolder {
+		return fmt.Errorf("invalid fetched cover location: %s", newSettings.FetchedCoverLocation)
+	}
+	if err := validateTranscodeSettings(&newSettings); err != nil {
+		return err
+	}
+	if newSettings.LogLevel == "" {
+		newSettings.LogLevel = logLevelInfo
+	}
+	if _, err := parseLogLevel(newSettings.LogLevel); err != nil {
+		return err
+	}
+	
+	// The host token is never changed from the settings screen, extra tokens have their own API
+	if newSettings.WebRemoteToken == "" {
+		newSettings.WebRemoteToken = a.settings.WebRemoteToken
+	}
+	newSettings.WebRemoteTokens = a.settings.WebRemoteTokens
+	
+	// Update settings. Volume changes go through SetVolume so the cap and limiter apply.
 	oldDiscordRPC := a.settings.DiscordRPC
+	oldWebRemote := a.settings.WebRemote
+	oldWebRemotePort := a.settings.WebRemotePort
+	oldGameMode := a.settings.GameMode
+	oldAudioCacheMaxMB := a.settings.AudioCacheMaxMB
+	requestedVolume := newSettings.Volume
+	a.volumeMutex.Lock()
+	newSettings.Volume = a.settings.Volume
 	a.settings = &newSettings
+	a.volumeMutex.Unlock()
+	
+	if _, err := a.SetVolume(requestedVolume); err != nil {
+		return err
+	}
+	mediaJobs.setCPUFraction(newSettings.MediaJobCPUFraction)
+	a.resizeMemoryCaches(&newSettings)
+	setLogLevel(newSettings.LogLevel)
+	if oldGameMode != newSettings.GameMode {
+		go a.checkGameMode()
+	}
+	if newSettings.AudioCacheMaxMB < oldAudioCacheMaxMB {
+		go a.trimAudioCache()
+	}
 	
 	// Handle Discord RPC changes
 	if oldDiscordRPC != newSettings.DiscordRPC {
 		if newSettings.DiscordRPC && !a.discordActive {
 			go a.initDiscordRPC()
-		} else if !newSettings.DiscordRPC && a.discordActive {
-			client.Logout()
+		} else if !newSettings.DiscordRPC {
+			a.stopDiscordReconnect()
+			a.discord.close()
 			a.discordActive = false
 		}
 	}
 	
+	// Handle web remote changes, restarting it when the port moves
+	if oldWebRemote && (!newSettings.WebRemote || oldWebRemotePort != newSettings.WebRemotePort) {
+		if err := a.StopWebRemote(); err != nil {
+			logError("%v", err)
+		}
+	}
+	if newSettings.WebRemote {
+		if _, err := a.StartWebRemote(); err != nil {
+			return err
+		}
+	}
+	
 	// Save settings
 	return a.saveSettings()
 }
@@ -334,6 +889,9 @@ func (a *App) GetAppInfo() map[string]string {
 // ResetSettings resets settings to defaults
 func (a *App) ResetSettings() error {
 	a.settings = getDefaultSettings()
+	mediaJobs.setCPUFraction(a.settings.MediaJobCPUFraction)
+	a.resizeMemoryCaches(a.settings)
+	setLogLevel(a.settings.LogLevel)
 	return a.saveSettings()
 }
 
@@ -341,7 +899,7 @@ func (a *App) ResetSettings() error {
 func (a *App) initMPRIS() {
 	conn, err := dbus.SessionBus()
 	if err != nil {
-		fmt.Printf("Failed to connect to D-Bus session bus: %v\n", err)
+		logError("Failed to connect to D-Bus session bus: %v", err)
 		return
 	}
 	a.dbusConn = conn
@@ -349,11 +907,11 @@ func (a *App) initMPRIS() {
 	// Request the bus name
 	reply, err := conn.RequestName(busName, dbus.NameFlagDoNotQueue)
 	if err != nil {
-		fmt.Printf("Failed to request D-Bus name: %v\n", err)
+		logError("Failed to request D-Bus name: %v", err)
 		return
 	}
 	if reply != dbus.RequestNameReplyPrimaryOwner {
-		fmt.Printf("Name %s already taken\n", busName)
+		logWarn("Name %s already taken", busName)
 		return
 	}
 
@@ -364,14 +922,14 @@ func (a *App) initMPRIS() {
 	// Export the MediaPlayer2 interface
 	err = conn.Export(mediaPlayer2, mprisPath, mprisInterface)
 	if err != nil {
-		fmt.Printf("Failed to export MediaPlayer2 interface: %v\n", err)
+		logError("Failed to export MediaPlayer2 interface: %v", err)
 		return
 	}
 
 	// Export the Player interface
 	err = conn.Export(player, mprisPath, playerInterface)
 	if err != nil {
-		fmt.Printf("Failed to export Player interface: %v\n", err)
+		logError("Failed to export Player interface: %v", err)
 		return
 	}
 
@@ -389,7 +947,7 @@ func (a *App) initMPRIS() {
 			"PlaybackStatus": {Value: "Stopped", Writable: false, Emit: prop.EmitTrue, Callback: nil},
 			"Rate":           {Value: 1.0, Writable: true, Emit: prop.EmitTrue, Callback: nil},
 			"Metadata":       {Value: map[string]dbus.Variant{}, Writable: false, Emit: prop.EmitTrue, Callback: nil},
-			"Volume":         {Value: 1.0, Writable: true, Emit: prop.EmitTrue, Callback: nil},
+			"Volume":         {Value: a.settings.Volume, Writable: true, Emit: prop.EmitTrue, Callback: a.onMPRISVolumeChange},
 			"Position":       {Value: int64(0), Writable: false, Emit: prop.EmitFalse, Callback: nil},
 			"MinimumRate":    {Value: 1.0, Writable: false, Emit: prop.EmitTrue, Callback: nil},
 			"MaximumRate":    {Value: 1.0, Writable: false, Emit: prop.EmitTrue, Callback: nil},
@@ -400,11 +958,15 @@ func (a *App) initMPRIS() {
 			"CanSeek":        {Value: true, Writable: false, Emit: prop.EmitTrue, Callback: nil},
 			"CanControl":     {Value: true, Writable: false, Emit: prop.EmitTrue, Callback: nil},
 		},
+		staticInterface: {
+			"StopAfterCurrent":        {Value: a.playbackModes.StopAfterCurrent, Writable: true, Emit: prop.EmitTrue, Callback: a.onMPRISPlaybackModeChange},
+			"ClearQueueAfterPlayback": {Value: a.playbackModes.ClearQueueAfterPlayback, Writable: true, Emit: prop.EmitTrue, Callback: a.onMPRISPlaybackModeChange},
+		},
 	}
 
 	props, err := prop.Export(conn, mprisPath, propsSpec)
 	if err != nil {
-		fmt.Printf("Failed to export properties: %v\n", err)
+		logError("Failed to export properties: %v", err)
 		return
 	}
 	a.mprisProps = props
@@ -423,15 +985,19 @@ func (a *App) initMPRIS() {
 				Name:    playerInterface,
 				Methods: introspect.Methods(player),
 			},
+			{
+				Name:       staticInterface,
+				Properties: props.Introspection(staticInterface),
+			},
 		},
 	}
 	err = conn.Export(introspect.NewIntrospectable(n), mprisPath, "org.freedesktop.DBus.Introspectable")
 	if err != nil {
-		fmt.Printf("Failed to export introspection: %v\n", err)
+		logError("Failed to export introspection: %v", err)
 		return
 	}
 
-	fmt.Println("MPRIS interface initialized successfully")
+	logInfo("MPRIS interface initialized successfully")
 }
 
 // updateMPRISMetadata updates MPRIS metadata
@@ -454,33 +1020,24 @@ func (a *App) updateMPRISMetadata(song *Song, isPlaying bool) error {
 	// Update metadata
 	if song != nil {
 		metadata := map[string]dbus.Variant{
-			"mpris:trackid":  dbus.MakeVariant(dbus.ObjectPath("/track/" + strings.ReplaceAll(song.FilePath, "/", "_"))),
+			"mpris:trackid":  dbus.MakeVariant(mprisTrackID(song)),
 			"xesam:title":    dbus.MakeVariant(song.Title),
 			"xesam:artist":   dbus.MakeVariant([]string{song.Artist}),
 			"xesam:album":    dbus.MakeVariant(song.Album),
-			"mpris:length":   dbus.MakeVariant(int64(song.DurationSec) * 1000000), // microseconds
+			"mpris:length":   dbus.MakeVariant(int64(a.effectiveDuration(song) / time.Microsecond)), // microseconds, scaled by tempo effects
 		}
 
-		// Add artwork if available
-		if song.CoverData != "" {
-			// Use the saved cover art file
-			hash := fmt.Sprintf("%x", song.FilePath)
-			tempDir := filepath.Join(os.TempDir(), "static-covers")
-			coverPath := filepath.Join(tempDir, hash+".jpg")
-			
-			// Check if PNG version exists
-			if _, err := os.Stat(coverPath); os.IsNotExist(err) {
-				coverPath = filepath.Join(tempDir, hash+".png")
-			}
-			
-			if _, err := os.Stat(coverPath); err == nil {
-				metadata["mpris:artUrl"] = dbus.MakeVariant("file://" + coverPath)
+		// Add artwork if available, also shared with file managers through the XDG thumbnail cache
+		if coverData := a.songCoverData(*song); coverData != "" {
+			if coverPath, _, err := a.artworkFile(coverData); err == nil {
+				metadata["mpris:artUrl"] = dbus.MakeVariant(fileURI(coverPath))
+				go a.exportCoverThumbnail(song.FilePath, coverPath)
 			}
 		}
 
 		a.mprisProps.Set(playerInterface, "Metadata", dbus.MakeVariant(metadata))
 		
-		fmt.Printf("MPRIS: Updated metadata - %s by %s (%s)\n", song.Title, song.Artist, status)
+		logDebug("MPRIS: Updated metadata - %s by %s (%s)", song.Title, song.Artist, status)
 	} else {
 		// Clear metadata
 		a.mprisProps.Set(playerInterface, "Metadata", dbus.MakeVariant(map[string]dbus.Variant{}))
@@ -492,52 +1049,68 @@ func (a *App) updateMPRISMetadata(song *Song, isPlaying bool) error {
 // startCoverServer starts a local HTTP server to serve cover art for Discord RPC
 func (a *App) startCoverServer() {
 	// Find an available port
-	listener, err := net.Listen("tcp", ":0")
+	// Loopback only, the artwork isn't shared with the network
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
-		fmt.Printf("Failed to find available port for cover server: %v\n", err)
+		logError("Failed to find available port for cover server: %v", err)
 		return
 	}
 	
 	a.coverServerPort = listener.Addr().(*net.TCPAddr).Port
-	listener.Close()
+	a.coverServerToken = randomToken(16)
 	
 	// Create HTTP server
 	mux := http.NewServeMux()
 	mux.HandleFunc("/cover", a.serveCoverArt)
 	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Cover server is running!"))
 	})
 	
 	a.coverServer = &http.Server{
-		Addr:    ":" + strconv.Itoa(a.coverServerPort),
-		Handler: mux,
+		Handler: a.requireCoverToken(mux),
 	}
 	
-	fmt.Printf("Starting cover art server on port %d\n", a.coverServerPort)
+	logInfo("Starting cover art server on 127.0.0.1:%d", a.coverServerPort)
 	
 	// Start server
-	err = a.coverServer.ListenAndServe()
+	err = a.coverServer.Serve(listener)
 	if err != nil && err != http.ErrServerClosed {
-		fmt.Printf("Cover server error: %v\n", err)
+		logError("Cover server error: %v", err)
 	}
 }
 
+// requireCoverToken rejects cover server requests without this session's token, so other
+// local processes and web pages can't read from it
+func (a *App) requireCoverToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.coverServerToken)) != 1 {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// coverServerURL returns the URL of a cover server endpoint, with the token
+func (a *App) coverServerURL(path string) string {
+	return fmt.Sprintf("http://127.0.0.1:%d%s?token=%s", a.coverServerPort, path, a.coverServerToken)
+}
+
 // serveCoverArt serves the current song's cover art
 func (a *App) serveCoverArt(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("Cover server: Request received from %s\n", r.RemoteAddr)
+	logDebug("Cover server: Request received from %s", r.RemoteAddr)
 	
 	a.coverMutex.RLock()
 	song := a.currentSong
 	a.coverMutex.RUnlock()
 	
 	if song == nil {
-		fmt.Println("Cover server: No current song")
+		logDebug("Cover server: No current song")
 		// Serve a default music icon
 		w.Header().Set("Content-Type", "image/svg+xml")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Cache-Control", "no-cache")
 		w.WriteHeader(http.StatusOK)
 		defaultIcon := `<svg xmlns="http://www.w3.org/2000/svg" width="512" height="512" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M9 18V5l12-2v13"/><circle cx="6" cy="18" r="3"/><circle cx="18" cy="16" r="3"/></svg>`
@@ -546,10 +1119,9 @@ func (a *App) serveCoverArt(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	if song.CoverData == "" {
-		fmt.Printf("Cover server: Song '%s' has no cover data\n", song.Title)
+		logDebug("Cover server: Song '%s' has no cover data", song.Title)
 		// Serve a default music icon
 		w.Header().Set("Content-Type", "image/svg+xml")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Cache-Control", "no-cache")
 		w.WriteHeader(http.StatusOK)
 		defaultIcon := `<svg xmlns="http://www.w3.org/2000/svg" width="512" height="512" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M9 18V5l12-2v13"/><circle cx="6" cy="18" r="3"/><circle cx="18" cy="16" r="3"/></svg>`
@@ -557,43 +1129,21 @@ func (a *App) serveCoverArt(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	fmt.Printf("Cover server: Serving cover for '%s'\n", song.Title)
-	
-	// Parse the data URL
-	parts := strings.Split(song.CoverData, ",")
-	if len(parts) != 2 {
-		fmt.Printf("Cover server: Invalid cover data format for '%s'\n", song.Title)
-		http.Error(w, "Invalid cover data", http.StatusInternalServerError)
-		return
-	}
+	logDebug("Cover server: Serving cover for '%s'", song.Title)
 	
-	// Decode base64 image data
-	imageData, err := base64.StdEncoding.DecodeString(parts[1])
+	// Served from the artwork store, oversized covers are shrunk there
+	coverPath, mimeType, err := a.artworkFile(song.CoverData)
 	if err != nil {
-		fmt.Printf("Cover server: Failed to decode image for '%s': %v\n", song.Title, err)
-		http.Error(w, "Failed to decode image", http.StatusInternalServerError)
+		logDebug("Cover server: Invalid cover data for '%s': %v", song.Title, err)
+		http.Error(w, "Invalid cover data", http.StatusInternalServerError)
 		return
 	}
-	
-	// Set content type based on data URL
-	if strings.Contains(parts[0], "image/jpeg") {
-		w.Header().Set("Content-Type", "image/jpeg")
-	} else if strings.Contains(parts[0], "image/png") {
-		w.Header().Set("Content-Type", "image/png")
-	} else if strings.Contains(parts[0], "image/webp") {
-		w.Header().Set("Content-Type", "image/webp")
-	} else {
-		w.Header().Set("Content-Type", "image/jpeg") // default
-	}
-	
-	// Enable CORS for Discord
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", mimeType)
 	w.Header().Set("Cache-Control", "no-cache")
 	
-	w.WriteHeader(http.StatusOK)
-	w.Write(imageData)
+	http.ServeFile(w, r, coverPath)
 	
-	fmt.Printf("Cover server: Successfully served %d bytes for '%s'\n", len(imageData), song.Title)
+	logDebug("Cover server: Served %s for '%s'", filepath.Base(coverPath), song.Title)
 }
 
 // Custom Discord RPC activity with type support
@@ -601,10 +1151,16 @@ type CustomActivity struct {
 	Type       int                    `json:"type"`
 	Details    string                 `json:"details,omitempty"`
 	State      string                 `json:"state,omitempty"`
-	Timestamps *client.Timestamps     `json:"timestamps,omitempty"`
+	Timestamps *CustomTimestamps      `json:"timestamps,omitempty"`
 	Assets     *CustomAssets          `json:"assets,omitempty"`
 }
 
+// CustomTimestamps are Unix milliseconds, Discord draws the progress bar from them
+type CustomTimestamps struct {
+	Start int64 `json:"start,omitempty"`
+	End   int64 `json:"end,omitempty"`
+}
+
 type CustomAssets struct {
 	LargeImage string `json:"large_image,omitempty"`
 	LargeText  string `json:"large_text,omitempty"`
@@ -619,73 +1175,42 @@ type CustomRPCPayload struct {
 }
 
 type CustomRPCArgs struct {
-	PID      int            `json:"pid"`
-	Activity CustomActivity `json:"activity"`
+	PID      int             `json:"pid"`
+	Activity *CustomActivity `json:"activity"` // nil clears the presence
 }
 
 // setCustomActivity sends a custom activity with type support via raw IPC
 func (a *App) setCustomActivity(activity CustomActivity) error {
-	// Create the payload
-	payload := map[string]interface{}{
-		"cmd":   "SET_ACTIVITY",
-		"nonce": fmt.Sprintf("%d", time.Now().UnixNano()),
-		"args": map[string]interface{}{
-			"pid":      os.Getpid(),
-			"activity": activity,
-		},
-	}
-	
-	// Convert to JSON for logging
-	data, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %v", err)
-	}
-	
-	fmt.Printf("Custom Discord RPC: Sending activity type %d: %s (payload: %d bytes)\n", activity.Type, activity.Details, len(data))
-	
-	// For now, we'll use the regular rich-go client but log what we would send
-	// In a full implementation, we'd send this via raw IPC
-	
-	// Fallback to regular rich-go for now
-	regularActivity := client.Activity{
-		Details:    activity.Details,
-		State:      activity.State,
-		Timestamps: activity.Timestamps,
-	}
-	
-	if activity.Assets != nil {
-		regularActivity.LargeImage = activity.Assets.LargeImage
-		regularActivity.LargeText = activity.Assets.LargeText
-		regularActivity.SmallImage = activity.Assets.SmallImage
-		regularActivity.SmallText = activity.Assets.SmallText
-	}
-	
-	return client.SetActivity(regularActivity)
+	logDebug("Custom Discord RPC: Sending activity type %d: %s", activity.Type, activity.Details)
+	return a.discord.setActivity(&activity)
 }
+
 // uploadCoverAndUpdate uploads cover to Imgur and updates Discord RPC
 func (a *App) uploadCoverAndUpdate(song *Song) {
 	if song.CoverData == "" {
 		return
 	}
 	
-	// Parse the data URL to get image data
-	parts := strings.Split(song.CoverData, ",")
-	if len(parts) != 2 {
-		fmt.Println("Invalid cover data format")
+	// Uploads compete with a game for bandwidth, the song playing once it ends gets one
+	if a.deferForGame(deferredCoverUpload, func() {
+		if song := a.currentSong; song != nil {
+			a.uploadCoverAndUpdate(song)
+		}
+	}) {
 		return
 	}
 	
-	// Decode base64 image data
-	imageData, err := base64.StdEncoding.DecodeString(parts[1])
+	// Oversized covers are shrunk, Discord shows them small anyway
+	imageData, _, err := a.sharedCover(song.CoverData)
 	if err != nil {
-		fmt.Printf("Failed to decode cover data: %v\n", err)
+		logWarn("Not uploading cover of '%s': %v", song.Title, err)
 		return
 	}
 	
 	// Upload to Imgur
 	url, err := a.uploadCoverToImgur(imageData)
 	if err != nil {
-		fmt.Printf("Failed to upload cover to Imgur: %v\n", err)
+		logError("Failed to upload cover to Imgur: %v", err)
 		return
 	}
 	
@@ -694,7 +1219,7 @@ func (a *App) uploadCoverAndUpdate(song *Song) {
 	a.currentCoverURL = url
 	a.coverMutex.Unlock()
 	
-	fmt.Printf("Cover uploaded to Imgur: %s\n", url)
+	logInfo("Cover uploaded to Imgur: %s", url)
 	
 	// Update Discord RPC with new cover
 	if a.discordActive && a.currentSong != nil {
@@ -702,44 +1227,6 @@ func (a *App) uploadCoverAndUpdate(song *Song) {
 	}
 }
 
-// Custom Discord IPC connection
-type CustomDiscordRPC struct {
-	conn   net.Conn
-	appID  string
-	active bool
-	mutex  sync.Mutex
-}
-
-// sendCustomActivity sends activity with type field via raw Discord IPC
-func (a *App) sendCustomActivity(activity CustomActivity) error {
-	if !a.discordActive {
-		return fmt.Errorf("Discord RPC not active")
-	}
-
-	// Create the payload
-	payload := CustomRPCPayload{
-		Cmd: "SET_ACTIVITY",
-		Args: CustomRPCArgs{
-			PID:      os.Getpid(),
-			Activity: activity,
-		},
-		Nonce: fmt.Sprintf("%d", time.Now().UnixNano()),
-	}
-
-	// Marshal to JSON
-	data, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %v", err)
-	}
-
-	fmt.Printf("Custom Discord RPC: Sending activity with type %d\n", activity.Type)
-	fmt.Printf("Custom Discord RPC: Payload: %s\n", string(data))
-
-	// For now, we'll use the regular rich-go client but log what we would send
-	// In a full implementation, we'd send this via raw IPC
-	
-	return nil
-}
 func (a *App) uploadCoverToImgur(imageData []byte) (string, error) {
 	// Create hash for caching
 	hasher := md5.New()
@@ -747,13 +1234,10 @@ func (a *App) uploadCoverToImgur(imageData []byte) (string, error) {
 	hash := hex.EncodeToString(hasher.Sum(nil))
 	
 	// Check cache first
-	a.cacheMutex.RLock()
-	if url, exists := a.coverCache[hash]; exists {
-		a.cacheMutex.RUnlock()
-		fmt.Printf("Using cached Imgur URL: %s\n", url)
+	if url, exists := a.cachedCoverURL(hash); exists {
+		logDebug("Using cached Imgur URL: %s", url)
 		return url, nil
 	}
-	a.cacheMutex.RUnlock()
 	
 	// Upload to Imgur (anonymous upload - no API key needed)
 	var buf bytes.Buffer
@@ -806,11 +1290,12 @@ func (a *App) uploadCoverToImgur(imageData []byte) (string, error) {
 	}
 	
 	// Cache the result
-	a.cacheMutex.Lock()
-	a.coverCache[hash] = result.Data.Link
-	a.cacheMutex.Unlock()
+	now := time.Now()
+	if err := a.coverURLs.set(hash, coverURL{URL: result.Data.Link, Uploaded: now, Checked: now, Used: now}); err != nil {
+		logWarn("Could not save cover cache: %v", err)
+	}
 	
-	fmt.Printf("Uploaded to Imgur: %s\n", result.Data.Link)
+	logInfo("Uploaded to Imgur: %s", result.Data.Link)
 	return result.Data.Link, nil
 }
 // updateCoverURL updates the current cover URL for Discord RPC
@@ -823,70 +1308,89 @@ func (a *App) updateCoverURL() {
 	
 	// If we have a song with cover data, trigger Imgur upload
 	if a.currentSong != nil && a.currentSong.CoverData != "" {
-		fmt.Printf("Triggering Imgur upload for: %s\n", a.currentSong.Title)
+		logDebug("Triggering Imgur upload for: %s", a.currentSong.Title)
 		// Upload will happen in background and update the URL
 		go a.uploadCoverAndUpdate(a.currentSong)
 	}
 }
+// initDiscordRPC connects to Discord, retrying in the background if it isn't running yet
 func (a *App) initDiscordRPC() {
-	fmt.Println("Attempting to initialize Discord RPC...")
+	if err := a.connectDiscordRPC(); err != nil {
+		a.reconnectDiscordRPC()
+	}
+}
+
+// connectDiscordRPC makes one connection attempt and restores the presence on success
+func (a *App) connectDiscordRPC() error {
+	logDebug("Attempting to initialize Discord RPC...")
 	
 	// Check if Discord is running by trying to connect
-	err := client.Login("1418623365631181003") // Discord application ID
+	err := a.discord.connect(discordAppID)
 	if err != nil {
-		fmt.Printf("Failed to initialize Discord RPC: %v\n", err)
+		logError("Failed to initialize Discord RPC: %v", err)
 		
 		// Provide more specific error messages
 		if strings.Contains(err.Error(), "connection refused") || strings.Contains(err.Error(), "no such file") {
-			fmt.Println("Discord is not running or Discord RPC is not available")
+			logWarn("Discord is not running or Discord RPC is not available")
 		} else if strings.Contains(err.Error(), "invalid") {
-			fmt.Println("Invalid Discord application ID - you may need to create a Discord application")
+			logWarn("Invalid Discord application ID - you may need to create a Discord application")
 		} else {
-			fmt.Printf("Unknown Discord RPC error: %v\n", err)
+			logError("Unknown Discord RPC error: %v", err)
 		}
 		
 		a.discordActive = false
-		return
+		return err
 	}
 	
 	a.discordActive = true
-	fmt.Println("Discord RPC connected successfully!")
+	logInfo("Discord RPC connected successfully!")
+	a.emitEvent(eventDiscordConnected)
+	
+	// Reconnecting mid-song shows the song right away
+	if a.currentSong != nil {
+		return a.UpdateDiscordPresence(a.currentSong, a.isPlaying)
+	}
 	
 	// Set initial presence
-	err = client.SetActivity(client.Activity{
-		State:      "Ready to play music",
-		Details:    "Static",
-		LargeImage: "music_icon", // This needs to be uploaded to Discord app assets
-		LargeText:  "Static",
+	err = a.setCustomActivity(CustomActivity{
+		Type:    2, // 2 = Listening
+		State:   "Ready to play music",
+		Details: "Static",
+		Assets: &CustomAssets{
+			LargeImage: "music_icon", // This needs to be uploaded to Discord app assets
+			LargeText:  "Static",
+		},
 	})
 	
 	if err != nil {
-		fmt.Printf("Failed to set initial Discord presence: %v\n", err)
+		logError("Failed to set initial Discord presence: %v", err)
 		// Don't mark as inactive just because we can't set activity
 		// The connection might still work for song updates
 	} else {
-		fmt.Println("Initial Discord presence set successfully")
+		logInfo("Initial Discord presence set successfully")
 	}
+	return nil
 }
 
 // UpdateDiscordPresence updates Discord Rich Presence with current song
 func (a *App) UpdateDiscordPresence(song *Song, isPlaying bool) error {
 	if !a.discordActive {
-		fmt.Println("Discord RPC not active - skipping presence update")
+		logDebug("Discord RPC not active - skipping presence update")
 		return fmt.Errorf("Discord RPC not active")
 	}
+	
+	// Another player owns the session, leave its presence alone
+	if a.shouldYieldSession() {
+		logDebug("Discord RPC: yielding to another media session - skipping presence update")
+		return nil
+	}
 
-	var state, details string
+	var state, details, largeText string
 	var largeImage, smallImage string
 	
 	if song != nil {
-		// Format like Spotify: "Listening to [song name]" for details, "by Artist" for state
-		if isPlaying {
-			details = song.Title  // Just the song name
-		} else {
-			details = song.Title  // Just the song name when paused too
-		}
-		state = fmt.Sprintf("by %s", song.Artist)
+		// Format from the presence templates, like Spotify by default
+		details, state, largeText = a.presenceText(song)
 		
 		// Use Imgur URL if available, fallback to static icon
 		a.coverMutex.RLock()
@@ -895,7 +1399,7 @@ func (a *App) UpdateDiscordPresence(song *Song, isPlaying bool) error {
 		
 		if coverURL != "" {
 			largeImage = coverURL
-			fmt.Printf("Using Imgur cover URL: %s\n", coverURL)
+			logDebug("Using Imgur cover URL: %s", coverURL)
 		} else {
 			largeImage = "music_icon" // Fallback to static asset
 			// Try to upload to Imgur if we have cover data
@@ -913,6 +1417,7 @@ func (a *App) UpdateDiscordPresence(song *Song, isPlaying bool) error {
 	} else {
 		details = "Static"
 		state = "Ready to play music"
+		largeText = "Static"
 		largeImage = "music_icon"
 		smallImage = ""
 	}
@@ -924,117 +1429,96 @@ func (a *App) UpdateDiscordPresence(song *Song, isPlaying bool) error {
 		State:   state,
 		Assets: &CustomAssets{
 			LargeImage: largeImage,
-			LargeText: func() string {
-				if song != nil {
-					return fmt.Sprintf("%s - %s", song.Artist, song.Title)
-				}
-				return "Static"
-			}(),
+			LargeText:  largeText,
 			SmallImage: smallImage,
-			SmallText: func() string {
-				if song != nil {
-					return map[bool]string{true: "Playing", false: "Paused"}[isPlaying]
-				}
-				return "Ready"
-			}(),
+			SmallText:  a.presenceStateText(song, isPlaying),
 		},
 	}
 
-	// Add timestamps for song progress bar (like Spotify)
-	if song != nil && isPlaying && song.DurationSec > 0 {
-		now := time.Now()
-		endTime := now.Add(time.Duration(song.DurationSec) * time.Second)
-		activity.Timestamps = &client.Timestamps{
-			Start: &now,
-			End:   &endTime,
-		}
-		fmt.Printf("Discord RPC: Set initial timestamps for new song - duration: %ds\n", song.DurationSec)
+	// Add timestamps for song progress bar (like Spotify), resuming from where it paused
+	startTime := a.playbackStarted()
+	activity.Timestamps = a.presenceProgress(song, isPlaying, startTime)
+	if activity.Timestamps != nil {
+		logDebug("Discord RPC: Set timestamps - elapsed: %.1fs, duration: %ds", time.Since(startTime).Seconds(), song.DurationSec)
 	}
 
-	fmt.Printf("Discord RPC: Setting LISTENING activity - %s (%s) with image: %s\n", details, state, largeImage)
+	logDebug("Discord RPC: Setting LISTENING activity - %s (%s) with image: %s", details, state, largeImage)
 	
 	err := a.setCustomActivity(activity)
 	if err != nil {
-		fmt.Printf("Discord RPC: Failed to set activity: %v\n", err)
+		logDebug("Discord RPC: Failed to set activity: %v", err)
 		a.discordActive = false
+		a.reconnectDiscordRPC()
 		return err
 	}
 	
 	return nil
 }
 
-// saveCoverForDiscord saves cover art for Discord RPC and returns the file path
-func (a *App) saveCoverForDiscord(song *Song) string {
-	if song.CoverData == "" {
-		return ""
-	}
-
-	// Create discord covers directory
-	discordDir := filepath.Join(os.TempDir(), "static-discord")
-	os.MkdirAll(discordDir, 0755)
-
-	// Generate filename based on song path hash
-	hasher := md5.New()
-	hasher.Write([]byte(song.FilePath))
-	hash := hex.EncodeToString(hasher.Sum(nil))
-	
-	// Extract image data from base64 data URL
-	parts := strings.Split(song.CoverData, ",")
-	if len(parts) != 2 {
-		return ""
-	}
-	
-	imageData, err := base64.StdEncoding.DecodeString(parts[1])
-	if err != nil {
-		fmt.Printf("Error decoding cover data: %v\n", err)
-		return ""
-	}
-	
-	// Determine file extension from MIME type
-	ext := ".jpg"
-	if strings.Contains(parts[0], "png") {
-		ext = ".png"
-	}
+// SetCurrentSong sets the current playing song and updates media controls
+func (a *App) SetCurrentSong(song *Song, isPlaying bool) error {
+	a.resolveSongPath(song)
+	a.fillSongCover(song)
 	
-	coverPath := filepath.Join(discordDir, hash+ext)
+	// Play/pause toggles call this too, only a different file is a new track
+	previous := a.currentSong
+	isNewTrack := song != nil && (previous == nil || previous.FilePath != song.FilePath)
 	
-	// Save cover art
-	err = os.WriteFile(coverPath, imageData, 0644)
-	if err != nil {
-		fmt.Printf("Error saving Discord cover: %v\n", err)
-		return ""
-	}
+	// Record when the song started for accurate progress tracking, resuming where it paused
+	a.setClockState(isNewTrack, isPlaying)
 	
-	fmt.Printf("Saved Discord cover: %s\n", coverPath)
-	return coverPath
-}
-
-// SetCurrentSong sets the current playing song and updates media controls
-func (a *App) SetCurrentSong(song *Song, isPlaying bool) error {
 	a.currentSong = song
 	a.isPlaying = isPlaying
 	
 	// Update cover URL for Discord RPC
 	a.updateCoverURL()
-	
-	// Record when the song started for accurate progress tracking
-	if song != nil && isPlaying {
-		a.songStartTime = time.Now()
-	}
 
 	// Update Discord RPC - try to reconnect if it failed
 	if err := a.UpdateDiscordPresence(song, isPlaying); err != nil {
-		fmt.Printf("Failed to update Discord presence: %v\n", err)
-		// Try to reconnect Discord RPC if it's enabled in settings
+		logError("Failed to update Discord presence: %v", err)
+		// A song change skips the rest of the reconnect backoff
 		if a.settings.DiscordRPC && !a.discordActive {
-			fmt.Println("Attempting to reconnect Discord RPC...")
-			go a.initDiscordRPC()
+			a.reconnectDiscordRPC()
 		}
 	}
 
 	// Update OS media controls
 	if err := a.updateOSMediaControls(song, isPlaying); err != nil {
-		fmt.Printf("Failed to update OS media controls: %v\n", err)
+		logError("Failed to update OS media controls: %v", err)
+	}
+	
+	// Hide the dock/taskbar progress and Now Playing info when nothing is loaded
+	if song == nil {
+		a.hideTaskbarProgress()
+		a.publishNowPlaying(nil, false)
+	}
+	
+	// Update Slack/Telegram presence (rate-limited)
+	if !a.shouldYieldSession() {
+		a.updatePresenceSinks(song, isPlaying)
+	}
+	
+	if isNewTrack {
+		go a.watchSongFile(song.FilePath)
+		if song.CoverHash == "" && song.CoverData == "" {
+			go a.fetchMissingCover(*song)
+		}
+		
+		a.session.mutex.Lock()
+		a.session.positionSec = 0
+		a.session.mutex.Unlock()
+		go a.saveSession()
+		
+		transition := a.takeTransition(previous, song)
+		go a.publishTransition(transition)
+		if isPlaying {
+			go a.recordPlay(song, transition.Reason)
+			go a.notifyTrackChange(*song)
+		}
+		a.maybeAutoShare(song)
+		if a.settings.AutoMix {
+			go a.prepareNextTransition(song)
+		}
 	}
 
 	return nil
@@ -1042,16 +1526,18 @@ func (a *App) SetCurrentSong(song *Song, isPlaying bool) error {
 
 // UpdateDiscordPresenceWithPosition updates Discord RPC with current playback position
 func (a *App) UpdateDiscordPresenceWithPosition(currentTimeSeconds float64) error {
-	if !a.discordActive || a.currentSong == nil {
+	if !a.discordActive || a.currentSong == nil || a.shouldYieldSession() {
+		return nil
+	}
+	if a.throttlePresenceForGame() {
 		return nil
 	}
 
 	song := a.currentSong
 	isPlaying := a.isPlaying
 	
-	// Format like Spotify
-	details := song.Title
-	state := fmt.Sprintf("by %s", song.Artist)
+	// Format from the presence templates
+	details, state, largeText := a.presenceText(song)
 	
 	// Use Imgur URL if available
 	a.coverMutex.RLock()
@@ -1078,36 +1564,24 @@ func (a *App) UpdateDiscordPresenceWithPosition(currentTimeSeconds float64) erro
 		State:   state,
 		Assets: &CustomAssets{
 			LargeImage: largeImage,
-			LargeText:  fmt.Sprintf("%s - %s", song.Artist, song.Title),
+			LargeText:  largeText,
 			SmallImage: smallImage,
-			SmallText:  map[bool]string{true: "Playing", false: "Paused"}[isPlaying],
+			SmallText:  a.presenceStateText(song, isPlaying),
 		},
 	}
 
-	// Add timestamps for accurate progress bar
-	if isPlaying && song.DurationSec > 0 {
-		now := time.Now()
-		// Calculate when the song actually started based on current position
-		songStartTime := now.Add(-time.Duration(currentTimeSeconds) * time.Second)
-		// Calculate when the song will end
-		songEndTime := songStartTime.Add(time.Duration(song.DurationSec) * time.Second)
-		
-		// Ensure timestamps are valid (start should be before end)
-		if songStartTime.Before(songEndTime) {
-			activity.Timestamps = &client.Timestamps{
-				Start: &songStartTime,
-				End:   &songEndTime,
-			}
-			fmt.Printf("Discord RPC: Updated timestamps - elapsed: %.1fs, total: %ds\n", currentTimeSeconds, song.DurationSec)
-		} else {
-			fmt.Printf("Discord RPC: Invalid timestamps, skipping - elapsed: %.1fs, total: %ds\n", currentTimeSeconds, song.DurationSec)
-		}
+	// Add timestamps for accurate progress bar, none while paused
+	songStartTime := time.Now().Add(-time.Duration(currentTimeSeconds * float64(time.Second)))
+	activity.Timestamps = a.presenceProgress(song, isPlaying, songStartTime)
+	if activity.Timestamps != nil {
+		logDebug("Discord RPC: Updated timestamps - elapsed: %.1fs, total: %ds", currentTimeSeconds, song.DurationSec)
 	}
 
 	err := a.setCustomActivity(activity)
 	if err != nil {
-		fmt.Printf("Discord RPC: Failed to update activity: %v\n", err)
+		logDebug("Discord RPC: Failed to update activity: %v", err)
 		a.discordActive = false
+		a.reconnectDiscordRPC()
 		return err
 	}
 	
@@ -1136,16 +1610,13 @@ func (a *App) updateOSMediaControls(song *Song, isPlaying bool) error {
 func (a *App) updateWindowsMediaControls(song *Song, isPlaying bool) error {
 	// For Windows, we would use Windows Runtime APIs
 	// This is a placeholder - would need Windows-specific implementation
-	fmt.Printf("Windows Media Control: %s - %s (%s)\n", song.Artist, song.Title, map[bool]string{true: "Playing", false: "Paused"}[isPlaying])
+	logDebug("Windows Media Control: %s - %s (%s)", song.Artist, song.Title, map[bool]string{true: "Playing", false: "Paused"}[isPlaying])
 	return nil
 }
 
-// updateMacOSMediaControls updates macOS media controls
+// updateMacOSMediaControls updates the macOS Now Playing Center
 func (a *App) updateMacOSMediaControls(song *Song, isPlaying bool) error {
-	// For macOS, we would use MediaPlayer framework or AppleScript
-	// This is a placeholder - would need macOS-specific implementation
-	fmt.Printf("macOS Media Control: %s - %s (%s)\n", song.Artist, song.Title, map[bool]string{true: "Playing", false: "Paused"}[isPlaying])
-	return nil
+	return a.publishNowPlaying(song, isPlaying)
 }
 
 // updateLinuxMediaControls updates Linux media controls via MPRIS
@@ -1173,8 +1644,13 @@ func (a *App) GetStaticFolderPath() string {
 	}
 }
 
-// getDurationFromMP3 extracts duration from MP3 file
+// getDurationFromMP3 extracts duration from MP3 file. The Xing/VBRI header is read when
+// present, only files without one are walked frame by frame.
 func (a *App) getDurationFromMP3(filePath string) (time.Duration, error) {
+	if duration, ok, err := mp3HeaderDuration(filePath); err == nil && ok {
+		return duration, nil
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return 0, err
@@ -1218,6 +1694,9 @@ func (a *App) extractMetadata(filePath string) (Song, error) {
 	song := Song{
 		FilePath: filePath,
 	}
+	if trackID, err := computeTrackID(filePath); err == nil {
+		song.TrackID = trackID
+	}
 
 	// Extract metadata using tag library
 	metadata, err := tag.ReadFrom(file)
@@ -1225,20 +1704,32 @@ func (a *App) extractMetadata(filePath string) (Song, error) {
 		song.Title = metadata.Title()
 		song.Artist = metadata.Artist()
 		song.Album = metadata.Album()
+		song.Language = languageFromTags(metadata)
+		song.TrackNumber, _ = metadata.Track()
+		song.DiscNumber, _ = metadata.Disc()
+		song.Year = metadata.Year()
+		song.Genre = strings.TrimSpace(metadata.Genre())
+		song.Rating = ratingFromTags(metadata)
+		song.ReplayGain = replayGainFromTags(metadata)
+		song.HasLyrics = lyricsFromTags(metadata) != nil
 
 		// Extract cover art
+		// Extract cover art into the cover store, songs sharing a cover reference one copy
 		picture := metadata.Picture()
 		if picture != nil {
-			coverData := base64.StdEncoding.EncodeToString(picture.Data)
-			song.CoverData = fmt.Sprintf("data:%s;base64,%s", picture.MIMEType, coverData)
-			
-			// For MPRIS, also save cover to temp file
-			if runtime.GOOS == "linux" {
-				a.saveCoverArtForMPRIS(filePath, picture.Data, picture.MIMEType)
-			}
+			song.CoverHash = a.addCover(picture.MIMEType, picture.Data)
 		}
 	}
 
+	// Songs without embedded art use a cover.jpg/folder.jpg next to them, then a cover
+	// downloaded for their album
+	if song.CoverHash == "" {
+		song.CoverHash = a.folderCoverHash(filePath)
+	}
+	if song.CoverHash == "" {
+		song.CoverHash = a.fetchedCoverHash(song)
+	}
+
 	// If title is empty, use filename
 	if song.Title == "" {
 		name := filepath.Base(filePath)
@@ -1256,21 +1747,16 @@ func (a *App) extractMetadata(filePath string) (Song, error) {
 	}
 
 	// Extract duration for different audio formats
-	ext := strings.ToLower(filepath.Ext(filePath))
-	if ext == ".mp3" {
-		if duration, err := a.getDurationFromMP3(filePath); err == nil {
-			song.Duration = a.formatDuration(duration)
-			song.DurationSec = int(duration.Seconds())
-		}
+	if duration, err := a.audioDuration(filePath); err == nil {
+		song.Duration = a.formatDuration(duration)
+		song.DurationSec = int(duration.Seconds())
 	} else {
-		// For other formats, try to get duration from metadata
-		if metadata != nil {
-			// Some tag libraries provide duration, but dhowden/tag doesn't
-			// For now, we'll leave it as 0:00 for non-MP3 files
-			// In a production app, you'd want to use a more comprehensive audio library
-		}
+		logWarn("%v", err)
 	}
 
+	// Encoder delay/padding for gapless albums
+	song.Gapless = a.extractGaplessInfo(filePath, metadata)
+
 	// Fallback duration if not extracted
 	if song.Duration == "" {
 		song.Duration = "0:00"
@@ -1279,79 +1765,109 @@ func (a *App) extractMetadata(filePath string) (Song, error) {
 	return song, nil
 }
 
-// saveCoverArtForMPRIS saves cover art to a temporary file for MPRIS
-func (a *App) saveCoverArtForMPRIS(audioPath string, coverData []byte, mimeType string) {
-	if len(coverData) == 0 {
-		return
-	}
-
-	// Create temp directory for covers
-	tempDir := filepath.Join(os.TempDir(), "static-covers")
-	os.MkdirAll(tempDir, 0755)
-
-	// Generate filename based on audio file path
-	hash := fmt.Sprintf("%x", audioPath)
-	ext := ".jpg"
-	if strings.Contains(mimeType, "png") {
-		ext = ".png"
-	}
-	
-	coverPath := filepath.Join(tempDir, hash+ext)
-	
-	// Save cover art
-	err := os.WriteFile(coverPath, coverData, 0644)
-	if err != nil {
-		fmt.Printf("Failed to save cover art: %v\n", err)
-	}
-}
-
 // GetPlaylists scans the static folder and returns all playlists
 func (a *App) GetPlaylists() ([]Playlist, error) {
+	return a.scanPlaylists(false)
+}
+
+// scanPlaylists reads every playlist of the static folder. With stream set, playlists
+// are also emitted as they're found and loaded, see StreamPlaylists.
+func (a *App) scanPlaylists(stream bool) ([]Playlist, error) {
 	staticPath := a.GetStaticFolderPath()
-	fmt.Printf("GetPlaylists called - looking in: %s\n", staticPath)
+	logDebug("GetPlaylists called - looking in: %s", staticPath)
+	
+	// Keep showing the playlists of an unplugged drive instead of dropping them
+	if !isLibraryRootAvailable(staticPath) {
+		if offline := a.offlinePlaylists(staticPath); len(offline) > 0 {
+			logWarn("Static folder unavailable, showing %d offline playlists", len(offline))
+			if stream {
+				for _, playlist := range listPlaylists(offline) {
+					a.emitEvent(eventPlaylistLoaded, playlist)
+				}
+			}
+			return listPlaylists(offline), nil
+		}
+	}
 	
 	// Check if static folder exists
 	if _, err := os.Stat(staticPath); os.IsNotExist(err) {
-		fmt.Printf("Static folder not found at: %s\n", staticPath)
+		logWarn("Static folder not found at: %s", staticPath)
 		return []Playlist{}, fmt.Errorf("static folder not found at: %s", staticPath)
 	}
 
-	fmt.Printf("Static folder exists at: %s\n", staticPath)
+	logDebug("Static folder exists at: %s", staticPath)
 	var playlists []Playlist
+	
+	a.beginScan()
+	defer a.endScan()
+	a.setScanPlaylists(staticPath)
+	if stream {
+		a.emitPlaylistsFound(staticPath)
+	}
 
 	// Walk through the static directory
 	err := filepath.WalkDir(staticPath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			fmt.Printf("Error walking directory %s: %v\n", path, err)
+			logError("Error walking directory %s: %v", path, err)
 			return err
 		}
+		if a.scanCancelled() {
+			return errScanCancelled
+		}
 
 		// Skip the root static directory
 		if path == staticPath {
 			return nil
 		}
 
+		// Skip hidden and system folders (.git, .stfolder, $RECYCLE.BIN, ...)
+		if d.IsDir() && a.isIgnoredScanEntry(d.Name()) {
+			logDebug("Skipping hidden/system folder: %s", path)
+			return filepath.SkipDir
+		}
+
 		// Only process directories that are direct children of static
 		if d.IsDir() && filepath.Dir(path) == staticPath {
-			fmt.Printf("Found potential playlist directory: %s\n", path)
+			logDebug("Found potential playlist directory: %s", path)
 			playlist, err := a.loadPlaylist(path)
+			if errors.Is(err, errScanCancelled) {
+				return err
+			}
 			if err != nil {
-				fmt.Printf("Error loading playlist %s: %v\n", path, err)
+				logError("Error loading playlist %s: %v", path, err)
 				return nil // Continue with other playlists
 			}
+			a.cachePlaylist(playlist)
 			playlists = append(playlists, playlist)
+			if stream {
+				a.emitEvent(eventPlaylistLoaded, listPlaylists([]Playlist{playlist})[0])
+			}
 		}
 
 		return nil
 	})
 
+	if errors.Is(err, errScanCancelled) {
+		return nil, err
+	}
 	if err != nil {
-		fmt.Printf("Error scanning playlists: %v\n", err)
+		logError("Error scanning playlists: %v", err)
 		return nil, fmt.Errorf("error scanning playlists: %v", err)
 	}
 
-	fmt.Printf("Found %d playlists total\n", len(playlists))
-	return playlists, nil
+	logDebug("Found %d playlists total", len(playlists))
+	// Pruning walks the whole library, while gaming it waits for the game to end
+	pruneIndex := func() { a.pruneLibraryIndex(playlists) }
+	if !a.deferForGame(deferredIndexPrune, pruneIndex) {
+		go pruneIndex()
+	}
+	if runtime.GOOS == "linux" {
+		pruneThumbnails := func() { a.pruneCoverThumbnails(playlists) }
+		if !a.deferForGame(deferredThumbnailPrune, pruneThumbnails) {
+			go pruneThumbnails()
+		}
+	}
+	return listPlaylists(playlists), nil
 }
 
 // loadPlaylist loads a single playlist from its folder
@@ -1408,44 +1924,39 @@ func (a *App) loadPlaylist(playlistDir string) (Playlist, error) {
 				// Encode to base64 data URL
 				encoded := base64.StdEncoding.EncodeToString(imageData)
 				coverData = fmt.Sprintf("data:%s;base64,%s", mimeType, encoded)
-				fmt.Printf("Loaded playlist cover: %s\n", coverPath)
+				logDebug("Loaded playlist cover: %s", coverPath)
 			} else {
-				fmt.Printf("Error reading cover file %s: %v\n", coverPath, err)
+				logError("Error reading cover file %s: %v", coverPath, err)
 			}
 		} else {
-			fmt.Printf("Cover file not found: %s\n", coverPath)
+			logWarn("Cover file not found: %s", coverPath)
 		}
 	}
 
 	// Auto-scan for music files in the musics folder
-	musicsDir := filepath.Join(playlistDir, "musics")
-	var allSongFiles []string
-	
-	if _, err := os.Stat(musicsDir); err == nil {
-		err := filepath.WalkDir(musicsDir, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if !d.IsDir() {
-				ext := strings.ToLower(filepath.Ext(path))
-				if ext == ".mp3" || ext == ".wav" || ext == ".ogg" || ext == ".m4a" || ext == ".flac" {
-					allSongFiles = append(allSongFiles, path)
-				}
-			}
-			return nil
-		})
-		if err != nil {
-			return Playlist{}, err
-		}
+	allSongFiles, err := a.scanMusicFiles(playlistDir, config)
+	if err != nil {
+		return Playlist{}, err
 	}
 
+	a.scanFolderStarted(playlistDir, len(allSongFiles))
+	
+	// Tags are read in parallel, only from new or changed files
+	fresh := make(map[string]indexEntry)
+	metadata := make(map[string]metadataResult, len(allSongFiles))
+	for i, result := range a.songMetadataBatch(allSongFiles, fresh) {
+		metadata[allSongFiles[i]] = result
+	}
+	
 	// Generate positions for songs that don't have them
-	needsUpdate := a.generateSongPositions(playlistDir, allSongFiles, &config)
+	needsUpdate := a.generateSongPositions(playlistDir, allSongFiles, metadata, &config)
 
 	// Create songs with positions
 	songMap := make(map[int]Song) // position -> song
+	failed := 0
+	positions := make([]int, len(allSongFiles))
 	
-	for _, songPath := range allSongFiles {
+	for i, songPath := range allSongFiles {
 		filename := filepath.Base(songPath)
 		position, exists := config.Songs[filename]
 		
@@ -1455,17 +1966,43 @@ func (a *App) loadPlaylist(playlistDir string) (Playlist, error) {
 			config.Songs[filename] = position
 			needsUpdate = true
 		}
-		
-		fmt.Printf("Processing song: %s at position %d\n", filename, position)
-		metadata, err := a.extractMetadata(songPath)
-		if err == nil {
-			metadata.Position = position
-			songMap[position] = metadata
-		} else {
-			fmt.Printf("Error extracting metadata from %s: %v\n", songPath, err)
+		positions[i] = position
+	}
+	
+	// An explicit track list orders the songs and can add songs from outside musics/
+	if len(config.Tracks) > 0 {
+		scanned := len(allSongFiles)
+		allSongFiles, positions = applyTrackList(playlistDir, config.Tracks, allSongFiles, positions)
+		for i, result := range a.songMetadataBatch(allSongFiles[scanned:], fresh) {
+			metadata[allSongFiles[scanned+i]] = result
 		}
 	}
 	
+	for i, songPath := range allSongFiles {
+		result := metadata[songPath]
+		if result.err == nil && !matchesLanguages(result.song, config.Languages) {
+			continue
+		}
+		if result.err == nil {
+			metadata := result.song
+			metadata.Position = positions[i]
+			songMap[positions[i]] = metadata
+		} else if result.err != errScanCancelled {
+			logError("Error extracting metadata from %s: %v", allSongFiles[i], result.err)
+			failed++
+		}
+	}
+	a.countScannedFiles(len(allSongFiles), len(fresh), failed)
+	
+	if err := a.storeSongs(fresh); err != nil {
+		logWarn("Could not update library index: %v", err)
+	}
+	
+	// Songs read before the cancel stay indexed, the playlist itself is incomplete
+	if a.scanCancelled() {
+		return Playlist{}, errScanCancelled
+	}
+	
 	// Convert map to sorted slice
 	var maxPosition int
 	for pos := range songMap {
@@ -1482,10 +2019,10 @@ func (a *App) loadPlaylist(playlistDir string) (Playlist, error) {
 
 	// Save updated config if positions were generated
 	if needsUpdate {
-		fmt.Printf("Saving updated playlist.toml with generated positions\n")
+		logDebug("Saving updated playlist.toml with generated positions")
 		err := a.savePlaylistConfig(playlistDir, config)
 		if err != nil {
-			fmt.Printf("Warning: Could not save playlist.toml: %v\n", err)
+			logWarn("Could not save playlist.toml: %v", err)
 		}
 	}
 
@@ -1496,6 +2033,7 @@ func (a *App) loadPlaylist(playlistDir string) (Playlist, error) {
 		Songs:       songs,
 		CoverData:   coverData,
 		Position:    config.Position, // Current playback position
+		Schedule:    config.Schedule,
 	}
 
 	// Auto-generate position if not set or invalid
@@ -1507,113 +2045,183 @@ func (a *App) loadPlaylist(playlistDir string) (Playlist, error) {
 		}
 	}
 
-	fmt.Printf("Loaded playlist '%s' with %d songs, current position: %d\n", playlist.Name, len(songs), playlist.Position)
+	if err := a.library.storePlaylist(playlist); err != nil {
+		logWarn("Could not update library index: %v", err)
+	}
+	
+	logDebug("Loaded playlist '%s' with %d songs, current position: %d", playlist.Name, len(songs), playlist.Position)
 	return playlist, nil
 }
 
-// GetSongFile returns the file path for a song (for audio streaming)
-func (a *App) GetSongFile(filePath string) (string, error) {
-	// Verify file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return "", fmt.Errorf("song file not found: %s", filePath)
+// systemFolderNames are OS-managed folders that never contain playlists
+var systemFolderNames = map[string]bool{
+	"$RECYCLE.BIN":              true,
+	"System Volume Information": true,
+	"lost+found":                true,
+}
+
+// isSupportedAudioFile reports whether a file has a playable audio extension
+func isSupportedAudioFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3", ".wav", ".ogg", ".m4a", ".flac":
+		return true
 	}
-	return filePath, nil
+	return false
 }
 
-// processAudioWithFFmpeg applies audio effects using FFmpeg
-func (a *App) processAudioWithFFmpeg(inputPath string, nightcore bool, bassBoost bool) ([]byte, error) {
-	// Create cache directory
-	cacheDir := filepath.Join(os.TempDir(), "static-cache")
-	os.MkdirAll(cacheDir, 0755)
+// isIgnoredScanEntry reports whether a file or folder name should be skipped by scans
+func (a *App) isIgnoredScanEntry(name string) bool {
+	if systemFolderNames[name] {
+		return true
+	}
+	// Dotfiles cover .git, .stfolder, .DS_Store and macOS "._" resource forks
+	return strings.HasPrefix(name, ".") && !a.settings.ScanHiddenFiles
+}
 
-	// Generate cache key based on file path and effects
-	hasher := md5.New()
-	hasher.Write([]byte(inputPath))
-	hasher.Write([]byte(fmt.Sprintf("nightcore:%t,bassboost:%t", nightcore, bassBoost)))
-	cacheKey := hex.EncodeToString(hasher.Sum(nil))
-	cachedFile := filepath.Join(cacheDir, cacheKey+".mp3")
+// matchesScanGlob matches a glob against a path relative to musics/.
+// Patterns containing a slash match the whole relative path, others match the base name.
+func matchesScanGlob(pattern string, relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	target := relPath
+	if !strings.Contains(pattern, "/") {
+		target = filepath.Base(relPath)
+	}
+	matched, err := filepath.Match(pattern, target)
+	return err == nil && matched
+}
 
-	// Check if cached version exists
-	if _, err := os.Stat(cachedFile); err == nil {
-		fmt.Printf("Using cached processed audio: %s\n", cachedFile)
-		return os.ReadFile(cachedFile)
+// matchesAnyScanGlob reports whether relPath matches any of the patterns
+func matchesAnyScanGlob(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if matchesScanGlob(pattern, relPath) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Build FFmpeg filter chain
-	var filters []string
-	
-	if bassBoost {
-		// Bass boost: amplify frequencies below 200Hz by 10dB
-		filters = append(filters, "bass=g=10:f=200:w=1")
+// scanMusicFiles returns the audio files in a playlist's musics folder and source folders,
+// honouring hidden file rules and the playlist's include/exclude globs
+func (a *App) scanMusicFiles(playlistDir string, config PlaylistConfig) ([]string, error) {
+	songFiles, err := a.scanSongFolder(filepath.Join(playlistDir, "musics"), config)
+	if err != nil {
+		return nil, err
 	}
-	
-	if nightcore {
-		// Nightcore: increase tempo by 1.2x and pitch by 3 semitones
-		// Use rubberband for better quality pitch shifting
-		filters = append(filters, "rubberband=tempo=1.2:pitch=1.189") // 1.189 ≈ 3 semitones
+
+	// Source folders on unplugged drives or unreachable shares are skipped, not an error
+	for _, sourceDir := range playlistSources(playlistDir, config) {
+		if _, err := os.Stat(sourceDir); err != nil {
+			logWarn("Source folder unavailable, skipping: %s", sourceDir)
+			continue
+		}
+		sourceFiles, err := a.scanSongFolder(sourceDir, config)
+		if err != nil {
+			logError("Error scanning source folder %s: %v", sourceDir, err)
+			continue
+		}
+		songFiles = append(songFiles, sourceFiles...)
+	}
+	return songFiles, nil
+}
+
+// scanSongFolder returns the audio files in a folder of a playlist, the include/exclude
+// globs are relative to it
+func (a *App) scanSongFolder(musicsDir string, config PlaylistConfig) ([]string, error) {
+	var songFiles []string
+
+	if _, err := os.Stat(musicsDir); err != nil {
+		return songFiles, nil
 	}
 
-	// If no effects, just copy the file
+	err := filepath.WalkDir(musicsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == musicsDir {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(musicsDir, path)
+		if a.isIgnoredScanEntry(d.Name()) || matchesAnyScanGlob(config.Exclude, relPath) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() || !isSupportedAudioFile(path) {
+			return nil
+		}
+		if len(config.Include) > 0 && !matchesAnyScanGlob(config.Include, relPath) {
+			return nil
+		}
+
+		songFiles = append(songFiles, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return songFiles, nil
+}
+
+// GetSongFile returns the file path for a song (for audio streaming)
+func (a *App) GetSongFile(filePath string) (string, error) {
+	return a.resolveLibraryFile(filePath)
+}
+
+// processAudioWithFFmpeg applies audio effects using FFmpeg and returns the path of the processed file
+func (a *App) processAudioWithFFmpeg(inputPath string, chain EffectChain) (string, error) {
+	cachedFile := processedCacheFile(inputPath, chain)
+	if reuseProcessedCache(cachedFile, inputPath, chain) {
+		return cachedFile, nil
+	}
+
+	// Build FFmpeg filter chain, using rubberband for better quality pitch shifting
+	filters := chain.filters(true)
+
+	// If no effects, use the original file
 	if len(filters) == 0 {
-		return os.ReadFile(inputPath)
+		return inputPath, nil
 	}
 
 	// Build FFmpeg command with better settings
 	filterChain := strings.Join(filters, ",")
-	cmd := exec.Command("ffmpeg", 
-		"-i", inputPath,
-		"-af", filterChain,
-		"-acodec", "libmp3lame",
-		"-b:a", "192k",
-		"-ar", "44100",
-		"-ac", "2", // stereo
-		"-f", "mp3",
-		"-y", // overwrite output file
-		cachedFile,
-	)
-
-	fmt.Printf("Running FFmpeg: %s\n", cmd.String())
+	args := append([]string{"-i", inputPath, "-af", filterChain}, chain.codecArgs()...)
+	cmd := exec.Command("ffmpeg", append(args, "-y", cachedFile)...) // -y overwrites the output file
+
+	logDebug("Running FFmpeg: %s", cmd.String())
 	
 	// Run FFmpeg with timeout
-	output, err := cmd.CombinedOutput()
+	var output []byte
+	var err error
+	mediaJobs.do(jobForeground, func() { output, err = cmd.CombinedOutput() })
 	if err != nil {
-		// Try fallback without rubberband for nightcore
-		if nightcore && strings.Contains(string(output), "rubberband") {
-			fmt.Println("Rubberband not available, using atempo + asetrate fallback")
-			filters = []string{}
-			if bassBoost {
-				filters = append(filters, "bass=g=10:f=200:w=1")
-			}
-			if nightcore {
-				// Fallback: use atempo for speed and asetrate for pitch
-				filters = append(filters, "atempo=1.2", "asetrate=44100*1.189")
-			}
+		// Try fallback without rubberband for nightcore and pitch shifting
+		if chain.needsRubberband() && strings.Contains(string(output), "rubberband") {
+			logWarn("Rubberband not available, using atempo + asetrate fallback")
+			filters = chain.filters(false)
 			
 			filterChain = strings.Join(filters, ",")
-			cmd = exec.Command("ffmpeg", 
-				"-i", inputPath,
-				"-af", filterChain,
-				"-acodec", "libmp3lame",
-				"-b:a", "192k",
-				"-ar", "44100",
-				"-ac", "2",
-				"-f", "mp3",
-				"-y",
-				cachedFile,
-			)
+			args = append([]string{"-i", inputPath, "-af", filterChain}, chain.codecArgs()...)
+			cmd = exec.Command("ffmpeg", append(args, "-y", cachedFile)...)
 			
-			output, err = cmd.CombinedOutput()
+			mediaJobs.do(jobForeground, func() { output, err = cmd.CombinedOutput() })
 		}
 		
 		if err != nil {
-			return nil, fmt.Errorf("FFmpeg error: %v\nOutput: %s", err, string(output))
+			return "", fmt.Errorf("FFmpeg error: %v\nOutput: %s", err, string(output))
 		}
 	}
 
-	fmt.Printf("FFmpeg processing complete: %s\n", cachedFile)
+	if err := writeProcessedManifest(cachedFile, inputPath, chain, filterChain); err != nil {
+		logWarn("Could not write cache manifest: %v", err)
+	}
+	go a.trimAudioCache()
 	
-	// Read processed file
-	return os.ReadFile(cachedFile)
+	logInfo("FFmpeg processing complete: %s", cachedFile)
+	return cachedFile, nil
 }
 
 // checkFFmpegAvailable checks if FFmpeg is installed and available
@@ -1624,44 +2232,87 @@ func (a *App) checkFFmpegAvailable() bool {
 }
 // GetSongFileURL returns a data URL for the song file with optional audio effects applied
 func (a *App) GetSongFileURL(filePath string, nightcore bool, bassBoost bool) (string, error) {
-	fmt.Printf("GetSongFileURL called: file=%s, nightcore=%t, bassBoost=%t\n", filePath, nightcore, bassBoost)
+	return a.GetSongFileURLWithEffects(filePath, EffectChain{Nightcore: nightcore, BassBoost: bassBoost})
+}
+
+// GetSongFileURLWithEffects returns a data URL for the song file with an effect chain applied.
+// The whole file is held in memory, GetSongStreamURL should be preferred.
+func (a *App) GetSongFileURLWithEffects(filePath string, chain EffectChain) (string, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	logDebug("GetSongFileURLWithEffects called: file=%s, effects=%s", filePath, chain.cacheKey())
+	
+	audioPath, mimeType, err := a.playbackSource(filePath, chain)
+	if err != nil {
+		return "", err
+	}
+	
+	data, err := os.ReadFile(audioPath)
+	if err != nil {
+		return "", fmt.Errorf("error reading file: %v", err)
+	}
+	logDebug("Audio data size: %d bytes", len(data))
+
+	// Create data URL
+	encoded := base64.StdEncoding.EncodeToString(data)
+	dataURL := fmt.Sprintf("data:%s;base64,%s", mimeType, encoded)
+
+	logDebug("Generated data URL, total length: %d", len(dataURL))
+	return dataURL, nil
+}
+
+// chainSource returns the audio an effect chain processes, the requested stem or the song
+func (a *App) chainSource(filePath string, chain EffectChain) string {
+	// Separated stems replace the original audio as the input of the chain
+	if chain.Stem != "" {
+		stemPath, err := a.stemFile(filePath, chain.Stem)
+		if err != nil {
+			logWarn("Stem %s unavailable, using original: %v", chain.Stem, err)
+			return filePath
+		}
+		touchCacheEntry(filepath.Dir(stemPath))
+		return stemPath
+	}
+	return filePath
+}
+
+// playbackSource returns the file to play for a song with an effect chain applied,
+// running FFmpeg when needed, along with its MIME type
+func (a *App) playbackSource(filePath string, chain EffectChain) (string, string, error) {
+	if err := chain.validate(); err != nil {
+		return "", "", err
+	}
 	
 	// Verify file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return "", fmt.Errorf("song file not found: %s", filePath)
+		return "", "", fmt.Errorf("song file not found: %s", filePath)
 	}
+	chain = a.playableChain(filePath, chain)
 
-	var data []byte
-	var err error
-
+	sourcePath := a.chainSource(filePath, chain)
+	audioPath := sourcePath
+	nightcore, bassBoost := chain.Nightcore, chain.BassBoost
+	
 	// Apply audio effects if requested and FFmpeg is available
-	if (nightcore || bassBoost) && a.checkFFmpegAvailable() {
-		fmt.Printf("Processing audio with effects: nightcore=%t, bassBoost=%t\n", nightcore, bassBoost)
-		data, err = a.processAudioWithFFmpeg(filePath, nightcore, bassBoost)
+	if chain.hasFilters() && a.checkFFmpegAvailable() {
+		logDebug("Processing audio with effects: %s", chain.cacheKey())
+		processedPath, err := a.processAudioWithFFmpeg(sourcePath, chain)
 		if err != nil {
-			fmt.Printf("FFmpeg processing failed, falling back to original: %v\n", err)
 			// Fallback to original file if processing fails
-			data, err = os.ReadFile(filePath)
-			if err != nil {
-				return "", fmt.Errorf("error reading file: %v", err)
-			}
-		}
-	} else {
-		// No effects or FFmpeg not available, read original file
-		if nightcore || bassBoost {
-			fmt.Println("FFmpeg not available, effects will be ignored")
-		}
-		fmt.Printf("Reading original file: %s\n", filePath)
-		data, err = os.ReadFile(filePath)
-		if err != nil {
-			return "", fmt.Errorf("error reading file: %v", err)
+			logWarn("FFmpeg processing failed, falling back to original: %v", err)
+		} else {
+			audioPath = processedPath
 		}
+	} else if nightcore || bassBoost {
+		// No effects or FFmpeg not available, play the original file
+		logWarn("FFmpeg not available, effects will be ignored")
 	}
 
-	fmt.Printf("Audio data size: %d bytes\n", len(data))
-
-	// Determine MIME type based on extension
-	ext := strings.ToLower(filepath.Ext(filePath))
+	// Determine MIME type based on extension, FFmpeg output is MP3 or transcoded Opus in Ogg
+	ext := strings.ToLower(filepath.Ext(audioPath))
 	var mimeType string
 	switch ext {
 	case ".mp3":
@@ -1677,13 +2328,7 @@ func (a *App) GetSongFileURL(filePath string, nightcore bool, bassBoost bool) (s
 	default:
 		mimeType = "audio/mpeg"
 	}
-
-	// Create data URL
-	encoded := base64.StdEncoding.EncodeToString(data)
-	dataURL := fmt.Sprintf("data:%s;base64,%s", mimeType, encoded)
-
-	fmt.Printf("Generated data URL, total length: %d\n", len(dataURL))
-	return dataURL, nil
+	return audioPath, mimeType, nil
 }
 
 // NotifyPlaybackState notifies the backend about playback state changes
@@ -1691,23 +2336,24 @@ func (a *App) NotifyPlaybackState(song Song, isPlaying bool) error {
 	return a.SetCurrentSong(&song, isPlaying)
 }
 
-// UpdatePlaybackPosition updates Discord RPC with current playback position
+// UpdatePlaybackPosition updates Discord RPC and the taskbar progress with current playback position
 func (a *App) UpdatePlaybackPosition(currentTimeSeconds float64) error {
+	seeked := a.syncPlaybackClock(currentTimeSeconds)
+	a.updateMPRISPosition(currentTimeSeconds, seeked)
+	a.publishTaskbarProgress(currentTimeSeconds)
+	a.trackSessionPosition(currentTimeSeconds)
+	a.syncNowPlayingPosition(currentTimeSeconds)
+	a.publishLyricLine(currentTimeSeconds)
 	return a.UpdateDiscordPresenceWithPosition(currentTimeSeconds)
 }
 
-// CheckFFmpegInstalled checks if FFmpeg is available on the system
-func (a *App) CheckFFmpegInstalled() bool {
-	return a.checkFFmpegAvailable()
-}
-
 // ClearAudioCache clears all cached processed audio files
 func (a *App) ClearAudioCache() error {
-	cacheDir := filepath.Join(os.TempDir(), "static-cache")
+	cacheDir := audioCacheDir()
 	
 	// Check if cache directory exists
 	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
-		fmt.Println("Cache directory doesn't exist, nothing to clear")
+		logInfo("Cache directory doesn't exist, nothing to clear")
 		return nil
 	}
 	
@@ -1727,7 +2373,7 @@ func (a *App) ClearAudioCache() error {
 	})
 	
 	if err != nil {
-		fmt.Printf("Error calculating cache size: %v\n", err)
+		logError("Error calculating cache size: %v", err)
 	}
 	
 	// Remove all files in cache directory
@@ -1742,13 +2388,13 @@ func (a *App) ClearAudioCache() error {
 		return fmt.Errorf("failed to recreate cache directory: %v", err)
 	}
 	
-	fmt.Printf("Cache cleared successfully. Freed %d bytes (%.2f MB)\n", totalSize, float64(totalSize)/(1024*1024))
+	logInfo("Cache cleared successfully. Freed %d bytes (%.2f MB)", totalSize, float64(totalSize)/(1024*1024))
 	return nil
 }
 
 // GetCacheInfo returns information about the audio cache
 func (a *App) GetCacheInfo() (map[string]interface{}, error) {
-	cacheDir := filepath.Join(os.TempDir(), "static-cache")
+	cacheDir := audioCacheDir()
 	
 	info := map[string]interface{}{
 		"path":      cacheDir,
@@ -1757,6 +2403,7 @@ func (a *App) GetCacheInfo() (map[string]interface{}, error) {
 		"totalSize": int64(0),
 		"sizeMB":    0.0,
 	}
+	a.audioCacheStats(info)
 	
 	// Check if cache directory exists
 	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
@@ -1810,12 +2457,13 @@ func (a *App) TestDiscordRPC() map[string]interface{} {
 	if !a.discordActive {
 		result["message"] = "Discord RPC is not connected. Make sure Discord is running."
 		// Try to reconnect
-		go a.initDiscordRPC()
+		a.reconnectDiscordRPC()
 		return result
 	}
 	
 	// Test by setting a simple activity
-	err := client.SetActivity(client.Activity{
+	err := a.setCustomActivity(CustomActivity{
+		Type:    2,
 		State:   "Testing connection",
 		Details: "Discord RPC Test",
 	})
@@ -1824,6 +2472,7 @@ func (a *App) TestDiscordRPC() map[string]interface{} {
 		result["connected"] = false
 		result["message"] = fmt.Sprintf("Connection test failed: %v", err)
 		a.discordActive = false
+		a.reconnectDiscordRPC()
 	} else {
 		result["message"] = "Discord RPC is working correctly"
 	}
@@ -1831,15 +2480,9 @@ func (a *App) TestDiscordRPC() map[string]interface{} {
 	return result
 }
 
-// GetDiscordRPCStatus returns the current Discord RPC status
-func (a *App) GetDiscordRPCStatus() map[string]interface{} {
-	return map[string]interface{}{
-		"enabled":       a.settings.DiscordRPC,
-		"connected":     a.discordActive,
-		"applicationId": "1418623365631181003",
-	}
-}
 func (a *App) ScanPlaylistFiles(playlistPath string) (map[string][]string, error) {
+	playlistPath = normalizePath(playlistPath)
+
 	result := map[string][]string{
 		"musics": {},
 		"covers": {},
@@ -1848,33 +2491,35 @@ func (a *App) ScanPlaylistFiles(playlistPath string) (map[string][]string, error
 	musicsDir := filepath.Join(playlistPath, "musics")
 	coversDir := filepath.Join(playlistPath, "covers")
 
-	// Scan music files
-	if _, err := os.Stat(musicsDir); err == nil {
-		err := filepath.WalkDir(musicsDir, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if !d.IsDir() {
-				ext := strings.ToLower(filepath.Ext(path))
-				if ext == ".mp3" || ext == ".wav" || ext == ".ogg" || ext == ".m4a" || ext == ".flac" {
-					relPath, _ := filepath.Rel(musicsDir, path)
-					result["musics"] = append(result["musics"], relPath)
-				}
-			}
-			return nil
-		})
-		if err != nil {
-			return nil, err
+	// Load include/exclude rules from playlist.toml
+	var config PlaylistConfig
+	playlistFile := filepath.Join(playlistPath, "playlist.toml")
+	if _, err := os.Stat(playlistFile); err == nil {
+		if _, err := toml.DecodeFile(playlistFile, &config); err != nil {
+			return nil, fmt.Errorf("error parsing playlist.toml: %v", err)
 		}
 	}
 
+	// Scan music files
+	songFiles, err := a.scanMusicFiles(playlistPath, config)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range songFiles {
+		relPath, _ := filepath.Rel(musicsDir, path)
+		result["musics"] = append(result["musics"], relPath)
+	}
+
 	// Scan cover files
 	if _, err := os.Stat(coversDir); err == nil {
 		err := filepath.WalkDir(coversDir, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
-			if !d.IsDir() {
+			if d.IsDir() && path != coversDir && a.isIgnoredScanEntry(d.Name()) {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && !a.isIgnoredScanEntry(d.Name()) {
 				ext := strings.ToLower(filepath.Ext(path))
 				if ext == ".jpg" || ext == ".jpeg" || ext == ".png" || ext == ".webp" {
 					relPath, _ := filepath.Rel(coversDir, path)
@@ -1890,8 +2535,9 @@ func (a *App) ScanPlaylistFiles(playlistPath string) (map[string][]string, error
 
 	return result, nil
 }
-// generateSongPositions automatically generates positions for songs that don't have them
-func (a *App) generateSongPositions(playlistDir string, songFiles []string, config *PlaylistConfig) bool {
+// generateSongPositions automatically generates positions for songs that don't have them.
+// New songs are ordered by folder, album, disc and track number, then file name.
+func (a *App) generateSongPositions(playlistDir string, songFiles []string, metadata map[string]metadataResult, config *PlaylistConfig) bool {
 	needsUpdate := false
 	nextPosition := 1
 	
@@ -1902,8 +2548,14 @@ func (a *App) generateSongPositions(playlistDir string, songFiles []string, conf
 		}
 	}
 	
-	// Sort song files by filename for consistent ordering
+	// Sort song files by filename for consistent ordering, then by album track order
+	songFiles = append([]string(nil), songFiles...)
 	sort.Strings(songFiles)
+	sort.SliceStable(songFiles, func(i, j int) bool {
+		first, second := metadata[songFiles[i]].song, metadata[songFiles[j]].song
+		first.FilePath, second.FilePath = songFiles[i], songFiles[j]
+		return albumOrderLess(first, second)
+	})
 	
 	// Generate positions for songs that don't have them
 	for _, songPath := range songFiles {
@@ -1911,7 +2563,7 @@ func (a *App) generateSongPositions(playlistDir string, songFiles []string, conf
 		
 		if _, exists := config.Songs[filename]; !exists {
 			config.Songs[filename] = nextPosition
-			fmt.Printf("Auto-generated position %d for song: %s\n", nextPosition, filename)
+			logDebug("Auto-generated position %d for song: %s", nextPosition, filename)
 			nextPosition++
 			needsUpdate = true
 		}
@@ -1937,7 +2589,7 @@ func (a *App) savePlaylistConfig(playlistDir string, config PlaylistConfig) erro
 		return fmt.Errorf("error writing playlist.toml: %v", err)
 	}
 	
-	fmt.Printf("Saved playlist config to %s\n", playlistFile)
+	logDebug("Saved playlist config to %s", playlistFile)
 	return nil
 }
 
@@ -1977,11 +2629,15 @@ func (a *App) savePlaylistPosition(playlistDir string, position int) error {
 
 // UpdatePlaylistPosition updates the current playback position in a playlist and saves it
 func (a *App) UpdatePlaylistPosition(playlistPath string, position int) error {
+	playlistPath = normalizePath(playlistPath)
+
 	return a.savePlaylistPosition(playlistPath, position)
 }
 
 // GetPlaylistPosition returns the current playback position for a playlist
 func (a *App) GetPlaylistPosition(playlistPath string) (int, error) {
+	playlistPath = normalizePath(playlistPath)
+
 	playlistFile := filepath.Join(playlistPath, "playlist.toml")
 	
 	var config PlaylistConfig
@@ -1996,6 +2652,8 @@ func (a *App) GetPlaylistPosition(playlistPath string) (int, error) {
 	return config.Position, nil
 }
 func (a *App) UpdateSongPosition(playlistPath string, songFilename string, newPosition int) error {
+	playlistPath = normalizePath(playlistPath)
+
 	playlistFile := filepath.Join(playlistPath, "playlist.toml")
 	
 	var config PlaylistConfig
@@ -2021,6 +2679,8 @@ func (a *App) UpdateSongPosition(playlistPath string, songFilename string, newPo
 
 // GetSongPositions returns all song positions for a playlist
 func (a *App) GetSongPositions(playlistPath string) (map[string]int, error) {
+	playlistPath = normalizePath(playlistPath)
+
 	playlistFile := filepath.Join(playlistPath, "playlist.toml")
 	
 	var config PlaylistConfig
@@ -2043,36 +2703,23 @@ func (a *App) GetSongPositions(playlistPath string) (map[string]int, error) {
 // Cleanup shuts down the cover art server gracefully
 func (a *App) Cleanup() {
 	if a.coverServer != nil {
-		fmt.Println("Shutting down cover art server...")
+		logInfo("Shutting down cover art server...")
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		
 		if err := a.coverServer.Shutdown(ctx); err != nil {
-			fmt.Printf("Error shutting down cover server: %v\n", err)
+			logError("Error shutting down cover server: %v", err)
 		} else {
-			fmt.Println("Cover art server shut down successfully")
+			logInfo("Cover art server shut down successfully")
 		}
 	}
-}
-// GetCoverServerInfo returns information about the cover server for debugging
-func (a *App) GetCoverServerInfo() map[string]interface{} {
-	a.coverMutex.RLock()
-	coverURL := a.currentCoverURL
-	a.coverMutex.RUnlock()
 	
-	a.cacheMutex.RLock()
-	cacheSize := len(a.coverCache)
-	a.cacheMutex.RUnlock()
-	
-	return map[string]interface{}{
-		"port":         a.coverServerPort,
-		"running":      a.coverServer != nil,
-		"coverURL":     coverURL,
-		"hasSong":      a.currentSong != nil,
-		"hasCover":     a.currentSong != nil && a.currentSong.CoverData != "",
-		"testURL":      fmt.Sprintf("http://localhost:%d/test", a.coverServerPort),
-		"cacheSize":    cacheSize,
-		"usingImgur":   strings.Contains(coverURL, "imgur.com") || strings.Contains(coverURL, "i.imgur.com"),
-		"activityType": "Listening (type 2)",
-	}
-}
\ No newline at end of file
+	a.stopAudioServer()
+	clearTranscodeSession()
+	a.stopDiscordReconnect()
+	a.discord.close()
+	
+	if err := a.library.close(); err != nil {
+		logError("Error closing library index: %v", err)
+	}
+}
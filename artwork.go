@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Covers handed to Discord, the cover server and system media controls are shrunk when
+// over either limit. The files and the library keep the original art.
+const (
+	coverMaxBytes    = 512 << 10
+	coverMaxEdge     = 1024
+	coverJPEGQuality = 90
+)
+
+// Artwork store limits. Files unused for artworkMaxIdle go first, then the least
+// recently used until the store fits artworkMaxMB.
+const (
+	artworkMaxMB     = 64
+	artworkMaxIdle   = 30 * 24 * time.Hour
+	artworkPathCache = 64 // Cover hashes whose store file is known, see artworkFile
+)
+
+// artworkExtensions maps the MIME types of stored artwork to file extensions
+var artworkExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// errInvalidCover is returned for cover data that isn't a readable image
+var errInvalidCover = errors.New("invalid cover image")
+
+// shrinkCover checks cover art and re-encodes it as a JPEG of at most coverMaxEdge when
+// it's too large. Formats Go can't decode (WebP) are passed through as they are.
+func shrinkCover(data []byte, mimeType string) ([]byte, string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) && !strings.HasSuffix(mimeType, "/jpeg") && !strings.HasSuffix(mimeType, "/png") {
+		return data, mimeType, nil
+	}
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		return nil, "", errInvalidCover
+	}
+	if len(data) <= coverMaxBytes && config.Width <= coverMaxEdge && config.Height <= coverMaxEdge {
+		return data, mimeType, nil
+	}
+
+	cover, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errInvalidCover
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleImage(cover, coverMaxEdge), &jpeg.Options{Quality: coverJPEGQuality}); err != nil {
+		return nil, "", fmt.Errorf("error encoding cover: %v", err)
+	}
+	logDebug("Resized %dx%d cover from %d to %d bytes", config.Width, config.Height, len(data), buf.Len())
+	return buf.Bytes(), "image/jpeg", nil
+}
+
+// artworkStore writes each distinct cover once, as a file named after the hash of the
+// original image. MPRIS, Discord, the cover server and thumbnails all read from it.
+type artworkStore struct {
+	paths *lruCache // Cover hash -> stored file
+	mutex sync.Mutex
+}
+
+// artworkDir is where the artwork store keeps its files
+func artworkDir() string {
+	return filepath.Join(os.TempDir(), "static-artwork")
+}
+
+// artworkFile returns the stored file of a cover data URL, writing it the first time.
+// Oversized covers are stored shrunk, see shrinkCover.
+func (a *App) artworkFile(dataURL string) (string, string, error) {
+	data, mimeType, err := decodeDataURL(dataURL)
+	if err != nil || len(data) == 0 {
+		return "", "", errInvalidCover
+	}
+	hash := hashCover(data)
+
+	// Same cover, same file: concurrent requests for it write it once
+	a.artwork.mutex.Lock()
+	defer a.artwork.mutex.Unlock()
+	if cached, ok := a.artwork.paths.get(hash); ok {
+		path := cached.(string)
+		if _, err := os.Stat(path); err == nil {
+			touchCacheEntry(path)
+			return path, artworkMIMEType(path), nil
+		}
+	}
+	for _, ext := range artworkExtensions {
+		path := filepath.Join(artworkDir(), hash+ext)
+		if _, err := os.Stat(path); err == nil {
+			touchCacheEntry(path)
+			a.artwork.paths.put(hash, path)
+			return path, artworkMIMEType(path), nil
+		}
+	}
+
+	stored, mimeType, err := shrinkCover(data, mimeType)
+	if err != nil {
+		return "", "", err
+	}
+	ext, ok := artworkExtensions[mimeType]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported cover type: %s", mimeType)
+	}
+	if err := os.MkdirAll(artworkDir(), 0755); err != nil {
+		return "", "", fmt.Errorf("error creating artwork folder: %v", err)
+	}
+	path := filepath.Join(artworkDir(), hash+ext)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, stored, 0644); err != nil {
+		return "", "", fmt.Errorf("error writing artwork: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", "", fmt.Errorf("error writing artwork: %v", err)
+	}
+	a.artwork.paths.put(hash, path)
+	return path, mimeType, nil
+}
+
+// artworkMIMEType returns the MIME type of a stored artwork file
+func artworkMIMEType(path string) string {
+	ext := filepath.Ext(path)
+	for mimeType, extension := range artworkExtensions {
+		if extension == ext {
+			return mimeType
+		}
+	}
+	return "image/jpeg"
+}
+
+// sharedCover returns a cover data URL as image bytes fit for sharing outside the app,
+// read from the artwork store
+func (a *App) sharedCover(dataURL string) ([]byte, string, error) {
+	path, mimeType, err := a.artworkFile(dataURL)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading artwork: %v", err)
+	}
+	return data, mimeType, nil
+}
+
+// pruneArtwork applies the artwork store's limits and removes the cover folder older
+// versions wrote for Discord
+func (a *App) pruneArtwork() {
+	os.RemoveAll(filepath.Join(os.TempDir(), "static-discord"))
+
+	a.artwork.mutex.Lock()
+	defer a.artwork.mutex.Unlock()
+
+	dirEntries, err := os.ReadDir(artworkDir())
+	if err != nil {
+		return
+	}
+	type storedFile struct {
+		path     string
+		size     int64
+		lastUsed time.Time
+	}
+	var files []storedFile
+	var total int64
+	removed := 0
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		if err != nil || dirEntry.IsDir() {
+			continue
+		}
+		path := filepath.Join(artworkDir(), dirEntry.Name())
+		// Leftovers of interrupted writes and covers not shown for long
+		if strings.HasSuffix(path, ".tmp") || time.Since(info.ModTime()) > artworkMaxIdle {
+			if os.Remove(path) == nil {
+				removed++
+			}
+			continue
+		}
+		files = append(files, storedFile{path: path, size: info.Size(), lastUsed: info.ModTime()})
+		total += info.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].lastUsed.Before(files[j].lastUsed) })
+	for _, file := range files {
+		if total <= artworkMaxMB<<20 {
+			break
+		}
+		if os.Remove(file.path) == nil {
+			total -= file.size
+			removed++
+		}
+	}
+	if removed > 0 {
+		logInfo("Artwork store: removed %d unused covers", removed)
+	}
+}
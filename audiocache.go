@@ -0,0 +1,194 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAudioCacheMaxMB = 2048
+	minAudioCacheMaxMB     = 100
+	audioCacheTrimInterval = 10 * time.Minute
+	audioCacheMinIdle      = 5 * time.Minute // Entries used this recently may be playing and are kept
+)
+
+// audioCacheJanitor evicts the least recently used processed audio once the cache
+// outgrows its limit
+type audioCacheJanitor struct {
+	trimming     bool
+	evictions    int64
+	evictedBytes int64
+	lastTrim     time.Time
+	mutex        sync.Mutex
+}
+
+// audioCacheEntry is one evictable unit: a processed file with its manifest, an
+// automix transition, or a song's stem folder
+type audioCacheEntry struct {
+	paths    []string
+	size     int64
+	lastUsed time.Time
+}
+
+// audioCacheDir is where processed audio, transitions and stems are cached
+func audioCacheDir() string {
+	return filepath.Join(os.TempDir(), "static-cache")
+}
+
+// touchCacheEntry marks a cached file or folder as just used, cache hits move it to
+// the back of the eviction order
+func touchCacheEntry(path string) {
+	now := time.Now()
+	os.Chtimes(path, now, now)
+}
+
+// listAudioCacheEntries groups the cache contents into evictable entries
+func listAudioCacheEntries(cacheDir string) ([]*audioCacheEntry, int64) {
+	dirEntries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return nil, 0
+	}
+
+	var entries []*audioCacheEntry
+	var total int64
+	byName := make(map[string]*audioCacheEntry)
+	for _, dirEntry := range dirEntries {
+		path := filepath.Join(cacheDir, dirEntry.Name())
+		if dirEntry.IsDir() {
+			// Each song's stems are evicted together, other folders as a whole
+			folders := []string{path}
+			if dirEntry.Name() == "stems" {
+				folders = nil
+				stems, _ := os.ReadDir(path)
+				for _, stem := range stems {
+					folders = append(folders, filepath.Join(path, stem.Name()))
+				}
+			}
+			for _, folder := range folders {
+				entry := folderCacheEntry(folder)
+				entries = append(entries, entry)
+				total += entry.size
+			}
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		// A processed file and its manifest go together
+		name := strings.TrimSuffix(dirEntry.Name(), ".json")
+		entry := byName[name]
+		if entry == nil {
+			entry = &audioCacheEntry{}
+			byName[name] = entry
+			entries = append(entries, entry)
+		}
+		entry.paths = append(entry.paths, path)
+		entry.size += info.Size()
+		if info.ModTime().After(entry.lastUsed) {
+			entry.lastUsed = info.ModTime()
+		}
+		total += info.Size()
+	}
+	return entries, total
+}
+
+// folderCacheEntry sums a cached folder, it was last used when it or any file in it was
+func folderCacheEntry(folder string) *audioCacheEntry {
+	entry := &audioCacheEntry{paths: []string{folder}}
+	filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() {
+			entry.size += info.Size()
+		}
+		if info.ModTime().After(entry.lastUsed) {
+			entry.lastUsed = info.ModTime()
+		}
+		return nil
+	})
+	return entry
+}
+
+// trimAudioCache evicts least recently used entries until the cache fits its limit.
+// Only one trim runs at a time, concurrent calls return right away.
+func (a *App) trimAudioCache() {
+	a.audioCache.mutex.Lock()
+	if a.audioCache.trimming {
+		a.audioCache.mutex.Unlock()
+		return
+	}
+	a.audioCache.trimming = true
+	a.audioCache.mutex.Unlock()
+
+	limit := int64(a.settings.AudioCacheMaxMB) << 20
+	if limit <= 0 {
+		limit = defaultAudioCacheMaxMB << 20
+	}
+	entries, total := listAudioCacheEntries(audioCacheDir())
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+
+	var evictions, evictedBytes int64
+	recent := time.Now().Add(-audioCacheMinIdle)
+	for _, entry := range entries {
+		if total <= limit {
+			break
+		}
+		if entry.lastUsed.After(recent) {
+			continue
+		}
+		for _, path := range entry.paths {
+			os.RemoveAll(path)
+		}
+		total -= entry.size
+		evictions++
+		evictedBytes += entry.size
+	}
+	if evictions > 0 {
+		logInfo("Audio cache: evicted %d entries (%.1f MB), %.1f MB left",
+			evictions, float64(evictedBytes)/(1024*1024), float64(total)/(1024*1024))
+	}
+
+	a.audioCache.mutex.Lock()
+	a.audioCache.trimming = false
+	a.audioCache.evictions += evictions
+	a.audioCache.evictedBytes += evictedBytes
+	a.audioCache.lastTrim = time.Now()
+	a.audioCache.mutex.Unlock()
+}
+
+// runAudioCacheJanitor trims the cache on startup and then periodically, catching
+// entries written by transitions and stem separation
+func (a *App) runAudioCacheJanitor() {
+	a.trimAudioCache()
+	ticker := time.NewTicker(audioCacheTrimInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		a.trimAudioCache()
+	}
+}
+
+// audioCacheStats adds the limit and eviction counters to GetCacheInfo
+func (a *App) audioCacheStats(info map[string]interface{}) {
+	a.audioCache.mutex.Lock()
+	defer a.audioCache.mutex.Unlock()
+	info["maxSizeMB"] = a.settings.AudioCacheMaxMB
+	info["evictions"] = a.audioCache.evictions
+	info["evictedMB"] = float64(a.audioCache.evictedBytes) / (1024 * 1024)
+	if !a.audioCache.lastTrim.IsZero() {
+		info["lastTrim"] = a.audioCache.lastTrim
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// eventTransitionReady tells the frontend a mix into the next queued song is ready
+const eventTransitionReady = "automix:transition-ready"
+
+const (
+	defaultTransitionSec = 8.0
+	minTransitionSec     = 1.0
+	maxTransitionSec     = 30.0
+	maxTempoAdjust       = 0.08 // Beyond ±8% atempo becomes audible, fall back to a plain crossfade
+)
+
+// TransitionPlan describes a prepared mix from one song into the next.
+// The frontend plays MixURL from StartAtSec in the outgoing song, then
+// continues the incoming song from ResumeAtSec.
+type TransitionPlan struct {
+	FromPath    string  `json:"fromPath"`
+	ToPath      string  `json:"toPath"`
+	FromBPM     float64 `json:"fromBPM"`
+	ToBPM       float64 `json:"toBPM"`
+	TempoRatio  float64 `json:"tempoRatio"`  // atempo applied to the incoming song during the overlap
+	BeatMatched bool    `json:"beatMatched"` // False when the tempos were too far apart to match
+	LengthSec   float64 `json:"lengthSec"`   // Overlap length, snapped to whole bars when beat matched
+	StartAtSec  float64 `json:"startAtSec"`
+	ResumeAtSec float64 `json:"resumeAtSec"`
+	MixURL      string  `json:"mixURL"`
+}
+
+// probeDuration returns a file's duration in seconds using ffprobe
+func probeDuration(filePath string) (float64, error) {
+	output, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		filePath,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %v", err)
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+}
+
+// matchTempo returns the atempo ratio that brings the incoming tempo to the outgoing one,
+// also trying half and double time. ok is false when no ratio is within maxTempoAdjust.
+func matchTempo(fromBPM float64, toBPM float64) (float64, bool) {
+	if fromBPM <= 0 || toBPM <= 0 {
+		return 1, false
+	}
+	best := 0.0
+	for _, candidate := range []float64{toBPM, toBPM * 2, toBPM / 2} {
+		ratio := fromBPM / candidate
+		if best == 0 || math.Abs(ratio-1) < math.Abs(best-1) {
+			best = ratio
+		}
+	}
+	if math.Abs(best-1) > maxTempoAdjust {
+		return 1, false
+	}
+	return best, true
+}
+
+// PrepareTransition renders a tempo-matched crossfade from one song into another.
+// lengthSec overrides the transition length setting when greater than zero.
+func (a *App) PrepareTransition(fromPath string, toPath string, lengthSec float64) (TransitionPlan, error) {
+	fromPath, err := a.resolveLibraryFile(fromPath)
+	if err != nil {
+		return TransitionPlan{}, err
+	}
+	toPath, err = a.resolveLibraryFile(toPath)
+	if err != nil {
+		return TransitionPlan{}, err
+	}
+
+	if !a.checkFFmpegAvailable() {
+		return TransitionPlan{}, fmt.Errorf("FFmpeg is required for auto-mix")
+	}
+	if lengthSec <= 0 {
+		lengthSec = a.settings.AutoMixTransitionSec
+	}
+	lengthSec = math.Max(minTransitionSec, math.Min(maxTransitionSec, lengthSec))
+
+	plan := TransitionPlan{FromPath: fromPath, ToPath: toPath, TempoRatio: 1, LengthSec: lengthSec}
+
+	if plan.FromBPM, err = a.GetTrackBPM(fromPath); err != nil {
+		logWarn("Auto-mix: no BPM for %s: %v", fromPath, err)
+	}
+	if plan.ToBPM, err = a.GetTrackBPM(toPath); err != nil {
+		logWarn("Auto-mix: no BPM for %s: %v", toPath, err)
+	}
+	plan.TempoRatio, plan.BeatMatched = matchTempo(plan.FromBPM, plan.ToBPM)
+
+	// Snap the overlap to whole bars so phrases line up
+	if plan.BeatMatched {
+		barSec := 4 * 60 / plan.FromBPM
+		bars := math.Max(1, math.Round(lengthSec/barSec))
+		plan.LengthSec = bars * barSec
+	}
+
+	fromDuration, err := probeDuration(fromPath)
+	if err != nil {
+		return TransitionPlan{}, err
+	}
+	if fromDuration <= plan.LengthSec {
+		return TransitionPlan{}, fmt.Errorf("song is shorter than the transition")
+	}
+	plan.StartAtSec = fromDuration - plan.LengthSec
+	plan.ResumeAtSec = plan.LengthSec * plan.TempoRatio // Stretched audio covers more of the original
+
+	data, err := renderTransition(plan)
+	if err != nil {
+		return TransitionPlan{}, err
+	}
+	plan.MixURL = "data:audio/mpeg;base64," + base64.StdEncoding.EncodeToString(data)
+	return plan, nil
+}
+
+// renderTransition mixes the outgoing tail with the tempo-adjusted incoming head, cached like other effects
+func renderTransition(plan TransitionPlan) ([]byte, error) {
+	cacheDir := audioCacheDir()
+	os.MkdirAll(cacheDir, 0755)
+
+	hasher := md5.New()
+	hasher.Write([]byte(fmt.Sprintf("automix:%s|%s|%.3f|%.4f", pathKey(plan.FromPath), pathKey(plan.ToPath), plan.LengthSec, plan.TempoRatio)))
+	hasher.Write([]byte(fileVersion(plan.FromPath) + "|" + fileVersion(plan.ToPath)))
+	cachedFile := filepath.Join(cacheDir, hex.EncodeToString(hasher.Sum(nil))+".mp3")
+
+	if _, err := os.Stat(cachedFile); err == nil {
+		touchCacheEntry(cachedFile)
+		return os.ReadFile(cachedFile)
+	}
+
+	length := strconv.FormatFloat(plan.LengthSec, 'f', 3, 64)
+	filter := fmt.Sprintf(
+		"[0:a]atrim=0:%[1]s,asetpts=PTS-STARTPTS,aresample=44100[out];"+
+			"[1:a]atempo=%[2]s,atrim=0:%[1]s,asetpts=PTS-STARTPTS,aresample=44100[in];"+
+			"[out][in]acrossfade=d=%[1]s:c1=tri:c2=tri",
+		length, strconv.FormatFloat(plan.TempoRatio, 'f', 4, 64))
+
+	cmd := exec.Command("ffmpeg",
+		"-ss", strconv.FormatFloat(plan.StartAtSec, 'f', 3, 64),
+		"-i", plan.FromPath,
+		"-i", plan.ToPath,
+		"-filter_complex", filter,
+		"-acodec", "libmp3lame",
+		"-b:a", "192k",
+		"-ac", "2",
+		"-f", "mp3",
+		"-y",
+		cachedFile,
+	)
+
+	logDebug("Running FFmpeg: %s", cmd.String())
+	var output []byte
+	var err error
+	mediaJobs.do(jobBackground, func() { output, err = cmd.CombinedOutput() })
+	if err != nil {
+		return nil, fmt.Errorf("FFmpeg error: %v\nOutput: %s", err, string(output))
+	}
+	return os.ReadFile(cachedFile)
+}
+
+// prepareNextTransition renders the mix from the current song into the next queued one in the background
+func (a *App) prepareNextTransition(current *Song) {
+	a.queueMutex.Lock()
+	var next *Song
+	if a.queueIndex >= 0 && a.queueIndex+1 < len(a.queue) && a.queue[a.queueIndex].FilePath == current.FilePath {
+		song := a.queue[a.queueIndex+1]
+		next = &song
+	}
+	a.queueMutex.Unlock()
+
+	if next == nil {
+		return
+	}
+
+	plan, err := a.PrepareTransition(current.FilePath, next.FilePath, 0)
+	if err != nil {
+		logError("Auto-mix: failed to prepare transition: %v", err)
+		return
+	}
+	logInfo("Auto-mix: %s -> %s ready (%.1fs, tempo x%.3f)", current.Title, next.Title, plan.LengthSec, plan.TempoRatio)
+	a.emitEvent(eventTransitionReady, plan)
+}
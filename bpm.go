@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dhowden/tag"
+)
+
+// BPM analysis parameters. Audio is decoded to mono 11025 Hz and reduced to an
+// onset envelope with one value per hop, then autocorrelated over plausible tempos.
+const (
+	bpmSampleRate     = 11025
+	bpmHopSize        = 256
+	bpmAnalysisOffset = 30 // Seconds to skip, intros are often beatless
+	bpmAnalysisLength = 60 // Seconds of audio to analyze
+	bpmMin            = 70.0
+	bpmMax            = 180.0
+)
+
+// bpmStore caches tempos per file so each song is analyzed only once
+type bpmStore struct {
+	path  string
+	bpms  map[string]float64 // file path -> BPM, 0 when analysis found no steady beat
+	mutex sync.Mutex
+}
+
+// newBPMStore creates a BPM cache backed by the given file
+func newBPMStore(path string) *bpmStore {
+	return &bpmStore{path: path, bpms: make(map[string]float64)}
+}
+
+// load reads the cached tempos from disk
+func (b *bpmStore) load() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading BPM cache: %v", err)
+	}
+	if err := json.Unmarshal(data, &b.bpms); err != nil {
+		return fmt.Errorf("error parsing BPM cache: %v", err)
+	}
+	if b.bpms == nil {
+		b.bpms = make(map[string]float64)
+	}
+	return nil
+}
+
+// get returns a cached tempo
+func (b *bpmStore) get(filePath string) (float64, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	bpm, ok := b.bpms[filePath]
+	return bpm, ok
+}
+
+// set caches a tempo and saves the cache
+func (b *bpmStore) set(filePath string, bpm float64) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.bpms[filePath] = bpm
+	return writeJSONFile(b.path, b.bpms)
+}
+
+// bpmFromTags reads the BPM tag (ID3 TBPM, Vorbis BPM, MP4 tmpo) if present
+func bpmFromTags(metadata tag.Metadata) float64 {
+	if metadata == nil {
+		return 0
+	}
+	raw := metadata.Raw()
+	for _, key := range []string{"TBPM", "TBP", "bpm", "BPM", "tmpo"} {
+		value, ok := raw[key]
+		if !ok {
+			continue
+		}
+		if bpm, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(value)), 64); err == nil && bpm > 0 {
+			return bpm
+		}
+	}
+	return 0
+}
+
+// analyzeBPM estimates a song's tempo from its audio using FFmpeg to decode
+func analyzeBPM(filePath string) (float64, error) {
+	cmd := exec.Command("ffmpeg",
+		"-v", "error",
+		"-ss", strconv.Itoa(bpmAnalysisOffset),
+		"-t", strconv.Itoa(bpmAnalysisLength),
+		"-i", filePath,
+		"-ac", "1",
+		"-ar", strconv.Itoa(bpmSampleRate),
+		"-f", "s16le",
+		"-",
+	)
+	var pcm []byte
+	var err error
+	mediaJobs.do(jobBackground, func() { pcm, err = cmd.Output() })
+	if err != nil {
+		return 0, fmt.Errorf("FFmpeg decode failed: %v", err)
+	}
+
+	// Short songs have nothing after the offset, analyze from the start instead
+	if len(pcm) < bpmSampleRate*2*10 {
+		cmd = exec.Command("ffmpeg", "-v", "error", "-t", strconv.Itoa(bpmAnalysisLength), "-i", filePath,
+			"-ac", "1", "-ar", strconv.Itoa(bpmSampleRate), "-f", "s16le", "-")
+		mediaJobs.do(jobBackground, func() { pcm, err = cmd.Output() })
+		if err != nil {
+			return 0, fmt.Errorf("FFmpeg decode failed: %v", err)
+		}
+	}
+
+	return estimateBPM(pcm), nil
+}
+
+// estimateBPM finds the dominant tempo in 16-bit mono PCM, or 0 if there's no clear beat
+func estimateBPM(pcm []byte) float64 {
+	samples := len(pcm) / 2
+	hops := samples / bpmHopSize
+	if hops < 64 {
+		return 0
+	}
+
+	// Onset envelope: rise in log energy between hops
+	envelope := make([]float64, hops)
+	previous := 0.0
+	for h := 0; h < hops; h++ {
+		energy := 0.0
+		for i := 0; i < bpmHopSize; i++ {
+			offset := (h*bpmHopSize + i) * 2
+			sample := float64(int16(binary.LittleEndian.Uint16(pcm[offset:]))) / 32768
+			energy += sample * sample
+		}
+		logEnergy := math.Log1p(energy * 1000)
+		if rise := logEnergy - previous; rise > 0 {
+			envelope[h] = rise
+		}
+		previous = logEnergy
+	}
+
+	// Autocorrelate over the lags of plausible tempos
+	hopsPerSecond := float64(bpmSampleRate) / bpmHopSize
+	minLag := int(60 * hopsPerSecond / bpmMax)
+	maxLag := int(60 * hopsPerSecond / bpmMin)
+	bestLag, bestScore := 0, 0.0
+	for lag := minLag; lag <= maxLag && lag < hops; lag++ {
+		score := 0.0
+		for i := lag; i < hops; i++ {
+			score += envelope[i] * envelope[i-lag]
+		}
+		score /= float64(hops - lag)
+		if score > bestScore {
+			bestLag, bestScore = lag, score
+		}
+	}
+	if bestLag == 0 {
+		return 0
+	}
+
+	bpm := 60 * hopsPerSecond / float64(bestLag)
+	return math.Round(bpm*10) / 10
+}
+
+// GetTrackBPM returns a song's tempo from its tags, the cache or analysis, in that order
+func (a *App) GetTrackBPM(filePath string) (float64, error) {
+	filePath, err := a.resolveTrackRef(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	if bpm, ok := a.bpms.get(filePath); ok {
+		return bpm, nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	metadata, _ := tag.ReadFrom(file)
+	file.Close()
+
+	bpm := bpmFromTags(metadata)
+	if bpm == 0 {
+		if !a.checkFFmpegAvailable() {
+			return 0, fmt.Errorf("FFmpeg is required to analyze BPM")
+		}
+		if bpm, err = analyzeBPM(filePath); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := a.bpms.set(filePath, bpm); err != nil {
+		logError("Failed to cache BPM: %v", err)
+	}
+	return bpm, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// processedCacheFormat is bumped whenever the effect filters or the encoding change,
+// so entries rendered by older versions are re-rendered instead of reused
+const processedCacheFormat = 1
+
+// processedCodecArgs are the FFmpeg output settings for processed audio
+var processedCodecArgs = []string{
+	"-acodec", "libmp3lame",
+	"-b:a", "192k",
+	"-ar", "44100",
+	"-ac", "2", // stereo
+	"-f", "mp3",
+}
+
+// processedCacheFile returns where a song processed with an effect chain is cached. The
+// key covers the path, content version and effects, so files rewritten by a tagger don't
+// reuse audio processed from the old content.
+func processedCacheFile(inputPath string, chain EffectChain) string {
+	cacheDir := audioCacheDir()
+	if chain.Transcode.Codec != "" && !chain.Transcode.Persistent {
+		cacheDir = transcodeSessionDir()
+	}
+	os.MkdirAll(cacheDir, 0755)
+
+	hasher := md5.New()
+	hasher.Write([]byte(pathKey(inputPath)))
+	hasher.Write([]byte(fileVersion(inputPath)))
+	hasher.Write([]byte(chain.cacheKey()))
+	return filepath.Join(cacheDir, hex.EncodeToString(hasher.Sum(nil))+chain.outputExt())
+}
+
+// reuseProcessedCache reports whether a cached render can be played, which needs its
+// manifest to still match the source and settings. Stale renders are deleted.
+func reuseProcessedCache(cachedFile string, inputPath string, chain EffectChain) bool {
+	if _, err := os.Stat(cachedFile); err != nil {
+		return false
+	}
+	reason := validateProcessedCache(cachedFile, inputPath, chain)
+	if reason == "" {
+		logDebug("Using cached processed audio: %s", cachedFile)
+		touchCacheEntry(cachedFile)
+		return true
+	}
+	logWarn("Discarding cached processed audio %s: %s", cachedFile, reason)
+	os.Remove(cachedFile)
+	os.Remove(manifestPath(cachedFile))
+	return false
+}
+
+// cacheManifest sits next to a processed file and records how it was made
+type cacheManifest struct {
+	Format     int       `json:"format"`
+	Source     string    `json:"source"`
+	SourceHash string    `json:"sourceHash"` // SHA-256 of the source file contents
+	Effects    string    `json:"effects"`    // EffectChain.cacheKey()
+	Filters    string    `json:"filters"`    // Filter graph FFmpeg ran, may be the rubberband fallback
+	FFmpeg     string    `json:"ffmpeg"`     // First line of "ffmpeg -version"
+	Codec      string    `json:"codec"`
+	OutputSize int64     `json:"outputSize"`
+	OutputHash string    `json:"outputHash"` // SHA-256 of the processed file
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ffmpegVersionInfo caches the FFmpeg version, it's part of every manifest
+var ffmpegVersionInfo struct {
+	once    sync.Once
+	version string
+}
+
+// sourceHashes remembers file hashes by path and version so repeat plays don't re-read the source
+var sourceHashes = struct {
+	entries map[string]sourceHashEntry
+	mutex   sync.Mutex
+}{entries: make(map[string]sourceHashEntry)}
+
+type sourceHashEntry struct {
+	version string
+	hash    string
+}
+
+// ffmpegVersion returns the installed FFmpeg's version line, empty if it can't be run
+func ffmpegVersion() string {
+	ffmpegVersionInfo.once.Do(func() {
+		output, err := exec.Command("ffmpeg", "-version").Output()
+		if err != nil {
+			return
+		}
+		ffmpegVersionInfo.version = strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+	})
+	return ffmpegVersionInfo.version
+}
+
+// processedCodec describes the output settings of a chain in manifests
+func processedCodec(chain EffectChain) string {
+	return strings.Join(chain.codecArgs(), " ")
+}
+
+// manifestPath returns where a processed file's manifest is stored
+func manifestPath(cachedFile string) string {
+	return cachedFile + ".json"
+}
+
+// hashFile returns the SHA-256 of a file's contents
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// sourceHash hashes a source file, reusing the last hash while its size and mtime are unchanged
+func sourceHash(filePath string) (string, error) {
+	version := fileVersion(filePath)
+
+	sourceHashes.mutex.Lock()
+	entry, ok := sourceHashes.entries[filePath]
+	sourceHashes.mutex.Unlock()
+	if ok && entry.version == version {
+		return entry.hash, nil
+	}
+
+	hash, err := hashFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	sourceHashes.mutex.Lock()
+	sourceHashes.entries[filePath] = sourceHashEntry{version: version, hash: hash}
+	sourceHashes.mutex.Unlock()
+	return hash, nil
+}
+
+// validateProcessedCache checks a cached file against its manifest and returns why it
+// can't be used, or an empty string for a valid hit
+func validateProcessedCache(cachedFile string, inputPath string, chain EffectChain) string {
+	data, err := os.ReadFile(manifestPath(cachedFile))
+	if err != nil {
+		return "no manifest"
+	}
+	var manifest cacheManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "unreadable manifest"
+	}
+
+	switch {
+	case manifest.Format != processedCacheFormat:
+		return fmt.Sprintf("cache format %d, current is %d", manifest.Format, processedCacheFormat)
+	case manifest.Effects != chain.cacheKey():
+		return "effect chain changed"
+	case manifest.Codec != processedCodec(chain):
+		return "codec settings changed"
+	case manifest.FFmpeg != ffmpegVersion():
+		return "FFmpeg version changed"
+	}
+
+	if hash, err := sourceHash(inputPath); err != nil || hash != manifest.SourceHash {
+		return "source file changed"
+	}
+	info, err := os.Stat(cachedFile)
+	if err != nil || info.Size() != manifest.OutputSize {
+		return "processed file missing or truncated"
+	}
+	if hash, err := hashFile(cachedFile); err != nil || hash != manifest.OutputHash {
+		return "processed file checksum mismatch"
+	}
+	return ""
+}
+
+// writeProcessedManifest records how a freshly rendered file was made
+func writeProcessedManifest(cachedFile string, inputPath string, chain EffectChain, filters string) error {
+	sourceSum, err := sourceHash(inputPath)
+	if err != nil {
+		return fmt.Errorf("error hashing source: %v", err)
+	}
+	info, err := os.Stat(cachedFile)
+	if err != nil {
+		return err
+	}
+	outputSum, err := hashFile(cachedFile)
+	if err != nil {
+		return fmt.Errorf("error hashing processed file: %v", err)
+	}
+
+	return writeJSONFile(manifestPath(cachedFile), cacheManifest{
+		Format:     processedCacheFormat,
+		Source:     inputPath,
+		SourceHash: sourceSum,
+		Effects:    chain.cacheKey(),
+		Filters:    filters,
+		FFmpeg:     ffmpegVersion(),
+		Codec:      processedCodec(chain),
+		OutputSize: info.Size(),
+		OutputHash: outputSum,
+		CreatedAt:  time.Now(),
+	})
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Where fetched covers are kept
+const (
+	fetchedCoversConfig = "config" // Only in the config dir
+	fetchedCoversFolder = "folder" // Also as an image named after the song, next to it
+)
+
+const (
+	musicBrainzReleaseURL = "https://musicbrainz.org/ws/2/release/"
+	coverArtArchiveURL    = "https://coverartarchive.org/release/"
+	iTunesSearchURL       = "https://itunes.apple.com/search"
+	maxFetchedCoverBytes  = 8 << 20
+)
+
+// coverFetcher remembers which covers were looked for, so songs of an album that has no
+// cover online don't ask again on every play
+type coverFetcher struct {
+	attempted map[string]bool // Cover key -> looked up this run
+	mutex     sync.Mutex
+}
+
+// fetchedCoversDir is where downloaded covers are cached, one file per album
+func fetchedCoversDir() string {
+	return filepath.Join(getConfigDir(), "covers")
+}
+
+// fetchedCoverKey identifies the cover of a song's album, or of the song itself when it
+// has no album tag. "" when the tags aren't enough to look it up.
+func fetchedCoverKey(song Song) string {
+	artist := strings.ToLower(strings.TrimSpace(song.Artist))
+	if artist == "" || artist == "unknown artist" {
+		return ""
+	}
+	name := "album|" + strings.ToLower(strings.TrimSpace(song.Album))
+	if song.Album == "" || song.Album == "Unknown Album" {
+		name = "song|" + strings.ToLower(strings.TrimSpace(song.Title))
+	}
+	hash := md5.Sum([]byte(artist + "|" + name))
+	return hex.EncodeToString(hash[:])
+}
+
+// fetchedCoverPath returns the cached download of a song's cover, "" when there's none
+func fetchedCoverPath(song Song) string {
+	key := fetchedCoverKey(song)
+	if key == "" {
+		return ""
+	}
+	for ext := range folderCoverTypes {
+		path := filepath.Join(fetchedCoversDir(), key+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// fetchedCoverHash adds a song's downloaded cover to the cover store, "" when it has none
+func (a *App) fetchedCoverHash(song Song) string {
+	path := fetchedCoverPath(song)
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return a.addCover(folderCoverTypes[filepath.Ext(path)], data)
+}
+
+// downloadImage fetches an image, refusing other content and oversized files
+func downloadImage(imageURL string) ([]byte, error) {
+	req, err := http.NewRequest("GET", imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", musicBrainzAgent)
+	resp, err := identifyHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		return nil, fmt.Errorf("not an image: %s", resp.Header.Get("Content-Type"))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchedCoverBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFetchedCoverBytes {
+		return nil, fmt.Errorf("image too large")
+	}
+	return data, nil
+}
+
+// coverArtArchiveCover finds an album's release on MusicBrainz and downloads its front
+// cover from the Cover Art Archive
+func coverArtArchiveCover(artist string, album string) ([]byte, error) {
+	params := url.Values{}
+	params.Set("query", "release:"+luceneQuote(album)+" AND artist:"+luceneQuote(artist))
+	params.Set("limit", "3")
+	params.Set("fmt", "json")
+
+	req, err := http.NewRequest("GET", musicBrainzReleaseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", musicBrainzAgent) // Required by MusicBrainz
+	resp, err := identifyHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("MusicBrainz search failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("MusicBrainz returned HTTP %d", resp.StatusCode)
+	}
+	var result struct {
+		Releases []struct {
+			ID    string `json:"id"`
+			Score int    `json:"score"`
+		} `json:"releases"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse MusicBrainz response: %v", err)
+	}
+
+	// Not every release has art, the next best match often does
+	lastErr := fmt.Errorf("no release found")
+	for _, release := range result.Releases {
+		if release.Score < 90 {
+			break
+		}
+		data, err := downloadImage(coverArtArchiveURL + url.PathEscape(release.ID) + "/front-500")
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// iTunesCover looks an album, or a song without one, up on the iTunes Search API and
+// downloads its artwork at 600x600
+func iTunesCover(artist string, album string, title string) ([]byte, error) {
+	params := url.Values{}
+	params.Set("media", "music")
+	params.Set("limit", "1")
+	if album != "" {
+		params.Set("entity", "album")
+		params.Set("term", artist+" "+album)
+	} else {
+		params.Set("entity", "song")
+		params.Set("term", artist+" "+title)
+	}
+
+	resp, err := identifyHTTPClient.Get(iTunesSearchURL + "?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("iTunes search failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("iTunes search returned HTTP %d", resp.StatusCode)
+	}
+	var result struct {
+		Results []struct {
+			ArtworkURL string `json:"artworkUrl100"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse iTunes response: %v", err)
+	}
+	if len(result.Results) == 0 || result.Results[0].ArtworkURL == "" {
+		return nil, fmt.Errorf("no artwork found")
+	}
+	return downloadImage(strings.Replace(result.Results[0].ArtworkURL, "100x100bb", "600x600bb", 1))
+}
+
+// fetchMissingCover downloads a cover for a song without embedded or folder art, from
+// the Cover Art Archive or else iTunes, and gives it to the song. The playing song's
+// MPRIS and Discord art is updated too.
+func (a *App) fetchMissingCover(song Song) {
+	if !a.settings.FetchMissingCovers || song.CoverHash != "" || song.CoverData != "" {
+		return
+	}
+	key := fetchedCoverKey(song)
+	if key == "" {
+		return
+	}
+	a.coverFetch.mutex.Lock()
+	if a.coverFetch.attempted == nil {
+		a.coverFetch.attempted = make(map[string]bool)
+	}
+	attempted := a.coverFetch.attempted[key]
+	a.coverFetch.attempted[key] = true
+	a.coverFetch.mutex.Unlock()
+
+	path := fetchedCoverPath(song)
+	if path == "" {
+		if attempted {
+			return
+		}
+		album := song.Album
+		if album == "Unknown Album" {
+			album = ""
+		}
+		var data []byte
+		var err error
+		if album != "" {
+			data, err = coverArtArchiveCover(song.Artist, album)
+		}
+		if data == nil {
+			data, err = iTunesCover(song.Artist, album, song.Title)
+		}
+		if err != nil {
+			logWarn("No cover found online for %s - %s: %v", song.Artist, song.Title, err)
+			return
+		}
+		mimeType := http.DetectContentType(data)
+		if data, mimeType, err = shrinkCover(data, mimeType); err != nil {
+			logWarn("Fetched cover for %s is unusable: %v", song.Title, err)
+			return
+		}
+		ext := artworkExtensions[mimeType]
+		if _, ok := folderCoverTypes[ext]; !ok {
+			logWarn("Fetched cover for %s has an unsupported type: %s", song.Title, mimeType)
+			return
+		}
+		if err := os.MkdirAll(fetchedCoversDir(), 0755); err != nil {
+			logError("Error creating covers folder: %v", err)
+			return
+		}
+		path = filepath.Join(fetchedCoversDir(), key+ext)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			logError("Error saving fetched cover: %v", err)
+			return
+		}
+		logInfo("Fetched cover for %s - %s", song.Artist, song.Title)
+	}
+
+	if a.settings.FetchedCoverLocation == fetchedCoversFolder {
+		// Named after the song, so it's picked over other images in a playlist folder
+		name := strings.TrimSuffix(filepath.Base(song.FilePath), filepath.Ext(song.FilePath)) + filepath.Ext(path)
+		if data, err := os.ReadFile(path); err == nil {
+			if err := os.WriteFile(filepath.Join(filepath.Dir(song.FilePath), name), data, 0644); err != nil {
+				logWarn("Could not save cover next to %s: %v", song.FilePath, err)
+			}
+		}
+	}
+
+	updated, err := a.reloadSong(song.FilePath)
+	if err != nil || updated.CoverHash == "" {
+		return
+	}
+	a.emitEvent(eventSongUpdated, listSong(updated))
+	if current := a.currentSong; current != nil && current.FilePath == song.FilePath {
+		a.SetCurrentSong(&updated, a.isPlaying)
+	}
+}
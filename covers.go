@@ -0,0 +1,71 @@
+package main
+
+import "fmt"
+
+// listSong returns a copy of a song for list responses, with its cover replaced by the
+// HasCover flag. Covers are fetched with GetSongCover as rows become visible.
+func listSong(song Song) Song {
+	song.HasCover = song.CoverData != "" || song.CoverHash != ""
+	song.CoverData = ""
+	return song
+}
+
+// listSongs strips the covers of a list of songs
+func listSongs(songs []Song) []Song {
+	stripped := make([]Song, len(songs))
+	for i, song := range songs {
+		stripped[i] = listSong(song)
+	}
+	return stripped
+}
+
+// listPlaylists strips the covers of playlists and their songs
+func listPlaylists(playlists []Playlist) []Playlist {
+	stripped := make([]Playlist, len(playlists))
+	for i, playlist := range playlists {
+		playlist.HasCover = playlist.CoverData != ""
+		playlist.CoverData = ""
+		playlist.Songs = listSongs(playlist.Songs)
+		stripped[i] = playlist
+	}
+	return stripped
+}
+
+// fillSongCover loads the cover of a song the frontend sent without one, the playing
+// song needs it for Discord, MPRIS and sharing
+func (a *App) fillSongCover(song *Song) {
+	if song == nil || song.CoverData != "" || song.FilePath == "" {
+		return
+	}
+	if song.CoverHash == "" {
+		metadata, err := a.songMetadata(song.FilePath, nil)
+		if err != nil {
+			return
+		}
+		song.CoverHash = metadata.CoverHash
+	}
+	song.CoverData = a.songCoverData(*song)
+}
+
+// GetSongCover returns a song's embedded cover as a data URL, or "" when it has none
+func (a *App) GetSongCover(filePath string) (string, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	song, err := a.songMetadata(filePath, nil)
+	if err != nil {
+		return "", fmt.Errorf("error reading metadata: %v", err)
+	}
+	return a.songCoverData(song), nil
+}
+
+// GetPlaylistCover returns a playlist's cover image as a data URL, or "" when it has none
+func (a *App) GetPlaylistCover(playlistPath string) (string, error) {
+	playlist, err := a.getCachedPlaylist(normalizePath(playlistPath))
+	if err != nil {
+		return "", fmt.Errorf("error loading playlist: %v", err)
+	}
+	return playlist.CoverData, nil
+}
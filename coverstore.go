@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// indexCoversBucket maps cover hashes to cover data URLs
+var indexCoversBucket = []byte("covers")
+
+// coverStoreCacheSize is how many covers read from the index are kept in memory
+const coverStoreCacheSize = 64
+
+// coverStore keeps each distinct cover once, keyed by the hash of its image bytes, so
+// the songs of an album share one copy instead of embedding it each
+type coverStore struct {
+	pending map[string]string // Extracted covers not in the index yet, all of them without an index
+	recent  *lruCache         // Covers recently read from the index
+	folders *lruCache         // Folder image listings and folder cover hashes, see folderCoverHash
+	mutex   sync.Mutex
+}
+
+// hashCover identifies a cover by its content
+func hashCover(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// addCover stores an extracted cover and returns its hash
+func (a *App) addCover(mimeType string, data []byte) string {
+	hash := hashCover(data)
+	a.covers.mutex.Lock()
+	defer a.covers.mutex.Unlock()
+	if a.covers.pending == nil {
+		a.covers.pending = make(map[string]string)
+	}
+	if _, ok := a.covers.pending[hash]; !ok {
+		a.covers.pending[hash] = fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
+	}
+	return hash
+}
+
+// flushCovers writes pending covers to the index in one transaction
+func (a *App) flushCovers() error {
+	if a.library == nil {
+		return nil
+	}
+
+	a.covers.mutex.Lock()
+	flushing := make(map[string]string, len(a.covers.pending))
+	for hash, dataURL := range a.covers.pending {
+		flushing[hash] = dataURL
+	}
+	a.covers.mutex.Unlock()
+	if len(flushing) == 0 {
+		return nil
+	}
+
+	if err := a.library.putCovers(flushing); err != nil {
+		return err
+	}
+
+	a.covers.mutex.Lock()
+	for hash := range flushing {
+		delete(a.covers.pending, hash)
+	}
+	a.covers.mutex.Unlock()
+	return nil
+}
+
+// coverByHash returns a stored cover's data URL
+func (a *App) coverByHash(hash string) (string, bool) {
+	if hash == "" {
+		return "", false
+	}
+
+	a.covers.mutex.Lock()
+	dataURL, ok := a.covers.pending[hash]
+	a.covers.mutex.Unlock()
+	if ok {
+		return dataURL, true
+	}
+	if cached, ok := a.covers.recent.get(hash); ok {
+		return cached.(string), true
+	}
+
+	dataURL = a.library.cover(hash)
+	if dataURL == "" {
+		return "", false
+	}
+	a.covers.recent.put(hash, dataURL)
+	return dataURL, true
+}
+
+// songCoverData returns a song's cover as a data URL, inline or from the store
+func (a *App) songCoverData(song Song) string {
+	if song.CoverData != "" {
+		return song.CoverData
+	}
+	dataURL, _ := a.coverByHash(song.CoverHash)
+	return dataURL
+}
+
+// storeSongs indexes freshly extracted songs along with the covers they reference
+func (a *App) storeSongs(entries map[string]indexEntry) error {
+	if err := a.library.store(entries); err != nil {
+		return err
+	}
+	return a.flushCovers()
+}
+
+// putCovers adds covers that aren't indexed yet
+func (l *libraryIndex) putCovers(covers map[string]string) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(indexCoversBucket)
+		for hash, dataURL := range covers {
+			if bucket.Get([]byte(hash)) != nil {
+				continue
+			}
+			if err := bucket.Put([]byte(hash), []byte(dataURL)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// cover looks a cover hash up in the index
+func (l *libraryIndex) cover(hash string) string {
+	if l == nil {
+		return ""
+	}
+	var dataURL string
+	l.db.View(func(tx *bolt.Tx) error {
+		dataURL = string(tx.Bucket(indexCoversBucket).Get([]byte(hash)))
+		return nil
+	})
+	return dataURL
+}
+
+// deleteStaleCovers deletes covers no indexed song references anymore
+func deleteStaleCovers(tx *bolt.Tx) error {
+	referenced := make(map[string]bool)
+	tx.Bucket(indexSongsBucket).ForEach(func(_, data []byte) error {
+		var entry indexEntry
+		if json.Unmarshal(data, &entry) == nil && entry.Song.CoverHash != "" {
+			referenced[entry.Song.CoverHash] = true
+		}
+		return nil
+	})
+	_, err := deleteStaleKeys(tx.Bucket(indexCoversBucket), referenced)
+	return err
+}
+
+// GetCoverByHash returns the cover a song's coverHash refers to as a data URL. Songs of
+// the same album share a hash, so the frontend can fetch each cover once.
+func (a *App) GetCoverByHash(hash string) (string, error) {
+	dataURL, ok := a.coverByHash(hash)
+	if !ok {
+		return "", fmt.Errorf("unknown cover: %s", hash)
+	}
+	return dataURL, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Imgur keeps anonymous uploads as long as they're viewed, but may still delete them.
+// Links are trusted for coverURLCheckAge, then checked before use again.
+const (
+	coverURLCheckAge  = 7 * 24 * time.Hour
+	coverURLUnusedAge = 180 * 24 * time.Hour // Dropped on load when not used for this long
+)
+
+// coverURL is an uploaded cover's link and when it was last known to work
+type coverURL struct {
+	URL      string    `json:"url"`
+	Uploaded time.Time `json:"uploaded"`
+	Checked  time.Time `json:"checked"`
+	Used     time.Time `json:"used"`
+}
+
+// coverURLStore keeps the links of covers uploaded for Discord, so a cover is uploaded
+// once rather than once per app start
+type coverURLStore struct {
+	path  string
+	urls  map[string]coverURL // cover hash -> link
+	mutex sync.Mutex
+}
+
+// newCoverURLStore creates a cover link cache backed by the given file
+func newCoverURLStore(path string) *coverURLStore {
+	return &coverURLStore{path: path, urls: make(map[string]coverURL)}
+}
+
+// load reads the cached links from disk, leaving out those unused for long
+func (c *coverURLStore) load() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading cover cache: %v", err)
+	}
+	if err := json.Unmarshal(data, &c.urls); err != nil {
+		return fmt.Errorf("error parsing cover cache: %v", err)
+	}
+	if c.urls == nil {
+		c.urls = make(map[string]coverURL)
+	}
+	for hash, cached := range c.urls {
+		if time.Since(cached.Used) > coverURLUnusedAge {
+			delete(c.urls, hash)
+		}
+	}
+	return nil
+}
+
+// get returns the cached link of a cover
+func (c *coverURLStore) get(hash string) (coverURL, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cached, ok := c.urls[hash]
+	return cached, ok
+}
+
+// set caches a link and saves the cache
+func (c *coverURLStore) set(hash string, cached coverURL) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.urls[hash] = cached
+	return writeJSONFile(c.path, c.urls)
+}
+
+// remove drops a link that stopped working and saves the cache
+func (c *coverURLStore) remove(hash string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.urls, hash)
+	return writeJSONFile(c.path, c.urls)
+}
+
+// size returns the number of cached links
+func (c *coverURLStore) size() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.urls)
+}
+
+// coverURLAlive checks that an uploaded cover is still there. Imgur redirects deleted
+// images to a "removed" placeholder instead of failing.
+func coverURLAlive(url string) bool {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Head(url)
+	if err != nil {
+		// Offline says nothing about the link, Discord fails the same way
+		return true
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK && !strings.Contains(resp.Request.URL.Path, "removed")
+}
+
+// cachedCoverURL returns the link of an already uploaded cover, checking links that
+// haven't been checked for a while. Dead links are dropped so the cover is uploaded again.
+func (a *App) cachedCoverURL(hash string) (string, bool) {
+	cached, ok := a.coverURLs.get(hash)
+	if !ok {
+		return "", false
+	}
+
+	now := time.Now()
+	changed := false
+	if now.Sub(cached.Checked) > coverURLCheckAge {
+		if !coverURLAlive(cached.URL) {
+			logWarn("Cached cover URL is gone, uploading again: %s", cached.URL)
+			if err := a.coverURLs.remove(hash); err != nil {
+				logWarn("Could not update cover cache: %v", err)
+			}
+			return "", false
+		}
+		cached.Checked = now
+		changed = true
+	}
+	// Use times only matter for pruning, they're saved at most daily
+	if now.Sub(cached.Used) > 24*time.Hour {
+		cached.Used = now
+		changed = true
+	}
+	if changed {
+		if err := a.coverURLs.set(hash, cached); err != nil {
+			logWarn("Could not update cover cache: %v", err)
+		}
+	}
+	return cached.URL, true
+}
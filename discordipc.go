@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// discordAppID is the Discord application the presence is shown for
+const discordAppID = "1418623365631181003"
+
+// Discord IPC frame opcodes
+const (
+	discordOpHandshake = 0
+	discordOpFrame     = 1
+	discordOpClose     = 2
+	discordOpPing      = 3
+	discordOpPong      = 4
+)
+
+// discordIPCTimeout bounds the handshake and each command round trip
+const discordIPCTimeout = 5 * time.Second
+
+// discordMaxFrame guards against reading garbage as a huge frame length
+const discordMaxFrame = 1 << 20
+
+// discordIPCSlots is how many discord-ipc-N sockets Discord may listen on (one per running client)
+const discordIPCSlots = 10
+
+// CustomDiscordRPC is a raw Discord IPC connection. rich-go can't set the activity
+// type, so "Listening to" needs the frames written by hand.
+type CustomDiscordRPC struct {
+	conn   net.Conn
+	appID  string
+	active bool
+	mutex  sync.Mutex
+}
+
+// discordFrame is the JSON body of an IPC frame
+type discordFrame struct {
+	Cmd   string          `json:"cmd"`
+	Evt   string          `json:"evt"`
+	Nonce string          `json:"nonce"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// connect dials the first Discord IPC socket that accepts the handshake
+func (r *CustomDiscordRPC) connect(appID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.closeLocked()
+
+	var lastErr error
+	for slot := 0; slot < discordIPCSlots; slot++ {
+		conn, err := dialDiscordIPC(slot)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		r.conn = conn
+		if err := r.handshake(appID); err != nil {
+			conn.Close()
+			r.conn = nil
+			lastErr = err
+			continue
+		}
+		r.appID = appID
+		r.active = true
+		return nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no Discord IPC socket found")
+	}
+	return lastErr
+}
+
+// connectPath connects to the IPC socket at path, for RPC bridges like arRPC that
+// listen somewhere else than Discord
+func (r *CustomDiscordRPC) connectPath(appID string, path string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.closeLocked()
+	conn, err := dialIPCPath(path)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	if err := r.handshake(appID); err != nil {
+		conn.Close()
+		r.conn = nil
+		return err
+	}
+	r.appID = appID
+	r.active = true
+	return nil
+}
+
+// isActive reports whether the connection is up
+func (r *CustomDiscordRPC) isActive() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.active
+}
+
+// handshake identifies the application and waits for the READY dispatch
+func (r *CustomDiscordRPC) handshake(appID string) error {
+	r.conn.SetDeadline(time.Now().Add(discordIPCTimeout))
+	defer r.conn.SetDeadline(time.Time{})
+
+	if err := r.write(discordOpHandshake, map[string]interface{}{"v": 1, "client_id": appID}); err != nil {
+		return err
+	}
+	opcode, data, err := r.read()
+	if err != nil {
+		return err
+	}
+
+	if opcode == discordOpClose {
+		return fmt.Errorf("Discord closed the connection: %s", discordErrorMessage(data))
+	}
+	var frame discordFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return fmt.Errorf("invalid handshake response: %v", err)
+	}
+	if frame.Evt != "READY" {
+		return fmt.Errorf("unexpected handshake response: %s", frame.Evt)
+	}
+	return nil
+}
+
+// setActivity sends SET_ACTIVITY and waits for Discord's answer, nil clears the activity
+func (r *CustomDiscordRPC) setActivity(activity *CustomActivity) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.active {
+		return fmt.Errorf("Discord RPC not active")
+	}
+
+	payload := CustomRPCPayload{
+		Cmd: "SET_ACTIVITY",
+		Args: CustomRPCArgs{
+			PID:      os.Getpid(),
+			Activity: activity,
+		},
+		Nonce: fmt.Sprintf("%d", time.Now().UnixNano()),
+	}
+
+	if err := r.command(payload.Nonce, payload); err != nil {
+		r.closeLocked()
+		return err
+	}
+	return nil
+}
+
+// command sends a frame and reads until the response with the same nonce arrives
+func (r *CustomDiscordRPC) command(nonce string, payload interface{}) error {
+	r.conn.SetDeadline(time.Now().Add(discordIPCTimeout))
+	defer r.conn.SetDeadline(time.Time{})
+
+	if err := r.write(discordOpFrame, payload); err != nil {
+		return err
+	}
+	for {
+		opcode, data, err := r.read()
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case discordOpPing:
+			if err := r.writeRaw(discordOpPong, data); err != nil {
+				return err
+			}
+			continue
+		case discordOpClose:
+			return fmt.Errorf("Discord closed the connection: %s", discordErrorMessage(data))
+		}
+
+		var frame discordFrame
+		if err := json.Unmarshal(data, &frame); err != nil || frame.Nonce != nonce {
+			continue // Unrelated dispatch
+		}
+		if frame.Evt == "ERROR" {
+			return fmt.Errorf("Discord rejected %s: %s", frame.Cmd, discordErrorMessage(frame.Data))
+		}
+		return nil
+	}
+}
+
+// write marshals payload into a frame
+func (r *CustomDiscordRPC) write(opcode uint32, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	return r.writeRaw(opcode, data)
+}
+
+// writeRaw sends one frame: opcode and length as little-endian uint32, then the JSON body
+func (r *CustomDiscordRPC) writeRaw(opcode uint32, data []byte) error {
+	frame := make([]byte, 8+len(data))
+	binary.LittleEndian.PutUint32(frame[0:4], opcode)
+	binary.LittleEndian.PutUint32(frame[4:8], uint32(len(data)))
+	copy(frame[8:], data)
+	_, err := r.conn.Write(frame)
+	return err
+}
+
+// read receives one frame
+func (r *CustomDiscordRPC) read() (uint32, []byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r.conn, header); err != nil {
+		return 0, nil, err
+	}
+	opcode := binary.LittleEndian.Uint32(header[0:4])
+	length := binary.LittleEndian.Uint32(header[4:8])
+	if length > discordMaxFrame {
+		return 0, nil, fmt.Errorf("Discord IPC frame too large: %d bytes", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.conn, data); err != nil {
+		return 0, nil, err
+	}
+	return opcode, data, nil
+}
+
+// close says goodbye to Discord and drops the connection
+func (r *CustomDiscordRPC) close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.closeLocked()
+}
+
+// closeLocked closes the connection, caller must hold the mutex
+func (r *CustomDiscordRPC) closeLocked() {
+	if r.conn == nil {
+		return
+	}
+	r.conn.SetDeadline(time.Now().Add(time.Second))
+	r.writeRaw(discordOpClose, []byte("{}"))
+	r.conn.Close()
+	r.conn = nil
+	r.active = false
+}
+
+// discordErrorMessage pulls the message out of an error or close payload
+func discordErrorMessage(data []byte) string {
+	var body struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &body) != nil || body.Message == "" {
+		return string(data)
+	}
+	return fmt.Sprintf("%s (code %d)", body.Message, body.Code)
+}
@@ -0,0 +1,54 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// discordSocketDirs lists where Discord may create its IPC socket, including the
+// snap and flatpak sandboxes
+func discordSocketDirs() []string {
+	var dirs []string
+	for _, name := range []string{"XDG_RUNTIME_DIR", "TMPDIR", "TMP", "TEMP"} {
+		if dir := os.Getenv(name); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	dirs = append(dirs, "/tmp")
+
+	runtimeDir := fmt.Sprintf("/run/user/%d", os.Getuid())
+	dirs = append(dirs,
+		filepath.Join(runtimeDir, "snap.discord"),
+		filepath.Join(runtimeDir, "app", "com.discordapp.Discord"),
+		filepath.Join(runtimeDir, ".flatpak", "com.discordapp.Discord", "xdg-run"),
+	)
+	return dirs
+}
+
+// dialIPCPath connects to a unix socket speaking the Discord IPC protocol
+func dialIPCPath(path string) (net.Conn, error) {
+	return net.DialTimeout("unix", path, 2*time.Second)
+}
+
+// dialDiscordIPC connects to the discord-ipc-<slot> unix socket
+func dialDiscordIPC(slot int) (net.Conn, error) {
+	var lastErr error
+	for _, dir := range discordSocketDirs() {
+		path := filepath.Join(dir, fmt.Sprintf("discord-ipc-%d", slot))
+		if _, err := os.Stat(path); err != nil {
+			lastErr = err
+			continue
+		}
+		conn, err := net.DialTimeout("unix", path, 2*time.Second)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	npipe "gopkg.in/natefinch/npipe.v2"
+)
+
+// dialIPCPath connects to a named pipe speaking the Discord IPC protocol
+func dialIPCPath(path string) (net.Conn, error) {
+	return npipe.DialTimeout(path, 2*time.Second)
+}
+
+// dialDiscordIPC connects to the \\.\pipe\discord-ipc-<slot> named pipe. DialTimeout
+// matters, a plain dial blocks for a long time when Discord isn't running.
+func dialDiscordIPC(slot int) (net.Conn, error) {
+	return npipe.DialTimeout(fmt.Sprintf(`\\.\pipe\discord-ipc-%d`, slot), 2*time.Second)
+}
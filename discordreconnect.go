@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// eventDiscordConnected tells the frontend Discord RPC (re)connected
+const eventDiscordConnected = "discord:connected"
+
+// Reconnect backoff, doubling from the minimum up to the maximum
+const (
+	discordReconnectMin = 2 * time.Second
+	discordReconnectMax = 2 * time.Minute
+)
+
+// discordReconnector retries the Discord connection in the background, so starting
+// Discord after Static doesn't need a song change to bring the presence back
+type discordReconnector struct {
+	running bool
+	wake    chan struct{} // Retry now and reset the backoff
+	stop    chan struct{}
+	mutex   sync.Mutex
+}
+
+// reconnectDiscordRPC starts the reconnect loop, or makes a running loop retry right away
+func (a *App) reconnectDiscordRPC() {
+	if !a.settings.DiscordRPC {
+		return
+	}
+
+	a.discordRetry.mutex.Lock()
+	defer a.discordRetry.mutex.Unlock()
+
+	if a.discordRetry.running {
+		select {
+		case a.discordRetry.wake <- struct{}{}:
+		default:
+		}
+		return
+	}
+	a.discordRetry.running = true
+	a.discordRetry.wake = make(chan struct{}, 1)
+	a.discordRetry.stop = make(chan struct{})
+	go a.discordReconnectLoop(a.discordRetry.wake, a.discordRetry.stop)
+}
+
+// stopDiscordReconnect ends the reconnect loop, used when Discord RPC is turned off
+func (a *App) stopDiscordReconnect() {
+	a.discordRetry.mutex.Lock()
+	defer a.discordRetry.mutex.Unlock()
+
+	if a.discordRetry.running {
+		close(a.discordRetry.stop)
+		a.discordRetry.running = false
+	}
+}
+
+// discordReconnectLoop retries with exponential backoff until connected or stopped
+func (a *App) discordReconnectLoop(wake chan struct{}, stop chan struct{}) {
+	delay := discordReconnectMin
+	for {
+		logDebug("Discord RPC: retrying in %s", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-wake:
+			timer.Stop()
+			delay = discordReconnectMin
+		case <-timer.C:
+		}
+
+		if !a.settings.DiscordRPC || a.discordActive || a.connectDiscordRPC() == nil {
+			break
+		}
+		delay *= 2
+		if delay > discordReconnectMax {
+			delay = discordReconnectMax
+		}
+	}
+
+	a.discordRetry.mutex.Lock()
+	select {
+	case <-stop: // Stopped while connecting, stopDiscordReconnect already reset the state
+	default:
+		a.discordRetry.running = false
+	}
+	a.discordRetry.mutex.Unlock()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventDuckingChanged tells the frontend to lower or restore the playback volume
+const eventDuckingChanged = "volume:ducking"
+
+const (
+	duckingPollInterval  = 1 * time.Second
+	duckingReleaseDelay  = 2 * time.Second // Keep ducking briefly so back-to-back sounds don't pump the volume
+	defaultDuckingAmount = 0.6
+)
+
+// Reasons for ducking
+const (
+	duckReasonVoiceChat    = "voice-chat"
+	duckReasonNotification = "notification"
+)
+
+// voiceChatApps are application names whose streams mean a call is in progress
+var voiceChatApps = []string{"discord", "webrtc voiceengine", "zoom", "teams", "mumble", "skype", "slack", "telegram", "element", "signal"}
+
+// DuckingState describes whether Static's volume is currently lowered for another sound
+type DuckingState struct {
+	Active bool    `json:"active"`
+	Reason string  `json:"reason,omitempty"`
+	Factor float64 `json:"factor"` // Multiply the playback volume by this, 1.0 when not ducking
+}
+
+// pulseStream is a playback or capture stream reported by pactl
+type pulseStream struct {
+	properties map[string]string
+	corked     bool
+}
+
+// duckingMonitor tracks ducking state between polls
+type duckingMonitor struct {
+	state     DuckingState
+	lastHeard time.Time
+	mutex     sync.Mutex
+}
+
+// listPulseStreams parses `pactl list sink-inputs` or `pactl list source-outputs`. pactl
+// translates its labels, so it's run in the C locale to get the English ones.
+func listPulseStreams(kind string) ([]pulseStream, error) {
+	cmd := exec.Command("pactl", "list", kind)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %v", kind, err)
+	}
+
+	var streams []pulseStream
+	var current *pulseStream
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "Sink Input #"), strings.HasPrefix(line, "Source Output #"):
+			streams = append(streams, pulseStream{properties: make(map[string]string)})
+			current = &streams[len(streams)-1]
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "Corked:"):
+			current.corked = strings.TrimSpace(strings.TrimPrefix(line, "Corked:")) == "yes"
+		case strings.Contains(line, " = "):
+			parts := strings.SplitN(line, " = ", 2)
+			current.properties[parts[0]] = strings.Trim(parts[1], `"`)
+		}
+	}
+	return streams, scanner.Err()
+}
+
+// isVoiceChatStream reports whether a stream belongs to a call
+func isVoiceChatStream(stream pulseStream) bool {
+	role := stream.properties["media.role"]
+	if role == "phone" || role == "communication" {
+		return true
+	}
+
+	name := strings.ToLower(stream.properties["application.name"] + " " + stream.properties["application.process.binary"])
+	for _, app := range voiceChatApps {
+		if strings.Contains(name, app) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotificationStream reports whether a stream is a desktop event sound
+func isNotificationStream(stream pulseStream) bool {
+	if stream.properties["media.role"] == "event" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(stream.properties["application.id"]), "canberra")
+}
+
+// detectDuckingReason returns why Static should duck right now, or "" if it shouldn't
+func (a *App) detectDuckingReason() (string, error) {
+	if a.settings.DuckOnVoiceChat {
+		// An open microphone is the most reliable sign of an active call
+		captures, err := listPulseStreams("source-outputs")
+		if err != nil {
+			return "", err
+		}
+		for _, stream := range captures {
+			if !stream.corked && isVoiceChatStream(stream) {
+				return duckReasonVoiceChat, nil
+			}
+		}
+	}
+
+	playbacks, err := listPulseStreams("sink-inputs")
+	if err != nil {
+		return "", err
+	}
+	for _, stream := range playbacks {
+		if stream.corked {
+			continue
+		}
+		if a.settings.DuckOnVoiceChat && stream.properties["media.role"] == "phone" {
+			return duckReasonVoiceChat, nil
+		}
+		if a.settings.DuckOnNotifications && isNotificationStream(stream) {
+			return duckReasonNotification, nil
+		}
+	}
+	return "", nil
+}
+
+// GetDuckingState returns whether playback is currently ducked
+func (a *App) GetDuckingState() DuckingState {
+	a.ducking.mutex.Lock()
+	defer a.ducking.mutex.Unlock()
+	return a.ducking.state
+}
+
+// watchDucking polls PulseAudio/PipeWire for voice chat and notification streams
+func (a *App) watchDucking() {
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	ticker := time.NewTicker(duckingPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.checkDucking()
+	}
+}
+
+// checkDucking ducks or restores the volume based on the other active streams
+func (a *App) checkDucking() {
+	reason := ""
+	if a.settings.DuckOnVoiceChat || a.settings.DuckOnNotifications {
+		var err error
+		reason, err = a.detectDuckingReason()
+		if err != nil {
+			logWarn("Ducking: %v", err)
+			return
+		}
+	}
+
+	a.ducking.mutex.Lock()
+	now := time.Now()
+	if reason != "" {
+		a.ducking.lastHeard = now
+	} else if a.ducking.state.Active && now.Sub(a.ducking.lastHeard) < duckingReleaseDelay {
+		// Hold the duck a little longer
+		a.ducking.mutex.Unlock()
+		return
+	}
+
+	amount := a.settings.DuckingAmount
+	if amount <= 0 || amount > 1 {
+		amount = defaultDuckingAmount
+	}
+	next := DuckingState{Active: reason != "", Reason: reason, Factor: 1.0}
+	if next.Active {
+		next.Factor = 1.0 - amount
+	}
+	changed := next != a.ducking.state
+	a.ducking.state = next
+	a.ducking.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if next.Active {
+		logInfo("Ducking: lowering volume by %.0f%% for %s", amount*100, reason)
+	} else {
+		logInfo("Ducking: restoring volume")
+	}
+	a.emitEvent(eventDuckingChanged, next)
+}
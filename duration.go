@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// oggTailSize is how much of the end of an Ogg file is searched for the last page
+const oggTailSize = 64 * 1024
+
+// opusSampleRate is the rate Opus granule positions count in, whatever the input rate
+const opusSampleRate = 48000
+
+// audioDuration returns a song's duration, parsing the format's headers natively and
+// falling back to ffprobe for files the parsers don't understand
+func (a *App) audioDuration(filePath string) (time.Duration, error) {
+	var duration time.Duration
+	var err error
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".mp3":
+		return a.getDurationFromMP3(filePath)
+	case ".flac":
+		duration, err = flacDuration(filePath)
+	case ".wav":
+		duration, err = wavDuration(filePath)
+	case ".ogg":
+		duration, err = oggDuration(filePath)
+	case ".m4a":
+		duration, err = m4aDuration(filePath)
+	default:
+		err = fmt.Errorf("unsupported format")
+	}
+	if err == nil && duration > 0 {
+		return duration, nil
+	}
+
+	seconds, probeErr := probeDuration(filePath)
+	if probeErr != nil {
+		if err == nil {
+			err = probeErr
+		}
+		return 0, fmt.Errorf("no duration for %s: %v", filepath.Base(filePath), err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// mp3HeaderDuration reads an MP3's length from the frame count in its Xing/Info or VBRI
+// header, ok is false when the file has neither
+func mp3HeaderDuration(filePath string) (time.Duration, bool, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, false, err
+	}
+	defer file.Close()
+
+	data, err := readMP3Head(file)
+	if err != nil {
+		return 0, false, err
+	}
+	frameStart, header, ok := findFirstMP3Frame(data)
+	if !ok {
+		return 0, false, nil
+	}
+
+	frames, ok := mp3FrameCount(data, frameStart, header)
+	if !ok || frames == 0 || header.sampleRate == 0 {
+		return 0, false, nil
+	}
+	return samplesDuration(uint64(frames)*uint64(header.samplesPerFrame()), uint64(header.sampleRate)), true, nil
+}
+
+// mp3FrameCount returns the frame count stored in the first frame by VBR encoders (Xing,
+// or Info for CBR files from LAME) or by the Fraunhofer encoder (VBRI)
+func mp3FrameCount(data []byte, frameStart int, header mp3FrameHeader) (int64, bool) {
+	// Xing/Info follows the side information, its frame count is the first optional field
+	xingStart := frameStart + 4 + header.sideInfoSize()
+	if xingStart+12 <= len(data) {
+		marker := string(data[xingStart : xingStart+4])
+		flags := binary.BigEndian.Uint32(data[xingStart+4 : xingStart+8])
+		if (marker == "Xing" || marker == "Info") && flags&0x01 != 0 {
+			return int64(binary.BigEndian.Uint32(data[xingStart+8 : xingStart+12])), true
+		}
+	}
+
+	// VBRI always sits 32 bytes after the header: version, delay, quality, bytes, frames
+	vbriStart := frameStart + 4 + 32
+	if vbriStart+18 <= len(data) && string(data[vbriStart:vbriStart+4]) == "VBRI" {
+		return int64(binary.BigEndian.Uint32(data[vbriStart+14 : vbriStart+18])), true
+	}
+	return 0, false
+}
+
+// samplesDuration converts a sample count at a rate into a duration
+func samplesDuration(samples uint64, sampleRate uint64) time.Duration {
+	if sampleRate == 0 {
+		return 0
+	}
+	return time.Duration(samples/sampleRate*uint64(time.Second) +
+		samples%sampleRate*uint64(time.Second)/sampleRate)
+}
+
+// flacDuration reads the total sample count and rate from the STREAMINFO block,
+// which the format requires to be the first metadata block
+func flacDuration(filePath string) (time.Duration, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	// An ID3v2 tag some taggers put in front of FLAC files is skipped like in MP3s
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(file, head); err != nil {
+		return 0, err
+	}
+	offset := int64(skipID3v2(head))
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	// "fLaC", 4 byte block header, 34 byte STREAMINFO
+	block := make([]byte, 4+4+34)
+	if _, err := io.ReadFull(file, block); err != nil {
+		return 0, err
+	}
+	if string(block[0:4]) != "fLaC" || block[4]&0x7f != 0 {
+		return 0, fmt.Errorf("missing FLAC STREAMINFO")
+	}
+	info := block[8:]
+
+	// Bits: sample rate (20), channels (3), bits per sample (5), total samples (36)
+	sampleRate := uint64(info[10])<<12 | uint64(info[11])<<4 | uint64(info[12])>>4
+	totalSamples := uint64(info[13]&0x0f)<<32 | uint64(binary.BigEndian.Uint32(info[14:18]))
+	if totalSamples == 0 {
+		return 0, fmt.Errorf("FLAC stream length unknown")
+	}
+	return samplesDuration(totalSamples, sampleRate), nil
+}
+
+// wavDuration divides the data chunk size by the byte rate from the fmt chunk
+func wavDuration(filePath string) (time.Duration, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return 0, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0, fmt.Errorf("not a RIFF WAVE file")
+	}
+
+	var byteRate uint32
+	offset := int64(12)
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			return 0, fmt.Errorf("missing WAVE data chunk")
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		offset += 8
+
+		switch id {
+		case "fmt ":
+			format := make([]byte, 12)
+			if _, err := io.ReadFull(file, format); err != nil {
+				return 0, err
+			}
+			byteRate = binary.LittleEndian.Uint32(format[8:12])
+		case "data":
+			if byteRate == 0 {
+				return 0, fmt.Errorf("WAVE data before fmt chunk")
+			}
+			// Streaming writers leave the size at 0 or 0xFFFFFFFF, use what's on disk
+			if remaining := info.Size() - offset; size == 0 || size > remaining {
+				size = remaining
+			}
+			return time.Duration(float64(size) / float64(byteRate) * float64(time.Second)), nil
+		}
+
+		// Chunks are padded to an even size
+		offset += size + size%2
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// oggDuration reads the codec's sample rate from the first page and the final granule
+// position from the last page. Vorbis and Opus are supported.
+func oggDuration(filePath string) (time.Duration, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// First page: 27 byte header, segment table, then the identification packet
+	page := make([]byte, 27+255+64)
+	n, err := io.ReadFull(file, page)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+	page = page[:n]
+	if len(page) < 28 || string(page[0:4]) != "OggS" {
+		return 0, fmt.Errorf("not an Ogg file")
+	}
+	packetStart := 27 + int(page[26])
+	if packetStart+19 > len(page) {
+		return 0, fmt.Errorf("truncated Ogg header")
+	}
+	packet := page[packetStart:]
+
+	var sampleRate, preSkip uint64
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")):
+		sampleRate = uint64(binary.LittleEndian.Uint32(packet[12:16]))
+	case bytes.HasPrefix(packet, []byte("OpusHead")):
+		sampleRate = opusSampleRate
+		preSkip = uint64(binary.LittleEndian.Uint16(packet[10:12]))
+	default:
+		return 0, fmt.Errorf("unsupported Ogg codec")
+	}
+
+	// Last page: its granule position is the total sample count
+	tailSize := int64(oggTailSize)
+	if tailSize > info.Size() {
+		tailSize = info.Size()
+	}
+	tail := make([]byte, tailSize)
+	if _, err := file.ReadAt(tail, info.Size()-tailSize); err != nil && err != io.EOF {
+		return 0, err
+	}
+	last := bytes.LastIndex(tail, []byte("OggS"))
+	if last < 0 || last+14 > len(tail) {
+		return 0, fmt.Errorf("no final Ogg page")
+	}
+	granule := binary.LittleEndian.Uint64(tail[last+6 : last+14])
+	if granule == ^uint64(0) || granule <= preSkip {
+		return 0, fmt.Errorf("invalid Ogg granule position")
+	}
+	return samplesDuration(granule-preSkip, sampleRate), nil
+}
+
+// m4aDuration reads the movie duration and timescale from moov/mvhd
+func m4aDuration(filePath string) (time.Duration, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	moovStart, moovEnd, err := findMP4Atom(file, 0, info.Size(), "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhdStart, _, err := findMP4Atom(file, moovStart, moovEnd, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+
+	// Version 1 uses 64-bit times and duration, version 0 32-bit
+	header := make([]byte, 32)
+	if _, err := file.ReadAt(header, mvhdStart); err != nil && err != io.EOF {
+		return 0, err
+	}
+	var timescale, duration uint64
+	if header[0] == 1 {
+		timescale = uint64(binary.BigEndian.Uint32(header[20:24]))
+		duration = binary.BigEndian.Uint64(header[24:32])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(header[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(header[16:20]))
+	}
+	return samplesDuration(duration, timescale), nil
+}
+
+// findMP4Atom looks for an atom among the children between start and end and returns
+// the range of its payload
+func findMP4Atom(file *os.File, start int64, end int64, name string) (int64, int64, error) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := file.ReadAt(header[:8], offset); err != nil {
+			return 0, 0, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0: // Extends to the end of the enclosing range
+			size = end - offset
+		case 1: // 64-bit size follows the type
+			if _, err := file.ReadAt(header[8:16], offset+8); err != nil {
+				return 0, 0, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return 0, 0, fmt.Errorf("corrupt MP4 atom at %d", offset)
+		}
+		if string(header[4:8]) == name {
+			return offset + headerSize, offset + size, nil
+		}
+		offset += size
+	}
+	return 0, 0, fmt.Errorf("MP4 atom %s not found", name)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// maxPitchSemitones limits pitch shifting in either direction
+const maxPitchSemitones = 6
+
+// nightcoreTempo is the speed-up applied by the nightcore effect
+const nightcoreTempo = 1.2
+
+// loudnormFilter normalizes to -14 LUFS with -1 dBTP peaks, the level streaming services use
+const loudnormFilter = "loudnorm=I=-14:TP=-1:LRA=11"
+
+// eventPlaybackRateChanged tells the frontend to rescale lyrics and progress
+const eventPlaybackRateChanged = "playback:rate-changed"
+
+// EffectChain is the set of audio effects applied to a song before playback
+type EffectChain struct {
+	Nightcore      bool             `json:"nightcore"`
+	BassBoost      bool             `json:"bassBoost"`
+	Stem           string           `json:"stem"`           // "", "instrumental" or "vocals", played instead of the full mix
+	PitchSemitones int              `json:"pitchSemitones"` // Pitch shift without changing speed, -6 to +6
+	Normalize      bool             `json:"normalize"`      // EBU R128 loudness normalization
+	Transcode      TranscodeProfile `json:"-"`              // Re-encoding for webviews that can't decode the format, see ProbePlaybackSupport
+}
+
+// validate checks the chain's parameters
+func (c EffectChain) validate() error {
+	if c.PitchSemitones < -maxPitchSemitones || c.PitchSemitones > maxPitchSemitones {
+		return fmt.Errorf("pitch shift must be between -%d and +%d semitones", maxPitchSemitones, maxPitchSemitones)
+	}
+	return nil
+}
+
+// cacheKey identifies the chain in processed audio cache keys
+func (c EffectChain) cacheKey() string {
+	key := fmt.Sprintf("nightcore:%t,bassboost:%t,stem:%s,pitch:%d", c.Nightcore, c.BassBoost, c.Stem, c.PitchSemitones)
+	if c.Normalize {
+		key += ",normalize" // Only when set, so existing cache entries keep their keys
+	}
+	if c.Transcode.Codec != "" {
+		key += ",transcode:" + c.Transcode.key()
+	}
+	return key
+}
+
+// hasFilters reports whether the chain needs an FFmpeg pass
+func (c EffectChain) hasFilters() bool {
+	return c.Nightcore || c.BassBoost || c.PitchSemitones != 0 || c.Normalize || c.Transcode.Codec != ""
+}
+
+// needsRubberband reports whether the chain's filters differ without rubberband
+func (c EffectChain) needsRubberband() bool {
+	return c.Nightcore || c.PitchSemitones != 0
+}
+
+// filters returns the chain's FFmpeg audio filters in order. Without rubberband, atempo
+// and asetrate stand in for its tempo and pitch changes.
+func (c EffectChain) filters(rubberband bool) []string {
+	var filters []string
+	if c.BassBoost {
+		// Bass boost: amplify frequencies below 200Hz by 10dB
+		filters = append(filters, "bass=g=10:f=200:w=1")
+	}
+	if c.Nightcore {
+		// Nightcore: increase tempo by 1.2x and pitch by 3 semitones (1.189)
+		if rubberband {
+			filters = append(filters, "rubberband=tempo=1.2:pitch=1.189")
+		} else {
+			filters = append(filters, "atempo=1.2", "asetrate=44100*1.189")
+		}
+	}
+	if c.PitchSemitones != 0 {
+		// Pitch shift without changing speed
+		filters = append(filters, c.pitchFilter(rubberband))
+	}
+	if c.Normalize {
+		// Last, so the level is measured after the other effects
+		filters = append(filters, loudnormFilter)
+	}
+	if len(filters) == 0 && c.Transcode.Codec != "" {
+		// Re-encoding alone still needs a graph
+		filters = append(filters, "anull")
+	}
+	return filters
+}
+
+// tempo returns how much faster than the original the chain plays
+func (c EffectChain) tempo() float64 {
+	if c.Nightcore {
+		return nightcoreTempo
+	}
+	return 1.0
+}
+
+// pitchRatio converts the semitone shift to a frequency ratio
+func (c EffectChain) pitchRatio() float64 {
+	return math.Pow(2, float64(c.PitchSemitones)/12)
+}
+
+// pitchFilter returns the FFmpeg filter for the pitch shift. Without rubberband the
+// sample rate trick changes pitch and speed together, so atempo undoes the speed change.
+// The audio is resampled to 44.1kHz first, asetrate assumes that's the rate it starts from.
+func (c EffectChain) pitchFilter(rubberband bool) string {
+	ratio := c.pitchRatio()
+	if rubberband {
+		return fmt.Sprintf("rubberband=pitch=%.4f", ratio)
+	}
+	return fmt.Sprintf("aresample=44100,asetrate=44100*%.4f,aresample=44100,atempo=%.4f", ratio, 1/ratio)
+}
+
+// SetActiveEffects tells the backend which effects the playing audio was processed with,
+// so durations shown in Discord and MPRIS and synced lyrics follow the altered tempo
+func (a *App) SetActiveEffects(chain EffectChain) error {
+	if err := chain.validate(); err != nil {
+		return err
+	}
+
+	a.effectsMutex.Lock()
+	rateChanged := a.activeEffects.tempo() != chain.tempo()
+	a.activeEffects = chain
+	a.effectsMutex.Unlock()
+
+	if !rateChanged {
+		return nil
+	}
+
+	a.emitEvent(eventPlaybackRateChanged, chain.tempo())
+	if song := a.currentSong; song != nil {
+		if err := a.updateOSMediaControls(song, a.isPlaying); err != nil {
+			logError("Failed to update OS media controls: %v", err)
+		}
+	}
+	return nil
+}
+
+// playbackRate returns the tempo of the playing audio relative to the original
+func (a *App) playbackRate() float64 {
+	a.effectsMutex.RLock()
+	defer a.effectsMutex.RUnlock()
+	return a.activeEffects.tempo()
+}
+
+// effectiveDuration returns how long a song actually plays for with the active effects
+func (a *App) effectiveDuration(song *Song) time.Duration {
+	return time.Duration(float64(song.DurationSec) / a.playbackRate() * float64(time.Second))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// libraryRoots returns the folders songs may be read from: the static folder, which holds
+// the playlist folders, and the source folders its playlists scan
+func (a *App) libraryRoots() []string {
+	staticPath := a.GetStaticFolderPath()
+	if staticPath == "" {
+		return nil
+	}
+	roots := []string{staticPath}
+	entries, err := os.ReadDir(staticPath)
+	if err != nil {
+		return roots
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		playlistDir := filepath.Join(staticPath, entry.Name())
+		config, err := readPlaylistConfig(playlistDir)
+		if err != nil {
+			continue
+		}
+		roots = append(roots, playlistSources(playlistDir, config)...)
+	}
+	return roots
+}
+
+// canonicalPath returns the absolute path of a file with symlinks resolved, so a link
+// can't point a library path somewhere else
+func canonicalPath(path string) (string, error) {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(absolute)
+}
+
+// listedSong reports whether a playlist lists the file, which covers songs referenced
+// from outside the library roots. realPath must be canonical, listed paths are resolved
+// too, so a request only matches the file a listed path actually points to.
+func (a *App) listedSong(realPath string) bool {
+	a.playlistMutex.RLock()
+	var listed []string
+	for _, playlist := range a.playlistCache {
+		for _, song := range playlist.Songs {
+			listed = append(listed, song.FilePath)
+		}
+	}
+	a.playlistMutex.RUnlock()
+
+	key := pathKey(realPath)
+	for _, filePath := range listed {
+		if realListed, err := canonicalPath(filePath); err == nil && pathKey(realListed) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLibraryFile makes sure a path from the frontend is an audio file in the library,
+// so a compromised webview can't have arbitrary files read back to it
+func (a *App) checkLibraryFile(filePath string) error {
+	if !isSupportedAudioFile(filePath) {
+		return fmt.Errorf("not an audio file: %s", filePath)
+	}
+	realPath, err := canonicalPath(filePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("song file not found: %s", filePath)
+	}
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %v", filePath, err)
+	}
+	if !isSupportedAudioFile(realPath) {
+		return fmt.Errorf("not an audio file: %s", filePath)
+	}
+
+	for _, root := range a.libraryRoots() {
+		realRoot, err := canonicalPath(root)
+		if err != nil {
+			continue
+		}
+		if _, ok := pathInside(realRoot, realPath); ok {
+			return nil
+		}
+	}
+	if a.listedSong(realPath) {
+		return nil
+	}
+	return fmt.Errorf("file is outside the library: %s", filePath)
+}
+
+// resolveLibraryFile resolves a track reference from the frontend to a file that may be
+// served, read or retagged, see checkLibraryFile
+func (a *App) resolveLibraryFile(ref string) (string, error) {
+	filePath, err := a.resolveTrackRef(ref)
+	if err != nil {
+		return "", err
+	}
+	if err := a.checkLibraryFile(filePath); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// folderCoverNames are the image names used as cover art for songs without an embedded
+// one, best first. An image named after the song in the playlist's covers/ folder wins
+// over all of them.
+var folderCoverNames = []string{"cover", "folder", "album", "front"}
+
+// folderCoverTypes maps the image extensions looked for to their MIME types
+var folderCoverTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+}
+
+// folderCoverCacheSize is how many folder listings and folder image hashes are kept, so
+// a scan doesn't list the folder and read its image again for every song
+const folderCoverCacheSize = 256
+
+// folderImages returns the image files of a folder, cached until the folder changes
+func (a *App) folderImages(dir string) []string {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil
+	}
+	key := "dir|" + dir + "|" + statVersion(info)
+	if cached, ok := a.covers.folders.get(key); ok {
+		return cached.([]string)
+	}
+
+	var images []string
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if _, ok := folderCoverTypes[strings.ToLower(filepath.Ext(entry.Name()))]; ok && !entry.IsDir() {
+			images = append(images, entry.Name())
+		}
+	}
+	a.covers.folders.put(key, images)
+	return images
+}
+
+// pickFolderCover returns the best image of a folder for a song, "" when none fits
+func (a *App) pickFolderCover(dir string, songName string) string {
+	best := ""
+	bestRank := len(folderCoverNames)
+	for _, name := range a.folderImages(dir) {
+		base := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+		rank := -1
+		if base != songName {
+			rank = len(folderCoverNames)
+			for i, coverName := range folderCoverNames {
+				if base == coverName {
+					rank = i
+					break
+				}
+			}
+		}
+		if rank < bestRank {
+			best, bestRank = filepath.Join(dir, name), rank
+		}
+	}
+	return best
+}
+
+// folderCoverPath finds the cover image for a song without embedded art: an image next
+// to the song, then one in the playlist's covers/ folder
+func (a *App) folderCoverPath(filePath string) string {
+	songName := strings.ToLower(strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)))
+	if cover := a.pickFolderCover(filepath.Dir(filePath), songName); cover != "" {
+		return cover
+	}
+	if playlistDir, ok := playlistDirOf(a.GetStaticFolderPath(), filePath); ok {
+		return a.pickFolderCover(filepath.Join(playlistDir, "covers"), songName)
+	}
+	return ""
+}
+
+// folderCoverHash adds a song's folder cover to the cover store and returns its hash,
+// "" when there's no folder cover. Songs of one folder share the image, it's read once.
+func (a *App) folderCoverHash(filePath string) string {
+	coverPath := a.folderCoverPath(filePath)
+	if coverPath == "" {
+		return ""
+	}
+	key := "img|" + coverPath + "|" + fileVersion(coverPath)
+	if cached, ok := a.covers.folders.get(key); ok {
+		if hash := cached.(string); hash != "" {
+			if _, ok := a.coverByHash(hash); ok {
+				return hash
+			}
+		}
+	}
+
+	data, err := os.ReadFile(coverPath)
+	if err != nil {
+		logError("Error reading folder cover %s: %v", coverPath, err)
+		return ""
+	}
+	hash := a.addCover(folderCoverTypes[strings.ToLower(filepath.Ext(coverPath))], data)
+	a.covers.folders.put(key, hash)
+	return hash
+}
@@ -15,7 +15,7 @@ import {
   ChevronRight,
   Cat
 } from 'lucide-react'
-import { GetPlaylists, GetSongFileURL, NotifyPlaybackState, UpdatePlaybackPosition, GetSettings, UpdateSettings, CheckFFmpegInstalled, ClearAudioCache, GetCacheInfo, UpdatePlaylistPosition, GetPlaylistPosition } from '../wailsjs/go/main/App'
+import { GetPlaylists, GetSongFileURL, NotifyPlaybackState, UpdatePlaybackPosition, GetSettings, UpdateSettings, GetSystemStatus, ClearAudioCache, GetCacheInfo, UpdatePlaylistPosition, GetPlaylistPosition } from '../wailsjs/go/main/App'
 import { LogPrint as WailsLogPrint } from '../wailsjs/runtime/runtime'
 
 // Fallback for development mode
@@ -206,7 +206,8 @@ function App() {
     loadSettings()
     
     // Check if FFmpeg is available
-    CheckFFmpegInstalled().then(available => {
+    GetSystemStatus().then(status => {
+      const available = status.ffmpeg.available
       setFfmpegAvailable(available)
       if (!available) {
         LogPrint('FFmpeg not found. Audio effects will not work.')
@@ -1321,9 +1322,10 @@ function App() {
                     <button
                       onClick={async () => {
                         try {
-                          const { GetCoverServerInfo } = await import('../wailsjs/go/main/App')
-                          const info = await GetCoverServerInfo()
+                          const status = await GetSystemStatus()
+                          const info = status.coverServer
                           LogPrint(`Cover Server Info: ${JSON.stringify(info, null, 2)}`)
+                          LogPrint(`Discord RPC Status: ${JSON.stringify(status.discord, null, 2)}`)
                           
                           // Test the server
                           if (info.testURL) {
@@ -2,8 +2,6 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 
-export function CheckFFmpegInstalled():Promise<boolean>;
-
 export function Cleanup():Promise<void>;
 
 export function ClearAudioCache():Promise<void>;
@@ -12,10 +10,6 @@ export function GetAppInfo():Promise<Record<string, string>>;
 
 export function GetCacheInfo():Promise<Record<string, any>>;
 
-export function GetCoverServerInfo():Promise<Record<string, any>>;
-
-export function GetDiscordRPCStatus():Promise<Record<string, any>>;
-
 export function GetPlaylistPosition(arg1:string):Promise<number>;
 
 export function GetPlaylists():Promise<Array<main.Playlist>>;
@@ -30,6 +24,8 @@ export function GetSongPositions(arg1:string):Promise<Record<string, number>>;
 
 export function GetStaticFolderPath():Promise<string>;
 
+export function GetSystemStatus():Promise<Record<string, any>>;
+
 export function NotifyPlaybackState(arg1:main.Song,arg2:boolean):Promise<void>;
 
 export function ResetSettings():Promise<void>;
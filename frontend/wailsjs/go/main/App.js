@@ -2,10 +2,6 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
-export function CheckFFmpegInstalled() {
-  return window['go']['main']['App']['CheckFFmpegInstalled']();
-}
-
 export function Cleanup() {
   return window['go']['main']['App']['Cleanup']();
 }
@@ -22,14 +18,6 @@ export function GetCacheInfo() {
   return window['go']['main']['App']['GetCacheInfo']();
 }
 
-export function GetCoverServerInfo() {
-  return window['go']['main']['App']['GetCoverServerInfo']();
-}
-
-export function GetDiscordRPCStatus() {
-  return window['go']['main']['App']['GetDiscordRPCStatus']();
-}
-
 export function GetPlaylistPosition(arg1) {
   return window['go']['main']['App']['GetPlaylistPosition'](arg1);
 }
@@ -58,6 +46,10 @@ export function GetStaticFolderPath() {
   return window['go']['main']['App']['GetStaticFolderPath']();
 }
 
+export function GetSystemStatus() {
+  return window['go']['main']['App']['GetSystemStatus']();
+}
+
 export function NotifyPlaybackState(arg1, arg2) {
   return window['go']['main']['App']['NotifyPlaybackState'](arg1, arg2);
 }
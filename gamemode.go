@@ -0,0 +1,186 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventGameModeChanged tells the frontend game mode started or ended
+const eventGameModeChanged = "gamemode:changed"
+
+const (
+	gameModePollInterval     = 5 * time.Second
+	gameModeGPUThreshold     = 80               // GPU utilization percentage that counts as gaming
+	gameModePresenceInterval = 60 * time.Second // Slowest Discord position updates are sent at while gaming
+)
+
+// Reasons game mode is active
+const (
+	gameReasonFullscreen = "fullscreen"
+	gameReasonGPULoad    = "gpu-load"
+)
+
+// Work deferred until game mode ends, one entry per kind so repeats don't pile up
+const (
+	deferredCoverUpload    = "cover-upload"
+	deferredIndexPrune     = "index-prune"
+	deferredThumbnailPrune = "thumbnail-prune"
+)
+
+// GameModeState describes whether heavy background work is held back for a game
+type GameModeState struct {
+	Active bool   `json:"active"`
+	Reason string `json:"reason,omitempty"` // "fullscreen" or "gpu-load"
+}
+
+// gameModeMonitor tracks game mode between polls
+type gameModeMonitor struct {
+	state        GameModeState
+	deferred     map[string]func()
+	lastPresence time.Time // Last position update sent to Discord while gaming
+	mutex        sync.Mutex
+}
+
+// watchGameMode polls for fullscreen apps and GPU load
+func (a *App) watchGameMode() {
+	ticker := time.NewTicker(gameModePollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.checkGameMode()
+	}
+}
+
+// checkGameMode enters or leaves game mode. Leaving it resumes background media jobs
+// and runs the work deferred meanwhile.
+func (a *App) checkGameMode() {
+	reason := ""
+	if a.settings.GameMode {
+		reason = detectGame()
+	}
+
+	a.gameMode.mutex.Lock()
+	next := GameModeState{Active: reason != "", Reason: reason}
+	changed := next != a.gameMode.state
+	a.gameMode.state = next
+	var deferred map[string]func()
+	if changed && !next.Active {
+		deferred = a.gameMode.deferred
+		a.gameMode.deferred = nil
+	}
+	a.gameMode.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	mediaJobs.setPaused(next.Active)
+	if next.Active {
+		logInfo("Game mode: deferring background work (%s)", reason)
+	} else {
+		logInfo("Game mode: resuming background work, %d deferred tasks", len(deferred))
+		for _, task := range deferred {
+			go task()
+		}
+	}
+	a.emitEvent(eventGameModeChanged, next)
+}
+
+// detectGame returns why a game seems to be running, or ""
+func detectGame() string {
+	switch runtime.GOOS {
+	case "linux":
+		if x11FullscreenActive() {
+			return gameReasonFullscreen
+		}
+	case "windows":
+		if userNotificationState() == notifyBlockedFullscreen {
+			return gameReasonFullscreen
+		}
+	}
+	if gpuBusyPercent() >= gameModeGPUThreshold {
+		return gameReasonGPULoad
+	}
+	return ""
+}
+
+// gpuBusyPercent returns the highest utilization of any GPU, from the amdgpu driver's
+// sysfs counter or nvidia-smi, or 0 when neither is available
+func gpuBusyPercent() int {
+	busiest := 0
+	if runtime.GOOS == "linux" {
+		counters, _ := filepath.Glob("/sys/class/drm/card*/device/gpu_busy_percent")
+		for _, counter := range counters {
+			data, err := os.ReadFile(counter)
+			if err != nil {
+				continue
+			}
+			if busy, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && busy > busiest {
+				busiest = busy
+			}
+		}
+	}
+
+	// One line per GPU, e.g. "97"
+	output, err := exec.Command("nvidia-smi", "--query-gpu=utilization.gpu", "--format=csv,noheader,nounits").Output()
+	if err == nil {
+		for _, line := range strings.Split(string(output), "\n") {
+			if busy, err := strconv.Atoi(strings.TrimSpace(line)); err == nil && busy > busiest {
+				busiest = busy
+			}
+		}
+	}
+	return busiest
+}
+
+// gameModeActive reports whether a game is running and game mode is enabled
+func (a *App) gameModeActive() bool {
+	a.gameMode.mutex.Lock()
+	defer a.gameMode.mutex.Unlock()
+	return a.gameMode.state.Active
+}
+
+// deferForGame holds task back until game mode ends, replacing an earlier task of the
+// same kind. It returns false, and leaves running the task to the caller, when no game
+// is running.
+func (a *App) deferForGame(kind string, task func()) bool {
+	a.gameMode.mutex.Lock()
+	defer a.gameMode.mutex.Unlock()
+	if !a.gameMode.state.Active {
+		return false
+	}
+	if a.gameMode.deferred == nil {
+		a.gameMode.deferred = make(map[string]func())
+	}
+	a.gameMode.deferred[kind] = task
+	return true
+}
+
+// throttlePresenceForGame reports whether a Discord position update should be skipped
+// because one was sent recently while gaming. Track changes aren't throttled.
+func (a *App) throttlePresenceForGame() bool {
+	a.gameMode.mutex.Lock()
+	defer a.gameMode.mutex.Unlock()
+	if !a.gameMode.state.Active {
+		return false
+	}
+	now := time.Now()
+	if now.Sub(a.gameMode.lastPresence) < gameModePresenceInterval {
+		return true
+	}
+	a.gameMode.lastPresence = now
+	return false
+}
+
+// GetGameModeState reports whether background work is currently held back for a game
+func (a *App) GetGameModeState() GameModeState {
+	a.gameMode.mutex.Lock()
+	defer a.gameMode.mutex.Unlock()
+	return a.gameMode.state
+}
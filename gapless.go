@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dhowden/tag"
+)
+
+// GaplessInfo holds encoder delay and padding so track boundaries can be trimmed exactly
+type GaplessInfo struct {
+	EncoderDelay   int    `json:"encoderDelay"`           // Silent priming samples at the start
+	EncoderPadding int    `json:"encoderPadding"`         // Silent padding samples at the end
+	TotalSamples   int64  `json:"totalSamples,omitempty"` // Original sample count, when known
+	SampleRate     int    `json:"sampleRate,omitempty"`
+	Source         string `json:"source"` // "lame" or "itunsmpb"
+}
+
+// MPEG audio sample rates indexed by [version][rate index]
+var mpegSampleRates = map[int][3]int{
+	1: {44100, 48000, 32000}, // MPEG-1
+	2: {22050, 24000, 16000}, // MPEG-2
+	3: {11025, 12000, 8000},  // MPEG-2.5
+}
+
+// mp3FrameHeader is the decoded 4-byte header of an MPEG audio frame
+type mp3FrameHeader struct {
+	version    int // 1, 2 or 3 (2.5)
+	layer      int
+	sampleRate int
+	mono       bool
+}
+
+// parseMP3FrameHeader decodes an MPEG audio frame header, ok is false if b isn't one
+func parseMP3FrameHeader(b []byte) (mp3FrameHeader, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mp3FrameHeader{}, false
+	}
+
+	var header mp3FrameHeader
+	switch (b[1] >> 3) & 0x03 {
+	case 0:
+		header.version = 3
+	case 2:
+		header.version = 2
+	case 3:
+		header.version = 1
+	default:
+		return mp3FrameHeader{}, false
+	}
+
+	switch (b[1] >> 1) & 0x03 {
+	case 1:
+		header.layer = 3
+	case 2:
+		header.layer = 2
+	case 3:
+		header.layer = 1
+	default:
+		return mp3FrameHeader{}, false
+	}
+
+	rateIndex := (b[2] >> 2) & 0x03
+	if rateIndex == 3 || (b[2]>>4) == 0x0F {
+		return mp3FrameHeader{}, false
+	}
+	header.sampleRate = mpegSampleRates[header.version][rateIndex]
+	header.mono = (b[3]>>6)&0x03 == 3
+
+	return header, true
+}
+
+// sideInfoSize returns the Layer III side information size following the header
+func (h mp3FrameHeader) sideInfoSize() int {
+	if h.version == 1 {
+		if h.mono {
+			return 17
+		}
+		return 32
+	}
+	if h.mono {
+		return 9
+	}
+	return 17
+}
+
+// samplesPerFrame returns how many samples a Layer III frame holds
+func (h mp3FrameHeader) samplesPerFrame() int {
+	if h.version == 1 {
+		return 1152
+	}
+	return 576
+}
+
+// skipID3v2 returns the offset of the first byte after any leading ID3v2 tag
+func skipID3v2(data []byte) int {
+	if len(data) < 10 || string(data[:3]) != "ID3" {
+		return 0
+	}
+	size := int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F)
+	offset := 10 + size
+	if data[5]&0x10 != 0 {
+		offset += 10 // Footer present
+	}
+	return offset
+}
+
+// findFirstMP3Frame locates the first MPEG audio frame in data, returning its offset in data
+func findFirstMP3Frame(data []byte) (int, mp3FrameHeader, bool) {
+	for i := 0; i+4 <= len(data); i++ {
+		if header, ok := parseMP3FrameHeader(data[i:]); ok && header.layer == 3 {
+			return i, header, true
+		}
+	}
+	return 0, mp3FrameHeader{}, false
+}
+
+// mp3HeadWindow is how much audio after the ID3v2 tag is read to find the first frame
+// and its Xing/LAME header
+const mp3HeadWindow = 16 * 1024
+
+// readMP3Head reads the start of an MP3's audio, right after the ID3v2 tag. The tag
+// itself, which can hold megabytes of cover art, is skipped.
+func readMP3Head(file *os.File) ([]byte, error) {
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(file, head); err != nil {
+		return nil, err
+	}
+	tagEnd := skipID3v2(head)
+
+	data := make([]byte, mp3HeadWindow)
+	n, err := file.ReadAt(data, int64(tagEnd))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// readLAMEGapless reads encoder delay/padding from the LAME extension of an MP3's Xing/Info header
+func readLAMEGapless(filePath string) (*GaplessInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := readMP3Head(file)
+	if err != nil {
+		return nil, err
+	}
+
+	frameStart, header, ok := findFirstMP3Frame(data)
+	if !ok {
+		return nil, nil
+	}
+
+	xingStart := frameStart + 4 + header.sideInfoSize()
+	if xingStart+8 > len(data) {
+		return nil, nil
+	}
+	marker := string(data[xingStart : xingStart+4])
+	if marker != "Xing" && marker != "Info" {
+		return nil, nil
+	}
+
+	// Skip the optional Xing fields that are present
+	flags := binary.BigEndian.Uint32(data[xingStart+4 : xingStart+8])
+	lameStart := xingStart + 8
+	var frames int64
+	if flags&0x01 != 0 {
+		if lameStart+4 <= len(data) {
+			frames = int64(binary.BigEndian.Uint32(data[lameStart : lameStart+4]))
+		}
+		lameStart += 4
+	}
+	if flags&0x02 != 0 {
+		lameStart += 4
+	}
+	if flags&0x04 != 0 {
+		lameStart += 100
+	}
+	if flags&0x08 != 0 {
+		lameStart += 4
+	}
+
+	// LAME extension: 9 byte encoder string, then delay/padding packed into 3 bytes at +21
+	if lameStart+24 > len(data) {
+		return nil, nil
+	}
+	encoder := data[lameStart : lameStart+4]
+	if !bytes.Equal(encoder, []byte("LAME")) && !bytes.Equal(encoder, []byte("Lavc")) && !bytes.Equal(encoder, []byte("Lavf")) {
+		return nil, nil
+	}
+
+	packed := data[lameStart+21 : lameStart+24]
+	info := &GaplessInfo{
+		EncoderDelay:   int(packed[0])<<4 | int(packed[1])>>4,
+		EncoderPadding: int(packed[1]&0x0F)<<8 | int(packed[2]),
+		SampleRate:     header.sampleRate,
+		Source:         "lame",
+	}
+
+	if frames > 0 {
+		total := frames*int64(header.samplesPerFrame()) - int64(info.EncoderDelay) - int64(info.EncoderPadding)
+		if total > 0 {
+			info.TotalSamples = total
+		}
+	}
+
+	return info, nil
+}
+
+// parseITunSMPB parses an iTunSMPB value: " 00000000 00000840 000001CA 0000000000A3C5F6 ..."
+func parseITunSMPB(value string) *GaplessInfo {
+	value = strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E {
+			return ' '
+		}
+		return r
+	}, value)
+
+	fields := strings.Fields(value)
+	if len(fields) < 4 {
+		return nil
+	}
+
+	delay, err1 := strconv.ParseInt(fields[1], 16, 64)
+	padding, err2 := strconv.ParseInt(fields[2], 16, 64)
+	total, err3 := strconv.ParseInt(fields[3], 16, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil
+	}
+
+	return &GaplessInfo{
+		EncoderDelay:   int(delay),
+		EncoderPadding: int(padding),
+		TotalSamples:   total,
+		Source:         "itunsmpb",
+	}
+}
+
+// findITunSMPB looks for an iTunSMPB value in MP4 custom atoms or ID3v2 comment frames
+func findITunSMPB(metadata tag.Metadata) string {
+	for key, value := range metadata.Raw() {
+		if key == "iTunSMPB" {
+			return fmt.Sprint(value)
+		}
+		if comm, ok := value.(*tag.Comm); ok && comm.Description == "iTunSMPB" {
+			return comm.Text
+		}
+	}
+	return ""
+}
+
+// extractGaplessInfo returns gapless playback info for a song, preferring iTunSMPB over the LAME header
+func (a *App) extractGaplessInfo(filePath string, metadata tag.Metadata) *GaplessInfo {
+	if metadata != nil {
+		if value := findITunSMPB(metadata); value != "" {
+			if info := parseITunSMPB(value); info != nil {
+				return info
+			}
+		}
+	}
+
+	if strings.ToLower(filepath.Ext(filePath)) == ".mp3" {
+		info, err := readLAMEGapless(filePath)
+		if err != nil {
+			logError("Error reading gapless info from %s: %v", filePath, err)
+			return nil
+		}
+		return info
+	}
+
+	return nil
+}
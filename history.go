@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryTracksPerCard limits how many tracks each on-this-day card shows
+const memoryTracksPerCard = 10
+
+// historyPageSize is how many plays a page of GetHistory holds
+const historyPageSize = 50
+
+// HistoryEntry is a single play in the listening history
+type HistoryEntry struct {
+	FilePath    string    `json:"filePath"`
+	Title       string    `json:"title"`
+	Artist      string    `json:"artist"`
+	Album       string    `json:"album"`
+	DurationSec int       `json:"durationSec,omitempty"`
+	PlayedAt    time.Time `json:"playedAt"`
+	Reason      string    `json:"reason,omitempty"` // How playback got to the song, see TrackTransition
+}
+
+// historyStore keeps the listening history in an append-only JSON lines file
+type historyStore struct {
+	path    string
+	entries []HistoryEntry // Oldest first
+	mutex   sync.RWMutex
+}
+
+// newHistoryStore creates a history store backed by the given file
+func newHistoryStore(path string) *historyStore {
+	return &historyStore{path: path}
+}
+
+// load reads the history file, skipping lines that can't be parsed
+func (h *historyStore) load() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	file, err := os.Open(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error opening history file: %v", err)
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading history file: %v", err)
+	}
+
+	h.entries = entries
+	return nil
+}
+
+// add appends a play to the history
+func (h *historyStore) add(entry HistoryEntry) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error marshaling history entry: %v", err)
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening history file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing history file: %v", err)
+	}
+
+	h.entries = append(h.entries, entry)
+	return nil
+}
+
+// snapshot returns a copy of all history entries, oldest first
+func (h *historyStore) snapshot() []HistoryEntry {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	entries := make([]HistoryEntry, len(h.entries))
+	copy(entries, h.entries)
+	return entries
+}
+
+// HistoryPage is one page of the listening history, newest plays first
+type HistoryPage struct {
+	Page    int            `json:"page"`
+	Total   int            `json:"total"` // Plays in the whole history
+	Entries []HistoryEntry `json:"entries"`
+	HasMore bool           `json:"hasMore"`
+}
+
+// GetHistory returns a page of the listening history for the history view, newest plays
+// first. Pages start at 0.
+func (a *App) GetHistory(page int) HistoryPage {
+	if page < 0 {
+		page = 0
+	}
+	a.history.mutex.RLock()
+	defer a.history.mutex.RUnlock()
+
+	total := len(a.history.entries)
+	result := HistoryPage{Page: page, Total: total, Entries: []HistoryEntry{}}
+	// Entries are stored oldest first
+	end := total - page*historyPageSize
+	start := end - historyPageSize
+	if start < 0 {
+		start = 0
+	}
+	for i := end - 1; i >= start; i-- {
+		result.Entries = append(result.Entries, a.history.entries[i])
+	}
+	result.HasMore = start > 0
+	return result
+}
+
+// recordPlay adds a newly started song to the listening history, with the reason of the
+// transition to it
+func (a *App) recordPlay(song *Song, reason string) {
+	entry := HistoryEntry{
+		FilePath:    song.FilePath,
+		Title:       song.Title,
+		Artist:      song.Artist,
+		Album:       song.Album,
+		DurationSec: song.DurationSec,
+		PlayedAt:    time.Now(),
+		Reason:      reason,
+	}
+	if err := a.history.add(entry); err != nil {
+		logError("Failed to record play history: %v", err)
+	}
+	if err := a.stats.recordPlay(song.FilePath, entry.PlayedAt); err != nil {
+		logError("Failed to save play stats: %v", err)
+	}
+	a.recordCollectionProgress(*song)
+}
+
+// MemoryTrack is a track listened to on a past date
+type MemoryTrack struct {
+	FilePath  string `json:"filePath"`
+	Title     string `json:"title"`
+	Artist    string `json:"artist"`
+	Album     string `json:"album"`
+	PlayCount int    `json:"playCount"`
+}
+
+// MemoryCard groups what the user listened to on one past date
+type MemoryCard struct {
+	Label     string        `json:"label"` // e.g. "1 year ago" or "3 months ago"
+	Date      string        `json:"date"`  // YYYY-MM-DD
+	YearsAgo  int           `json:"yearsAgo,omitempty"`
+	MonthsAgo int           `json:"monthsAgo,omitempty"`
+	Plays     int           `json:"plays"`
+	Tracks    []MemoryTrack `json:"tracks"`
+}
+
+// memoryOffset returns how many months before now the date falls on the same day of month,
+// or 0 if it isn't an "on this day" date
+func memoryOffset(date time.Time, now time.Time) int {
+	if date.Day() != now.Day() {
+		return 0
+	}
+	months := (now.Year()-date.Year())*12 + int(now.Month()) - int(date.Month())
+	if months <= 0 {
+		return 0
+	}
+	return months
+}
+
+// GetOnThisDay returns what the user was listening to on this date in previous
+// years, and on this day of the month in the past year, most recent first
+func (a *App) GetOnThisDay() []MemoryCard {
+	now := time.Now()
+	cards := make(map[int]*MemoryCard) // months ago -> card
+	tracks := make(map[int]map[string]*MemoryTrack)
+
+	for _, entry := range a.history.snapshot() {
+		played := entry.PlayedAt.Local()
+		months := memoryOffset(played, now)
+		// Yearly memories go back forever, monthly ones only within the last year
+		if months == 0 || (months > 12 && months%12 != 0) {
+			continue
+		}
+
+		card, exists := cards[months]
+		if !exists {
+			card = &MemoryCard{Date: played.Format("2006-01-02")}
+			if months%12 == 0 {
+				card.YearsAgo = months / 12
+				card.Label = pluralize(card.YearsAgo, "year") + " ago"
+			} else {
+				card.MonthsAgo = months
+				card.Label = pluralize(months, "month") + " ago"
+			}
+			cards[months] = card
+			tracks[months] = make(map[string]*MemoryTrack)
+		}
+		card.Plays++
+
+		track, exists := tracks[months][entry.FilePath]
+		if !exists {
+			track = &MemoryTrack{
+				FilePath: entry.FilePath,
+				Title:    entry.Title,
+				Artist:   entry.Artist,
+				Album:    entry.Album,
+			}
+			tracks[months][entry.FilePath] = track
+		}
+		track.PlayCount++
+	}
+
+	result := make([]MemoryCard, 0, len(cards))
+	for months, card := range cards {
+		list := make([]MemoryTrack, 0, len(tracks[months]))
+		for _, track := range tracks[months] {
+			list = append(list, *track)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].PlayCount != list[j].PlayCount {
+				return list[i].PlayCount > list[j].PlayCount
+			}
+			return list[i].Title < list[j].Title
+		})
+		if len(list) > memoryTracksPerCard {
+			list = list[:memoryTracksPerCard]
+		}
+		card.Tracks = list
+		result = append(result, *card)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Date > result[j].Date
+	})
+	return result
+}
+
+// pluralize formats a count with a singular or plural unit
+func pluralize(count int, unit string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", count, unit)
+}
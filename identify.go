@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	acoustIDLookupURL   = "https://api.acoustid.org/v2/lookup"
+	musicBrainzURL      = "https://musicbrainz.org/ws/2/recording/"
+	musicBrainzAgent    = "Static/1.0.0 ( https://github.com/yasakei/static )"
+	identifyMaxMatches  = 5
+	identifyMinScore    = 0.5 // AcoustID scores below this are usually unrelated songs
+	identifyEnrichCount = 3   // Matches looked up on MusicBrainz for release details
+	musicBrainzInterval = 1100 * time.Millisecond
+)
+
+// identifyHTTPClient is used for AcoustID and MusicBrainz requests
+var identifyHTTPClient = &http.Client{Timeout: 20 * time.Second}
+
+// TrackMatch is a candidate identity for an unknown file
+type TrackMatch struct {
+	RecordingID string  `json:"recordingId"` // MusicBrainz recording MBID
+	Title       string  `json:"title"`
+	Artist      string  `json:"artist"`
+	Album       string  `json:"album"`
+	ReleaseDate string  `json:"releaseDate,omitempty"`
+	DurationSec int     `json:"durationSec,omitempty"`
+	Score       float64 `json:"score"` // AcoustID confidence, 0.0 to 1.0
+}
+
+// IdentifyResult answers "what is this song?" for a file
+type IdentifyResult struct {
+	FilePath string       `json:"filePath"`
+	Matches  []TrackMatch `json:"matches"` // Best match first, empty when nothing was recognized
+}
+
+// acoustIDResponse is the subset of the AcoustID lookup response we use
+type acoustIDResponse struct {
+	Status string `json:"status"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Results []struct {
+		Score      float64 `json:"score"`
+		Recordings []struct {
+			ID       string  `json:"id"`
+			Title    string  `json:"title"`
+			Duration float64 `json:"duration"`
+			Artists  []struct {
+				Name string `json:"name"`
+			} `json:"artists"`
+			ReleaseGroups []struct {
+				Title string `json:"title"`
+			} `json:"releasegroups"`
+		} `json:"recordings"`
+	} `json:"results"`
+}
+
+// fingerprintFile runs Chromaprint's fpcalc and returns the fingerprint and duration
+func fingerprintFile(filePath string) (string, int, error) {
+	output, err := exec.Command("fpcalc", "-json", filePath).Output()
+	if err != nil {
+		if _, lookErr := exec.LookPath("fpcalc"); lookErr != nil {
+			return "", 0, fmt.Errorf("install Chromaprint (fpcalc) to identify songs")
+		}
+		return "", 0, fmt.Errorf("fpcalc failed: %v", err)
+	}
+
+	var result struct {
+		Duration    float64 `json:"duration"`
+		Fingerprint string  `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return "", 0, fmt.Errorf("failed to parse fpcalc output: %v", err)
+	}
+	return result.Fingerprint, int(result.Duration), nil
+}
+
+// lookupAcoustID sends a fingerprint to AcoustID and returns the candidate recordings
+func lookupAcoustID(apiKey string, fingerprint string, duration int) ([]TrackMatch, error) {
+	params := url.Values{}
+	params.Set("client", apiKey)
+	params.Set("meta", "recordings releasegroups")
+	params.Set("duration", strconv.Itoa(duration))
+	params.Set("fingerprint", fingerprint)
+
+	// Fingerprints are long, so they go in a form body rather than the URL
+	resp, err := identifyHTTPClient.PostForm(acoustIDLookupURL, params)
+	if err != nil {
+		return nil, fmt.Errorf("AcoustID lookup failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result acoustIDResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse AcoustID response: %v", err)
+	}
+	if result.Status != "ok" {
+		message := "unknown error"
+		if result.Error != nil {
+			message = result.Error.Message
+		}
+		return nil, fmt.Errorf("AcoustID error: %s", message)
+	}
+
+	var matches []TrackMatch
+	seen := make(map[string]bool)
+	for _, res := range result.Results {
+		if res.Score < identifyMinScore {
+			continue
+		}
+		for _, recording := range res.Recordings {
+			if recording.Title == "" || seen[recording.ID] {
+				continue
+			}
+			seen[recording.ID] = true
+
+			var artists []string
+			for _, artist := range recording.Artists {
+				artists = append(artists, artist.Name)
+			}
+			match := TrackMatch{
+				RecordingID: recording.ID,
+				Title:       recording.Title,
+				Artist:      strings.Join(artists, ", "),
+				DurationSec: int(recording.Duration),
+				Score:       res.Score,
+			}
+			if len(recording.ReleaseGroups) > 0 {
+				match.Album = recording.ReleaseGroups[0].Title
+			}
+			matches = append(matches, match)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > identifyMaxMatches {
+		matches = matches[:identifyMaxMatches]
+	}
+	return matches, nil
+}
+
+// enrichFromMusicBrainz fills in the earliest release of a recording
+func enrichFromMusicBrainz(match *TrackMatch) error {
+	req, err := http.NewRequest("GET", musicBrainzURL+url.PathEscape(match.RecordingID)+"?inc=releases&fmt=json", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", musicBrainzAgent) // Required by MusicBrainz
+
+	resp, err := identifyHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("MusicBrainz lookup failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("MusicBrainz returned HTTP %d", resp.StatusCode)
+	}
+
+	var recording struct {
+		Releases []struct {
+			Title string `json:"title"`
+			Date  string `json:"date"`
+		} `json:"releases"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&recording); err != nil {
+		return fmt.Errorf("failed to parse MusicBrainz response: %v", err)
+	}
+
+	for _, release := range recording.Releases {
+		if release.Date == "" {
+			continue
+		}
+		if match.ReleaseDate == "" || release.Date < match.ReleaseDate {
+			match.ReleaseDate = release.Date
+			if match.Album == "" {
+				match.Album = release.Title
+			}
+		}
+	}
+	return nil
+}
+
+// IdentifyTrack fingerprints a file and looks it up on AcoustID and MusicBrainz,
+// returning candidate identities with confidence scores
+func (a *App) IdentifyTrack(filePath string) (IdentifyResult, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return IdentifyResult{}, err
+	}
+
+	apiKey := a.settings.AcoustIDAPIKey
+	if apiKey == "" {
+		return IdentifyResult{}, fmt.Errorf("an AcoustID API key is required to identify songs")
+	}
+
+	fingerprint, duration, err := fingerprintFile(filePath)
+	if err != nil {
+		return IdentifyResult{}, err
+	}
+
+	matches, err := lookupAcoustID(apiKey, fingerprint, duration)
+	if err != nil {
+		return IdentifyResult{}, err
+	}
+
+	// MusicBrainz allows one request per second, so only the best matches are enriched
+	for i := range matches {
+		if i >= identifyEnrichCount {
+			break
+		}
+		if i > 0 {
+			time.Sleep(musicBrainzInterval)
+		}
+		if err := enrichFromMusicBrainz(&matches[i]); err != nil {
+			logWarn("Identify: %v", err)
+		}
+	}
+
+	if matches == nil {
+		matches = []TrackMatch{}
+	}
+	logInfo("Identify: %d candidates for %s", len(matches), filePath)
+	return IdentifyResult{FilePath: filePath, Matches: matches}, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"sync"
+)
+
+// defaultMediaJobCPUFraction leaves half the cores free for playback and the UI
+const defaultMediaJobCPUFraction = 0.5
+
+// Media job priorities. Foreground jobs are ones the user is waiting on (effects for the
+// song about to play), background jobs are prefetches and analysis.
+const (
+	jobForeground = iota
+	jobBackground
+)
+
+// MediaJobStatus reports the FFmpeg/stem job pool
+type MediaJobStatus struct {
+	Workers int  `json:"workers"` // Jobs allowed to run at once
+	Running int  `json:"running"`
+	Queued  int  `json:"queued"`
+	Paused  bool `json:"paused"` // Background jobs are held back by game mode
+}
+
+// jobScheduler limits how many FFmpeg and stem separation processes run at once
+type jobScheduler struct {
+	workers          int
+	running          int
+	queuedForeground int
+	queuedBackground int
+	paused           bool // Background jobs wait while a game is running
+	mutex            sync.Mutex
+	cond             *sync.Cond
+}
+
+// mediaJobs schedules every FFmpeg, demucs and spleeter run
+var mediaJobs = newJobScheduler()
+
+// newJobScheduler creates a scheduler using the default CPU budget
+func newJobScheduler() *jobScheduler {
+	s := &jobScheduler{workers: workersForFraction(defaultMediaJobCPUFraction)}
+	s.cond = sync.NewCond(&s.mutex)
+	return s
+}
+
+// workersForFraction converts a fraction of the CPU cores into a worker count, at least one
+func workersForFraction(fraction float64) int {
+	return int(math.Max(1, math.Floor(float64(runtime.NumCPU())*fraction)))
+}
+
+// setCPUFraction resizes the pool, running jobs finish normally
+func (s *jobScheduler) setCPUFraction(fraction float64) {
+	s.mutex.Lock()
+	s.workers = workersForFraction(fraction)
+	s.mutex.Unlock()
+	s.cond.Broadcast()
+}
+
+// setPaused holds queued background jobs back or lets them start again, running jobs
+// and foreground jobs aren't affected
+func (s *jobScheduler) setPaused(paused bool) {
+	s.mutex.Lock()
+	s.paused = paused
+	s.mutex.Unlock()
+	s.cond.Broadcast()
+}
+
+// do runs job once a worker is free. Foreground jobs go ahead of queued background jobs.
+func (s *jobScheduler) do(priority int, job func()) {
+	s.mutex.Lock()
+	if priority == jobForeground {
+		s.queuedForeground++
+		for s.running >= s.workers {
+			s.cond.Wait()
+		}
+		s.queuedForeground--
+	} else {
+		s.queuedBackground++
+		for s.running >= s.workers || s.queuedForeground > 0 || s.paused {
+			s.cond.Wait()
+		}
+		s.queuedBackground--
+	}
+	s.running++
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		s.running--
+		s.mutex.Unlock()
+		s.cond.Broadcast()
+	}()
+	job()
+}
+
+// status returns the current pool usage
+func (s *jobScheduler) status() MediaJobStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return MediaJobStatus{
+		Workers: s.workers,
+		Running: s.running,
+		Queued:  s.queuedForeground + s.queuedBackground,
+		Paused:  s.paused,
+	}
+}
+
+// GetMediaJobStatus reports how many FFmpeg/stem jobs are running and waiting
+func (a *App) GetMediaJobStatus() MediaJobStatus {
+	return mediaJobs.status()
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dhowden/tag"
+)
+
+// languageCodes maps ISO 639-1 codes and English names to the ISO 639-2 codes ID3 TLAN
+// uses, so "ja", "Japanese" and "jpn" all end up as "jpn"
+var languageCodes = map[string]string{
+	"ar": "ara", "arabic": "ara",
+	"de": "deu", "ger": "deu", "german": "deu",
+	"en": "eng", "english": "eng",
+	"es": "spa", "spanish": "spa",
+	"fi": "fin", "finnish": "fin",
+	"fr": "fra", "fre": "fra", "french": "fra",
+	"hi": "hin", "hindi": "hin",
+	"id": "ind", "indonesian": "ind",
+	"it": "ita", "italian": "ita",
+	"ja": "jpn", "japanese": "jpn",
+	"ko": "kor", "korean": "kor",
+	"nl": "nld", "dut": "nld", "dutch": "nld",
+	"pl": "pol", "polish": "pol",
+	"pt": "por", "portuguese": "por",
+	"ru": "rus", "russian": "rus",
+	"sv": "swe", "swedish": "swe",
+	"th": "tha", "thai": "tha",
+	"tr": "tur", "turkish": "tur",
+	"uk": "ukr", "ukrainian": "ukr",
+	"vi": "vie", "vietnamese": "vie",
+	"zh": "zho", "chi": "zho", "chinese": "zho",
+}
+
+// LanguageCount is a language found in the library and how many songs use it
+type LanguageCount struct {
+	Language string `json:"language"` // ISO 639-2 code, "" for songs without one
+	Songs    int    `json:"songs"`
+}
+
+// languageStore keeps languages assigned by hand, which win over the file's tags
+type languageStore struct {
+	path      string
+	languages map[string]string // track ID, or path for files without one -> language
+	mutex     sync.Mutex
+}
+
+// newLanguageStore creates a language store backed by the given file
+func newLanguageStore(path string) *languageStore {
+	return &languageStore{path: path, languages: make(map[string]string)}
+}
+
+// load reads the assigned languages from disk
+func (l *languageStore) load() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading languages: %v", err)
+	}
+	if err := json.Unmarshal(data, &l.languages); err != nil {
+		return fmt.Errorf("error parsing languages: %v", err)
+	}
+	if l.languages == nil {
+		l.languages = make(map[string]string)
+	}
+	return nil
+}
+
+// get returns the language assigned to a song
+func (l *languageStore) get(key string) (string, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	language, ok := l.languages[key]
+	return language, ok
+}
+
+// set assigns a language, or removes the assignment for "", and saves the store
+func (l *languageStore) set(key string, language string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if language == "" {
+		delete(l.languages, key)
+	} else {
+		l.languages[key] = language
+	}
+	return writeJSONFile(l.path, l.languages)
+}
+
+// userDataKey identifies a song in the user data stores, by track ID so languages and
+// notes survive moves
+func userDataKey(song Song) string {
+	if song.TrackID != "" {
+		return song.TrackID
+	}
+	return pathKey(song.FilePath)
+}
+
+// normalizeLanguage turns a language tag into a lower case ISO 639-2 code where it's
+// known. "und", "xxx" and empty values mean no language.
+func normalizeLanguage(value string) string {
+	// TLAN may list several languages, the first is the main one
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == '/' || r == ';' || r == ',' || r == 0
+	})
+	if len(fields) == 0 {
+		return ""
+	}
+	language := strings.TrimSpace(fields[0])
+	if code, ok := languageCodes[language]; ok {
+		return code
+	}
+	if language == "und" || language == "xxx" {
+		return ""
+	}
+	return language
+}
+
+// languageFromTags reads the language tag (ID3 TLAN, Vorbis LANGUAGE) if present
+func languageFromTags(metadata tag.Metadata) string {
+	if metadata == nil {
+		return ""
+	}
+	raw := metadata.Raw()
+	for _, key := range []string{"TLAN", "TLA", "language", "LANGUAGE"} {
+		if value, ok := raw[key]; ok {
+			if language := normalizeLanguage(fmt.Sprint(value)); language != "" {
+				return language
+			}
+		}
+	}
+	return ""
+}
+
+// withLanguage returns a song with its hand-assigned language in place of the tagged one.
+// Cached songs keep the tagged language so removing an assignment brings it back.
+func (a *App) withLanguage(song Song) Song {
+	if language, ok := a.languages.get(userDataKey(song)); ok {
+		song.Language = language
+	}
+	return song
+}
+
+// SetSongLanguage assigns a language to a song, "" goes back to the file's tag. Takes an
+// ISO 639 code or an English language name.
+func (a *App) SetSongLanguage(filePath string, language string) (Song, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+	song, err := a.songMetadata(filePath, nil)
+	if err != nil {
+		return Song{}, fmt.Errorf("error reading metadata: %v", err)
+	}
+	if err := a.languages.set(userDataKey(song), normalizeLanguage(language)); err != nil {
+		return Song{}, err
+	}
+
+	song, err = a.songMetadata(filePath, nil)
+	if err != nil {
+		return Song{}, fmt.Errorf("error reading metadata: %v", err)
+	}
+	a.refreshSong(song)
+	a.emitEvent(eventSongUpdated, song)
+	return listSong(song), nil
+}
+
+// uniqueLibrarySongs returns every song of the library once, songs can be in several playlists
+func (a *App) uniqueLibrarySongs() ([]Song, error) {
+	songs, err := a.librarySongs()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	unique := songs[:0:0]
+	for _, song := range songs {
+		if !seen[song.FilePath] {
+			seen[song.FilePath] = true
+			unique = append(unique, song)
+		}
+	}
+	return unique, nil
+}
+
+// GetLibraryLanguages lists the languages of the library's songs, most common first
+func (a *App) GetLibraryLanguages() ([]LanguageCount, error) {
+	songs, err := a.uniqueLibrarySongs()
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, song := range songs {
+		counts[song.Language]++
+	}
+	languages := []LanguageCount{}
+	for language, count := range counts {
+		languages = append(languages, LanguageCount{Language: language, Songs: count})
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if languages[i].Songs != languages[j].Songs {
+			return languages[i].Songs > languages[j].Songs
+		}
+		return languages[i].Language < languages[j].Language
+	})
+	return languages, nil
+}
+
+// GetSongsByLanguage returns the library's songs in a language, "" returns the songs
+// without one
+func (a *App) GetSongsByLanguage(language string) ([]Song, error) {
+	songs, err := a.uniqueLibrarySongs()
+	if err != nil {
+		return nil, err
+	}
+	language = normalizeLanguage(language)
+	matching := []Song{}
+	for _, song := range songs {
+		if song.Language == language {
+			matching = append(matching, listSong(song))
+		}
+	}
+	return matching, nil
+}
+
+// matchesLanguages reports whether a song is in one of the languages, an empty list
+// matches every song
+func matchesLanguages(song Song, languages []string) bool {
+	if len(languages) == 0 {
+		return true
+	}
+	for _, language := range languages {
+		if normalizeLanguage(language) == song.Language {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Last.fm import events emitted to the frontend
+const (
+	eventLastfmImportProgress = "lastfm:import-progress"
+	eventLastfmImportDone     = "lastfm:import-done"
+)
+
+const (
+	lastfmAPIURL      = "https://ws.audioscrobbler.com/2.0/"
+	lastfmPageSize    = 200
+	lastfmRequestWait = 250 * time.Millisecond // Stay well below Last.fm's 5 requests/second limit
+)
+
+// LastfmImportResult summarizes a Last.fm history import
+type LastfmImportResult struct {
+	Username       string    `json:"username"`
+	Scrobbles      int       `json:"scrobbles"`
+	MatchedPlays   int       `json:"matchedPlays"`
+	MatchedTracks  int       `json:"matchedTracks"`
+	UnmatchedPlays int       `json:"unmatchedPlays"`
+	ImportedAt     time.Time `json:"importedAt"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// importedPlays aggregates the scrobbles matched to one local song
+type importedPlays struct {
+	count      int
+	lastPlayed time.Time
+}
+
+// lastfmRecentTracks is the subset of user.getRecentTracks we use
+type lastfmRecentTracks struct {
+	RecentTracks struct {
+		Track []struct {
+			Name   string `json:"name"`
+			Artist struct {
+				Text string `json:"#text"`
+			} `json:"artist"`
+			Date *struct {
+				UTS string `json:"uts"`
+			} `json:"date"`
+		} `json:"track"`
+		Attr struct {
+			TotalPages string `json:"totalPages"`
+		} `json:"@attr"`
+	} `json:"recenttracks"`
+	Error   int    `json:"error"`
+	Message string `json:"message"`
+}
+
+// matchKey normalizes artist and title so "The Beatles - Let It Be" matches "the beatles - let it be!"
+func matchKey(artist string, title string) string {
+	normalize := func(s string) string {
+		var b strings.Builder
+		for _, r := range strings.ToLower(s) {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	}
+	return normalize(artist) + "\x00" + normalize(title)
+}
+
+// fetchLastfmPage fetches one page of a user's scrobbles
+func fetchLastfmPage(client *http.Client, apiKey string, username string, page int) (*lastfmRecentTracks, error) {
+	params := url.Values{}
+	params.Set("method", "user.getrecenttracks")
+	params.Set("user", username)
+	params.Set("api_key", apiKey)
+	params.Set("format", "json")
+	params.Set("limit", strconv.Itoa(lastfmPageSize))
+	params.Set("page", strconv.Itoa(page))
+
+	resp, err := client.Get(lastfmAPIURL + "?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Last.fm history: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result lastfmRecentTracks
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse Last.fm response: %v", err)
+	}
+	if result.Error != 0 {
+		return nil, fmt.Errorf("Last.fm API error %d: %s", result.Error, result.Message)
+	}
+	return &result, nil
+}
+
+// ImportLastfmHistory starts a one-time import of the user's Last.fm scrobbles.
+// Progress is reported with lastfm:import-progress events and the summary with lastfm:import-done.
+// Running it again replaces the previously imported counts instead of adding to them.
+func (a *App) ImportLastfmHistory() error {
+	apiKey := a.settings.LastfmAPIKey
+	username := a.settings.LastfmUsername
+	if apiKey == "" || username == "" {
+		return fmt.Errorf("Last.fm API key and username are required")
+	}
+
+	a.lastfmMutex.Lock()
+	if a.lastfmImporting {
+		a.lastfmMutex.Unlock()
+		return fmt.Errorf("a Last.fm import is already running")
+	}
+	a.lastfmImporting = true
+	a.lastfmMutex.Unlock()
+
+	go func() {
+		result := a.runLastfmImport(apiKey, username)
+		a.lastfmMutex.Lock()
+		a.lastfmImporting = false
+		a.lastfmMutex.Unlock()
+		a.emitEvent(eventLastfmImportDone, result)
+	}()
+	return nil
+}
+
+// runLastfmImport fetches all scrobbles, matches them to local songs and seeds the stats store
+func (a *App) runLastfmImport(apiKey string, username string) LastfmImportResult {
+	result := LastfmImportResult{Username: username}
+
+	songs, err := a.librarySongs()
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to scan library: %v", err)
+		return result
+	}
+	library := make(map[string]string, len(songs)) // match key -> file path
+	for _, song := range songs {
+		library[matchKey(song.Artist, song.Title)] = song.FilePath
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	matched := make(map[string]*importedPlays)
+
+	for page, totalPages := 1, 1; page <= totalPages; page++ {
+		data, err := fetchLastfmPage(client, apiKey, username, page)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		if n, err := strconv.Atoi(data.RecentTracks.Attr.TotalPages); err == nil {
+			totalPages = n
+		}
+
+		for _, track := range data.RecentTracks.Track {
+			// The currently playing track has no date and isn't a scrobble yet
+			if track.Date == nil {
+				continue
+			}
+			result.Scrobbles++
+
+			filePath, ok := library[matchKey(track.Artist.Text, track.Name)]
+			if !ok {
+				result.UnmatchedPlays++
+				continue
+			}
+			result.MatchedPlays++
+
+			uts, _ := strconv.ParseInt(track.Date.UTS, 10, 64)
+			playedAt := time.Unix(uts, 0)
+			plays, exists := matched[filePath]
+			if !exists {
+				plays = &importedPlays{}
+				matched[filePath] = plays
+			}
+			plays.count++
+			if playedAt.After(plays.lastPlayed) {
+				plays.lastPlayed = playedAt
+			}
+		}
+
+		a.emitEvent(eventLastfmImportProgress, map[string]interface{}{
+			"page":       page,
+			"totalPages": totalPages,
+			"scrobbles":  result.Scrobbles,
+			"matched":    result.MatchedPlays,
+		})
+		time.Sleep(lastfmRequestWait)
+	}
+
+	if err := a.stats.applyImportedPlays(matched); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.MatchedTracks = len(matched)
+	result.ImportedAt = time.Now()
+	logInfo("Last.fm import: %d scrobbles, %d matched to %d songs", result.Scrobbles, result.MatchedPlays, result.MatchedTracks)
+	return result
+}
+
+// applyImportedPlays seeds play counts and last-played times from an import,
+// replacing the counts of any previous import
+func (s *statsStore) applyImportedPlays(imported map[string]*importedPlays) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Undo the previous import first so re-running doesn't double count
+	for _, stats := range s.tracks {
+		stats.PlayCount -= stats.ImportedPlays
+		if stats.PlayCount < 0 {
+			stats.PlayCount = 0
+		}
+		stats.ImportedPlays = 0
+	}
+
+	for filePath, plays := range imported {
+		stats := s.getLocked(filePath)
+		stats.PlayCount += plays.count
+		stats.ImportedPlays = plays.count
+		if plays.lastPlayed.After(stats.LastPlayed) {
+			stats.LastPlayed = plays.lastPlayed
+		}
+	}
+
+	return s.saveLocked()
+}
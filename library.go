@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Library events emitted to the frontend
+const (
+	eventLibrarySongsBatch = "library:songs-batch"
+	eventPlaylistFound     = "library:playlist-found"  // PlaylistSummary of a folder about to be read, without songs
+	eventPlaylistLoaded    = "library:playlist-loaded" // Playlist once its songs are read
+)
+
+// defaultSongPageSize is used when the frontend asks for a non-positive page size
+const defaultSongPageSize = 200
+
+// PlaylistSummary is a playlist without its song list, for large libraries
+type PlaylistSummary struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	FolderPath  string `json:"folderPath"`
+	HasCover    bool   `json:"hasCover,omitempty"` // Fetch the cover with GetPlaylistCover
+	Position    int    `json:"position"`
+	SongCount   int    `json:"songCount"`
+	Offline     bool   `json:"offline,omitempty"`
+}
+
+// SongPage is a slice of a playlist's songs
+type SongPage struct {
+	FolderPath string `json:"folderPath"`
+	Offset     int    `json:"offset"`
+	Total      int    `json:"total"`
+	Songs      []Song `json:"songs"`
+	Done       bool   `json:"done"` // True when this page contains the last song
+}
+
+// cachePlaylist remembers a loaded playlist so pages can be served without rescanning
+func (a *App) cachePlaylist(playlist Playlist) {
+	a.playlistMutex.Lock()
+	defer a.playlistMutex.Unlock()
+	a.playlistCache[filepath.Clean(playlist.FolderPath)] = playlist
+}
+
+// getCachedPlaylist returns a loaded playlist, scanning it if it isn't cached yet
+func (a *App) getCachedPlaylist(playlistPath string) (Playlist, error) {
+	key := filepath.Clean(playlistPath)
+
+	a.playlistMutex.RLock()
+	playlist, exists := a.playlistCache[key]
+	a.playlistMutex.RUnlock()
+	if exists {
+		return playlist, nil
+	}
+
+	playlist, err := a.loadPlaylist(key)
+	if err != nil {
+		return Playlist{}, err
+	}
+	a.cachePlaylist(playlist)
+	return playlist, nil
+}
+
+// librarySongs returns every song in the library, using cached playlists when available
+func (a *App) librarySongs() ([]Song, error) {
+	a.playlistMutex.RLock()
+	cached := len(a.playlistCache) > 0
+	var songs []Song
+	for _, playlist := range a.playlistCache {
+		songs = append(songs, playlist.Songs...)
+	}
+	a.playlistMutex.RUnlock()
+
+	if cached {
+		return songs, nil
+	}
+
+	playlists, err := a.GetPlaylists()
+	if err != nil {
+		return nil, err
+	}
+	songs = nil
+	for _, playlist := range playlists {
+		songs = append(songs, playlist.Songs...)
+	}
+	return songs, nil
+}
+
+// GetPlaylistSummaries returns the library's playlists without their songs, keeping the
+// response small for very large libraries. Only playlist.toml is read and song files are
+// counted, tags are left to GetPlaylistSongsPage.
+func (a *App) GetPlaylistSummaries() ([]PlaylistSummary, error) {
+	staticPath := a.GetStaticFolderPath()
+	if !isLibraryRootAvailable(staticPath) {
+		if offline := a.offlinePlaylists(staticPath); len(offline) > 0 {
+			summaries := make([]PlaylistSummary, 0, len(offline))
+			for _, playlist := range listPlaylists(offline) {
+				summaries = append(summaries, PlaylistSummary{
+					Name:        playlist.Name,
+					Description: playlist.Description,
+					FolderPath:  playlist.FolderPath,
+					HasCover:    playlist.HasCover,
+					Position:    playlist.Position,
+					SongCount:   len(playlist.Songs),
+					Offline:     true,
+				})
+			}
+			return summaries, nil
+		}
+	}
+
+	entries, err := os.ReadDir(staticPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("static folder not found at: %s", staticPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning playlists: %v", err)
+	}
+
+	summaries := make([]PlaylistSummary, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || a.isIgnoredScanEntry(entry.Name()) {
+			continue
+		}
+		playlistDir := filepath.Join(staticPath, entry.Name())
+		summary, err := a.playlistSummary(playlistDir)
+		if err != nil {
+			logError("Error loading playlist %s: %v", playlistDir, err)
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// playlistSummary describes a playlist folder from its playlist.toml and a count of its
+// song files. A loaded playlist is used as is, its count also honours the language filter.
+func (a *App) playlistSummary(playlistDir string) (PlaylistSummary, error) {
+	a.playlistMutex.RLock()
+	cached, ok := a.playlistCache[filepath.Clean(playlistDir)]
+	a.playlistMutex.RUnlock()
+	if ok {
+		return PlaylistSummary{
+			Name:        cached.Name,
+			Description: cached.Description,
+			FolderPath:  cached.FolderPath,
+			HasCover:    cached.CoverData != "",
+			Position:    cached.Position,
+			SongCount:   len(cached.Songs),
+			Offline:     cached.Offline,
+		}, nil
+	}
+
+	config, err := readPlaylistConfig(playlistDir)
+	if err != nil {
+		return PlaylistSummary{}, err
+	}
+	files, err := a.scanMusicFiles(playlistDir, config)
+	if err != nil {
+		return PlaylistSummary{}, err
+	}
+	if len(config.Tracks) > 0 {
+		files, _ = applyTrackList(playlistDir, config.Tracks, files, make([]int, len(files)))
+	}
+
+	summary := PlaylistSummary{
+		Name:        config.Name,
+		Description: config.Description,
+		FolderPath:  playlistDir,
+		Position:    config.Position,
+		SongCount:   len(files),
+	}
+	if summary.Name == "" {
+		summary.Name = filepath.Base(playlistDir)
+	}
+	if summary.Description == "" {
+		summary.Description = "Auto-generated playlist"
+	}
+	if config.Cover != "" {
+		if _, err := os.Stat(filepath.Join(playlistDir, config.Cover)); err == nil {
+			summary.HasCover = true
+		}
+	}
+	if summary.Position < 0 || summary.Position >= summary.SongCount {
+		summary.Position = 0
+	}
+	return summary, nil
+}
+
+// emitPlaylistsFound announces the playlist folders of a scan before any is read. Only
+// playlist.toml is read, so names show up at once even on large libraries.
+func (a *App) emitPlaylistsFound(staticPath string) {
+	entries, err := os.ReadDir(staticPath)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || a.isIgnoredScanEntry(entry.Name()) {
+			continue
+		}
+		playlistDir := filepath.Join(staticPath, entry.Name())
+		summary := PlaylistSummary{Name: entry.Name(), FolderPath: playlistDir}
+		if config, err := readPlaylistConfig(playlistDir); err == nil {
+			if config.Name != "" {
+				summary.Name = config.Name
+			}
+			summary.Description = config.Description
+			summary.Position = config.Position
+		}
+		a.emitEvent(eventPlaylistFound, summary)
+	}
+}
+
+// StreamPlaylists starts a library scan in the background and returns at once. Each
+// playlist is emitted as library:playlist-found when its folder is seen and as
+// library:playlist-loaded once read, library:scan-done ends the scan.
+func (a *App) StreamPlaylists() error {
+	staticPath := a.GetStaticFolderPath()
+	// Checked here so the frontend gets the error, offline playlists are still streamed
+	if _, err := os.Stat(staticPath); os.IsNotExist(err) && len(a.offlinePlaylists(staticPath)) == 0 {
+		return fmt.Errorf("static folder not found at: %s", staticPath)
+	}
+
+	go func() {
+		if _, err := a.scanPlaylists(true); err != nil {
+			logError("Streamed scan failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// songPage slices a playlist's songs into a page
+func songPage(playlist Playlist, offset int, limit int) SongPage {
+	total := len(playlist.Songs)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	if limit <= 0 {
+		limit = defaultSongPageSize
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return SongPage{
+		FolderPath: playlist.FolderPath,
+		Offset:     offset,
+		Total:      total,
+		Songs:      listSongs(playlist.Songs[offset:end]),
+		Done:       end >= total,
+	}
+}
+
+// GetPlaylistSongsPage returns up to limit songs of a playlist starting at offset
+func (a *App) GetPlaylistSongsPage(playlistPath string, offset int, limit int) (SongPage, error) {
+	playlistPath = normalizePath(playlistPath)
+
+	playlist, err := a.getCachedPlaylist(playlistPath)
+	if err != nil {
+		return SongPage{}, fmt.Errorf("error loading playlist: %v", err)
+	}
+	return songPage(playlist, offset, limit), nil
+}
+
+// StreamPlaylistSongs sends a playlist's songs to the frontend as a series of
+// library:songs-batch events and returns the total number of songs
+func (a *App) StreamPlaylistSongs(playlistPath string, batchSize int) (int, error) {
+	playlistPath = normalizePath(playlistPath)
+
+	playlist, err := a.getCachedPlaylist(playlistPath)
+	if err != nil {
+		return 0, fmt.Errorf("error loading playlist: %v", err)
+	}
+
+	go func() {
+		offset := 0
+		for {
+			page := songPage(playlist, offset, batchSize)
+			a.emitEvent(eventLibrarySongsBatch, page)
+			if page.Done {
+				break
+			}
+			offset += len(page.Songs)
+		}
+		logDebug("Streamed %d songs for playlist '%s'", len(playlist.Songs), playlist.Name)
+	}()
+
+	return len(playlist.Songs), nil
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// libraryIndexVersion is bumped whenever extracted metadata changes shape, which
+// drops the cached songs so they are re-extracted
+const libraryIndexVersion = 10
+
+var (
+	indexSongsBucket     = []byte("songs")
+	indexPlaylistsBucket = []byte("playlists")
+	indexMetaBucket      = []byte("meta")
+	indexVersionKey      = []byte("version")
+)
+
+// indexEntry is a song's extracted metadata along with the file state it was read from
+type indexEntry struct {
+	Size    int64 `json:"size"`
+	ModTime int64 `json:"modTime"` // Unix nanoseconds
+	Song    Song  `json:"song"`
+}
+
+// indexedPlaylist is what's kept of a playlist so it can be shown while its drive is offline
+type indexedPlaylist struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	CoverData   string            `json:"coverData,omitempty"`
+	Position    int               `json:"position"`
+	Schedule    *PlaylistSchedule `json:"schedule,omitempty"`
+	Songs       []string          `json:"songs"` // File paths in playlist order
+}
+
+// libraryIndex persists extracted song metadata keyed by file path so library scans
+// only read tags from new or modified files
+type libraryIndex struct {
+	db *bolt.DB
+}
+
+// openLibraryIndex opens (or creates) the index database
+func openLibraryIndex(path string) (*libraryIndex, error) {
+	// A second running instance holds the lock, don't hang waiting for it
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening library index: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(indexMetaBucket)
+		if err != nil {
+			return err
+		}
+		version := fmt.Sprint(libraryIndexVersion)
+		if string(meta.Get(indexVersionKey)) != version {
+			for _, name := range [][]byte{indexSongsBucket, indexTracksBucket, indexCoversBucket} {
+				if tx.Bucket(name) != nil {
+					if err := tx.DeleteBucket(name); err != nil {
+						return err
+					}
+				}
+			}
+			if err := meta.Put(indexVersionKey, []byte(version)); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.CreateBucketIfNotExists(indexPlaylistsBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(indexTracksBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(indexCoversBucket); err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists(indexSongsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error initializing library index: %v", err)
+	}
+
+	return &libraryIndex{db: db}, nil
+}
+
+// close closes the index database
+func (l *libraryIndex) close() error {
+	if l == nil {
+		return nil
+	}
+	return l.db.Close()
+}
+
+// lookup returns the indexed metadata for a file if it hasn't changed since it was indexed
+func (l *libraryIndex) lookup(filePath string, info fs.FileInfo) (Song, bool) {
+	if l == nil {
+		return Song{}, false
+	}
+
+	var entry indexEntry
+	found := false
+	l.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(indexSongsBucket).Get([]byte(filePath))
+		if data != nil && json.Unmarshal(data, &entry) == nil {
+			found = entry.Size == info.Size() && entry.ModTime == info.ModTime().UnixNano()
+		}
+		return nil
+	})
+	return entry.Song, found
+}
+
+// store writes freshly extracted songs in a single transaction
+func (l *libraryIndex) store(entries map[string]indexEntry) error {
+	if l == nil || len(entries) == 0 {
+		return nil
+	}
+
+	return l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(indexSongsBucket)
+		tracks := tx.Bucket(indexTracksBucket)
+		for filePath, entry := range entries {
+			// Playlist positions come from playlist.toml, not the file
+			entry.Song.Position = 0
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(filePath), data); err != nil {
+				return err
+			}
+			if entry.Song.TrackID != "" {
+				if err := tracks.Put([]byte(entry.Song.TrackID), []byte(filePath)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// remove forgets a song, e.g. after its file was deleted
+func (l *libraryIndex) remove(filePath string) error {
+	if l == nil {
+		return nil
+	}
+	return l.db.Update(func(tx *bolt.Tx) error {
+		var entry indexEntry
+		if data := tx.Bucket(indexSongsBucket).Get([]byte(filePath)); data != nil && json.Unmarshal(data, &entry) == nil {
+			tracks := tx.Bucket(indexTracksBucket)
+			if string(tracks.Get([]byte(entry.Song.TrackID))) == filePath {
+				if err := tracks.Delete([]byte(entry.Song.TrackID)); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Bucket(indexSongsBucket).Delete([]byte(filePath))
+	})
+}
+
+// storePlaylist remembers a playlist's details and song order
+func (l *libraryIndex) storePlaylist(playlist Playlist) error {
+	if l == nil {
+		return nil
+	}
+
+	entry := indexedPlaylist{
+		Name:        playlist.Name,
+		Description: playlist.Description,
+		CoverData:   playlist.CoverData,
+		Position:    playlist.Position,
+		Schedule:    playlist.Schedule,
+		Songs:       make([]string, 0, len(playlist.Songs)),
+	}
+	for _, song := range playlist.Songs {
+		entry.Songs = append(entry.Songs, song.FilePath)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return l.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(indexPlaylistsBucket).Put([]byte(playlist.FolderPath), data)
+	})
+}
+
+// playlists rebuilds the indexed playlists whose folders are inside root, without touching the disk
+func (l *libraryIndex) playlists(root string) []Playlist {
+	if l == nil {
+		return nil
+	}
+
+	var playlists []Playlist
+	l.db.View(func(tx *bolt.Tx) error {
+		songs := tx.Bucket(indexSongsBucket)
+		return tx.Bucket(indexPlaylistsBucket).ForEach(func(key, data []byte) error {
+			folderPath := string(key)
+			if filepath.Dir(folderPath) != filepath.Clean(root) {
+				return nil
+			}
+			var entry indexedPlaylist
+			if json.Unmarshal(data, &entry) != nil {
+				return nil
+			}
+
+			playlist := Playlist{
+				Name:        entry.Name,
+				Description: entry.Description,
+				FolderPath:  folderPath,
+				CoverData:   entry.CoverData,
+				Position:    entry.Position,
+				Schedule:    entry.Schedule,
+			}
+			for _, songPath := range entry.Songs {
+				var song indexEntry
+				if raw := songs.Get([]byte(songPath)); raw == nil || json.Unmarshal(raw, &song) != nil {
+					continue
+				}
+				song.Song.Position = len(playlist.Songs) + 1
+				playlist.Songs = append(playlist.Songs, song.Song)
+			}
+			playlists = append(playlists, playlist)
+			return nil
+		})
+	})
+	return playlists
+}
+
+// prune removes entries for songs and playlists that are no longer in the library,
+// returning how many songs were removed
+func (l *libraryIndex) prune(keepSongs map[string]bool, keepPlaylists map[string]bool) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	removed := 0
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if removed, err = deleteStaleKeys(tx.Bucket(indexSongsBucket), keepSongs); err != nil {
+			return err
+		}
+		if err := deleteStaleTracks(tx.Bucket(indexTracksBucket), keepSongs); err != nil {
+			return err
+		}
+		if err := deleteStaleCovers(tx); err != nil {
+			return err
+		}
+		_, err = deleteStaleKeys(tx.Bucket(indexPlaylistsBucket), keepPlaylists)
+		return err
+	})
+	return removed, err
+}
+
+// deleteStaleKeys deletes every key of a bucket that isn't in keep
+func deleteStaleKeys(bucket *bolt.Bucket, keep map[string]bool) (int, error) {
+	// Deleting while iterating a cursor skips keys, collect them first
+	var stale [][]byte
+	bucket.ForEach(func(key, _ []byte) error {
+		if !keep[string(key)] {
+			stale = append(stale, append([]byte(nil), key...))
+		}
+		return nil
+	})
+	for _, key := range stale {
+		if err := bucket.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+	return len(stale), nil
+}
+
+// deleteStaleTracks deletes track IDs that point at songs no longer in the library
+func deleteStaleTracks(bucket *bolt.Bucket, keepSongs map[string]bool) error {
+	var stale [][]byte
+	bucket.ForEach(func(key, filePath []byte) error {
+		if !keepSongs[string(filePath)] {
+			stale = append(stale, append([]byte(nil), key...))
+		}
+		return nil
+	})
+	for _, key := range stale {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// songMetadata returns a song's metadata from memory or the index, extracting it when the
+// file is new or changed. Extracted songs are added to fresh so the caller can store them
+// in bulk, with a nil fresh they're stored right away.
+func (a *App) songMetadata(filePath string, fresh map[string]indexEntry) (Song, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+	if song, ok := a.cachedSongMetadata(filePath, info); ok {
+		return a.withUserData(song), nil
+	}
+	if song, ok := a.library.lookup(filePath, info); ok {
+		a.songCache.put(filePath, cachedSong{version: statVersion(info), song: song})
+		a.registerTrack(song)
+		return a.withUserData(song), nil
+	}
+
+	// Only reads that go to the disk count against the scan's rate limit
+	if fresh != nil {
+		a.scanThrottle.wait(a.settings.ScanFilesPerSecond)
+	}
+	song, err := a.extractMetadata(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+	entry := indexEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Song:    song,
+	}
+	if fresh != nil {
+		fresh[filePath] = entry
+	} else if err := a.storeSongs(map[string]indexEntry{filePath: entry}); err != nil {
+		logWarn("Library index: %v", err)
+	}
+	a.songCache.put(filePath, cachedSong{version: statVersion(info), song: song})
+	a.registerTrack(song)
+	return a.withUserData(song), nil
+}
+
+// pruneLibraryIndex drops index entries for songs and playlists that were removed from the library
+func (a *App) pruneLibraryIndex(playlists []Playlist) {
+	keepSongs := make(map[string]bool)
+	keepPlaylists := make(map[string]bool)
+	for _, playlist := range playlists {
+		keepPlaylists[playlist.FolderPath] = true
+		for _, song := range playlist.Songs {
+			keepSongs[song.FilePath] = true
+		}
+	}
+
+	removed, err := a.library.prune(keepSongs, keepPlaylists)
+	if err != nil {
+		logWarn("Library index: %v", err)
+	} else if removed > 0 {
+		logInfo("Library index: pruned %d removed songs", removed)
+	}
+}
+
+// songPaths returns the paths of every indexed song
+func (l *libraryIndex) songPaths() []string {
+	if l == nil {
+		return nil
+	}
+
+	var paths []string
+	l.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(indexSongsBucket).ForEach(func(key, _ []byte) error {
+			paths = append(paths, string(key))
+			return nil
+		})
+	})
+	return paths
+}
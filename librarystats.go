@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// indexLastScanKey stores the last ScanReport in the index meta bucket
+var indexLastScanKey = []byte("lastScan")
+
+// ScanReport is the telemetry of a full library scan
+type ScanReport struct {
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+	Playlists  int       `json:"playlists"`
+	Files      int       `json:"files"`     // Audio files found
+	Extracted  int       `json:"extracted"` // Files whose tags were read from disk
+	Cached     int       `json:"cached"`    // Files served from the library index
+	Failed     int       `json:"failed"`    // Files whose tags couldn't be read
+}
+
+// FormatStats is the share of one file format in the library
+type FormatStats struct {
+	Format string `json:"format"` // Lowercase extension without the dot, e.g. "flac"
+	Tracks int    `json:"tracks"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// LibraryStats summarizes the library index
+type LibraryStats struct {
+	TotalTracks      int           `json:"totalTracks"`
+	TotalDurationSec int64         `json:"totalDurationSec"`
+	TotalBytes       int64         `json:"totalBytes"`
+	Formats          []FormatStats `json:"formats"` // Most tracks first
+	Playlists        int           `json:"playlists"`
+	IndexBytes       int64         `json:"indexBytes"` // Size of the index database
+	Scanning         bool          `json:"scanning"`
+	LastScan         *ScanReport   `json:"lastScan,omitempty"`
+}
+
+// scanTracker counts files while a library scan is running
+type scanTracker struct {
+	report       ScanReport
+	progress     ScanProgress
+	lastProgress time.Time // When progress was last emitted
+	cancelled    bool
+	running      int // Scans in progress, GetPlaylists can be called concurrently
+	mutex        sync.Mutex
+}
+
+// beginScan starts collecting telemetry for a full library scan
+func (a *App) beginScan() {
+	a.scanStats.mutex.Lock()
+	defer a.scanStats.mutex.Unlock()
+	if a.scanStats.running == 0 {
+		a.scanStats.report = ScanReport{StartedAt: time.Now()}
+		a.scanStats.progress = ScanProgress{}
+		a.scanStats.cancelled = false
+	}
+	a.scanStats.running++
+}
+
+// countScannedFiles adds one playlist's files to the running scan
+func (a *App) countScannedFiles(files int, extracted int, failed int) {
+	a.scanStats.mutex.Lock()
+	defer a.scanStats.mutex.Unlock()
+	if a.scanStats.running == 0 {
+		return // Single playlist load outside a full scan
+	}
+	a.scanStats.report.Playlists++
+	a.scanStats.progress.PlaylistsDone++
+	a.scanStats.report.Files += files
+	a.scanStats.report.Extracted += extracted
+	a.scanStats.report.Failed += failed
+	a.scanStats.report.Cached += files - extracted - failed
+}
+
+// endScan finishes the running scan and stores its report in the index
+func (a *App) endScan() {
+	a.scanStats.mutex.Lock()
+	a.scanStats.running--
+	if a.scanStats.running > 0 {
+		a.scanStats.mutex.Unlock()
+		return
+	}
+	report := a.scanStats.report
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	progress := a.scanStats.progress
+	progress.Cancelled = a.scanStats.cancelled
+	a.scanStats.mutex.Unlock()
+
+	a.emitEvent(eventScanDone, progress)
+	if progress.Cancelled {
+		logInfo("Library scan cancelled after %d files", progress.FilesProcessed)
+		return // A partial scan's report would misrepresent the library
+	}
+
+	if err := a.library.setLastScan(report); err != nil {
+		logWarn("Could not save scan report: %v", err)
+	}
+	logInfo("Library scan: %d files in %d playlists, %d read from disk, %d from index, %d failed, %dms",
+		report.Files, report.Playlists, report.Extracted, report.Cached, report.Failed, report.DurationMs)
+}
+
+// setLastScan stores the last scan report
+func (l *libraryIndex) setLastScan(report ScanReport) error {
+	if l == nil {
+		return nil
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return l.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(indexMetaBucket).Put(indexLastScanKey, data)
+	})
+}
+
+// stats totals the indexed songs and playlists
+func (l *libraryIndex) stats() LibraryStats {
+	stats := LibraryStats{Formats: []FormatStats{}}
+	if l == nil {
+		return stats
+	}
+
+	formats := make(map[string]*FormatStats)
+	l.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(indexMetaBucket).Get(indexLastScanKey); data != nil {
+			var report ScanReport
+			if json.Unmarshal(data, &report) == nil {
+				stats.LastScan = &report
+			}
+		}
+		stats.Playlists = tx.Bucket(indexPlaylistsBucket).Stats().KeyN
+
+		return tx.Bucket(indexSongsBucket).ForEach(func(key, data []byte) error {
+			var entry indexEntry
+			if json.Unmarshal(data, &entry) != nil {
+				return nil
+			}
+			stats.TotalTracks++
+			stats.TotalDurationSec += int64(entry.Song.DurationSec)
+			stats.TotalBytes += entry.Size
+
+			format := strings.TrimPrefix(strings.ToLower(filepath.Ext(string(key))), ".")
+			if formats[format] == nil {
+				formats[format] = &FormatStats{Format: format}
+			}
+			formats[format].Tracks++
+			formats[format].Bytes += entry.Size
+			return nil
+		})
+	})
+
+	for _, format := range formats {
+		stats.Formats = append(stats.Formats, *format)
+	}
+	sort.Slice(stats.Formats, func(i, j int) bool {
+		if stats.Formats[i].Tracks != stats.Formats[j].Tracks {
+			return stats.Formats[i].Tracks > stats.Formats[j].Tracks
+		}
+		return stats.Formats[i].Format < stats.Formats[j].Format
+	})
+
+	if info, err := os.Stat(l.db.Path()); err == nil {
+		stats.IndexBytes = info.Size()
+	}
+	return stats
+}
+
+// GetLibraryStats reports library totals, the format breakdown and the last scan's timing
+func (a *App) GetLibraryStats() LibraryStats {
+	stats := a.library.stats()
+
+	a.scanStats.mutex.Lock()
+	stats.Scanning = a.scanStats.running > 0
+	a.scanStats.mutex.Unlock()
+	return stats
+}
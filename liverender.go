@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// liveRenderChunk is how much FFmpeg output is read before it's sent to the player
+const liveRenderChunk = 32 * 1024
+
+// liveRender is an effect chain that's rendered while it streams. The first request
+// renders into the cache, later requests get the cached file with seeking.
+type liveRender struct {
+	source     string // Audio the chain processes, the song or a stem
+	chain      EffectChain
+	cachedFile string
+	caching    bool // A request is writing the cached file
+	mutex      sync.Mutex
+}
+
+// claimCache reports whether the caller should write the cached file, only one
+// request at a time does
+func (r *liveRender) claimCache() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.caching {
+		return false
+	}
+	r.caching = true
+	return true
+}
+
+// releaseCache lets another request write the cached file, which matters when the render
+// failed or its cached file gets evicted
+func (r *liveRender) releaseCache() {
+	r.mutex.Lock()
+	r.caching = false
+	r.mutex.Unlock()
+}
+
+// startRender runs FFmpeg on a filter graph with the encoded audio going to stdout. The
+// first chunk is read before returning, so a graph FFmpeg rejects fails here instead of
+// mid-stream.
+func startRender(ctx context.Context, source string, filterChain string, codecArgs []string) (*exec.Cmd, io.Reader, []byte, error) {
+	args := append([]string{"-i", source, "-af", filterChain}, codecArgs...)
+	cmd := exec.CommandContext(ctx, "ffmpeg", append(args, "pipe:1")...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	first := make([]byte, liveRenderChunk)
+	n, err := io.ReadAtLeast(stdout, first, 1)
+	if err != nil {
+		cmd.Wait()
+		return nil, nil, nil, fmt.Errorf("FFmpeg error: %v\nOutput: %s", err, stderr.String())
+	}
+	return cmd, stdout, first[:n], nil
+}
+
+// serveLiveRender pipes FFmpeg's output to the player as it's encoded, so effects start
+// within a second instead of after the whole song is re-encoded. The response has no
+// length and can't be seeked, requests after the render finished get the cached file.
+func (a *App) serveLiveRender(w http.ResponseWriter, r *http.Request, render *liveRender) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", render.chain.outputMIME())
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Accept-Ranges", "none")
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	// The caching render keeps going when the player disconnects, so the song can be
+	// seeked next time. Renders for other requests stop with their request.
+	caching := render.claimCache()
+	ctx := r.Context()
+	if caching {
+		ctx = context.Background()
+		defer render.releaseCache()
+	}
+
+	mediaJobs.do(jobForeground, func() {
+		filterChain := strings.Join(render.chain.filters(true), ",")
+		cmd, stdout, first, err := startRender(ctx, render.source, filterChain, render.chain.codecArgs())
+		if err != nil && render.chain.needsRubberband() && strings.Contains(err.Error(), "rubberband") {
+			logWarn("Rubberband not available, using atempo + asetrate fallback")
+			filterChain = strings.Join(render.chain.filters(false), ",")
+			cmd, stdout, first, err = startRender(ctx, render.source, filterChain, render.chain.codecArgs())
+		}
+		if err != nil {
+			logError("Live render failed: %v", err)
+			http.Error(w, "effect rendering failed", http.StatusInternalServerError)
+			return
+		}
+
+		var part *os.File
+		partFile := render.cachedFile + ".part"
+		if caching {
+			if part, err = os.Create(partFile); err != nil {
+				logWarn("Could not cache live render: %v", err)
+			}
+		}
+
+		flusher, _ := w.(http.Flusher)
+		clientGone := false
+		chunk := first
+		buf := make([]byte, liveRenderChunk)
+		for {
+			if part != nil {
+				if _, err := part.Write(chunk); err != nil {
+					logWarn("Could not cache live render: %v", err)
+					part.Close()
+					os.Remove(partFile)
+					part = nil
+				}
+			}
+			if !clientGone {
+				if _, err := w.Write(chunk); err != nil {
+					clientGone = true
+				} else if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			if clientGone && part == nil {
+				cmd.Process.Kill() // Nobody wants the rest
+				break
+			}
+
+			n, err := stdout.Read(buf)
+			if err != nil {
+				break
+			}
+			chunk = buf[:n]
+		}
+		err = cmd.Wait()
+
+		if part == nil {
+			return
+		}
+		part.Close()
+		if err != nil {
+			logError("Live render of %s failed: %v", render.source, err)
+			os.Remove(partFile)
+			return
+		}
+		a.finishLiveRender(render, partFile, filterChain)
+	})
+}
+
+// finishLiveRender moves a complete render into the cache. The manifest goes first, so
+// the audio never sits in the cache without one and gets discarded as invalid.
+func (a *App) finishLiveRender(render *liveRender, partFile string, filterChain string) {
+	err := writeProcessedManifest(partFile, render.source, render.chain, filterChain)
+	if err == nil {
+		err = os.Rename(manifestPath(partFile), manifestPath(render.cachedFile))
+	}
+	if err == nil {
+		err = os.Rename(partFile, render.cachedFile)
+	}
+	if err != nil {
+		logWarn("Could not cache live render: %v", err)
+		os.Remove(partFile)
+		os.Remove(manifestPath(partFile))
+		return
+	}
+	logInfo("Live render complete: %s", render.cachedFile)
+	go a.trimAudioCache()
+}
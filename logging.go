@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log levels the settings accept
+const (
+	logLevelDebug = "debug"
+	logLevelInfo  = "info"
+	logLevelWarn  = "warn"
+	logLevelError = "error"
+)
+
+const (
+	maxLogFileBytes  = 5 << 20 // static.log is rotated past this
+	keptLogFiles     = 3       // Rotated files kept, static.log.1 is the newest
+	recentLogEntries = 2000    // Entries kept in memory for the in-app log viewer
+)
+
+// LogEntry is one log line as shown by the in-app log viewer
+type LogEntry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"` // "debug", "info", "warn" or "error"
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+var (
+	// logLevel is the minimum level logged, changed with the LogLevel setting
+	logLevel slog.LevelVar
+	// recentLogs keeps the latest entries for GetRecentLogs
+	recentLogs = &logBuffer{entries: make([]LogEntry, recentLogEntries)}
+	// logOutput is the log file, nil when it couldn't be opened
+	logOutput *rotatingFile
+)
+
+// logsDir is where log files are written
+func logsDir() string {
+	return filepath.Join(getConfigDir(), "logs")
+}
+
+// logBuffer is a ring of the most recent log entries
+type logBuffer struct {
+	entries []LogEntry
+	next    int
+	full    bool
+	mutex   sync.Mutex
+}
+
+// add stores an entry, dropping the oldest once the ring is full
+func (b *logBuffer) add(entry LogEntry) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// snapshot returns the stored entries, oldest first
+func (b *logBuffer) snapshot() []LogEntry {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if !b.full {
+		return append([]LogEntry(nil), b.entries[:b.next]...)
+	}
+	return append(append([]LogEntry(nil), b.entries[b.next:]...), b.entries[:b.next]...)
+}
+
+// rotatingFile is a log file that's rotated once it outgrows maxLogFileBytes
+type rotatingFile struct {
+	path  string
+	file  *os.File
+	size  int64
+	mutex sync.Mutex
+}
+
+// openRotatingFile opens a log file for appending
+func openRotatingFile(path string) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &rotatingFile{path: path, file: file, size: info.Size()}, nil
+}
+
+// Write appends to the log file, rotating it first when the line wouldn't fit
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.size > 0 && f.size+int64(len(p)) > maxLogFileBytes {
+		if err := f.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotateLocked shifts static.log to static.log.1 and so on, dropping the oldest. Caller
+// must hold the mutex.
+func (f *rotatingFile) rotateLocked() error {
+	f.file.Close()
+	for i := keptLogFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	os.Rename(f.path, f.path+".1")
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	f.file, f.size = file, 0
+	return nil
+}
+
+// logHandler writes records as text to the console and log file and keeps them for the
+// in-app log viewer
+type logHandler struct {
+	slog.Handler
+	attrs []slog.Attr
+}
+
+func (h *logHandler) Handle(ctx context.Context, record slog.Record) error {
+	entry := LogEntry{Time: record.Time, Level: levelName(record.Level), Message: record.Message}
+	addAttr := func(attr slog.Attr) bool {
+		if entry.Attrs == nil {
+			entry.Attrs = make(map[string]string)
+		}
+		entry.Attrs[attr.Key] = attr.Value.String()
+		return true
+	}
+	for _, attr := range h.attrs {
+		addAttr(attr)
+	}
+	record.Attrs(addAttr)
+	recentLogs.add(entry)
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logHandler{Handler: h.Handler.WithAttrs(attrs), attrs: append(append([]slog.Attr(nil), h.attrs...), attrs...)}
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	return &logHandler{Handler: h.Handler.WithGroup(name), attrs: h.attrs}
+}
+
+// levelName returns the settings name of a level
+func levelName(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return logLevelError
+	case level >= slog.LevelWarn:
+		return logLevelWarn
+	case level >= slog.LevelInfo:
+		return logLevelInfo
+	default:
+		return logLevelDebug
+	}
+}
+
+// parseLogLevel converts a settings level name
+func parseLogLevel(name string) (slog.Level, error) {
+	switch name {
+	case logLevelDebug:
+		return slog.LevelDebug, nil
+	case logLevelInfo, "":
+		return slog.LevelInfo, nil
+	case logLevelWarn:
+		return slog.LevelWarn, nil
+	case logLevelError:
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("invalid log level: %s", name)
+}
+
+// setLogLevel changes the minimum level logged
+func setLogLevel(name string) error {
+	level, err := parseLogLevel(name)
+	if err != nil {
+		return err
+	}
+	logLevel.Set(level)
+	return nil
+}
+
+// setupLogging sends logs to the console and to static.log in the config dir. Without
+// a writable config dir only the console gets them.
+func setupLogging() {
+	var output io.Writer = os.Stdout
+	if file, err := openRotatingFile(filepath.Join(logsDir(), "static.log")); err != nil {
+		fmt.Fprintf(os.Stderr, "Log file unavailable, logging to the console only: %v\n", err)
+	} else {
+		logOutput = file
+		output = io.MultiWriter(os.Stdout, file)
+	}
+	slog.SetDefault(slog.New(&logHandler{Handler: slog.NewTextHandler(output, &slog.HandlerOptions{Level: &logLevel})}))
+}
+
+// logAt logs a printf-style message at a level
+func logAt(level slog.Level, format string, args ...interface{}) {
+	ctx := context.Background()
+	logger := slog.Default()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	logger.Log(ctx, level, strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
+
+// logDebug logs details only wanted when tracking a problem down
+func logDebug(format string, args ...interface{}) { logAt(slog.LevelDebug, format, args...) }
+
+// logInfo logs what the app is doing
+func logInfo(format string, args ...interface{}) { logAt(slog.LevelInfo, format, args...) }
+
+// logWarn logs problems the app works around
+func logWarn(format string, args ...interface{}) { logAt(slog.LevelWarn, format, args...) }
+
+// logError logs failures the user will notice
+func logError(format string, args ...interface{}) { logAt(slog.LevelError, format, args...) }
+
+// GetRecentLogs returns the latest log entries at or above minLevel ("" for all), oldest
+// first, at most limit of them (0 for every entry kept)
+func (a *App) GetRecentLogs(minLevel string, limit int) ([]LogEntry, error) {
+	threshold := slog.LevelDebug
+	if minLevel != "" {
+		level, err := parseLogLevel(minLevel)
+		if err != nil {
+			return nil, err
+		}
+		threshold = level
+	}
+
+	entries := []LogEntry{}
+	for _, entry := range recentLogs.snapshot() {
+		if level, _ := parseLogLevel(entry.Level); level >= threshold {
+			entries = append(entries, entry)
+		}
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// GetLogFilePath returns the log file's path, "" when logs only go to the console
+func (a *App) GetLogFilePath() string {
+	if logOutput == nil {
+		return ""
+	}
+	return logOutput.path
+}
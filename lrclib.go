@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lrclibURL = "https://lrclib.net/api/get"
+	// lrclibMissRetry is how long a song LRCLIB had no lyrics for isn't asked about again
+	lrclibMissRetry = 7 * 24 * time.Hour
+)
+
+// lrclibEntry is a cached LRCLIB answer, including that it had no lyrics
+type lrclibEntry struct {
+	Found        bool      `json:"found"`
+	Plain        string    `json:"plain,omitempty"`
+	Synced       string    `json:"synced,omitempty"` // LRC text
+	Instrumental bool      `json:"instrumental,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// lyricsCacheDir is where downloaded lyrics are cached, one file per song
+func lyricsCacheDir() string {
+	return filepath.Join(getConfigDir(), "lyrics")
+}
+
+// lyricsCacheKey identifies a song's lyrics by its tags, "" when they aren't enough to
+// look it up
+func lyricsCacheKey(song Song) string {
+	artist := strings.ToLower(strings.TrimSpace(song.Artist))
+	title := strings.ToLower(strings.TrimSpace(song.Title))
+	if artist == "" || artist == "unknown artist" || title == "" {
+		return ""
+	}
+	hash := md5.Sum([]byte(artist + "|" + title + "|" + strings.ToLower(strings.TrimSpace(song.Album))))
+	return hex.EncodeToString(hash[:])
+}
+
+// fetchLRCLIB asks LRCLIB for a song's lyrics. The duration lets it pick the right
+// recording when there are several.
+func fetchLRCLIB(song Song) (lrclibEntry, error) {
+	params := url.Values{}
+	params.Set("artist_name", song.Artist)
+	params.Set("track_name", song.Title)
+	if song.Album != "" && song.Album != "Unknown Album" {
+		params.Set("album_name", song.Album)
+	}
+	if song.DurationSec > 0 {
+		params.Set("duration", strconv.Itoa(song.DurationSec))
+	}
+
+	req, err := http.NewRequest("GET", lrclibURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return lrclibEntry{}, err
+	}
+	req.Header.Set("User-Agent", musicBrainzAgent) // LRCLIB asks clients to identify themselves
+	resp, err := identifyHTTPClient.Do(req)
+	if err != nil {
+		return lrclibEntry{}, fmt.Errorf("LRCLIB request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	entry := lrclibEntry{FetchedAt: time.Now()}
+	if resp.StatusCode == http.StatusNotFound {
+		return entry, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return lrclibEntry{}, fmt.Errorf("LRCLIB returned HTTP %d", resp.StatusCode)
+	}
+	var result struct {
+		PlainLyrics  string `json:"plainLyrics"`
+		SyncedLyrics string `json:"syncedLyrics"`
+		Instrumental bool   `json:"instrumental"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return lrclibEntry{}, fmt.Errorf("failed to parse LRCLIB response: %v", err)
+	}
+	entry.Found = true
+	entry.Plain = strings.TrimSpace(result.PlainLyrics)
+	entry.Synced = result.SyncedLyrics
+	entry.Instrumental = result.Instrumental
+	return entry, nil
+}
+
+// lrclibLyrics returns a song's lyrics from LRCLIB, nil when it has none. Answers are
+// cached, misses for a week.
+func lrclibLyrics(song Song) (*Lyrics, error) {
+	key := lyricsCacheKey(song)
+	if key == "" {
+		return nil, nil
+	}
+	cachePath := filepath.Join(lyricsCacheDir(), key+".json")
+
+	var entry lrclibEntry
+	cached := false
+	if data, err := os.ReadFile(cachePath); err == nil && json.Unmarshal(data, &entry) == nil {
+		cached = entry.Found || time.Since(entry.FetchedAt) < lrclibMissRetry
+	}
+	if !cached {
+		var err error
+		if entry, err = fetchLRCLIB(song); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(lyricsCacheDir(), 0755); err != nil {
+			logError("Error creating lyrics folder: %v", err)
+		} else if err := writeJSONFile(cachePath, entry); err != nil {
+			logError("Error caching lyrics: %v", err)
+		}
+	}
+
+	if !entry.Found {
+		return nil, nil
+	}
+	lyrics := &Lyrics{Source: lyricsSourceLRCLIB, Plain: entry.Plain, Instrumental: entry.Instrumental}
+	if entry.Synced != "" {
+		lyrics.Synced = parseLRC(entry.Synced)
+		if lyrics.Plain == "" {
+			lyrics.Plain = plainLyrics(entry.Synced)
+		}
+	}
+	return lyrics, nil
+}
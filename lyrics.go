@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/dhowden/tag"
+)
+
+// Where a song's lyrics came from
+const (
+	lyricsSourceLRC      = "lrc"      // .lrc file next to the song
+	lyricsSourceEmbedded = "embedded" // SYLT or USLT frame, or a lyrics tag
+	lyricsSourceLRCLIB   = "lrclib"   // Downloaded from LRCLIB
+)
+
+// LyricLine is one timed line of synced lyrics
+type LyricLine struct {
+	TimeSec float64 `json:"timeSec"`
+	Text    string  `json:"text"`
+}
+
+// SyncedLyrics are a song's timed lyrics, scaled to the playback rate
+type SyncedLyrics struct {
+	FilePath string      `json:"filePath"`
+	Rate     float64     `json:"rate"` // Tempo the timestamps were scaled for
+	Lines    []LyricLine `json:"lines"`
+}
+
+// Lyrics are a song's plain and, when available, timed lyrics. Timed lines are scaled
+// to the playback rate.
+type Lyrics struct {
+	FilePath     string      `json:"filePath"`
+	Source       string      `json:"source"` // "lrc", "embedded" or "lrclib"
+	Plain        string      `json:"plain"`
+	Synced       []LyricLine `json:"synced"` // Empty when only plain lyrics are known
+	Rate         float64     `json:"rate"`   // Tempo the timestamps were scaled for
+	Instrumental bool        `json:"instrumental,omitempty"`
+}
+
+var (
+	lrcTimestamp = regexp.MustCompile(`\[(\d+):(\d+(?:\.\d+)?)\]`)
+	lrcOffset    = regexp.MustCompile(`^\[offset:\s*([+-]?\d+)\]`)
+)
+
+// parseLRC parses LRC lyrics, including lines with several timestamps and the [offset:] tag
+func parseLRC(content string) []LyricLine {
+	var lines []LyricLine
+	offset := 0.0
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if match := lrcOffset.FindStringSubmatch(line); match != nil {
+			// Positive offsets make lyrics appear sooner
+			if ms, err := strconv.Atoi(match[1]); err == nil {
+				offset = -float64(ms) / 1000
+			}
+			continue
+		}
+
+		stamps := lrcTimestamp.FindAllStringSubmatchIndex(line, -1)
+		if len(stamps) == 0 {
+			continue
+		}
+		text := strings.TrimSpace(line[stamps[len(stamps)-1][1]:])
+		for _, stamp := range stamps {
+			minutes, _ := strconv.Atoi(line[stamp[2]:stamp[3]])
+			seconds, _ := strconv.ParseFloat(line[stamp[4]:stamp[5]], 64)
+			lines = append(lines, LyricLine{TimeSec: float64(minutes)*60 + seconds, Text: text})
+		}
+	}
+
+	for i := range lines {
+		lines[i].TimeSec += offset
+		if lines[i].TimeSec < 0 {
+			lines[i].TimeSec = 0
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].TimeSec < lines[j].TimeSec })
+	return lines
+}
+
+// plainLyrics strips the timestamps and tags from LRC lyrics, leaving the text
+func plainLyrics(content string) string {
+	var text []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		stamps := lrcTimestamp.FindAllStringIndex(line, -1)
+		if len(stamps) > 0 {
+			line = strings.TrimSpace(line[stamps[len(stamps)-1][1]:])
+		} else if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			continue // [ar:], [ti:] and other ID tags
+		}
+		text = append(text, line)
+	}
+	return strings.TrimSpace(strings.Join(text, "\n"))
+}
+
+// linesText joins timed lines into plain lyrics
+func linesText(lines []LyricLine) string {
+	text := make([]string, len(lines))
+	for i, line := range lines {
+		text[i] = line.Text
+	}
+	return strings.TrimSpace(strings.Join(text, "\n"))
+}
+
+// decodeID3Text decodes ID3v2 text in the given encoding: 0 ISO-8859-1, 1 UTF-16 with
+// BOM, 2 UTF-16BE, 3 UTF-8
+func decodeID3Text(b []byte, encoding byte) string {
+	switch encoding {
+	case 1, 2:
+		var order binary.ByteOrder = binary.BigEndian
+		if len(b) >= 2 && b[0] == 0xFF && b[1] == 0xFE {
+			order, b = binary.LittleEndian, b[2:]
+		} else if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+			b = b[2:]
+		}
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = order.Uint16(b[i*2:])
+		}
+		return string(utf16.Decode(units))
+	case 3:
+		return string(b)
+	default:
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+	}
+}
+
+// cutID3Text splits null-terminated ID3v2 text off the front of b
+func cutID3Text(b []byte, encoding byte) (string, []byte, bool) {
+	if encoding == 1 || encoding == 2 {
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return decodeID3Text(b[:i], encoding), b[i+2:], true
+			}
+		}
+		return "", nil, false
+	}
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return "", nil, false
+	}
+	return decodeID3Text(b[:i], encoding), b[i+1:], true
+}
+
+// parseSYLT parses an ID3v2 SYLT frame with millisecond timestamps. Frames timed in
+// MPEG frames are skipped, nil is returned for them.
+func parseSYLT(frame []byte) []LyricLine {
+	// Encoding, language, timestamp format, content type, then the descriptor
+	if len(frame) < 6 || frame[4] != 2 {
+		return nil
+	}
+	encoding := frame[0]
+	_, rest, ok := cutID3Text(frame[6:], encoding)
+	if !ok {
+		return nil
+	}
+
+	var lines []LyricLine
+	for len(rest) > 0 {
+		var text string
+		if text, rest, ok = cutID3Text(rest, encoding); !ok || len(rest) < 4 {
+			break
+		}
+		ms := binary.BigEndian.Uint32(rest)
+		rest = rest[4:]
+		// Some taggers put the line break at the start of the next line instead of the end
+		lines = append(lines, LyricLine{TimeSec: float64(ms) / 1000, Text: strings.TrimSpace(text)})
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].TimeSec < lines[j].TimeSec })
+	return lines
+}
+
+// sidecarLyrics reads the .lrc file next to a song, nil when there's none
+func sidecarLyrics(filePath string) (*Lyrics, error) {
+	lrcPath := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".lrc"
+	data, err := os.ReadFile(lrcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading lyrics: %v", err)
+	}
+	content := string(data)
+	return &Lyrics{Source: lyricsSourceLRC, Plain: plainLyrics(content), Synced: parseLRC(content)}, nil
+}
+
+// embeddedLyrics reads the lyrics in a song's tags, nil when it has none
+func embeddedLyrics(filePath string) *Lyrics {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	metadata, err := tag.ReadFrom(file)
+	file.Close()
+	if err != nil {
+		return nil
+	}
+	return lyricsFromTags(metadata)
+}
+
+// lyricsFromTags returns the lyrics in ID3 USLT and SYLT frames, Vorbis LYRICS comments
+// or MP4 lyrics atoms, nil when there are none. A SYLT frame gives the timing, lyrics
+// holding LRC text are parsed as such.
+func lyricsFromTags(metadata tag.Metadata) *Lyrics {
+	if metadata == nil {
+		return nil
+	}
+	lyrics := &Lyrics{Source: lyricsSourceEmbedded}
+	for key, value := range metadata.Raw() {
+		frame, ok := value.([]byte)
+		if ok && (strings.HasPrefix(key, "SYLT") || strings.HasPrefix(key, "SLT")) {
+			if lines := parseSYLT(frame); len(lines) > 0 {
+				lyrics.Synced = lines
+				break
+			}
+		}
+	}
+	if text := strings.TrimSpace(metadata.Lyrics()); text != "" {
+		if lrcTimestamp.MatchString(text) {
+			if lyrics.Synced == nil {
+				lyrics.Synced = parseLRC(text)
+			}
+			text = plainLyrics(text)
+		}
+		lyrics.Plain = text
+	}
+	if lyrics.Plain == "" {
+		lyrics.Plain = linesText(lyrics.Synced)
+	}
+	if lyrics.Plain == "" {
+		return nil
+	}
+	return lyrics
+}
+
+// GetLyrics finds a song's lyrics: the .lrc file next to it, then its embedded lyrics,
+// then, when online lyrics are enabled, LRCLIB. Timed lines are scaled for
+// tempo-changing effects so they stay in sync with the altered audio.
+func (a *App) GetLyrics(filePath string) (Lyrics, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return Lyrics{}, err
+	}
+
+	lyrics, err := sidecarLyrics(filePath)
+	if err != nil {
+		return Lyrics{}, err
+	}
+	if lyrics == nil || len(lyrics.Synced) == 0 {
+		// A plain .lrc is still worth replacing with timed lyrics from the tags
+		if embedded := embeddedLyrics(filePath); embedded != nil && (lyrics == nil || len(embedded.Synced) > 0) {
+			lyrics = embedded
+		}
+	}
+	if lyrics == nil && a.settings.FetchLyricsOnline {
+		song, err := a.songMetadata(filePath, nil)
+		if err != nil {
+			return Lyrics{}, fmt.Errorf("error reading metadata: %v", err)
+		}
+		if lyrics, err = lrclibLyrics(song); err != nil {
+			logError("LRCLIB lookup failed for %s: %v", filePath, err)
+		}
+	}
+	if lyrics == nil {
+		return Lyrics{}, fmt.Errorf("no lyrics for this song")
+	}
+
+	lyrics.FilePath = filePath
+	lyrics.Rate = a.playbackRate()
+	synced := make([]LyricLine, len(lyrics.Synced))
+	for i, line := range lyrics.Synced {
+		synced[i] = LyricLine{TimeSec: line.TimeSec / lyrics.Rate, Text: line.Text}
+	}
+	lyrics.Synced = synced
+	return *lyrics, nil
+}
+
+// GetEmbeddedLyrics returns the lyrics stored in a song's tags, without looking for .lrc
+// files or online
+func (a *App) GetEmbeddedLyrics(filePath string) (Lyrics, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return Lyrics{}, err
+	}
+	lyrics := embeddedLyrics(filePath)
+	if lyrics == nil {
+		return Lyrics{}, fmt.Errorf("no embedded lyrics for this song")
+	}
+	lyrics.FilePath = filePath
+	lyrics.Rate = a.playbackRate()
+	for i := range lyrics.Synced {
+		lyrics.Synced[i].TimeSec /= lyrics.Rate
+	}
+	return *lyrics, nil
+}
+
+// GetSyncedLyrics returns a song's timed lyrics from wherever GetLyrics finds them,
+// scaled for tempo-changing effects
+func (a *App) GetSyncedLyrics(filePath string) (SyncedLyrics, error) {
+	lyrics, err := a.GetLyrics(filePath)
+	if err != nil {
+		return SyncedLyrics{}, err
+	}
+	if len(lyrics.Synced) == 0 {
+		return SyncedLyrics{}, fmt.Errorf("no synced lyrics for this song")
+	}
+	return SyncedLyrics{FilePath: lyrics.FilePath, Rate: lyrics.Rate, Lines: lyrics.Synced}, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// eventLyricLine is emitted with a LyricPosition when playback reaches another line of
+// the playing song's synced lyrics
+const eventLyricLine = "lyrics:line"
+
+// LyricPosition is the line of synced lyrics at a playback position
+type LyricPosition struct {
+	FilePath     string     `json:"filePath"`
+	Index        int        `json:"index"`          // Into the song's synced lines, -1 before the first
+	Line         *LyricLine `json:"line,omitempty"` // nil before the first line
+	Next         *LyricLine `json:"next,omitempty"` // nil on the last line
+	LineProgress float64    `json:"lineProgress"`   // 0.0 to 1.0 through the line, for karaoke-style fills
+}
+
+// lyricsTracker holds the playing song's synced lyrics and the line last announced
+type lyricsTracker struct {
+	filePath string
+	rate     float64 // Playback rate the lines were scaled for
+	lines    []LyricLine
+	loading  bool
+	index    int // Line last emitted
+	mutex    sync.Mutex
+}
+
+// lyricPosition finds the line being sung at positionSec
+func lyricPosition(filePath string, lines []LyricLine, positionSec float64) LyricPosition {
+	index := sort.Search(len(lines), func(i int) bool { return lines[i].TimeSec > positionSec }) - 1
+	position := LyricPosition{FilePath: filePath, Index: index}
+	if index >= 0 {
+		line := lines[index]
+		position.Line = &line
+	}
+	if index+1 < len(lines) {
+		next := lines[index+1]
+		position.Next = &next
+		if position.Line != nil && next.TimeSec > position.Line.TimeSec {
+			position.LineProgress = (positionSec - position.Line.TimeSec) / (next.TimeSec - position.Line.TimeSec)
+		}
+	} else if position.Line != nil {
+		position.LineProgress = 1
+	}
+	return position
+}
+
+// storeLyrics keeps the synced lines loaded for a song, unless another song started meanwhile
+func (a *App) storeLyrics(filePath string, rate float64, lines []LyricLine) {
+	a.lyrics.mutex.Lock()
+	defer a.lyrics.mutex.Unlock()
+	if a.lyrics.filePath == filePath && a.lyrics.rate == rate {
+		a.lyrics.lines = lines
+		a.lyrics.loading = false
+	}
+}
+
+// currentLyricLines returns the playing song's synced lines. Lyrics that aren't loaded yet
+// are loaded in the background and false is returned, unless wait is set.
+func (a *App) currentLyricLines(wait bool) (string, []LyricLine, bool) {
+	song := a.currentSong
+	if song == nil {
+		return "", nil, false
+	}
+	filePath, rate := song.FilePath, a.playbackRate()
+
+	a.lyrics.mutex.Lock()
+	if a.lyrics.filePath == filePath && a.lyrics.rate == rate {
+		lines, loading := a.lyrics.lines, a.lyrics.loading
+		a.lyrics.mutex.Unlock()
+		if !loading || !wait {
+			return filePath, lines, !loading
+		}
+	} else {
+		a.lyrics.filePath, a.lyrics.rate = filePath, rate
+		a.lyrics.lines, a.lyrics.loading, a.lyrics.index = nil, true, -2
+		a.lyrics.mutex.Unlock()
+		if !wait {
+			go func() {
+				lyrics, _ := a.GetLyrics(filePath)
+				a.storeLyrics(filePath, rate, lyrics.Synced)
+			}()
+			return filePath, nil, false
+		}
+	}
+
+	// The background load may still be running, loading again is cheap as LRCLIB is cached
+	lyrics, _ := a.GetLyrics(filePath)
+	a.storeLyrics(filePath, rate, lyrics.Synced)
+	return filePath, lyrics.Synced, true
+}
+
+// publishLyricLine tells the frontend when the playing song moves to another lyric line.
+// Only done when lyrics are shown.
+func (a *App) publishLyricLine(positionSec float64) {
+	if !a.settings.ShowLyrics {
+		return
+	}
+	filePath, lines, ok := a.currentLyricLines(false)
+	if !ok || len(lines) == 0 {
+		return
+	}
+	position := lyricPosition(filePath, lines, positionSec)
+
+	a.lyrics.mutex.Lock()
+	changed := a.lyrics.filePath == filePath && a.lyrics.index != position.Index
+	if changed {
+		a.lyrics.index = position.Index
+	}
+	a.lyrics.mutex.Unlock()
+	if changed {
+		a.emitEvent(eventLyricLine, position)
+	}
+}
+
+// GetCurrentLyricLine returns the line of the playing song's synced lyrics at a playback
+// position, in seconds of the audio as played with tempo effects
+func (a *App) GetCurrentLyricLine(positionSeconds float64) (LyricPosition, error) {
+	filePath, lines, _ := a.currentLyricLines(true)
+	if filePath == "" {
+		return LyricPosition{}, fmt.Errorf("no song is playing")
+	}
+	if len(lines) == 0 {
+		return LyricPosition{}, fmt.Errorf("no synced lyrics for this song")
+	}
+	return lyricPosition(filePath, lines, positionSeconds), nil
+}
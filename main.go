@@ -12,19 +12,28 @@ import (
 var assets embed.FS
 
 func main() {
+	setupLogging()
+
 	// Create an instance of the app structure
 	app := NewApp()
 
+	// Open the window with the size and state it was closed with
+	width, height, startState := app.loadWindowState().windowOptions()
+
 	// Create application with options
 	err := wails.Run(&options.App{
-		Title:  "Static",
-		Width:  1200,
-		Height: 800,
+		Title:            "Static",
+		Width:            width,
+		Height:           height,
+		WindowStartState: startState,
 		AssetServer: &assetserver.Options{
 			Assets: assets,
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
+		OnDomReady:       app.domReady,
+		OnShutdown:       app.shutdown,
+		OnBeforeClose:    app.beforeClose,
 		Bind: []interface{}{
 			app,
 		},
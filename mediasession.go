@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Media session policies. Other players are only detected through MPRIS, so yielding
+// works on Linux, elsewhere Static always keeps presence and media keys.
+const (
+	sessionPolicyPriority = "priority" // Keep presence and media keys even if another player is active
+	sessionPolicyYield    = "yield"    // Hand presence and media keys to another active player
+)
+
+// mediaSessionPollInterval controls how often other players are checked
+const mediaSessionPollInterval = 5 * time.Second
+
+// MediaSession describes another media player detected on the system
+type MediaSession struct {
+	BusName        string `json:"busName"`
+	Identity       string `json:"identity"`
+	PlaybackStatus string `json:"playbackStatus"`
+	Title          string `json:"title"`
+	Artist         string `json:"artist"`
+}
+
+// getOtherMediaSessions lists MPRIS players other than Static (Linux only)
+func (a *App) getOtherMediaSessions() ([]MediaSession, error) {
+	sessions := []MediaSession{}
+	if runtime.GOOS != "linux" || a.dbusConn == nil {
+		return sessions, nil
+	}
+
+	var names []string
+	err := a.dbusConn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names)
+	if err != nil {
+		return sessions, fmt.Errorf("failed to list D-Bus names: %v", err)
+	}
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, mprisInterface+".") || name == busName {
+			continue
+		}
+
+		obj := a.dbusConn.Object(name, mprisPath)
+		session := MediaSession{BusName: name}
+
+		if v, err := obj.GetProperty(mprisInterface + ".Identity"); err == nil {
+			session.Identity, _ = v.Value().(string)
+		}
+		if v, err := obj.GetProperty(playerInterface + ".PlaybackStatus"); err == nil {
+			session.PlaybackStatus, _ = v.Value().(string)
+		}
+		if v, err := obj.GetProperty(playerInterface + ".Metadata"); err == nil {
+			if metadata, ok := v.Value().(map[string]dbus.Variant); ok {
+				if title, ok := metadata["xesam:title"]; ok {
+					session.Title, _ = title.Value().(string)
+				}
+				if artists, ok := metadata["xesam:artist"]; ok {
+					if list, ok := artists.Value().([]string); ok {
+						session.Artist = strings.Join(list, ", ")
+					}
+				}
+			}
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// GetMediaSessions returns other detected media players and whether Static is yielding to them
+func (a *App) GetMediaSessions() (map[string]interface{}, error) {
+	sessions, err := a.getOtherMediaSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	a.sessionMutex.RLock()
+	yielding := a.sessionYielded
+	a.sessionMutex.RUnlock()
+
+	return map[string]interface{}{
+		"policy":    a.settings.MediaSessionPolicy,
+		"yielding":  yielding,
+		"sessions":  sessions,
+		"supported": runtime.GOOS == "linux",
+	}, nil
+}
+
+// shouldYieldSession reports whether Static should currently step back for another player
+func (a *App) shouldYieldSession() bool {
+	if a.settings.MediaSessionPolicy != sessionPolicyYield {
+		return false
+	}
+
+	a.sessionMutex.RLock()
+	defer a.sessionMutex.RUnlock()
+	return a.sessionYielded
+}
+
+// watchMediaSessions periodically checks for other active players and applies the session policy
+func (a *App) watchMediaSessions() {
+	ticker := time.NewTicker(mediaSessionPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.checkMediaSessions()
+	}
+}
+
+// checkMediaSessions yields or reclaims presence and media keys based on other players' state
+func (a *App) checkMediaSessions() {
+	otherPlaying := false
+	if a.settings.MediaSessionPolicy == sessionPolicyYield {
+		sessions, err := a.getOtherMediaSessions()
+		if err != nil {
+			logWarn("Media sessions: %v", err)
+			return
+		}
+		for _, session := range sessions {
+			if session.PlaybackStatus == "Playing" {
+				otherPlaying = true
+				break
+			}
+		}
+	}
+
+	a.sessionMutex.Lock()
+	changed := a.sessionYielded != otherPlaying
+	a.sessionYielded = otherPlaying
+	a.sessionMutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if otherPlaying {
+		logInfo("Media sessions: another player is active, yielding presence and media keys")
+		a.releaseMediaKeys()
+		if a.discordActive {
+			// Clear the presence rather than showing an idle one next to the other player's
+			if err := a.discord.setActivity(nil); err != nil {
+				logWarn("Media sessions: failed to clear Discord presence: %v", err)
+			}
+		}
+	} else {
+		logInfo("Media sessions: no other active player, reclaiming presence and media keys")
+		a.reclaimMediaKeys()
+		if a.discordActive && a.currentSong != nil {
+			a.UpdateDiscordPresence(a.currentSong, a.isPlaying)
+		}
+	}
+}
+
+// releaseMediaKeys drops the MPRIS bus name so desktop media keys reach the other player
+func (a *App) releaseMediaKeys() {
+	if a.dbusConn == nil {
+		return
+	}
+	if _, err := a.dbusConn.ReleaseName(busName); err != nil {
+		logError("Media sessions: failed to release %s: %v", busName, err)
+	}
+}
+
+// reclaimMediaKeys requests the MPRIS bus name again after yielding
+func (a *App) reclaimMediaKeys() {
+	if a.dbusConn == nil {
+		return
+	}
+	reply, err := a.dbusConn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		logError("Media sessions: failed to reclaim %s: %v", busName, err)
+		return
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner && reply != dbus.RequestNameReplyAlreadyOwner {
+		logWarn("Name %s already taken", busName)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"sync"
+)
+
+// Default in-memory cache sizes, in entries
+const (
+	defaultMetadataCacheSize  = 2000
+	defaultThumbnailCacheSize = 500
+)
+
+// Cover thumbnail edge limits for GetCoverThumbnail
+const (
+	defaultCoverThumbnailSize = 128
+	minCoverThumbnailSize     = 32
+	maxCoverThumbnailSize     = 512
+)
+
+// LRUStats describes one in-memory cache
+type LRUStats struct {
+	Entries   int     `json:"entries"`
+	Capacity  int     `json:"capacity"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Evictions int64   `json:"evictions"`
+	HitRate   float64 `json:"hitRate"` // 0 to 1, 0 before the first lookup
+}
+
+// CacheStats is returned by DebugCacheStats
+type CacheStats struct {
+	Metadata   LRUStats `json:"metadata"`
+	Thumbnails LRUStats `json:"thumbnails"`
+}
+
+// lruCache is a fixed-size least recently used cache keyed by string
+type lruCache struct {
+	capacity  int
+	order     *list.List // Front is the most recently used
+	items     map[string]*list.Element
+	hits      int64
+	misses    int64
+	evictions int64
+	mutex     sync.Mutex
+}
+
+// lruEntry is stored in the cache's list
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+// cachedSong is a metadata cache value, valid while the file's version is unchanged
+type cachedSong struct {
+	version string
+	song    Song
+}
+
+// newLRUCache creates a cache holding at most capacity entries
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns a cached value and marks it as recently used
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry).value, true
+}
+
+// put stores a value, evicting the least recently used entries over capacity
+func (c *lruCache) put(key string, value interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		element.Value.(*lruEntry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value})
+	c.evictLocked()
+}
+
+// resize changes the capacity, dropping entries if it shrank
+func (c *lruCache) resize(capacity int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.capacity = capacity
+	c.evictLocked()
+}
+
+// evictLocked trims the cache to capacity, caller must hold the mutex
+func (c *lruCache) evictLocked() {
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+		c.evictions++
+	}
+}
+
+// stats returns the cache's counters
+func (c *lruCache) stats() LRUStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := LRUStats{
+		Entries:   c.order.Len(),
+		Capacity:  c.capacity,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRate = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
+
+// resizeMemoryCaches applies the cache sizes from the settings
+func (a *App) resizeMemoryCaches(settings *Settings) {
+	if settings.MetadataCacheSize > 0 {
+		a.songCache.resize(settings.MetadataCacheSize)
+	}
+	if settings.ThumbnailCacheSize > 0 {
+		a.thumbCache.resize(settings.ThumbnailCacheSize)
+	}
+}
+
+// GetSongMetadata returns a song's tags, from memory for recently used songs
+func (a *App) GetSongMetadata(filePath string) (Song, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+
+	song, err := a.songMetadata(filePath, nil)
+	if err != nil {
+		return Song{}, fmt.Errorf("error reading metadata: %v", err)
+	}
+	return song, nil
+}
+
+// GetCoverThumbnail returns a song's cover scaled to fit size pixels as a PNG data URL,
+// or "" when the song has no cover. Meant for list rows, where full covers are too big.
+func (a *App) GetCoverThumbnail(filePath string, size int) (string, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	if size <= 0 {
+		size = defaultCoverThumbnailSize
+	}
+	if size < minCoverThumbnailSize {
+		size = minCoverThumbnailSize
+	}
+	if size > maxCoverThumbnailSize {
+		size = maxCoverThumbnailSize
+	}
+
+	key := fmt.Sprintf("%s|%d|%s", filePath, size, fileVersion(filePath))
+	if cached, ok := a.thumbCache.get(key); ok {
+		return cached.(string), nil
+	}
+
+	song, err := a.songMetadata(filePath, nil)
+	if err != nil {
+		return "", fmt.Errorf("error reading metadata: %v", err)
+	}
+
+	thumbnail := ""
+	if coverData := a.songCoverData(song); coverData != "" {
+		data, _, err := a.sharedCover(coverData)
+		if err != nil {
+			return "", fmt.Errorf("error reading cover: %v", err)
+		}
+		cover, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return "", fmt.Errorf("unsupported cover format: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, scaleImage(cover, size)); err != nil {
+			return "", fmt.Errorf("error encoding thumbnail: %v", err)
+		}
+		thumbnail = "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+
+	a.thumbCache.put(key, thumbnail)
+	return thumbnail, nil
+}
+
+// DebugCacheStats reports hit rates and sizes of the in-memory metadata and thumbnail caches
+func (a *App) DebugCacheStats() CacheStats {
+	return CacheStats{
+		Metadata:   a.songCache.stats(),
+		Thumbnails: a.thumbCache.stats(),
+	}
+}
+
+// cachedSongMetadata returns a song from memory if the file is unchanged since it was cached
+func (a *App) cachedSongMetadata(filePath string, info os.FileInfo) (Song, bool) {
+	cached, ok := a.songCache.get(filePath)
+	if !ok {
+		return Song{}, false
+	}
+	entry := cached.(cachedSong)
+	if entry.version != statVersion(info) {
+		return Song{}, false
+	}
+	return entry.song, true
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// musicBrainzSearchLimit is how many recordings a text search asks MusicBrainz for
+const musicBrainzSearchLimit = 5
+
+// Where a metadata suggestion came from
+const (
+	suggestionAcoustID    = "acoustid"    // Fingerprint match, enriched from MusicBrainz
+	suggestionMusicBrainz = "musicbrainz" // MusicBrainz search by the current tags
+)
+
+// MetadataSuggestion is a proposed correction of a song's tags
+type MetadataSuggestion struct {
+	RecordingID string   `json:"recordingId"` // MusicBrainz recording MBID
+	Title       string   `json:"title"`
+	Artist      string   `json:"artist"`
+	Album       string   `json:"album"`
+	Year        int      `json:"year,omitempty"`
+	Score       float64  `json:"score"`   // Confidence, 0.0 to 1.0
+	Source      string   `json:"source"`  // "acoustid" or "musicbrainz"
+	Changes     []string `json:"changes"` // Fields that differ from the song's tags
+}
+
+// MetadataLookup holds a song's current tags and the suggested corrections, best first
+type MetadataLookup struct {
+	FilePath    string               `json:"filePath"`
+	Title       string               `json:"title"`
+	Artist      string               `json:"artist"`
+	Album       string               `json:"album"`
+	Year        int                  `json:"year,omitempty"`
+	Suggestions []MetadataSuggestion `json:"suggestions"`
+}
+
+// musicBrainzSearchResponse is the subset of a MusicBrainz recording search we use
+type musicBrainzSearchResponse struct {
+	Recordings []struct {
+		ID           string `json:"id"`
+		Score        int    `json:"score"` // 0-100
+		Title        string `json:"title"`
+		ArtistCredit []struct {
+			Name       string `json:"name"`
+			JoinPhrase string `json:"joinphrase"`
+		} `json:"artist-credit"`
+		Releases []struct {
+			Title string `json:"title"`
+			Date  string `json:"date"`
+		} `json:"releases"`
+	} `json:"recordings"`
+}
+
+// luceneQuote quotes a value for a MusicBrainz search query
+func luceneQuote(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	return `"` + value + `"`
+}
+
+// releaseYear returns the year of a "YYYY-MM-DD" release date, 0 when there's none
+func releaseYear(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+// searchMusicBrainz looks recordings up by title and, when known, artist
+func searchMusicBrainz(title string, artist string) ([]MetadataSuggestion, error) {
+	query := "recording:" + luceneQuote(title)
+	if artist != "" {
+		query += " AND artist:" + luceneQuote(artist)
+	}
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("limit", strconv.Itoa(musicBrainzSearchLimit))
+	params.Set("fmt", "json")
+
+	req, err := http.NewRequest("GET", musicBrainzURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", musicBrainzAgent) // Required by MusicBrainz
+
+	resp, err := identifyHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("MusicBrainz search failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("MusicBrainz returned HTTP %d", resp.StatusCode)
+	}
+
+	var result musicBrainzSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse MusicBrainz response: %v", err)
+	}
+
+	var suggestions []MetadataSuggestion
+	for _, recording := range result.Recordings {
+		var credit strings.Builder
+		for _, artist := range recording.ArtistCredit {
+			credit.WriteString(artist.Name + artist.JoinPhrase)
+		}
+		suggestion := MetadataSuggestion{
+			RecordingID: recording.ID,
+			Title:       recording.Title,
+			Artist:      credit.String(),
+			Score:       float64(recording.Score) / 100,
+			Source:      suggestionMusicBrainz,
+		}
+		// The earliest release is usually the original album
+		date := ""
+		for _, release := range recording.Releases {
+			if suggestion.Album == "" || (release.Date != "" && (date == "" || release.Date < date)) {
+				suggestion.Album, date = release.Title, release.Date
+			}
+		}
+		suggestion.Year = releaseYear(date)
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
+
+// tagChanges lists the fields of a suggestion that differ from the song's tags
+func tagChanges(song Song, suggestion MetadataSuggestion) []string {
+	changes := []string{}
+	differs := func(current string, suggested string) bool {
+		return suggested != "" && strings.TrimSpace(current) != suggested
+	}
+	if differs(song.Title, suggestion.Title) {
+		changes = append(changes, "title")
+	}
+	if differs(song.Artist, suggestion.Artist) {
+		changes = append(changes, "artist")
+	}
+	if differs(song.Album, suggestion.Album) {
+		changes = append(changes, "album")
+	}
+	if suggestion.Year > 0 && song.Year != suggestion.Year {
+		changes = append(changes, "year")
+	}
+	return changes
+}
+
+// LookupMetadata suggests tag corrections for a song from MusicBrainz. With an AcoustID
+// API key and fpcalc installed the file is fingerprinted first, otherwise or when that
+// finds nothing MusicBrainz is searched by the current title and artist.
+func (a *App) LookupMetadata(filePath string) (MetadataLookup, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return MetadataLookup{}, err
+	}
+	song, err := a.songMetadata(filePath, nil)
+	if err != nil {
+		return MetadataLookup{}, fmt.Errorf("error reading metadata: %v", err)
+	}
+
+	var suggestions []MetadataSuggestion
+	if a.settings.AcoustIDAPIKey != "" {
+		result, err := a.IdentifyTrack(filePath)
+		if err != nil {
+			logWarn("Metadata lookup: fingerprinting failed, searching by tags: %v", err)
+		}
+		for _, match := range result.Matches {
+			suggestions = append(suggestions, MetadataSuggestion{
+				RecordingID: match.RecordingID,
+				Title:       match.Title,
+				Artist:      match.Artist,
+				Album:       match.Album,
+				Year:        releaseYear(match.ReleaseDate),
+				Score:       match.Score,
+				Source:      suggestionAcoustID,
+			})
+		}
+	}
+	if len(suggestions) == 0 {
+		artist := song.Artist
+		if artist == "Unknown Artist" {
+			artist = ""
+		}
+		if suggestions, err = searchMusicBrainz(song.Title, artist); err != nil {
+			return MetadataLookup{}, err
+		}
+	}
+
+	lookup := MetadataLookup{
+		FilePath:    filePath,
+		Title:       song.Title,
+		Artist:      song.Artist,
+		Album:       song.Album,
+		Year:        song.Year,
+		Suggestions: []MetadataSuggestion{},
+	}
+	for _, suggestion := range suggestions {
+		suggestion.Changes = tagChanges(song, suggestion)
+		lookup.Suggestions = append(lookup.Suggestions, suggestion)
+	}
+	logInfo("Metadata lookup: %d suggestions for %s", len(lookup.Suggestions), filePath)
+	return lookup, nil
+}
+
+// ApplyMetadata writes a suggestion's title, artist, album and year to the song file.
+// Empty fields are left as they are.
+func (a *App) ApplyMetadata(filePath string, suggestion MetadataSuggestion) (Song, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+	if !a.checkFFmpegAvailable() {
+		return Song{}, fmt.Errorf("FFmpeg is required to edit tags")
+	}
+
+	changes := make(map[string]string)
+	for key, value := range map[string]string{"title": suggestion.Title, "artist": suggestion.Artist, "album": suggestion.Album} {
+		if value = strings.TrimSpace(value); value != "" {
+			changes[key] = value
+		}
+	}
+	if suggestion.Year > 0 {
+		changes["date"] = strconv.Itoa(suggestion.Year)
+	}
+	if len(changes) == 0 {
+		return Song{}, fmt.Errorf("suggestion has no tags to write")
+	}
+	return a.retagSong(filePath, changes)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Ways MigrateFlatLibrary groups files into playlists
+const (
+	migrateByAlbum  = "album"
+	migrateByArtist = "artist"
+	migrateByFolder = "folder"
+)
+
+// migrateUnsortedPlaylist takes files directly in the source folder with the folder strategy
+const migrateUnsortedPlaylist = "Unsorted"
+
+// MigrationMove is one file of a migration
+type MigrationMove struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Error string `json:"error,omitempty"` // Set when the move failed, the file stays where it was
+}
+
+// MigrationPlaylist is a playlist folder a migration creates or adds to
+type MigrationPlaylist struct {
+	Name     string          `json:"name"`
+	Folder   string          `json:"folder"`
+	Existing bool            `json:"existing"` // The folder is already in the library, songs are appended
+	Songs    []MigrationMove `json:"songs"`
+}
+
+// MigrationPlan describes what MigrateFlatLibrary did, or would do on a dry run
+type MigrationPlan struct {
+	SourceDir  string              `json:"sourceDir"`
+	Strategy   string              `json:"strategy"`
+	DryRun     bool                `json:"dryRun"`
+	Playlists  []MigrationPlaylist `json:"playlists"`
+	Unreadable []string            `json:"unreadable"` // Audio files whose tags couldn't be read, left in place
+	Moved      int                 `json:"moved"`
+	Failed     int                 `json:"failed"`
+}
+
+// playlistFolderName makes a group name usable as a folder name on every OS
+func playlistFolderName(name string) string {
+	return safeFileName(name)
+}
+
+// migrationGroup returns the playlist a song goes into for a strategy
+func migrationGroup(sourceDir string, song Song, strategy string) string {
+	switch strategy {
+	case migrateByAlbum:
+		return song.Album
+	case migrateByArtist:
+		return song.Artist
+	}
+	relative, err := filepath.Rel(sourceDir, filepath.Dir(song.FilePath))
+	if err != nil || relative == "." {
+		return migrateUnsortedPlaylist
+	}
+	return strings.Join(strings.Split(relative, string(filepath.Separator)), " - ")
+}
+
+// migrationSourceFiles lists the audio files below sourceDir, leaving out the library
+// itself in case the static folder is inside the source
+func migrationSourceFiles(sourceDir string, staticPath string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(sourceDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Unreadable folders are skipped
+		}
+		if entry.IsDir() {
+			if pathKey(path) == pathKey(staticPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isSupportedAudioFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// MigrateFlatLibrary organizes a folder of loose audio files into playlist folders in the
+// static folder, one per album, artist or source subfolder depending on strategy. Files
+// are moved into <playlist>/musics/ and each playlist gets a playlist.toml listing its
+// songs in order. With dryRun nothing is touched and the returned plan is a preview.
+func (a *App) MigrateFlatLibrary(sourceDir string, strategy string, dryRun bool) (MigrationPlan, error) {
+	sourceDir = normalizePath(sourceDir)
+	strategy = strings.ToLower(strings.TrimSpace(strategy))
+	if strategy == "" {
+		strategy = migrateByAlbum
+	}
+	if strategy != migrateByAlbum && strategy != migrateByArtist && strategy != migrateByFolder {
+		return MigrationPlan{}, fmt.Errorf("unknown migration strategy: %s", strategy)
+	}
+	if info, err := os.Stat(sourceDir); err != nil || !info.IsDir() {
+		return MigrationPlan{}, fmt.Errorf("not a folder: %s", sourceDir)
+	}
+	staticPath := a.GetStaticFolderPath()
+	if staticPath == "" {
+		return MigrationPlan{}, fmt.Errorf("static folder not set")
+	}
+	if pathKey(sourceDir) == pathKey(staticPath) {
+		return MigrationPlan{}, fmt.Errorf("the source folder is the static folder")
+	}
+
+	files, err := migrationSourceFiles(sourceDir, staticPath)
+	if err != nil {
+		return MigrationPlan{}, fmt.Errorf("error reading %s: %v", sourceDir, err)
+	}
+
+	plan := MigrationPlan{
+		SourceDir:  sourceDir,
+		Strategy:   strategy,
+		DryRun:     dryRun,
+		Playlists:  []MigrationPlaylist{},
+		Unreadable: []string{},
+	}
+
+	// Tags are read without storing the songs in the index, they're about to move
+	byFolder := make(map[string]int)
+	claimed := make(map[string]bool)
+	for i, result := range a.songMetadataBatch(files, make(map[string]indexEntry)) {
+		if result.err != nil {
+			plan.Unreadable = append(plan.Unreadable, files[i])
+			continue
+		}
+		name := strings.TrimSpace(migrationGroup(sourceDir, result.song, strategy))
+		folder := playlistFolderName(name)
+		if folder == "" {
+			name, folder = migrateUnsortedPlaylist, migrateUnsortedPlaylist
+		}
+
+		index, ok := byFolder[strings.ToLower(folder)]
+		if !ok {
+			playlistDir := filepath.Join(staticPath, folder)
+			_, statErr := os.Stat(playlistDir)
+			plan.Playlists = append(plan.Playlists, MigrationPlaylist{
+				Name:     name,
+				Folder:   playlistDir,
+				Existing: statErr == nil,
+			})
+			index = len(plan.Playlists) - 1
+			byFolder[strings.ToLower(folder)] = index
+		}
+		playlist := &plan.Playlists[index]
+
+		// Numbered like availablePath, also avoiding names claimed earlier in this plan
+		musicsDir := filepath.Join(playlist.Folder, "musics")
+		ext := filepath.Ext(files[i])
+		base := strings.TrimSuffix(filepath.Base(files[i]), ext)
+		destination := filepath.Join(musicsDir, base+ext)
+		for n := 2; ; n++ {
+			if _, err := os.Stat(destination); os.IsNotExist(err) && !claimed[pathKey(destination)] {
+				break
+			}
+			destination = filepath.Join(musicsDir, fmt.Sprintf("%s (%d)%s", base, n, ext))
+		}
+		claimed[pathKey(destination)] = true
+		playlist.Songs = append(playlist.Songs, MigrationMove{From: files[i], To: destination})
+	}
+
+	if dryRun {
+		return plan, nil
+	}
+
+	for i := range plan.Playlists {
+		a.migratePlaylist(&plan.Playlists[i], sourceDir, &plan)
+	}
+
+	logInfo("Migration: moved %d files from %s into %d playlists (%d failed)",
+		plan.Moved, sourceDir, len(plan.Playlists), plan.Failed)
+	return plan, nil
+}
+
+// migratePlaylist moves a planned playlist's songs and writes its playlist.toml, new songs
+// are numbered after the songs a playlist that already existed has
+func (a *App) migratePlaylist(playlist *MigrationPlaylist, sourceDir string, plan *MigrationPlan) {
+	musicsDir := filepath.Join(playlist.Folder, "musics")
+	if err := os.MkdirAll(musicsDir, 0755); err != nil {
+		for i := range playlist.Songs {
+			playlist.Songs[i].Error = err.Error()
+		}
+		plan.Failed += len(playlist.Songs)
+		return
+	}
+
+	config := PlaylistConfig{
+		Name:        playlist.Name,
+		Description: "Migrated from " + sourceDir,
+	}
+	// A playlist.toml that doesn't parse is left alone rather than overwritten
+	writeConfig := true
+	playlistFile := filepath.Join(playlist.Folder, "playlist.toml")
+	if _, err := os.Stat(playlistFile); err == nil {
+		if _, err := toml.DecodeFile(playlistFile, &config); err != nil {
+			logError("Migration: %s: error parsing playlist.toml: %v", playlist.Folder, err)
+			writeConfig = false
+		}
+	}
+	if config.Songs == nil {
+		config.Songs = make(map[string]int)
+	}
+	nextPosition := 1
+	for _, position := range config.Songs {
+		if position >= nextPosition {
+			nextPosition = position + 1
+		}
+	}
+
+	for i := range playlist.Songs {
+		move := &playlist.Songs[i]
+		if err := moveFile(move.From, move.To); err != nil {
+			move.Error = err.Error()
+			plan.Failed++
+			continue
+		}
+		config.Songs[filepath.Base(move.To)] = nextPosition
+		nextPosition++
+		plan.Moved++
+	}
+
+	if writeConfig {
+		if err := a.savePlaylistConfig(playlist.Folder, config); err != nil {
+			logWarn("Migration: %v", err)
+		}
+	}
+
+	a.playlistMutex.Lock()
+	delete(a.playlistCache, filepath.Clean(playlist.Folder))
+	a.playlistMutex.Unlock()
+}
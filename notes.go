@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// maxTrackNoteLength keeps notes to annotations rather than documents
+const maxTrackNoteLength = 2000
+
+// noteStore keeps the personal notes attached to songs
+type noteStore struct {
+	path  string
+	notes map[string]string // track ID, or path for files without one -> note
+	mutex sync.Mutex
+}
+
+// newNoteStore creates a note store backed by the given file
+func newNoteStore(path string) *noteStore {
+	return &noteStore{path: path, notes: make(map[string]string)}
+}
+
+// load reads the notes from disk
+func (n *noteStore) load() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	data, err := os.ReadFile(n.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading notes: %v", err)
+	}
+	if err := json.Unmarshal(data, &n.notes); err != nil {
+		return fmt.Errorf("error parsing notes: %v", err)
+	}
+	if n.notes == nil {
+		n.notes = make(map[string]string)
+	}
+	return nil
+}
+
+// get returns the note of a song
+func (n *noteStore) get(key string) string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.notes[key]
+}
+
+// set stores a note, or removes it for "", and saves the store
+func (n *noteStore) set(key string, note string) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if note == "" {
+		delete(n.notes, key)
+	} else {
+		n.notes[key] = note
+	}
+	return writeJSONFile(n.path, n.notes)
+}
+
+// withUserData returns a song with what the user assigned to it: language, note and rating
+func (a *App) withUserData(song Song) Song {
+	song = a.withLanguage(song)
+	song.Note = a.notes.get(userDataKey(song))
+	return a.withRating(song)
+}
+
+// moveUserData keeps a song's language, note and rating after its track ID changed
+func (a *App) moveUserData(oldKey string, newKey string) {
+	if oldKey == newKey {
+		return
+	}
+	if language, ok := a.languages.get(oldKey); ok {
+		if err := a.languages.set(newKey, language); err == nil {
+			a.languages.set(oldKey, "")
+		}
+	}
+	if note := a.notes.get(oldKey); note != "" {
+		if err := a.notes.set(newKey, note); err == nil {
+			a.notes.set(oldKey, "")
+		}
+	}
+	if err := a.ratings.move(oldKey, newKey); err != nil {
+		logWarn("Could not move rating: %v", err)
+	}
+}
+
+// SetTrackNote attaches a personal note to a song, "" removes it
+func (a *App) SetTrackNote(filePath string, text string) (Song, error) {
+	filePath, err := a.resolveTrackRef(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) > maxTrackNoteLength {
+		return Song{}, fmt.Errorf("notes are limited to %d characters", maxTrackNoteLength)
+	}
+	song, err := a.songMetadata(filePath, nil)
+	if err != nil {
+		return Song{}, fmt.Errorf("error reading metadata: %v", err)
+	}
+	if err := a.notes.set(userDataKey(song), text); err != nil {
+		return Song{}, err
+	}
+
+	song.Note = text
+	a.refreshSong(song)
+	a.emitEvent(eventSongUpdated, song)
+	return listSong(song), nil
+}
+
+// GetTrackNote returns the note attached to a song, "" when it has none
+func (a *App) GetTrackNote(filePath string) (string, error) {
+	filePath, err := a.resolveTrackRef(filePath)
+	if err != nil {
+		return "", err
+	}
+	song, err := a.songMetadata(filePath, nil)
+	if err != nil {
+		return "", fmt.Errorf("error reading metadata: %v", err)
+	}
+	return song.Note, nil
+}
+
+// SearchTrackNotes returns the library's songs whose notes contain the query
+func (a *App) SearchTrackNotes(query string) ([]Song, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	songs, err := a.uniqueLibrarySongs()
+	if err != nil {
+		return nil, err
+	}
+	matching := []Song{}
+	for _, song := range songs {
+		if song.Note != "" && strings.Contains(strings.ToLower(song.Note), query) {
+			matching = append(matching, listSong(song))
+		}
+	}
+	return matching, nil
+}
+
+// importTrackNote attaches a note from an imported file to a song that has none yet
+func (a *App) importTrackNote(song Song, note string) {
+	note = strings.TrimSpace(note)
+	if note == "" || song.Note != "" || len([]rune(note)) > maxTrackNoteLength {
+		return
+	}
+	if err := a.notes.set(userDataKey(song), note); err != nil {
+		logWarn("Could not import note: %v", err)
+		return
+	}
+	song.Note = note
+	a.refreshSong(song)
+}
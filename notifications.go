@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// eventTrackNotification asks the frontend to show a track change notification on
+// desktops where the backend can't post one itself
+const eventTrackNotification = "notification:track"
+
+// Reasons track change notifications are held back
+const (
+	notifyBlockedDisabled   = "disabled"
+	notifyBlockedDND        = "do-not-disturb"
+	notifyBlockedFullscreen = "fullscreen"
+)
+
+// freedesktop notification server
+const (
+	notificationsService   = "org.freedesktop.Notifications"
+	notificationsPath      = "/org/freedesktop/Notifications"
+	notificationsInterface = "org.freedesktop.Notifications"
+)
+
+// NotificationState tells the settings page whether track notifications would show now
+type NotificationState struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"` // Why they're held back: "disabled", "do-not-disturb" or "fullscreen"
+}
+
+// trackNotifier remembers the last notification so the next track replaces it
+type trackNotifier struct {
+	lastID uint32
+	mutex  sync.Mutex
+}
+
+// notificationBlock returns why a track change notification shouldn't show right now,
+// or "" when it may. The override setting shows them over DND and fullscreen apps too.
+func (a *App) notificationBlock() string {
+	if !a.settings.ShowNotifications {
+		return notifyBlockedDisabled
+	}
+	if a.settings.NotifyDuringDND {
+		return ""
+	}
+	if runtime.GOOS == "linux" {
+		if a.linuxDoNotDisturb() {
+			return notifyBlockedDND
+		}
+		if x11FullscreenActive() {
+			return notifyBlockedFullscreen
+		}
+		return ""
+	}
+	return userNotificationState()
+}
+
+// linuxDoNotDisturb checks the notification server's inhibition flag, which KDE Plasma
+// sets in Do Not Disturb mode, and GNOME's banner setting
+func (a *App) linuxDoNotDisturb() bool {
+	if a.dbusConn != nil {
+		obj := a.dbusConn.Object(notificationsService, notificationsPath)
+		if v, err := obj.GetProperty(notificationsInterface + ".Inhibited"); err == nil {
+			if inhibited, ok := v.Value().(bool); ok && inhibited {
+				return true
+			}
+		}
+	}
+
+	output, err := exec.Command("gsettings", "get", "org.gnome.desktop.notifications", "show-banners").Output()
+	return err == nil && strings.TrimSpace(string(output)) == "false"
+}
+
+// x11FullscreenActive reports whether the focused window is fullscreen, like a movie,
+// game or presentation. Wayland compositors don't expose this, only X11 and XWayland
+// windows are detected.
+func x11FullscreenActive() bool {
+	if os.Getenv("DISPLAY") == "" {
+		return false
+	}
+
+	// "_NET_ACTIVE_WINDOW(WINDOW): window id # 0x3a00007"
+	output, err := exec.Command("xprop", "-root", "_NET_ACTIVE_WINDOW").Output()
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return false
+	}
+	windowID := strings.TrimSuffix(fields[len(fields)-1], ",")
+	if !strings.HasPrefix(windowID, "0x") || windowID == "0x0" {
+		return false
+	}
+
+	state, err := exec.Command("xprop", "-id", windowID, "_NET_WM_STATE").Output()
+	return err == nil && strings.Contains(string(state), "_NET_WM_STATE_FULLSCREEN")
+}
+
+// notifyTrackChange shows a notification for a newly started song unless the desktop
+// is in Do Not Disturb mode or a fullscreen app is in front
+func (a *App) notifyTrackChange(song Song) {
+	if reason := a.notificationBlock(); reason != "" {
+		if reason != notifyBlockedDisabled {
+			logDebug("Track notification suppressed: %s", reason)
+		}
+		return
+	}
+
+	title := song.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(song.FilePath), filepath.Ext(song.FilePath))
+	}
+	body := song.Artist
+	if song.Album != "" {
+		if body != "" {
+			body += " — "
+		}
+		body += song.Album
+	}
+
+	if runtime.GOOS != "linux" || a.dbusConn == nil {
+		a.emitEvent(eventTrackNotification, map[string]string{"title": title, "body": body})
+		return
+	}
+
+	a.notifier.mutex.Lock()
+	defer a.notifier.mutex.Unlock()
+
+	hints := map[string]dbus.Variant{
+		"category":      dbus.MakeVariant("x-gnome.music"),
+		"transient":     dbus.MakeVariant(true), // Don't pile up in the notification history
+		"desktop-entry": dbus.MakeVariant("static"),
+	}
+	obj := a.dbusConn.Object(notificationsService, notificationsPath)
+	call := obj.Call(notificationsInterface+".Notify", 0,
+		"Static", a.notifier.lastID, "audio-x-generic", title, body, []string{}, hints, int32(-1))
+	if call.Err != nil {
+		logError("Failed to show track notification: %v", call.Err)
+		return
+	}
+	call.Store(&a.notifier.lastID)
+}
+
+// GetNotificationState reports whether track notifications would show right now
+func (a *App) GetNotificationState() NotificationState {
+	reason := a.notificationBlock()
+	return NotificationState{Allowed: reason == "", Reason: reason}
+}
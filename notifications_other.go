@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+// userNotificationState is only implemented on Windows, Linux checks the desktop itself
+func userNotificationState() string {
+	return ""
+}
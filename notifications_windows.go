@@ -0,0 +1,36 @@
+//go:build windows
+
+package main
+
+import "unsafe"
+
+var procSHQueryUserNotificationState = shell32.NewProc("SHQueryUserNotificationState")
+
+// QUERY_USER_NOTIFICATION_STATE values
+const (
+	qunsBusy                 = 2 // A fullscreen app is running
+	qunsRunningD3DFullScreen = 3
+	qunsPresentationMode     = 4
+	qunsQuietTime            = 6 // The first hour after a new user's first sign-in
+	qunsApp                  = 7 // A Windows Store app is fullscreen
+)
+
+// userNotificationState asks Windows whether notifications are welcome, which they
+// aren't over fullscreen games, videos and presentations
+func userNotificationState() string {
+	var state uint32
+	if err := procSHQueryUserNotificationState.Find(); err != nil {
+		return ""
+	}
+	if hr, _, _ := procSHQueryUserNotificationState.Call(uintptr(unsafe.Pointer(&state))); hr != 0 {
+		return ""
+	}
+
+	switch state {
+	case qunsBusy, qunsRunningD3DFullScreen, qunsApp:
+		return notifyBlockedFullscreen
+	case qunsPresentationMode, qunsQuietTime:
+		return notifyBlockedDND
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+//go:build darwin && cgo
+
+package main
+
+/*
+#cgo CFLAGS: -x objective-c -fobjc-arc
+#cgo LDFLAGS: -framework Foundation -framework AppKit -framework MediaPlayer
+#include <stdlib.h>
+#include "nowplaying_darwin.h"
+*/
+import "C"
+
+import (
+	"math"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// eventSeekRequested asks the frontend player to seek, with the position in seconds
+const eventSeekRequested = "playback:seek-requested"
+
+// nowPlayingSeekTolerance is how far the reported position may drift from the
+// extrapolated one before Control Center is told about a seek
+const nowPlayingSeekTolerance = 2.0
+
+// nowPlayingCommands maps MPRemoteCommandCenter commands to web remote actions,
+// which the frontend player already handles
+var nowPlayingCommands = map[C.int]string{
+	C.STATIC_COMMAND_PLAY:     "play",
+	C.STATIC_COMMAND_PAUSE:    "pause",
+	C.STATIC_COMMAND_TOGGLE:   "toggle",
+	C.STATIC_COMMAND_NEXT:     "next",
+	C.STATIC_COMMAND_PREVIOUS: "previous",
+}
+
+// nowPlaying tracks what was last published to MPNowPlayingInfoCenter
+var nowPlaying struct {
+	app       *App // Receives remote commands
+	init      sync.Once
+	song      *Song
+	playing   bool
+	elapsed   float64   // Position when last reported
+	updatedAt time.Time // When elapsed was reported, later positions are extrapolated from it
+	mutex     sync.Mutex
+}
+
+// currentNowPlayingPosition extrapolates the playback position, caller must hold the mutex
+func (a *App) currentNowPlayingPosition() float64 {
+	if !nowPlaying.playing || nowPlaying.updatedAt.IsZero() {
+		return nowPlaying.elapsed
+	}
+	return nowPlaying.elapsed + time.Since(nowPlaying.updatedAt).Seconds()*a.playbackRate()
+}
+
+//export staticRemoteCommand
+func staticRemoteCommand(command C.int, value C.double) {
+	app := nowPlaying.app
+	if app == nil {
+		return
+	}
+	if command == C.STATIC_COMMAND_SEEK {
+		app.emitEvent(eventSeekRequested, float64(value))
+		return
+	}
+	if action, ok := nowPlayingCommands[command]; ok {
+		app.emitEvent(eventRemoteCommand, action)
+	}
+}
+
+// publishNowPlaying shows a song in Control Center, the Touch Bar and on AirPods controls
+func (a *App) publishNowPlaying(song *Song, isPlaying bool) error {
+	nowPlaying.init.Do(func() {
+		nowPlaying.app = a
+		C.staticNowPlayingInit()
+	})
+
+	if song == nil {
+		nowPlaying.mutex.Lock()
+		nowPlaying.song = nil
+		nowPlaying.mutex.Unlock()
+		C.staticNowPlayingClear()
+		return nil
+	}
+
+	// Play/pause toggles keep the position, a new song starts from zero
+	nowPlaying.mutex.Lock()
+	elapsed := 0.0
+	if nowPlaying.song != nil && nowPlaying.song.FilePath == song.FilePath {
+		elapsed = a.currentNowPlayingPosition()
+	}
+	nowPlaying.song = song
+	nowPlaying.playing = isPlaying
+	nowPlaying.elapsed = elapsed
+	nowPlaying.updatedAt = time.Now()
+	nowPlaying.mutex.Unlock()
+
+	a.setNowPlayingInfo(song, isPlaying, elapsed)
+	return nil
+}
+
+// syncNowPlayingPosition republishes the elapsed time after a seek, normal playback
+// is extrapolated by macOS from the playback rate
+func (a *App) syncNowPlayingPosition(currentTimeSeconds float64) {
+	nowPlaying.mutex.Lock()
+	song, playing := nowPlaying.song, nowPlaying.playing
+	if song == nil {
+		nowPlaying.mutex.Unlock()
+		return
+	}
+	expected := a.currentNowPlayingPosition()
+	nowPlaying.elapsed = currentTimeSeconds
+	nowPlaying.updatedAt = time.Now()
+	nowPlaying.mutex.Unlock()
+
+	if math.Abs(currentTimeSeconds-expected) > nowPlayingSeekTolerance {
+		a.setNowPlayingInfo(song, playing, currentTimeSeconds)
+	}
+}
+
+// setNowPlayingInfo hands the song's metadata and artwork to MPNowPlayingInfoCenter
+func (a *App) setNowPlayingInfo(song *Song, isPlaying bool, elapsed float64) {
+	title := C.CString(song.Title)
+	artist := C.CString(song.Artist)
+	album := C.CString(song.Album)
+	defer C.free(unsafe.Pointer(title))
+	defer C.free(unsafe.Pointer(artist))
+	defer C.free(unsafe.Pointer(album))
+
+	var artwork unsafe.Pointer
+	var artworkLength C.int
+	if coverData := a.songCoverData(*song); coverData != "" {
+		if data, _, err := a.sharedCover(coverData); err == nil {
+			artwork = C.CBytes(data)
+			artworkLength = C.int(len(data))
+			defer C.free(artwork)
+		}
+	}
+
+	rate := 0.0
+	if isPlaying {
+		rate = a.playbackRate()
+	}
+	playing := C.int(0)
+	if isPlaying {
+		playing = 1
+	}
+
+	C.staticNowPlayingSet(title, artist, album,
+		C.double(a.effectiveDuration(song).Seconds()), C.double(elapsed), C.double(rate), playing,
+		artwork, artworkLength)
+}
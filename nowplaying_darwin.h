@@ -0,0 +1,14 @@
+// Bridge between Go and MPNowPlayingInfoCenter / MPRemoteCommandCenter
+
+#define STATIC_COMMAND_PLAY 0
+#define STATIC_COMMAND_PAUSE 1
+#define STATIC_COMMAND_TOGGLE 2
+#define STATIC_COMMAND_NEXT 3
+#define STATIC_COMMAND_PREVIOUS 4
+#define STATIC_COMMAND_SEEK 5
+
+void staticNowPlayingInit(void);
+void staticNowPlayingSet(const char *title, const char *artist, const char *album,
+                         double duration, double elapsed, double rate, int playing,
+                         const void *artwork, int artworkLength);
+void staticNowPlayingClear(void);
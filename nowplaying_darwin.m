@@ -0,0 +1,77 @@
+//go:build darwin && cgo
+
+#import <AppKit/AppKit.h>
+#import <MediaPlayer/MediaPlayer.h>
+
+#include "nowplaying_darwin.h"
+
+// Implemented in nowplaying_darwin.go
+extern void staticRemoteCommand(int command, double value);
+
+static MPRemoteCommandHandlerStatus forwardCommand(int command, double value) {
+    staticRemoteCommand(command, value);
+    return MPRemoteCommandHandlerStatusSuccess;
+}
+
+void staticNowPlayingInit(void) {
+    dispatch_async(dispatch_get_main_queue(), ^{
+        MPRemoteCommandCenter *center = [MPRemoteCommandCenter sharedCommandCenter];
+
+        [center.playCommand addTargetWithHandler:^MPRemoteCommandHandlerStatus(MPRemoteCommandEvent *event) {
+            return forwardCommand(STATIC_COMMAND_PLAY, 0);
+        }];
+        [center.pauseCommand addTargetWithHandler:^MPRemoteCommandHandlerStatus(MPRemoteCommandEvent *event) {
+            return forwardCommand(STATIC_COMMAND_PAUSE, 0);
+        }];
+        [center.togglePlayPauseCommand addTargetWithHandler:^MPRemoteCommandHandlerStatus(MPRemoteCommandEvent *event) {
+            return forwardCommand(STATIC_COMMAND_TOGGLE, 0);
+        }];
+        [center.nextTrackCommand addTargetWithHandler:^MPRemoteCommandHandlerStatus(MPRemoteCommandEvent *event) {
+            return forwardCommand(STATIC_COMMAND_NEXT, 0);
+        }];
+        [center.previousTrackCommand addTargetWithHandler:^MPRemoteCommandHandlerStatus(MPRemoteCommandEvent *event) {
+            return forwardCommand(STATIC_COMMAND_PREVIOUS, 0);
+        }];
+        [center.changePlaybackPositionCommand addTargetWithHandler:^MPRemoteCommandHandlerStatus(MPRemoteCommandEvent *event) {
+            MPChangePlaybackPositionCommandEvent *positionEvent = (MPChangePlaybackPositionCommandEvent *)event;
+            return forwardCommand(STATIC_COMMAND_SEEK, positionEvent.positionTime);
+        }];
+    });
+}
+
+void staticNowPlayingSet(const char *title, const char *artist, const char *album,
+                         double duration, double elapsed, double rate, int playing,
+                         const void *artwork, int artworkLength) {
+    // Copy everything before returning, Go frees the arguments afterwards
+    NSMutableDictionary *info = [NSMutableDictionary dictionary];
+    info[MPMediaItemPropertyTitle] = [NSString stringWithUTF8String:title];
+    info[MPMediaItemPropertyArtist] = [NSString stringWithUTF8String:artist];
+    info[MPMediaItemPropertyAlbumTitle] = [NSString stringWithUTF8String:album];
+    info[MPMediaItemPropertyPlaybackDuration] = @(duration);
+    info[MPNowPlayingInfoPropertyElapsedPlaybackTime] = @(elapsed);
+    info[MPNowPlayingInfoPropertyPlaybackRate] = @(rate);
+
+    if (artwork != NULL && artworkLength > 0) {
+        NSImage *image = [[NSImage alloc] initWithData:[NSData dataWithBytes:artwork length:artworkLength]];
+        if (image != nil) {
+            info[MPMediaItemPropertyArtwork] = [[MPMediaItemArtwork alloc] initWithBoundsSize:image.size
+                                                                               requestHandler:^NSImage *(CGSize size) {
+                return image;
+            }];
+        }
+    }
+
+    dispatch_async(dispatch_get_main_queue(), ^{
+        MPNowPlayingInfoCenter *center = [MPNowPlayingInfoCenter defaultCenter];
+        center.nowPlayingInfo = info;
+        center.playbackState = playing ? MPNowPlayingPlaybackStatePlaying : MPNowPlayingPlaybackStatePaused;
+    });
+}
+
+void staticNowPlayingClear(void) {
+    dispatch_async(dispatch_get_main_queue(), ^{
+        MPNowPlayingInfoCenter *center = [MPNowPlayingInfoCenter defaultCenter];
+        center.nowPlayingInfo = nil;
+        center.playbackState = MPNowPlayingPlaybackStateStopped;
+    });
+}
@@ -0,0 +1,11 @@
+//go:build !darwin || !cgo
+
+package main
+
+// publishNowPlaying is only implemented on macOS, Linux uses MPRIS
+func (a *App) publishNowPlaying(song *Song, isPlaying bool) error {
+	return nil
+}
+
+// syncNowPlayingPosition is only implemented on macOS
+func (a *App) syncNowPlayingPosition(currentTimeSeconds float64) {}
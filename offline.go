@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// libraryRootPollInterval is how often the static folder's drive is checked
+const libraryRootPollInterval = 5 * time.Second
+
+// Events sent when the static folder's drive goes away or comes back
+const (
+	eventLibraryOffline = "library:offline"
+	eventLibraryOnline  = "library:online"
+)
+
+// isLibraryRootAvailable reports whether the static folder is present and its drive mounted
+func isLibraryRootAvailable(staticPath string) bool {
+	info, err := os.Stat(staticPath)
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	return !isUnmountedMountDir(staticPath)
+}
+
+// offlinePlaylists rebuilds the playlists of an unavailable static folder from the library
+// index, marked offline so the frontend can grey them out. Stats and history are keyed by
+// file path and stay attached to the songs.
+func (a *App) offlinePlaylists(staticPath string) []Playlist {
+	playlists := a.library.playlists(staticPath)
+	sort.Slice(playlists, func(i, j int) bool {
+		return strings.ToLower(playlists[i].Name) < strings.ToLower(playlists[j].Name)
+	})
+	for i := range playlists {
+		playlists[i].Offline = true
+		a.cachePlaylist(playlists[i])
+	}
+	return playlists
+}
+
+// watchLibraryRoot polls the static folder and tells the frontend when its drive is
+// unplugged or reattached, so playlists can be reloaded
+func (a *App) watchLibraryRoot() {
+	a.playlistMutex.Lock()
+	a.libraryOffline = !isLibraryRootAvailable(a.GetStaticFolderPath())
+	a.playlistMutex.Unlock()
+
+	ticker := time.NewTicker(libraryRootPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		a.checkLibraryRoot()
+	}
+}
+
+// checkLibraryRoot handles the static folder's drive going offline or coming back
+func (a *App) checkLibraryRoot() {
+	staticPath := a.GetStaticFolderPath()
+	available := isLibraryRootAvailable(staticPath)
+
+	a.playlistMutex.Lock()
+	if a.libraryOffline == !available {
+		a.playlistMutex.Unlock()
+		return
+	}
+	a.libraryOffline = !available
+	// Cached playlists were loaded for the previous state
+	a.playlistCache = make(map[string]Playlist)
+	a.playlistMutex.Unlock()
+
+	if available {
+		logInfo("Library drive for %s is back, reattaching playlists", staticPath)
+		a.runLibraryPreflight()
+		a.emitEvent(eventLibraryOnline, staticPath)
+	} else {
+		logWarn("Library drive for %s went offline", staticPath)
+		a.emitEvent(eventLibraryOffline, staticPath)
+	}
+}
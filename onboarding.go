@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Limits for counting audio files in a candidate folder, so huge drives answer quickly
+const (
+	musicFolderCountLimit = 20000
+	musicFolderMaxDepth   = 6
+)
+
+// Kinds of detected music folders
+const (
+	musicFolderUser     = "user"     // The user's music folder or a player's media folder
+	musicFolderExternal = "external" // A removable or secondary drive
+)
+
+// MusicFolder is a place first-run onboarding can offer as the library
+type MusicFolder struct {
+	Path         string `json:"path"`
+	Label        string `json:"label"`
+	Kind         string `json:"kind"`         // "user" or "external"
+	AudioFiles   int    `json:"audioFiles"`   // Audio files found, up to the count limit
+	Truncated    bool   `json:"truncated"`    // Counting stopped at the limit
+	StaticLayout bool   `json:"staticLayout"` // Already organized as playlist folders with musics/ inside
+	Current      bool   `json:"current"`      // It's the configured static folder
+}
+
+// xdgMusicDir reads XDG_MUSIC_DIR from user-dirs.dirs, which is localized, e.g. ~/Musik
+func xdgMusicDir(home string) string {
+	file, err := os.Open(filepath.Join(home, ".config", "user-dirs.dirs"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		value, ok := strings.CutPrefix(line, "XDG_MUSIC_DIR=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		return strings.Replace(value, "$HOME", home, 1)
+	}
+	return ""
+}
+
+// userMusicFolderCandidates lists the standard music locations of the current OS
+func userMusicFolderCandidates() []MusicFolder {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	user := func(path string, label string) MusicFolder {
+		return MusicFolder{Path: path, Label: label, Kind: musicFolderUser}
+	}
+
+	candidates := []MusicFolder{user(filepath.Join(home, "Music"), "Music")}
+	switch runtime.GOOS {
+	case "windows":
+		if oneDrive := os.Getenv("OneDrive"); oneDrive != "" {
+			candidates = append(candidates, user(filepath.Join(oneDrive, "Music"), "OneDrive Music"))
+		}
+		if public := os.Getenv("PUBLIC"); public != "" {
+			candidates = append(candidates, user(filepath.Join(public, "Music"), "Public Music"))
+		}
+	case "darwin":
+		candidates = append(candidates,
+			user(filepath.Join(home, "Music", "Music", "Media.localized", "Music"), "Apple Music library"),
+			user(filepath.Join(home, "Music", "iTunes", "iTunes Media", "Music"), "iTunes library"))
+	default:
+		if dir := xdgMusicDir(home); dir != "" {
+			candidates = append(candidates, user(dir, "Music"))
+		}
+	}
+	return candidates
+}
+
+// externalDriveCandidates lists mounted secondary drives, preferring a Music folder on them
+func externalDriveCandidates() []MusicFolder {
+	var roots []string
+	switch runtime.GOOS {
+	case "windows":
+		systemDrive := os.Getenv("SystemDrive")
+		for letter := 'D'; letter <= 'Z'; letter++ {
+			root := string(letter) + `:\`
+			if !strings.EqualFold(root[:2], systemDrive) {
+				roots = append(roots, root)
+			}
+		}
+	case "darwin":
+		entries, _ := os.ReadDir("/Volumes")
+		for _, entry := range entries {
+			// The boot volume shows up as a symlink to /
+			if entry.Type()&fs.ModeSymlink == 0 {
+				roots = append(roots, filepath.Join("/Volumes", entry.Name()))
+			}
+		}
+	default:
+		userName := os.Getenv("USER")
+		for _, parent := range []string{filepath.Join("/media", userName), filepath.Join("/run/media", userName), "/mnt"} {
+			entries, _ := os.ReadDir(parent)
+			for _, entry := range entries {
+				if entry.IsDir() {
+					roots = append(roots, filepath.Join(parent, entry.Name()))
+				}
+			}
+		}
+	}
+
+	var candidates []MusicFolder
+	for _, root := range roots {
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			continue
+		}
+		path := root
+		if info, err := os.Stat(filepath.Join(root, "Music")); err == nil && info.IsDir() {
+			path = filepath.Join(root, "Music")
+		}
+		label := filepath.Base(root)
+		if runtime.GOOS == "windows" {
+			label = root[:2]
+		}
+		candidates = append(candidates, MusicFolder{Path: path, Label: label, Kind: musicFolderExternal})
+	}
+	return candidates
+}
+
+// hasStaticLayout reports whether a folder already contains playlist folders with musics/ inside
+func hasStaticLayout(path string) bool {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(path, entry.Name(), "musics")); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// countAudioFiles counts supported audio files under a folder, stopping at the limit
+func (a *App) countAudioFiles(root string) (int, bool) {
+	count := 0
+	truncated := false
+	rootDepth := strings.Count(filepath.Clean(root), string(filepath.Separator))
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Unreadable folders don't stop the count
+		}
+		if path != root && a.isIgnoredScanEntry(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if strings.Count(path, string(filepath.Separator))-rootDepth >= musicFolderMaxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isSupportedAudioFile(path) {
+			count++
+			if count >= musicFolderCountLimit {
+				truncated = true
+				return filepath.SkipAll
+			}
+		}
+		return nil
+	})
+	return count, truncated
+}
+
+// DetectMusicFolders looks for music in the standard locations and on external drives,
+// so first-run onboarding can offer one-click library setup. Folders without audio files
+// are left out, the rest are sorted by how many files they hold.
+func (a *App) DetectMusicFolders() []MusicFolder {
+	candidates := append(userMusicFolderCandidates(), externalDriveCandidates()...)
+
+	// The same folder can come up twice, e.g. XDG_MUSIC_DIR being ~/Music
+	seen := make(map[string]bool)
+	var unique []MusicFolder
+	for _, candidate := range candidates {
+		key := pathKey(candidate.Path)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if info, err := os.Stat(candidate.Path); err == nil && info.IsDir() {
+			unique = append(unique, candidate)
+		}
+	}
+
+	current := pathKey(a.GetStaticFolderPath())
+	var wg sync.WaitGroup
+	for i := range unique {
+		wg.Add(1)
+		go func(folder *MusicFolder) {
+			defer wg.Done()
+			folder.AudioFiles, folder.Truncated = a.countAudioFiles(folder.Path)
+			folder.StaticLayout = hasStaticLayout(folder.Path)
+			folder.Current = pathKey(folder.Path) == current
+		}(&unique[i])
+	}
+	wg.Wait()
+
+	folders := []MusicFolder{}
+	for _, folder := range unique {
+		if folder.AudioFiles > 0 {
+			folders = append(folders, folder)
+		}
+	}
+	sort.SliceStable(folders, func(i, j int) bool {
+		return folders[i].AudioFiles > folders[j].AudioFiles
+	})
+	return folders
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventPartyRequestsChanged tells the host UI the request queue changed
+const eventPartyRequestsChanged = "party:requests-changed"
+
+const (
+	partySearchLimit       = 25
+	partyMaxPendingByGuest = 3 // Stops one guest from flooding the request queue
+	partyMaxGuestName      = 32
+)
+
+// SongRequest is a guest's request waiting for the host's approval
+type SongRequest struct {
+	ID          int       `json:"id"`
+	FilePath    string    `json:"filePath,omitempty"` // Only shown to the host
+	TrackID     string    `json:"trackId"`
+	Title       string    `json:"title"`
+	Artist      string    `json:"artist"`
+	Album       string    `json:"album"`
+	GuestName   string    `json:"guestName"`
+	RequestedAt time.Time `json:"requestedAt"`
+	Votes       int       `json:"votes"`
+
+	song    Song
+	guestID string
+	voters  map[string]bool
+}
+
+// partyQueue holds the vote-ranked song requests
+type partyQueue struct {
+	requests []*SongRequest
+	nextID   int
+	mutex    sync.Mutex
+}
+
+// submit adds a request, or counts it as a vote if the song was already requested
+func (p *partyQueue) submit(song Song, guestID string, guestName string) (SongRequest, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	pending := 0
+	for _, request := range p.requests {
+		if request.FilePath == song.FilePath {
+			request.voters[guestID] = true
+			request.Votes = len(request.voters)
+			return *request, nil
+		}
+		if request.guestID == guestID {
+			pending++
+		}
+	}
+	if pending >= partyMaxPendingByGuest {
+		return SongRequest{}, fmt.Errorf("you already have %d songs waiting, try again after the host approves them", pending)
+	}
+
+	p.nextID++
+	request := &SongRequest{
+		ID:          p.nextID,
+		FilePath:    song.FilePath,
+		TrackID:     song.TrackID,
+		Title:       song.Title,
+		Artist:      song.Artist,
+		Album:       song.Album,
+		GuestName:   guestName,
+		RequestedAt: time.Now(),
+		Votes:       1,
+		song:        song,
+		guestID:     guestID,
+		voters:      map[string]bool{guestID: true},
+	}
+	p.requests = append(p.requests, request)
+	return *request, nil
+}
+
+// vote adds a guest's vote to a request; voting twice has no effect
+func (p *partyQueue) vote(id int, guestID string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, request := range p.requests {
+		if request.ID == id {
+			request.voters[guestID] = true
+			request.Votes = len(request.voters)
+			return nil
+		}
+	}
+	return fmt.Errorf("request %d not found", id)
+}
+
+// take removes a request and returns it
+func (p *partyQueue) take(id int) (*SongRequest, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i, request := range p.requests {
+		if request.ID == id {
+			p.requests = append(p.requests[:i], p.requests[i+1:]...)
+			return request, nil
+		}
+	}
+	return nil, fmt.Errorf("request %d not found", id)
+}
+
+// clear drops all requests
+func (p *partyQueue) clear() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.requests = nil
+}
+
+// ranked returns the requests with the most votes first, oldest first on ties
+func (p *partyQueue) ranked() []SongRequest {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	list := make([]SongRequest, 0, len(p.requests))
+	for _, request := range p.requests {
+		list = append(list, *request)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Votes != list[j].Votes {
+			return list[i].Votes > list[j].Votes
+		}
+		return list[i].RequestedAt.Before(list[j].RequestedAt)
+	})
+	return list
+}
+
+// guestRanked returns the ranked requests without the host's file paths, for guests
+func (p *partyQueue) guestRanked() []SongRequest {
+	list := p.ranked()
+	for i := range list {
+		list[i].FilePath = ""
+	}
+	return list
+}
+
+// GetSongRequests returns the pending party requests, highest voted first
+func (a *App) GetSongRequests() []SongRequest {
+	return a.party.ranked()
+}
+
+// ApproveSongRequest adds a requested song to the end of the queue
+func (a *App) ApproveSongRequest(id int) (QueueState, error) {
+	request, err := a.party.take(id)
+	if err != nil {
+		return QueueState{}, err
+	}
+	logInfo("Party: approved %q requested by %s", request.Title, request.GuestName)
+	a.emitEvent(eventPartyRequestsChanged, a.party.ranked())
+	return a.Enqueue(request.song), nil
+}
+
+// RejectSongRequest removes a request without playing it
+func (a *App) RejectSongRequest(id int) error {
+	if _, err := a.party.take(id); err != nil {
+		return err
+	}
+	a.emitEvent(eventPartyRequestsChanged, a.party.ranked())
+	return nil
+}
+
+// ClearSongRequests drops every pending request
+func (a *App) ClearSongRequests() {
+	a.party.clear()
+	a.emitEvent(eventPartyRequestsChanged, []SongRequest{})
+}
+
+// partyGuestID identifies a guest by something it can't reset: the web remote token it
+// was issued, otherwise its address. Guests sharing an address share their limits.
+func (a *App) partyGuestID(r *http.Request) string {
+	if token := remoteToken(r); token != "" && a.remoteRole(r) != "" {
+		return "token:" + token
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// handlePartySearch searches the library by title, artist or album, optionally only in
+// one language (lang=jpn)
+func (a *App) handlePartySearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+	language := normalizeLanguage(r.URL.Query().Get("lang"))
+	if query == "" {
+		writeJSON(w, http.StatusOK, []RemoteSong{})
+		return
+	}
+
+	songs, err := a.librarySongs()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "library unavailable")
+		return
+	}
+
+	results := []RemoteSong{}
+	seen := make(map[string]bool)
+	for _, song := range songs {
+		if seen[song.FilePath] || (language != "" && song.Language != language) {
+			continue
+		}
+		haystack := strings.ToLower(song.Title + " " + song.Artist + " " + song.Album)
+		if strings.Contains(haystack, query) {
+			seen[song.FilePath] = true
+			results = append(results, toRemoteSong(song))
+			if len(results) >= partySearchLimit {
+				break
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handlePartyRequests lists requests (GET) or submits a new one (POST)
+func (a *App) handlePartyRequests(w http.ResponseWriter, r *http.Request) {
+	guestID := a.partyGuestID(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, a.party.guestRanked())
+	case http.MethodPost:
+		var body struct {
+			TrackID   string `json:"trackId"`
+			GuestName string `json:"guestName"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !isTrackID(body.TrackID) {
+			writeJSONError(w, http.StatusBadRequest, "invalid request")
+			return
+		}
+
+		// Only songs from the library can be requested
+		song, found := a.findLibraryTrack(body.TrackID)
+		if !found {
+			writeJSONError(w, http.StatusNotFound, "song not found")
+			return
+		}
+
+		name := strings.TrimSpace(body.GuestName)
+		if name == "" {
+			name = "Guest"
+		}
+		if len([]rune(name)) > partyMaxGuestName {
+			name = string([]rune(name)[:partyMaxGuestName])
+		}
+
+		request, err := a.party.submit(song, guestID, name)
+		if err != nil {
+			writeJSONError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		a.emitEvent(eventPartyRequestsChanged, a.party.ranked())
+		request.FilePath = ""
+		writeJSON(w, http.StatusOK, request)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "GET or POST required")
+	}
+}
+
+// handlePartyVote adds the guest's vote to a request
+func (a *App) handlePartyVote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	guestID := a.partyGuestID(r)
+
+	var body struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	if err := a.party.vote(body.ID, guestID); err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	a.emitEvent(eventPartyRequestsChanged, a.party.ranked())
+	writeJSON(w, http.StatusOK, map[string]int{"id": body.ID})
+}
+
+// findLibrarySong looks a song up by file path in the library
+func (a *App) findLibrarySong(filePath string) (Song, bool) {
+	songs, err := a.librarySongs()
+	if err != nil {
+		return Song{}, false
+	}
+	for _, song := range songs {
+		if song.FilePath == filePath {
+			return song, true
+		}
+	}
+	return Song{}, false
+}
+
+// findLibraryTrack looks a song up by the track ID remote clients know it by
+func (a *App) findLibraryTrack(trackID string) (Song, bool) {
+	filePath, err := a.resolveTrackRef(trackID)
+	if err != nil {
+		return Song{}, false
+	}
+	return a.findLibrarySong(filePath)
+}
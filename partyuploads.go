@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+// eventPartyUploadsChanged tells the host UI the guest upload inbox changed
+const eventPartyUploadsChanged = "party:uploads-changed"
+
+const (
+	partyUploadsPlaylist         = "Uploads" // Playlist folder approved uploads are moved into
+	partyMaxUploadBytes          = 50 << 20  // Larger than any reasonable single track
+	partyMaxPendingUploadByGuest = 2
+	// Caps on the whole inbox, so guests together can't fill the disk
+	partyMaxPendingUploads     = 20
+	partyMaxPendingUploadBytes = 500 << 20
+)
+
+// GuestUpload is a song file a guest sent through the web remote, quarantined until
+// the host approves it
+type GuestUpload struct {
+	ID         int       `json:"id"`
+	Filename   string    `json:"filename"`
+	Title      string    `json:"title"`
+	Artist     string    `json:"artist"`
+	Size       int64     `json:"size"`
+	GuestName  string    `json:"guestName"`
+	UploadedAt time.Time `json:"uploadedAt"`
+
+	path    string // Quarantined file
+	guestID string
+}
+
+// uploadInbox holds the guest uploads waiting for approval
+type uploadInbox struct {
+	uploads   []*GuestUpload
+	nextID    int
+	receiving map[string]int // Uploads still being received, by guest
+	reserved  int64          // Bytes set aside for the uploads being received
+	mutex     sync.Mutex
+}
+
+// uploadQuarantineDir holds pending uploads outside the library, so scans never see them
+func uploadQuarantineDir() string {
+	return filepath.Join(getConfigDir(), "uploads")
+}
+
+// clearUploadQuarantine removes files left over from a previous session, whose
+// requests were lost when the app exited
+func clearUploadQuarantine() {
+	os.RemoveAll(uploadQuarantineDir())
+}
+
+// sniffAudioFormat returns the extension matching a file's magic bytes, or "" when it
+// isn't one of the supported formats
+func sniffAudioFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("ID3")), len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return ".mp3"
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return ".flac"
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return ".ogg"
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return ".wav"
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return ".m4a"
+	}
+	return ""
+}
+
+// checkRoomLocked returns an error when another upload of the given size wouldn't fit in
+// the inbox or the guest's quota. Caller must hold the mutex.
+func (u *uploadInbox) checkRoomLocked(guestID string, size int64) error {
+	pending := u.receiving[guestID]
+	count := len(u.uploads)
+	total := u.reserved + size
+	for _, receiving := range u.receiving {
+		count += receiving
+	}
+	for _, existing := range u.uploads {
+		if existing.guestID == guestID {
+			pending++
+		}
+		total += existing.Size
+	}
+	if pending >= partyMaxPendingUploadByGuest {
+		return fmt.Errorf("you already have %d uploads waiting, try again after the host reviews them", pending)
+	}
+	if count >= partyMaxPendingUploads || total > partyMaxPendingUploadBytes {
+		return fmt.Errorf("the host has too many uploads to review, try again later")
+	}
+	return nil
+}
+
+// reserve sets room aside for an upload of up to size bytes before it's written to
+// disk, so concurrent uploads can't get past the caps together. The returned func
+// releases it once the upload was added or dropped.
+func (u *uploadInbox) reserve(guestID string, size int64) (func(), error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if err := u.checkRoomLocked(guestID, size); err != nil {
+		return nil, err
+	}
+	if u.receiving == nil {
+		u.receiving = make(map[string]int)
+	}
+	u.receiving[guestID]++
+	u.reserved += size
+	return func() {
+		u.mutex.Lock()
+		defer u.mutex.Unlock()
+		u.reserved -= size
+		if u.receiving[guestID]--; u.receiving[guestID] == 0 {
+			delete(u.receiving, guestID)
+		}
+	}, nil
+}
+
+// add quarantines an upload received under a reservation
+func (u *uploadInbox) add(upload *GuestUpload) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.nextID++
+	upload.ID = u.nextID
+	u.uploads = append(u.uploads, upload)
+}
+
+// take removes an upload from the inbox and returns it
+func (u *uploadInbox) take(id int) (*GuestUpload, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for i, upload := range u.uploads {
+		if upload.ID == id {
+			u.uploads = append(u.uploads[:i], u.uploads[i+1:]...)
+			return upload, nil
+		}
+	}
+	return nil, fmt.Errorf("upload %d not found", id)
+}
+
+// list returns the pending uploads, oldest first
+func (u *uploadInbox) list() []GuestUpload {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	list := make([]GuestUpload, 0, len(u.uploads))
+	for _, upload := range u.uploads {
+		list = append(list, *upload)
+	}
+	return list
+}
+
+// handlePartyUpload receives a song file from a guest as multipart form data with the
+// fields "file" and "guestName"
+func (a *App) handlePartyUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	if !a.settings.PartyUploads {
+		writeJSONError(w, http.StatusForbidden, "uploads are off")
+		return
+	}
+	guestID := a.partyGuestID(r)
+
+	// Room is set aside before the upload is written to disk. The file's size isn't known
+	// yet, so the most it may take is reserved.
+	release, err := a.uploads.reserve(guestID, partyMaxUploadBytes)
+	if err != nil {
+		writeJSONError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	defer release()
+
+	// Leave room for the multipart headers and the guest name
+	r.Body = http.MaxBytesReader(w, r.Body, partyMaxUploadBytes+64*1024)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "file too large or missing")
+		return
+	}
+	defer file.Close()
+
+	// The extension must be supported and match the content
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	head := make([]byte, 12)
+	n, _ := io.ReadFull(file, head)
+	if !isSupportedAudioFile(header.Filename) || sniffAudioFormat(head[:n]) != ext {
+		writeJSONError(w, http.StatusUnsupportedMediaType, "only MP3, FLAC, OGG, M4A and WAV files are accepted")
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "upload failed")
+		return
+	}
+
+	if err := os.MkdirAll(uploadQuarantineDir(), 0755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "upload failed")
+		return
+	}
+	quarantined, err := os.CreateTemp(uploadQuarantineDir(), "upload-*"+ext)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "upload failed")
+		return
+	}
+	size, err := io.Copy(quarantined, file)
+	quarantined.Close()
+	if err != nil {
+		os.Remove(quarantined.Name())
+		writeJSONError(w, http.StatusInternalServerError, "upload failed")
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("guestName"))
+	if name == "" {
+		name = "Guest"
+	}
+	if len([]rune(name)) > partyMaxGuestName {
+		name = string([]rune(name)[:partyMaxGuestName])
+	}
+
+	// Dotfiles would be skipped by scans
+	filename := safeFileName(strings.TrimLeft(filepath.Base(header.Filename), "."))
+	if !strings.EqualFold(filepath.Ext(filename), ext) || len(filename) == len(ext) {
+		filename = "upload" + ext
+	}
+	upload := &GuestUpload{
+		Filename:   filename,
+		Title:      strings.TrimSuffix(filename, filepath.Ext(filename)),
+		Size:       size,
+		GuestName:  name,
+		UploadedAt: time.Now(),
+		path:       quarantined.Name(),
+		guestID:    guestID,
+	}
+	if tagged, err := os.Open(upload.path); err == nil {
+		if metadata, err := tag.ReadFrom(tagged); err == nil {
+			if metadata.Title() != "" {
+				upload.Title = metadata.Title()
+			}
+			upload.Artist = metadata.Artist()
+		}
+		tagged.Close()
+	}
+
+	a.uploads.add(upload)
+	logInfo("Party: %s uploaded %q (%d bytes), waiting for approval", name, upload.Filename, size)
+	a.emitEvent(eventPartyUploadsChanged, a.uploads.list())
+	writeJSON(w, http.StatusOK, *upload)
+}
+
+// GetGuestUploads returns the uploads waiting for approval
+func (a *App) GetGuestUploads() []GuestUpload {
+	return a.uploads.list()
+}
+
+// ApproveGuestUpload moves an upload into the Uploads playlist and returns the song
+func (a *App) ApproveGuestUpload(id int) (Song, error) {
+	upload, err := a.uploads.take(id)
+	if err != nil {
+		return Song{}, err
+	}
+	a.emitEvent(eventPartyUploadsChanged, a.uploads.list())
+
+	playlistDir := filepath.Join(a.GetStaticFolderPath(), partyUploadsPlaylist)
+	musicsDir := filepath.Join(playlistDir, "musics")
+	if err := os.MkdirAll(musicsDir, 0755); err != nil {
+		os.Remove(upload.path)
+		return Song{}, fmt.Errorf("error creating uploads playlist: %v", err)
+	}
+
+	destination := availablePath(musicsDir, upload.Filename)
+	if err := moveFile(upload.path, destination); err != nil {
+		os.Remove(upload.path)
+		return Song{}, fmt.Errorf("error moving upload: %v", err)
+	}
+
+	// The playlist gets rescanned with the new file next time it's opened
+	a.playlistMutex.Lock()
+	delete(a.playlistCache, filepath.Clean(playlistDir))
+	a.playlistMutex.Unlock()
+
+	logInfo("Party: approved upload %q from %s", upload.Filename, upload.GuestName)
+	return a.songMetadata(destination, nil)
+}
+
+// RejectGuestUpload deletes an upload
+func (a *App) RejectGuestUpload(id int) error {
+	upload, err := a.uploads.take(id)
+	if err != nil {
+		return err
+	}
+	os.Remove(upload.path)
+	a.emitEvent(eventPartyUploadsChanged, a.uploads.list())
+	return nil
+}
+
+// availablePath returns a path for filename in dir that doesn't exist yet,
+// numbering the name on collisions
+func availablePath(dir string, filename string) string {
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	path := filepath.Join(dir, filename)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+	}
+}
+
+// moveFile moves a file, copying it when source and destination are on different drives
+func moveFile(source string, destination string) error {
+	if err := os.Rename(source, destination); err == nil {
+		return nil
+	}
+	if err := copyFile(source, destination); err != nil {
+		return err
+	}
+	return os.Remove(source)
+}
+
+// copyFile copies a file's contents to a new file, which is removed again on failure
+func copyFile(source string, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(destination)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(destination)
+		return err
+	}
+	return nil
+}
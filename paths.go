@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/godbus/dbus/v5"
+	"golang.org/x/text/unicode/norm"
+)
+
+// windowsPaths is true where backslashes separate paths and drive letters exist
+const windowsPaths = filepath.Separator == '\\'
+
+// normalizePath turns a path from the frontend into the form the backend uses: file://
+// URIs are decoded, separators are made native, drive letters upper case and the result
+// cleaned. The frontend may hand back paths with mixed separators on Windows.
+func normalizePath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return ""
+	}
+	if strings.HasPrefix(strings.ToLower(path), "file:") {
+		path = pathFromFileURI(path)
+	}
+	if windowsPaths {
+		path = filepath.FromSlash(path)
+		if len(path) >= 2 && path[1] == ':' {
+			path = strings.ToUpper(path[:1]) + path[1:]
+		}
+	}
+	return filepath.Clean(path)
+}
+
+// pathFromFileURI decodes file:///C:/Music/a.mp3, file://server/share/a.mp3 and
+// file:///home/me/a.mp3 into native paths
+func pathFromFileURI(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return uri
+	}
+	path := parsed.Path
+	if parsed.Host != "" && parsed.Host != "localhost" && windowsPaths {
+		return `\\` + parsed.Host + filepath.FromSlash(path) // UNC share
+	}
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:] // Drive letter, "/C:/Music" -> "C:/Music"
+	}
+	return filepath.FromSlash(path)
+}
+
+// fileURI returns the canonical file:// URI of a path, the form the thumbnail spec hashes
+func fileURI(path string) string {
+	slashed := filepath.ToSlash(path)
+	if windowsPaths && strings.HasPrefix(slashed, "//") {
+		host, rest, _ := strings.Cut(slashed[2:], "/")
+		return (&url.URL{Scheme: "file", Host: host, Path: "/" + rest}).String()
+	}
+	if filepath.VolumeName(path) != "" {
+		slashed = "/" + slashed
+	}
+	return (&url.URL{Scheme: "file", Path: slashed}).String()
+}
+
+// pathKey identifies a file the same way on every OS, for hashes and IDs derived from
+// paths. Windows paths are compared case-insensitively like the file system does.
+func pathKey(path string) string {
+	key := filepath.ToSlash(normalizePath(path))
+	if windowsPaths {
+		key = strings.ToLower(key)
+	}
+	return key
+}
+
+// hashPath returns the MD5 of a path's key as hex, for cache file names
+func hashPath(path string) string {
+	hash := md5.Sum([]byte(pathKey(path)))
+	return hex.EncodeToString(hash[:])
+}
+
+// mprisTrackID returns a valid D-Bus object path for a song from its track ID, or a hash
+// of its path for songs without one. Paths can contain characters object paths don't allow,
+// and so could IDs from old indexes, which get the hash too.
+func mprisTrackID(song *Song) dbus.ObjectPath {
+	id := strings.TrimPrefix(song.TrackID, trackIDPrefix)
+	if id != "" {
+		if path := dbus.ObjectPath("/org/static/track/" + id); path.IsValid() {
+			return path
+		}
+	}
+	return dbus.ObjectPath("/org/static/track/" + hashPath(song.FilePath))
+}
+
+// maxFileNameBytes keeps generated names well below the 255 byte limit of most file
+// systems, leaving room for numbering and prefixes
+const maxFileNameBytes = 200
+
+// windowsDeviceNames can't be used as file names on Windows, with any extension
+var windowsDeviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// transliterations are letters that don't decompose into an ASCII letter and a mark
+var transliterations = map[rune]string{
+	'ß': "ss", 'Æ': "AE", 'æ': "ae", 'Œ': "OE", 'œ': "oe", 'Ø': "O", 'ø': "o",
+	'Ł': "L", 'ł': "l", 'Đ': "D", 'đ': "d", 'Ð': "D", 'ð': "d", 'Þ': "Th", 'þ': "th", 'ı': "i",
+}
+
+// transliterate spells a name in ASCII: accents are dropped, letters like ß are written
+// out and what has no ASCII form, e.g. CJK, becomes '_'
+func transliterate(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case unicode.Is(unicode.Mn, r):
+		case transliterations[r] != "":
+			b.WriteString(transliterations[r])
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// safeFileName makes a name usable as a file or folder name on every OS: characters
+// Windows forbids become '_', device names get a '_' suffix and long names are cut at a
+// character boundary. Returns "" when nothing usable is left.
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, norm.NFC.String(name))
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+
+	for len(name) > maxFileNameBytes {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	name = strings.TrimRight(name, ". ")
+
+	base := strings.ToUpper(strings.TrimSpace(strings.SplitN(name, ".", 2)[0]))
+	if windowsDeviceNames[base] {
+		name = "_" + name
+	}
+	return name
+}
+
+// tempPathFor returns a hidden path next to a file for writing its replacement. The name
+// is a short ASCII form of the file's plus a hash of its path, so long or international
+// names can't exceed name limits or clash, and keeps the extension for FFmpeg.
+func tempPathFor(filePath string, purpose string) string {
+	dir, name := filepath.Split(filePath)
+	ext := filepath.Ext(name)
+	readable := []rune(transliterate(strings.TrimSuffix(name, ext)))
+	if len(readable) > 32 {
+		readable = readable[:32]
+	}
+	// Hidden so a scan running meanwhile skips it
+	return filepath.Join(dir, ".static-"+purpose+"-"+safeFileName(string(readable))+"-"+hashPath(filePath)[:8]+transliterate(ext))
+}
+
+// NormalizePath returns the backend's form of a path or file:// URI
+func (a *App) NormalizePath(path string) string {
+	return normalizePath(path)
+}
+
+// GetFileURI returns the file:// URI of a path
+func (a *App) GetFileURI(path string) string {
+	return fileURI(normalizePath(path))
+}
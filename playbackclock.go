@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	// positionStallAfter is how long the frontend can go without reporting the position
+	// before it's derived from songStartTime, e.g. while the window is minimized
+	positionStallAfter = 5 * time.Second
+	// positionWatchInterval is how often a derived position is pushed to presence and MPRIS
+	positionWatchInterval = 15 * time.Second
+	// seekTolerance is how far a reported position may drift from the clock before it
+	// counts as a seek
+	seekTolerance = 2.0
+)
+
+// playbackClock tracks the position between frontend updates. While playing it's the time
+// since songStartTime, which is moved on pauses and seeks. Positions are in seconds of the
+// audio actually playing, so tempo effects are already applied.
+type playbackClock struct {
+	pausedPosition float64   // Position while paused
+	reportedAt     time.Time // Last position update from the frontend
+	mutex          sync.Mutex
+}
+
+// positionLocked returns the current position. Caller must hold the clock mutex.
+func (a *App) positionLocked(now time.Time) float64 {
+	if !a.isPlaying || a.songStartTime.IsZero() {
+		return a.clock.pausedPosition
+	}
+	position := now.Sub(a.songStartTime).Seconds()
+	if a.currentSong != nil && a.currentSong.DurationSec > 0 {
+		position = math.Min(position, a.effectiveDuration(a.currentSong).Seconds())
+	}
+	return math.Max(position, 0)
+}
+
+// playbackPosition returns where the current song is, from the last reported position and
+// the time played since
+func (a *App) playbackPosition() float64 {
+	a.clock.mutex.Lock()
+	defer a.clock.mutex.Unlock()
+	return a.positionLocked(time.Now())
+}
+
+// playbackStarted returns when the current song would have started playing without
+// pauses or seeks, for progress timestamps
+func (a *App) playbackStarted() time.Time {
+	a.clock.mutex.Lock()
+	defer a.clock.mutex.Unlock()
+	now := time.Now()
+	return now.Add(-time.Duration(a.positionLocked(now) * float64(time.Second)))
+}
+
+// setClockState moves the clock on a track change or play/pause toggle. Call it before
+// isPlaying is updated.
+func (a *App) setClockState(isNewTrack bool, isPlaying bool) {
+	a.clock.mutex.Lock()
+	defer a.clock.mutex.Unlock()
+	now := time.Now()
+	position := a.positionLocked(now)
+	if isNewTrack {
+		position = 0
+	}
+	a.clock.pausedPosition = position
+	if isPlaying {
+		a.songStartTime = now.Add(-time.Duration(position * float64(time.Second)))
+	}
+}
+
+// syncPlaybackClock moves the clock to a position reported by the frontend, returning
+// whether it was a seek
+func (a *App) syncPlaybackClock(currentTimeSeconds float64) bool {
+	a.clock.mutex.Lock()
+	defer a.clock.mutex.Unlock()
+	now := time.Now()
+	seeked := !a.clock.reportedAt.IsZero() && math.Abs(a.positionLocked(now)-currentTimeSeconds) > seekTolerance
+	a.clock.pausedPosition = currentTimeSeconds
+	a.clock.reportedAt = now
+	if a.isPlaying {
+		a.songStartTime = now.Add(-time.Duration(currentTimeSeconds * float64(time.Second)))
+	}
+	return seeked
+}
+
+// positionStalled reports whether the frontend stopped reporting the position of a
+// playing song
+func (a *App) positionStalled() bool {
+	a.clock.mutex.Lock()
+	defer a.clock.mutex.Unlock()
+	return a.isPlaying && a.currentSong != nil && time.Since(a.clock.reportedAt) > positionStallAfter
+}
+
+// updateMPRISPosition sets the MPRIS Position property, and emits Seeked when the position
+// jumped so clients don't have to poll for it
+func (a *App) updateMPRISPosition(positionSec float64, seeked bool) {
+	if a.mprisProps == nil {
+		return
+	}
+	position := int64(positionSec * float64(time.Second/time.Microsecond))
+	a.mprisProps.SetMust(playerInterface, "Position", position)
+	if seeked && a.dbusConn != nil {
+		a.dbusConn.Emit(dbus.ObjectPath(mprisPath), playerInterface+".Seeked", position)
+	}
+}
+
+// watchPlaybackPosition keeps presence and MPRIS in step when the frontend stops
+// reporting the position, using the clock instead
+func (a *App) watchPlaybackPosition() {
+	ticker := time.NewTicker(positionWatchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !a.positionStalled() {
+			continue
+		}
+		position := a.playbackPosition()
+		a.updateMPRISPosition(position, false)
+		a.trackSessionPosition(position)
+		if err := a.UpdateDiscordPresenceWithPosition(position); err != nil {
+			logDebug("Discord RPC: Failed to update stalled position: %v", err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Audio formats the webview is probed for
+const (
+	formatMP3    = "mp3"
+	formatWAV    = "wav"
+	formatVorbis = "vorbis"
+	formatOpus   = "opus"
+	formatFLAC   = "flac"
+	formatAAC    = "aac"
+)
+
+// playbackProbeTypes are the MIME types each format is checked with in the webview
+var playbackProbeTypes = map[string]string{
+	formatMP3:    "audio/mpeg",
+	formatWAV:    "audio/wav",
+	formatVorbis: `audio/ogg; codecs="vorbis"`,
+	formatOpus:   `audio/ogg; codecs="opus"`,
+	formatFLAC:   "audio/flac",
+	formatAAC:    `audio/mp4; codecs="mp4a.40.2"`,
+}
+
+// PlaybackSupport is which audio formats the webview can decode. Until the webview has
+// been probed it's what its engine usually supports on this OS.
+type PlaybackSupport struct {
+	Platform string          `json:"platform"`
+	Engine   string          `json:"engine"` // "WebView2", "WKWebView" or "WebKitGTK"
+	Formats  map[string]bool `json:"formats"`
+	Probed   bool            `json:"probed"` // Formats were reported by the webview
+}
+
+// playbackSupport holds the probed formats, nil until the webview reported them
+type playbackSupport struct {
+	formats map[string]bool
+	mutex   sync.Mutex
+}
+
+// webviewEngine names the webview Wails uses on this OS
+func webviewEngine() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "WebView2"
+	case "darwin":
+		return "WKWebView"
+	default:
+		return "WebKitGTK"
+	}
+}
+
+// assumedPlaybackFormats is what the webview usually decodes. WebKitGTK depends on the
+// installed GStreamer plugins, AAC often needs gst-libav. WebKit on macOS has no Ogg.
+func assumedPlaybackFormats() map[string]bool {
+	formats := map[string]bool{formatMP3: true, formatWAV: true, formatVorbis: true, formatOpus: true, formatFLAC: true, formatAAC: true}
+	switch runtime.GOOS {
+	case "darwin":
+		formats[formatVorbis], formats[formatOpus] = false, false
+	case "linux":
+		formats[formatAAC] = false
+	}
+	return formats
+}
+
+// oggCodec tells Opus from Vorbis by the first packet of an Ogg file
+func oggCodec(filePath string) string {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return formatVorbis
+	}
+	defer file.Close()
+	head := make([]byte, 64)
+	n, _ := file.Read(head)
+	if bytes.Contains(head[:n], []byte("OpusHead")) {
+		return formatOpus
+	}
+	return formatVorbis
+}
+
+// audioFormat returns the probed format of an audio file, "" for unknown extensions
+func audioFormat(filePath string) string {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".mp3":
+		return formatMP3
+	case ".wav":
+		return formatWAV
+	case ".ogg":
+		return oggCodec(filePath)
+	case ".flac":
+		return formatFLAC
+	case ".m4a":
+		return formatAAC
+	}
+	return ""
+}
+
+// playbackFormats returns the formats the webview reported, or the assumed ones
+func (a *App) playbackFormats() (map[string]bool, bool) {
+	a.playback.mutex.Lock()
+	defer a.playback.mutex.Unlock()
+	if a.playback.formats != nil {
+		return a.playback.formats, true
+	}
+	return assumedPlaybackFormats(), false
+}
+
+// playableChain adds transcoding to a chain when the webview can't decode the audio it
+// would play, so those songs play in the transcode profile instead of failing silently
+func (a *App) playableChain(filePath string, chain EffectChain) EffectChain {
+	if chain.hasFilters() {
+		return chain // Already re-encoded to MP3
+	}
+	format := audioFormat(a.chainSource(filePath, chain))
+	formats, _ := a.playbackFormats()
+	if format == "" || formats[format] || !a.checkFFmpegAvailable() {
+		return chain
+	}
+	logWarn("Webview can't decode %s, transcoding %s", format, filepath.Base(filePath))
+	chain.Transcode = a.transcodeProfile(formats)
+	return chain
+}
+
+// GetPlaybackSupport returns which formats the webview can decode
+func (a *App) GetPlaybackSupport() PlaybackSupport {
+	formats, probed := a.playbackFormats()
+	copied := make(map[string]bool, len(formats))
+	for format, ok := range formats {
+		copied[format] = ok
+	}
+	return PlaybackSupport{Platform: runtime.GOOS, Engine: webviewEngine(), Formats: copied, Probed: probed}
+}
+
+// ProbePlaybackSupport asks the webview which formats it can decode. The answer arrives
+// through ReportPlaybackSupport, until then the assumed support is returned. Songs in
+// formats it can't decode are transcoded through FFmpeg.
+func (a *App) ProbePlaybackSupport() PlaybackSupport {
+	if a.ctx != nil {
+		var checks []string
+		for format, mimeType := range playbackProbeTypes {
+			checks = append(checks, fmt.Sprintf("%q: audio.canPlayType(%q)", format, mimeType))
+		}
+		wailsruntime.WindowExecJS(a.ctx, fmt.Sprintf(
+			"(function(){var audio=document.createElement('audio');window.go.main.App.ReportPlaybackSupport({%s});})();",
+			strings.Join(checks, ",")))
+	}
+	return a.GetPlaybackSupport()
+}
+
+// ReportPlaybackSupport takes the webview's canPlayType answers per format. "maybe" counts
+// as supported, WebKitGTK never answers "probably" for some formats it plays fine.
+func (a *App) ReportPlaybackSupport(answers map[string]string) PlaybackSupport {
+	formats := assumedPlaybackFormats()
+	for format := range playbackProbeTypes {
+		if answer, ok := answers[format]; ok {
+			formats[format] = answer == "probably" || answer == "maybe"
+		}
+	}
+
+	a.playback.mutex.Lock()
+	a.playback.formats = formats
+	a.playback.mutex.Unlock()
+
+	var unsupported []string
+	for format, ok := range formats {
+		if !ok {
+			unsupported = append(unsupported, format)
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		logWarn("Webview can't decode: %s", strings.Join(unsupported, ", "))
+	}
+	return a.GetPlaybackSupport()
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Playlist file formats ExportPlaylistFile and ImportPlaylistFile understand, picked by
+// file extension
+const (
+	playlistFileXSPF = ".xspf"
+	playlistFilePLS  = ".pls"
+)
+
+// xspfPlaylist is an XSPF document (https://xspf.org/spec), the parts players fill in
+type xspfPlaylist struct {
+	XMLName    xml.Name    `xml:"playlist"`
+	Version    string      `xml:"version,attr"`
+	Namespace  string      `xml:"xmlns,attr,omitempty"`
+	Title      string      `xml:"title,omitempty"`
+	Annotation string      `xml:"annotation,omitempty"`
+	Tracks     []xspfTrack `xml:"trackList>track"`
+}
+
+// xspfTrack is one track of an XSPF playlist
+type xspfTrack struct {
+	Locations  []string `xml:"location"`
+	Title      string   `xml:"title,omitempty"`
+	Creator    string   `xml:"creator,omitempty"`
+	Album      string   `xml:"album,omitempty"`
+	Annotation string   `xml:"annotation,omitempty"` // The song's note
+	TrackNum   int      `xml:"trackNum,omitempty"`
+	Duration   int64    `xml:"duration,omitempty"` // Milliseconds
+}
+
+// ImportPlaylistResult reports how an imported playlist file was resolved
+type ImportPlaylistResult struct {
+	Playlist Playlist        `json:"playlist"`
+	Resolved int             `json:"resolved"`
+	Missing  []QueueFileItem `json:"missing"` // Entries found neither on disk nor in the library
+}
+
+// playlistFileLocation resolves a location from a playlist file: file:// URIs, absolute
+// paths and paths relative to the playlist file. Streams and other URLs give "".
+func playlistFileLocation(location string, baseDir string) string {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return ""
+	}
+	if strings.HasPrefix(strings.ToLower(location), "file:") {
+		return normalizePath(location)
+	}
+	if scheme, _, ok := strings.Cut(location, "://"); ok && !strings.ContainsAny(scheme, `/\`) {
+		return ""
+	}
+	if unescaped, err := url.PathUnescape(location); err == nil && strings.Contains(location, "%") {
+		location = unescaped
+	}
+	if !filepath.IsAbs(location) && filepath.VolumeName(location) == "" {
+		location = filepath.Join(baseDir, filepath.FromSlash(location))
+	}
+	return normalizePath(location)
+}
+
+// encodeXSPF writes songs as an XSPF playlist with file:// locations
+func encodeXSPF(playlist Playlist) ([]byte, error) {
+	document := xspfPlaylist{
+		Version:    "1",
+		Namespace:  "http://xspf.org/ns/0/",
+		Title:      playlist.Name,
+		Annotation: playlist.Description,
+	}
+	for _, song := range playlist.Songs {
+		track := xspfTrack{
+			Locations:  []string{fileURI(song.FilePath)},
+			Title:      song.Title,
+			Annotation: song.Note,
+			TrackNum:   song.TrackNumber,
+			Duration:   int64(song.DurationSec) * 1000,
+		}
+		// Placeholders would be taken for real tags by other players
+		if song.Artist != "Unknown Artist" {
+			track.Creator = song.Artist
+		}
+		if song.Album != "Unknown Album" {
+			track.Album = song.Album
+		}
+		document.Tracks = append(document.Tracks, track)
+	}
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// decodeXSPF reads the title and entries of an XSPF playlist
+func decodeXSPF(data []byte, baseDir string) (string, []QueueFileItem, error) {
+	var document xspfPlaylist
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return "", nil, err
+	}
+	items := make([]QueueFileItem, 0, len(document.Tracks))
+	for _, track := range document.Tracks {
+		item := QueueFileItem{
+			Title:       track.Title,
+			Artist:      track.Creator,
+			Album:       track.Album,
+			DurationSec: int(track.Duration / 1000),
+			Note:        track.Annotation,
+		}
+		// The first location that is a local file, tags alone are matched otherwise
+		for _, location := range track.Locations {
+			if path := playlistFileLocation(location, baseDir); path != "" {
+				item.Path = path
+				break
+			}
+		}
+		if item.Path != "" || item.Title != "" {
+			items = append(items, item)
+		}
+	}
+	return strings.TrimSpace(document.Title), items, nil
+}
+
+// encodePLS writes songs as a PLS playlist with absolute paths
+func encodePLS(playlist Playlist) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("[playlist]\n")
+	for i, song := range playlist.Songs {
+		n := i + 1
+		fmt.Fprintf(&buf, "File%d=%s\n", n, song.FilePath)
+		title := song.Title
+		if song.Artist != "" && song.Artist != "Unknown Artist" {
+			title = song.Artist + " - " + song.Title
+		}
+		fmt.Fprintf(&buf, "Title%d=%s\n", n, title)
+		length := song.DurationSec
+		if length <= 0 {
+			length = -1 // Unknown, as the format has it
+		}
+		fmt.Fprintf(&buf, "Length%d=%d\n", n, length)
+	}
+	fmt.Fprintf(&buf, "NumberOfEntries=%d\nVersion=2\n", len(playlist.Songs))
+	return buf.Bytes()
+}
+
+// decodePLS reads the entries of a PLS playlist. Entries are keyed by number and may
+// come in any order, NumberOfEntries is often wrong so it's ignored.
+func decodePLS(data []byte, baseDir string) ([]QueueFileItem, error) {
+	entries := make(map[int]*QueueFileItem)
+	entry := func(n int) *QueueFileItem {
+		if entries[n] == nil {
+			entries[n] = &QueueFileItem{}
+		}
+		return entries[n]
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	sawHeader := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.EqualFold(line, "[playlist]") {
+			sawHeader = true
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		for _, field := range []string{"file", "title", "length"} {
+			n, err := strconv.Atoi(strings.TrimPrefix(key, field))
+			if !strings.HasPrefix(key, field) || err != nil {
+				continue
+			}
+			switch field {
+			case "file":
+				entry(n).Path = playlistFileLocation(value, baseDir)
+			case "title":
+				entry(n).Title = strings.TrimSpace(value)
+			case "length":
+				if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+					entry(n).DurationSec = seconds
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !sawHeader {
+		return nil, fmt.Errorf("missing [playlist] section")
+	}
+
+	numbers := make([]int, 0, len(entries))
+	for n := range entries {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	items := make([]QueueFileItem, 0, len(numbers))
+	for _, n := range numbers {
+		item := *entries[n]
+		if item.Path == "" {
+			continue // Streams, PLS is mostly used for internet radio
+		}
+		// "Artist - Title" is the usual title form, it helps matching by tags
+		if artist, title, ok := strings.Cut(item.Title, " - "); ok {
+			item.Artist, item.Title = artist, title
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// ExportPlaylistFile saves a playlist as XSPF or PLS, picked by the file extension, and
+// returns the path written. When path is empty a save dialog is shown.
+func (a *App) ExportPlaylistFile(playlistPath string, path string) (string, error) {
+	playlistDir, err := a.playlistFolder(playlistPath)
+	if err != nil {
+		return "", err
+	}
+	path = normalizePath(path)
+
+	if path == "" {
+		if a.ctx == nil {
+			return "", fmt.Errorf("no file path given")
+		}
+		chosen, err := wailsruntime.SaveFileDialog(a.ctx, wailsruntime.SaveDialogOptions{
+			Title:           "Export Playlist",
+			DefaultFilename: filepath.Base(playlistDir) + playlistFileXSPF,
+			Filters: []wailsruntime.FileFilter{
+				{DisplayName: "XSPF Playlist (*" + playlistFileXSPF + ")", Pattern: "*" + playlistFileXSPF},
+				{DisplayName: "PLS Playlist (*" + playlistFilePLS + ")", Pattern: "*" + playlistFilePLS},
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("error choosing export file: %v", err)
+		}
+		if chosen == "" {
+			return "", nil // Cancelled
+		}
+		path = chosen
+	}
+
+	playlist, err := a.getCachedPlaylist(playlistDir)
+	if err != nil {
+		return "", fmt.Errorf("error loading playlist: %v", err)
+	}
+	var data []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case playlistFileXSPF:
+		if data, err = encodeXSPF(playlist); err != nil {
+			return "", fmt.Errorf("error encoding playlist: %v", err)
+		}
+	case playlistFilePLS:
+		data = encodePLS(playlist)
+	default:
+		return "", fmt.Errorf("unsupported playlist format: %s", filepath.Ext(path))
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("error writing %s: %v", filepath.Base(path), err)
+	}
+
+	logInfo("Exported playlist %s with %d songs to %s", playlist.Name, len(playlist.Songs), path)
+	return path, nil
+}
+
+// ImportPlaylistFile creates a playlist from an XSPF or PLS file. Entries are matched to
+// files on disk first, then to library songs by tags. Songs are listed where they are,
+// or copied into the new playlist's musics/ with copyFiles. name overrides the title
+// stored in the file, the file name is used when neither is set.
+func (a *App) ImportPlaylistFile(path string, name string, copyFiles bool) (ImportPlaylistResult, error) {
+	path = normalizePath(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ImportPlaylistResult{}, fmt.Errorf("error reading playlist file: %v", err)
+	}
+
+	var title string
+	var items []QueueFileItem
+	baseDir := filepath.Dir(path)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case playlistFileXSPF:
+		title, items, err = decodeXSPF(data, baseDir)
+	case playlistFilePLS:
+		items, err = decodePLS(data, baseDir)
+	default:
+		return ImportPlaylistResult{}, fmt.Errorf("unsupported playlist format: %s", filepath.Ext(path))
+	}
+	if err != nil {
+		return ImportPlaylistResult{}, fmt.Errorf("error parsing %s: %v", filepath.Base(path), err)
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		name = title
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	// Entries whose file exists are taken as they are, the rest go through the library
+	resolve, err := a.queueItemResolver()
+	if err != nil {
+		return ImportPlaylistResult{}, err
+	}
+	var files []string
+	missing := []QueueFileItem{}
+	for _, item := range items {
+		if info, err := os.Stat(item.Path); item.Path != "" && err == nil && !info.IsDir() && isSupportedAudioFile(item.Path) {
+			files = append(files, item.Path)
+			if song, err := a.songMetadata(item.Path, nil); err == nil && item.Note != "" {
+				a.importTrackNote(song, item.Note)
+			}
+		} else if song, ok := resolve(item); ok {
+			files = append(files, song.FilePath)
+			a.importTrackNote(song, item.Note)
+		} else {
+			missing = append(missing, item)
+		}
+	}
+
+	created, err := a.CreatePlaylist(name, "")
+	if err != nil {
+		return ImportPlaylistResult{}, err
+	}
+	playlistDir := created.FolderPath
+	musicsDir := filepath.Join(playlistDir, "musics")
+
+	tracks := []string{}
+	seen := make(map[string]bool)
+	for _, filePath := range files {
+		if seen[pathKey(filePath)] {
+			continue
+		}
+		seen[pathKey(filePath)] = true
+		if copyFiles {
+			destination := availablePath(musicsDir, filepath.Base(filePath))
+			if err := copyFile(filePath, destination); err != nil {
+				logError("Error copying %s: %v", filePath, err)
+				continue
+			}
+			filePath = destination
+		}
+		tracks = append(tracks, trackEntry(musicsDir, filePath))
+	}
+
+	config, err := readPlaylistConfig(playlistDir)
+	if err != nil {
+		return ImportPlaylistResult{}, err
+	}
+	playlist, err := a.savePlaylistTracks(playlistDir, config, tracks)
+	if err != nil {
+		return ImportPlaylistResult{}, err
+	}
+	a.emitEvent(eventPlaylistsChanged)
+
+	logInfo("Imported playlist %s from %s: %d resolved, %d missing", name, path, len(tracks), len(missing))
+	return ImportPlaylistResult{Playlist: playlist, Resolved: len(tracks), Missing: missing}, nil
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// eventPlaylistsChanged is emitted when a playlist was created, renamed or deleted
+const eventPlaylistsChanged = "library:playlists-changed"
+
+// pathInside returns a path's location relative to dir, ok is false for paths outside it
+func pathInside(dir string, path string) (string, bool) {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// playlistFolder checks that an API argument is a playlist, a folder directly inside the
+// static folder, and returns its normalized path
+func (a *App) playlistFolder(playlistPath string) (string, error) {
+	playlistDir := normalizePath(playlistPath)
+	if pathKey(filepath.Dir(playlistDir)) != pathKey(a.GetStaticFolderPath()) {
+		return "", fmt.Errorf("not a playlist folder: %s", playlistPath)
+	}
+	info, err := os.Stat(playlistDir)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("playlist not found: %s", playlistPath)
+	}
+	return playlistDir, nil
+}
+
+// readPlaylistConfig reads a playlist's playlist.toml, a missing file is an empty config
+func readPlaylistConfig(playlistDir string) (PlaylistConfig, error) {
+	var config PlaylistConfig
+	playlistFile := filepath.Join(playlistDir, "playlist.toml")
+	if _, err := os.Stat(playlistFile); err == nil {
+		if _, err := toml.DecodeFile(playlistFile, &config); err != nil {
+			return PlaylistConfig{}, fmt.Errorf("error parsing playlist.toml: %v", err)
+		}
+	}
+	if config.Songs == nil {
+		config.Songs = make(map[string]int)
+	}
+	return config, nil
+}
+
+// CreatePlaylist makes an empty playlist: a folder in the static folder named after the
+// playlist, with musics/ inside and a playlist.toml
+func (a *App) CreatePlaylist(name string, description string) (Playlist, error) {
+	name = strings.TrimSpace(name)
+	folder := playlistFolderName(name)
+	if folder == "" {
+		return Playlist{}, fmt.Errorf("playlist name can't be empty")
+	}
+	playlistDir := filepath.Join(a.GetStaticFolderPath(), folder)
+	if _, err := os.Stat(playlistDir); err == nil {
+		return Playlist{}, fmt.Errorf("a playlist folder named %q already exists", folder)
+	}
+
+	if err := os.MkdirAll(filepath.Join(playlistDir, "musics"), 0755); err != nil {
+		return Playlist{}, fmt.Errorf("error creating playlist folder: %v", err)
+	}
+	config := PlaylistConfig{
+		Name:        name,
+		Description: strings.TrimSpace(description),
+		Songs:       make(map[string]int),
+	}
+	if err := a.savePlaylistConfig(playlistDir, config); err != nil {
+		os.RemoveAll(playlistDir)
+		return Playlist{}, err
+	}
+
+	playlist, err := a.getCachedPlaylist(playlistDir)
+	if err != nil {
+		return Playlist{}, fmt.Errorf("error loading playlist: %v", err)
+	}
+	logInfo("Created playlist %q in %s", name, playlistDir)
+	a.emitEvent(eventPlaylistsChanged)
+	return listPlaylists([]Playlist{playlist})[0], nil
+}
+
+// RenamePlaylist changes a playlist's name in playlist.toml and renames its folder to
+// match, unless another folder already has that name
+func (a *App) RenamePlaylist(playlistPath string, newName string) (Playlist, error) {
+	playlistDir, err := a.playlistFolder(playlistPath)
+	if err != nil {
+		return Playlist{}, err
+	}
+	newName = strings.TrimSpace(newName)
+	folder := playlistFolderName(newName)
+	if folder == "" {
+		return Playlist{}, fmt.Errorf("playlist name can't be empty")
+	}
+
+	config, err := readPlaylistConfig(playlistDir)
+	if err != nil {
+		return Playlist{}, err
+	}
+	config.Name = newName
+	if err := a.savePlaylistConfig(playlistDir, config); err != nil {
+		return Playlist{}, err
+	}
+
+	a.playlistMutex.Lock()
+	delete(a.playlistCache, filepath.Clean(playlistDir))
+	a.playlistMutex.Unlock()
+
+	newDir := filepath.Join(filepath.Dir(playlistDir), folder)
+	if newDir != playlistDir {
+		if _, err := os.Stat(newDir); err == nil && pathKey(newDir) != pathKey(playlistDir) {
+			logWarn("Kept folder %s for renamed playlist, %s exists", playlistDir, newDir)
+		} else if err := os.Rename(playlistDir, newDir); err != nil {
+			logWarn("Could not rename playlist folder: %v", err)
+		} else {
+			a.playlistFolderMoved(playlistDir, newDir)
+			playlistDir = newDir
+		}
+	}
+
+	playlist, err := a.getCachedPlaylist(playlistDir)
+	if err != nil {
+		return Playlist{}, fmt.Errorf("error loading playlist: %v", err)
+	}
+	a.emitEvent(eventPlaylistsChanged)
+	return listPlaylists([]Playlist{playlist})[0], nil
+}
+
+// playlistFolderMoved points the queue, the playing song and the startup playlist at a
+// playlist's new folder
+func (a *App) playlistFolderMoved(oldDir string, newDir string) {
+	a.queueMutex.Lock()
+	moved := false
+	for i := range a.queue {
+		if rel, ok := pathInside(oldDir, a.queue[i].FilePath); ok {
+			a.queue[i].FilePath = filepath.Join(newDir, rel)
+			moved = true
+		}
+	}
+	state := a.queueStateLocked()
+	a.queueMutex.Unlock()
+	if moved {
+		a.emitEvent(eventQueueChanged, state)
+	}
+
+	if current := a.currentSong; current != nil {
+		if rel, ok := pathInside(oldDir, current.FilePath); ok {
+			updated := *current
+			updated.FilePath = filepath.Join(newDir, rel)
+			a.currentSong = &updated
+		}
+	}
+
+	if pathKey(a.settings.StartupPlaylist) == pathKey(oldDir) {
+		a.settings.StartupPlaylist = newDir
+		if err := a.saveSettings(); err != nil {
+			logWarn("%v", err)
+		}
+	}
+}
+
+// DeletePlaylist deletes a playlist folder with its songs, moving it to the OS trash
+// unless toTrash is false. Queued songs from it are dropped.
+func (a *App) DeletePlaylist(playlistPath string, toTrash bool) error {
+	playlistDir, err := a.playlistFolder(playlistPath)
+	if err != nil {
+		return err
+	}
+
+	if toTrash {
+		if _, err := moveToTrash(playlistDir); err != nil {
+			return fmt.Errorf("error moving %s to the trash: %v", filepath.Base(playlistDir), err)
+		}
+	} else if err := os.RemoveAll(playlistDir); err != nil {
+		return fmt.Errorf("error deleting %s: %v", filepath.Base(playlistDir), err)
+	}
+
+	// The index drops its songs on the next scan
+	a.playlistMutex.Lock()
+	delete(a.playlistCache, filepath.Clean(playlistDir))
+	a.playlistMutex.Unlock()
+	a.removeFromQueueWhere(func(song Song) bool {
+		_, inside := pathInside(playlistDir, song.FilePath)
+		return inside
+	})
+
+	logInfo("Deleted playlist %s (trash: %v)", playlistDir, toTrash)
+	a.emitEvent(eventPlaylistsChanged)
+	return nil
+}
+
+// playlistSources returns the extra folders a playlist scans. Relative entries are
+// relative to the playlist folder, "~/" is the home folder.
+func playlistSources(playlistDir string, config PlaylistConfig) []string {
+	var sources []string
+	for _, source := range config.Sources {
+		source = strings.TrimSpace(source)
+		if strings.HasPrefix(source, "~/") || strings.HasPrefix(source, `~\`) {
+			if homeDir, err := os.UserHomeDir(); err == nil {
+				source = filepath.Join(homeDir, source[2:])
+			}
+		}
+		source = normalizePath(source)
+		if source == "" {
+			continue
+		}
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(playlistDir, source)
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// songRoot returns the folder of a playlist a song was scanned from, musics/ or one of
+// its sources, and the song's path relative to it
+func songRoot(playlistDir string, config PlaylistConfig, filePath string) (string, string, bool) {
+	roots := append([]string{filepath.Join(playlistDir, "musics")}, playlistSources(playlistDir, config)...)
+	for _, root := range roots {
+		if rel, ok := pathInside(root, filePath); ok {
+			return root, rel, true
+		}
+	}
+	return "", "", false
+}
+
+// How AddSongToPlaylist brings a song in
+const (
+	playlistAddReference = "reference" // List the song where it is
+	playlistAddCopy      = "copy"      // Copy the file into musics/
+	playlistAddMove      = "move"      // Move the file into musics/
+)
+
+// trackEntry returns how a song is listed in a playlist's track list: relative to
+// musics/ for its own files, absolute for songs kept elsewhere
+func trackEntry(musicsDir string, filePath string) string {
+	if rel, ok := pathInside(musicsDir, filePath); ok {
+		return filepath.ToSlash(rel)
+	}
+	return filePath
+}
+
+// trackEntryPath resolves a track list entry to a file path
+func trackEntryPath(musicsDir string, entry string) string {
+	if filepath.IsAbs(entry) || filepath.VolumeName(entry) != "" {
+		return normalizePath(entry)
+	}
+	return filepath.Join(musicsDir, filepath.FromSlash(entry))
+}
+
+// applyTrackList orders a playlist's files by its track list and adds the listed songs
+// from outside musics/. Files missing from the list follow it in their previous order.
+func applyTrackList(playlistDir string, tracks []string, files []string, positions []int) ([]string, []int) {
+	musicsDir := filepath.Join(playlistDir, "musics")
+	listedAt := make(map[string]int, len(tracks))
+	for i, entry := range tracks {
+		listedAt[pathKey(trackEntryPath(musicsDir, entry))] = i + 1
+	}
+
+	found := make(map[string]bool, len(files))
+	for i, file := range files {
+		key := pathKey(file)
+		found[key] = true
+		if position, ok := listedAt[key]; ok {
+			positions[i] = position
+		} else {
+			positions[i] += len(tracks)
+		}
+	}
+
+	for i, entry := range tracks {
+		filePath := trackEntryPath(musicsDir, entry)
+		if found[pathKey(filePath)] {
+			continue
+		}
+		if _, inside := pathInside(musicsDir, filePath); inside {
+			continue // Deleted or excluded since it was listed
+		}
+		if _, err := os.Stat(filePath); err != nil || !isSupportedAudioFile(filePath) {
+			logWarn("Listed song not found: %s", filePath)
+			continue
+		}
+		files = append(files, filePath)
+		positions = append(positions, i+1)
+	}
+	return files, positions
+}
+
+// playlistTracks returns a playlist's full track list: the one in playlist.toml followed
+// by the songs it doesn't list yet, in their current order
+func (a *App) playlistTracks(playlistDir string, config PlaylistConfig) ([]string, error) {
+	// Rescanned so songs added to musics/ since the last load are included
+	a.playlistMutex.Lock()
+	delete(a.playlistCache, filepath.Clean(playlistDir))
+	a.playlistMutex.Unlock()
+	playlist, err := a.getCachedPlaylist(playlistDir)
+	if err != nil {
+		return nil, fmt.Errorf("error loading playlist: %v", err)
+	}
+
+	musicsDir := filepath.Join(playlistDir, "musics")
+	tracks := append([]string(nil), config.Tracks...)
+	for _, song := range playlist.Songs {
+		if indexOfTrack(musicsDir, tracks, song.FilePath) < 0 {
+			tracks = append(tracks, trackEntry(musicsDir, song.FilePath))
+		}
+	}
+	return tracks, nil
+}
+
+// savePlaylistTracks writes a new track list, keeping the positions of the songs map in
+// step for older versions, and returns the reloaded playlist
+func (a *App) savePlaylistTracks(playlistDir string, config PlaylistConfig, tracks []string) (Playlist, error) {
+	config.Tracks = tracks
+	for i, entry := range tracks {
+		if !filepath.IsAbs(entry) {
+			config.Songs[filepath.Base(filepath.FromSlash(entry))] = i + 1
+		}
+	}
+	if err := a.savePlaylistConfig(playlistDir, config); err != nil {
+		return Playlist{}, err
+	}
+
+	a.playlistMutex.Lock()
+	delete(a.playlistCache, filepath.Clean(playlistDir))
+	a.playlistMutex.Unlock()
+
+	playlist, err := a.getCachedPlaylist(playlistDir)
+	if err != nil {
+		return Playlist{}, fmt.Errorf("error loading playlist: %v", err)
+	}
+	return listPlaylists([]Playlist{playlist})[0], nil
+}
+
+// indexOfTrack returns where a file is in a track list, or -1
+func indexOfTrack(musicsDir string, tracks []string, filePath string) int {
+	key := pathKey(filePath)
+	for i, entry := range tracks {
+		if pathKey(trackEntryPath(musicsDir, entry)) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddSongToPlaylist appends a song to a playlist. mode "reference" (the default) lists
+// the song where it is, "copy" and "move" bring the file into the playlist's musics/.
+func (a *App) AddSongToPlaylist(playlistPath string, filePath string, mode string) (Playlist, error) {
+	playlistDir, err := a.playlistFolder(playlistPath)
+	if err != nil {
+		return Playlist{}, err
+	}
+	filePath, err = a.resolveTrackRef(filePath)
+	if err != nil {
+		return Playlist{}, err
+	}
+	if info, err := os.Stat(filePath); err != nil || info.IsDir() || !isSupportedAudioFile(filePath) {
+		return Playlist{}, fmt.Errorf("not a song file: %s", filePath)
+	}
+	if mode == "" {
+		mode = playlistAddReference
+	}
+	if mode != playlistAddReference && mode != playlistAddCopy && mode != playlistAddMove {
+		return Playlist{}, fmt.Errorf("unknown add mode: %s", mode)
+	}
+
+	config, err := readPlaylistConfig(playlistDir)
+	if err != nil {
+		return Playlist{}, err
+	}
+	tracks, err := a.playlistTracks(playlistDir, config)
+	if err != nil {
+		return Playlist{}, err
+	}
+	musicsDir := filepath.Join(playlistDir, "musics")
+	if indexOfTrack(musicsDir, tracks, filePath) >= 0 {
+		return Playlist{}, fmt.Errorf("%s is already in the playlist", filepath.Base(filePath))
+	}
+
+	_, inside := pathInside(musicsDir, filePath)
+	if !inside && mode != playlistAddReference {
+		if err := os.MkdirAll(musicsDir, 0755); err != nil {
+			return Playlist{}, fmt.Errorf("error creating musics folder: %v", err)
+		}
+		destination := availablePath(musicsDir, filepath.Base(filePath))
+		if mode == playlistAddMove {
+			err = moveFile(filePath, destination)
+		} else {
+			err = copyFile(filePath, destination)
+		}
+		if err != nil {
+			return Playlist{}, fmt.Errorf("error adding %s: %v", filepath.Base(filePath), err)
+		}
+
+		// A playlist the song was moved out of is rescanned without it
+		if mode == playlistAddMove {
+			if sourceDir, ok := playlistDirOf(a.GetStaticFolderPath(), filePath); ok {
+				a.playlistMutex.Lock()
+				delete(a.playlistCache, filepath.Clean(sourceDir))
+				a.playlistMutex.Unlock()
+			}
+		}
+		filePath = destination
+	}
+
+	return a.savePlaylistTracks(playlistDir, config, append(tracks, trackEntry(musicsDir, filePath)))
+}
+
+// escapeScanGlob makes a path match itself literally as a scan glob. Windows doesn't
+// support escapes, but only "[" of the meta characters is allowed in its file names.
+func escapeScanGlob(path string) string {
+	if windowsPaths {
+		return strings.ReplaceAll(path, "[", "?")
+	}
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`).Replace(path)
+}
+
+// RemoveSongFromPlaylist takes a song out of a playlist. Songs in the playlist's musics/
+// are moved to the OS trash when deleteFile is set, otherwise they're excluded from
+// scans and stay on disk, as are songs of its source folders. Songs listed from
+// elsewhere are only unlisted.
+func (a *App) RemoveSongFromPlaylist(playlistPath string, filePath string, deleteFile bool) (Playlist, error) {
+	playlistDir, err := a.playlistFolder(playlistPath)
+	if err != nil {
+		return Playlist{}, err
+	}
+	filePath, err = a.resolveTrackRef(filePath)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	config, err := readPlaylistConfig(playlistDir)
+	if err != nil {
+		return Playlist{}, err
+	}
+	tracks, err := a.playlistTracks(playlistDir, config)
+	if err != nil {
+		return Playlist{}, err
+	}
+	musicsDir := filepath.Join(playlistDir, "musics")
+	index := indexOfTrack(musicsDir, tracks, filePath)
+	if index < 0 {
+		return Playlist{}, fmt.Errorf("%s is not in the playlist", filepath.Base(filePath))
+	}
+	tracks = append(tracks[:index], tracks[index+1:]...)
+
+	if root, rel, scanned := songRoot(playlistDir, config, filePath); scanned {
+		if deleteFile && root == musicsDir {
+			if _, err := a.DeleteSongFile(filePath, true); err != nil {
+				return Playlist{}, err
+			}
+			// Deleting rewrote playlist.toml
+			if config, err = readPlaylistConfig(playlistDir); err != nil {
+				return Playlist{}, err
+			}
+		} else {
+			config.Exclude = append(config.Exclude, escapeScanGlob(filepath.ToSlash(rel)))
+			delete(config.Songs, filepath.Base(filePath))
+		}
+	}
+	return a.savePlaylistTracks(playlistDir, config, tracks)
+}
+
+// ReorderPlaylist puts a playlist's songs in a new order. Songs left out of filePaths
+// keep their relative order after the listed ones.
+func (a *App) ReorderPlaylist(playlistPath string, filePaths []string) (Playlist, error) {
+	playlistDir, err := a.playlistFolder(playlistPath)
+	if err != nil {
+		return Playlist{}, err
+	}
+	config, err := readPlaylistConfig(playlistDir)
+	if err != nil {
+		return Playlist{}, err
+	}
+	tracks, err := a.playlistTracks(playlistDir, config)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	musicsDir := filepath.Join(playlistDir, "musics")
+	placed := make([]bool, len(tracks))
+	ordered := make([]string, 0, len(tracks))
+	for _, ref := range filePaths {
+		filePath, err := a.resolveTrackRef(ref)
+		if err != nil {
+			return Playlist{}, err
+		}
+		index := indexOfTrack(musicsDir, tracks, filePath)
+		if index < 0 {
+			return Playlist{}, fmt.Errorf("%s is not in the playlist", filepath.Base(filePath))
+		}
+		if !placed[index] {
+			placed[index] = true
+			ordered = append(ordered, tracks[index])
+		}
+	}
+	for i, entry := range tracks {
+		if !placed[i] {
+			ordered = append(ordered, entry)
+		}
+	}
+	return a.savePlaylistTracks(playlistDir, config, ordered)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// eventScheduleStopped is emitted with the reason when playback stops because the next
+// song's playlist is outside its schedule
+const eventScheduleStopped = "playback:schedule-stopped"
+
+// PlaylistSchedule limits when and how long a playlist can be played, e.g. a bedtime
+// playlist only playing 19:00-21:00 for 45 minutes a day. Empty fields don't limit.
+type PlaylistSchedule struct {
+	From       string `toml:"from,omitempty" json:"from,omitempty"`             // "19:00"
+	Until      string `toml:"until,omitempty" json:"until,omitempty"`           // "21:00", before From for windows past midnight
+	MaxMinutes int    `toml:"max_minutes,omitzero" json:"maxMinutes,omitempty"` // Listening time per day
+}
+
+// scheduleClock parses an "HH:MM" schedule time into minutes since midnight
+func scheduleClock(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// validate checks the schedule's times and limit
+func (s *PlaylistSchedule) validate() error {
+	if (s.From == "") != (s.Until == "") {
+		return fmt.Errorf("a schedule needs both a start and an end time")
+	}
+	if s.From != "" {
+		if _, err := scheduleClock(s.From); err != nil {
+			return err
+		}
+		if _, err := scheduleClock(s.Until); err != nil {
+			return err
+		}
+	}
+	if s.MaxMinutes < 0 {
+		return fmt.Errorf("daily limit can't be negative")
+	}
+	return nil
+}
+
+// inWindow reports whether a time falls inside the schedule's daily window
+func (s *PlaylistSchedule) inWindow(now time.Time) bool {
+	if s.From == "" {
+		return true
+	}
+	from, errFrom := scheduleClock(s.From)
+	until, errUntil := scheduleClock(s.Until)
+	if errFrom != nil || errUntil != nil || from == until {
+		return true
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if from < until {
+		return minute >= from && minute < until
+	}
+	return minute >= from || minute < until
+}
+
+// scheduleUsage is how long a scheduled playlist was listened to on one day
+type scheduleUsage struct {
+	Day     string `json:"day"` // 2006-01-02, local time
+	Seconds int    `json:"seconds"`
+}
+
+// scheduleUsageStore keeps today's listening time of scheduled playlists, on disk so
+// restarting the app doesn't reset a daily limit
+type scheduleUsageStore struct {
+	path  string
+	usage map[string]scheduleUsage // playlist folder -> listening time
+	mutex sync.Mutex
+}
+
+// newScheduleUsageStore creates a usage store backed by the given file
+func newScheduleUsageStore(path string) *scheduleUsageStore {
+	return &scheduleUsageStore{path: path, usage: make(map[string]scheduleUsage)}
+}
+
+// load reads the listening times from disk
+func (s *scheduleUsageStore) load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading schedule usage: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.usage); err != nil {
+		return fmt.Errorf("error parsing schedule usage: %v", err)
+	}
+	if s.usage == nil {
+		s.usage = make(map[string]scheduleUsage)
+	}
+	return nil
+}
+
+// seconds returns how long a playlist was listened to on the day of now
+func (s *scheduleUsageStore) seconds(playlistDir string, now time.Time) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	usage := s.usage[pathKey(playlistDir)]
+	if usage.Day != now.Format("2006-01-02") {
+		return 0
+	}
+	return usage.Seconds
+}
+
+// add counts listening time towards a playlist's day and saves the store
+func (s *scheduleUsageStore) add(playlistDir string, seconds int, now time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := pathKey(playlistDir)
+	day := now.Format("2006-01-02")
+	usage := s.usage[key]
+	if usage.Day != day {
+		usage = scheduleUsage{Day: day}
+	}
+	usage.Seconds += seconds
+	s.usage[key] = usage
+	return writeJSONFile(s.path, s.usage)
+}
+
+// scheduledPlaylistsOf returns the cached playlists with a schedule that contain a song
+func (a *App) scheduledPlaylistsOf(filePath string) []Playlist {
+	a.playlistMutex.RLock()
+	defer a.playlistMutex.RUnlock()
+
+	var scheduled []Playlist
+	for _, playlist := range a.playlistCache {
+		if playlist.Schedule == nil {
+			continue
+		}
+		for _, song := range playlist.Songs {
+			if song.FilePath == filePath {
+				scheduled = append(scheduled, playlist)
+				break
+			}
+		}
+	}
+	return scheduled
+}
+
+// scheduleBlock returns why a song can't be played now, "" when it can. A song in
+// several scheduled playlists has to be allowed by all of them.
+func (a *App) scheduleBlock(filePath string, now time.Time) string {
+	for _, playlist := range a.scheduledPlaylistsOf(filePath) {
+		schedule := playlist.Schedule
+		if !schedule.inWindow(now) {
+			return fmt.Sprintf("%s can only be played between %s and %s", playlist.Name, schedule.From, schedule.Until)
+		}
+		if schedule.MaxMinutes > 0 && a.scheduleUsage.seconds(playlist.FolderPath, now) >= schedule.MaxMinutes*60 {
+			return fmt.Sprintf("%s reached its daily limit of %d min", playlist.Name, schedule.MaxMinutes)
+		}
+	}
+	return ""
+}
+
+// recordScheduledListening counts a finished song towards the daily limits of its
+// scheduled playlists
+func (a *App) recordScheduledListening(song Song, now time.Time) {
+	if song.DurationSec <= 0 {
+		return
+	}
+	for _, playlist := range a.scheduledPlaylistsOf(song.FilePath) {
+		if playlist.Schedule.MaxMinutes == 0 {
+			continue
+		}
+		if err := a.scheduleUsage.add(playlist.FolderPath, song.DurationSec, now); err != nil {
+			logWarn("Could not save schedule usage: %v", err)
+		}
+	}
+}
+
+// SetPlaylistSchedule limits when and how long a playlist can be played, nil removes the
+// limits. Songs of the playlist queued outside its schedule don't start, and playback
+// stops when the next song is one of them.
+func (a *App) SetPlaylistSchedule(playlistPath string, schedule *PlaylistSchedule) (Playlist, error) {
+	playlistDir, err := a.playlistFolder(playlistPath)
+	if err != nil {
+		return Playlist{}, err
+	}
+	if schedule != nil {
+		if err := schedule.validate(); err != nil {
+			return Playlist{}, err
+		}
+		if *schedule == (PlaylistSchedule{}) {
+			schedule = nil
+		}
+	}
+
+	config, err := readPlaylistConfig(playlistDir)
+	if err != nil {
+		return Playlist{}, err
+	}
+	config.Schedule = schedule
+	if err := a.savePlaylistConfig(playlistDir, config); err != nil {
+		return Playlist{}, err
+	}
+
+	a.playlistMutex.Lock()
+	delete(a.playlistCache, filepath.Clean(playlistDir))
+	a.playlistMutex.Unlock()
+
+	playlist, err := a.getCachedPlaylist(playlistDir)
+	if err != nil {
+		return Playlist{}, fmt.Errorf("error loading playlist: %v", err)
+	}
+	a.emitEvent(eventPlaylistsChanged)
+	return listPlaylists([]Playlist{playlist})[0], nil
+}
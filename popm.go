@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// popmEmail is the POPM owner written with ratings. Most players read the rating Windows
+// Media Player writes, so it's used by everyone.
+const popmEmail = "Windows Media Player 9 Series"
+
+// popmStarValues maps 0-5 stars to the POPM rating byte, as Windows Media Player does
+var popmStarValues = [6]byte{0, 1, 64, 128, 196, 255}
+
+// popmPadding is left after the frames of rewritten tags so later edits fit in place
+const popmPadding = 1024
+
+// popmStars turns a POPM frame's rating byte into 0-5 stars
+func popmStars(frame []byte) int {
+	end := bytes.IndexByte(frame, 0)
+	if end < 0 || end+1 >= len(frame) {
+		return 0
+	}
+	value := frame[end+1]
+	switch {
+	case value == 0:
+		return 0
+	case value < 32:
+		return 1
+	case value < 96:
+		return 2
+	case value < 160:
+		return 3
+	case value < 224:
+		return 4
+	}
+	return 5
+}
+
+// syncsafe decodes a 28-bit ID3 syncsafe integer
+func syncsafe(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+// putSyncsafe encodes a 28-bit ID3 syncsafe integer
+func putSyncsafe(b []byte, n int) {
+	b[0], b[1], b[2], b[3] = byte(n>>21&0x7f), byte(n>>14&0x7f), byte(n>>7&0x7f), byte(n&0x7f)
+}
+
+// writePOPMRating replaces the POPM frames of an MP3's ID3v2.3/2.4 tag with one holding
+// the rating, adding a tag to files without one. The audio is copied into a temporary
+// file next to the song, which then replaces it.
+func writePOPMRating(filePath string, stars int) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// The existing tag's frames, minus POPM
+	version := byte(3)
+	var frames []byte
+	header := make([]byte, 10)
+	audioStart := int64(0)
+	if _, err := io.ReadFull(file, header); err == nil && string(header[:3]) == "ID3" {
+		version = header[3]
+		if version != 3 && version != 4 {
+			return fmt.Errorf("ID3v2.%d tags aren't supported", version)
+		}
+		if header[5]&0x90 != 0 {
+			return fmt.Errorf("unsynchronised ID3 tags and tags with a footer aren't supported")
+		}
+		size := syncsafe(header[6:10])
+		tag := make([]byte, size)
+		if _, err := io.ReadFull(file, tag); err != nil {
+			return fmt.Errorf("error reading ID3 tag: %v", err)
+		}
+		audioStart = int64(10 + size)
+
+		offset := 0
+		if header[5]&0x40 != 0 { // Extended header, dropped from the rewritten tag
+			if len(tag) < 4 {
+				return fmt.Errorf("invalid ID3 extended header")
+			}
+			extended := int(binary.BigEndian.Uint32(tag[:4]))
+			if version == 4 {
+				extended = syncsafe(tag[:4])
+			} else {
+				extended += 4
+			}
+			offset = extended
+		}
+		for offset+10 <= len(tag) && tag[offset] != 0 {
+			frameSize := int(binary.BigEndian.Uint32(tag[offset+4 : offset+8]))
+			if version == 4 {
+				frameSize = syncsafe(tag[offset+4 : offset+8])
+			}
+			end := offset + 10 + frameSize
+			if frameSize < 0 || end > len(tag) {
+				return fmt.Errorf("invalid ID3 frame at %d", offset)
+			}
+			if string(tag[offset:offset+4]) != "POPM" {
+				frames = append(frames, tag[offset:end]...)
+			}
+			offset = end
+		}
+	}
+
+	if stars > 0 {
+		body := append([]byte(popmEmail), 0, popmStarValues[stars])
+		frame := make([]byte, 10, 10+len(body))
+		copy(frame, "POPM")
+		if version == 4 {
+			putSyncsafe(frame[4:8], len(body))
+		} else {
+			binary.BigEndian.PutUint32(frame[4:8], uint32(len(body)))
+		}
+		frames = append(append(frames, frame...), body...)
+	}
+
+	newHeader := []byte{'I', 'D', '3', version, 0, 0, 0, 0, 0, 0}
+	putSyncsafe(newHeader[6:10], len(frames)+popmPadding)
+
+	tmpPath := tempPathFor(filePath, "tags")
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", filepath.Base(tmpPath), err)
+	}
+	_, err = tmp.Write(newHeader)
+	if err == nil {
+		_, err = tmp.Write(frames)
+	}
+	if err == nil {
+		_, err = tmp.Write(make([]byte, popmPadding))
+	}
+	if err == nil {
+		if _, err = file.Seek(audioStart, io.SeekStart); err == nil {
+			_, err = io.Copy(tmp, file)
+		}
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	file.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("error writing rating: %v", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("error replacing %s: %v", filepath.Base(filePath), err)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// eventLibraryWarnings is emitted after the startup preflight when something is wrong
+const eventLibraryWarnings = "library:warnings"
+
+// Library warning kinds
+const (
+	libraryWarningMissing    = "missing"    // Folder doesn't exist
+	libraryWarningUnmounted  = "unmounted"  // Folder is on a drive or share that isn't mounted
+	libraryWarningPermission = "permission" // Folder exists but can't be read
+)
+
+// removableMountPrefixes are where removable drives and network shares are usually mounted
+var removableMountPrefixes = []string{"/media/", "/run/media/", "/mnt/", "/Volumes/"}
+
+// LibraryWarning is an actionable problem found with the library on startup
+type LibraryWarning struct {
+	Kind      string   `json:"kind"`
+	Path      string   `json:"path"`
+	Message   string   `json:"message"`
+	Playlists []string `json:"playlists,omitempty"` // Playlists that are unavailable because of it
+}
+
+// libraryPreflight holds the warnings from the last preflight
+type libraryPreflight struct {
+	warnings []LibraryWarning
+	mutex    sync.Mutex
+}
+
+// isOnRemovableMount reports whether a path lives where removable or network drives are mounted
+func isOnRemovableMount(path string) bool {
+	if runtime.GOOS == "windows" {
+		// Any drive other than the system drive may be removable
+		systemDrive := os.Getenv("SystemDrive")
+		volume := filepath.VolumeName(path)
+		return volume != "" && !strings.EqualFold(volume, systemDrive)
+	}
+	for _, prefix := range removableMountPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mountPointOf returns the mount point a path is on (Linux only)
+func mountPointOf(path string) string {
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	best := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		mountPoint := strings.ReplaceAll(fields[1], `\040`, " ")
+		if path != mountPoint && !strings.HasPrefix(path, strings.TrimSuffix(mountPoint, "/")+"/") {
+			continue
+		}
+		if len(mountPoint) > len(best) {
+			best = mountPoint
+		}
+	}
+	return best
+}
+
+// isUnmountedMountDir detects an empty mount directory left behind when a drive isn't mounted,
+// e.g. /mnt/music existing on the root filesystem with the NAS share not attached
+func isUnmountedMountDir(path string) bool {
+	if runtime.GOOS != "linux" || !isOnRemovableMount(path) {
+		return false
+	}
+	mountPoint := mountPointOf(path)
+	if mountPoint == "" {
+		return false
+	}
+	for _, prefix := range removableMountPrefixes {
+		if strings.HasPrefix(mountPoint+"/", prefix) {
+			return false // Something is mounted at or below the removable prefix
+		}
+	}
+	entries, err := os.ReadDir(path)
+	return err == nil && len(entries) == 0
+}
+
+// knownPlaylists returns the playlist folders the library index has songs for, by name
+func (a *App) knownPlaylists(staticPath string) []string {
+	seen := make(map[string]bool)
+	for _, songPath := range a.library.songPaths() {
+		rel, err := filepath.Rel(staticPath, songPath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		parts := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+		if len(parts) == 2 {
+			seen[parts[0]] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkLibrary verifies the static folder and its playlists are mounted and readable
+func (a *App) checkLibrary() []LibraryWarning {
+	staticPath := a.GetStaticFolderPath()
+	known := a.knownPlaylists(staticPath)
+	warnings := []LibraryWarning{}
+
+	unavailable := func(kind string, message string) []LibraryWarning {
+		if len(known) > 0 {
+			message = fmt.Sprintf("%s, %s unavailable", message, pluralize(len(known), "playlist"))
+		}
+		return append(warnings, LibraryWarning{Kind: kind, Path: staticPath, Message: message, Playlists: known})
+	}
+
+	info, err := os.Stat(staticPath)
+	switch {
+	case os.IsNotExist(err):
+		if isOnRemovableMount(staticPath) {
+			return unavailable(libraryWarningUnmounted, fmt.Sprintf("Drive for %s is not mounted", staticPath))
+		}
+		if len(known) == 0 {
+			return warnings // Fresh install, nothing was ever scanned
+		}
+		return unavailable(libraryWarningMissing, fmt.Sprintf("Library folder %s no longer exists", staticPath))
+	case os.IsPermission(err):
+		return unavailable(libraryWarningPermission, fmt.Sprintf("No permission to access %s", staticPath))
+	case err != nil:
+		return unavailable(libraryWarningMissing, fmt.Sprintf("Can't access %s: %v", staticPath, err))
+	case !info.IsDir():
+		return unavailable(libraryWarningMissing, fmt.Sprintf("%s is not a folder", staticPath))
+	}
+
+	entries, err := os.ReadDir(staticPath)
+	if err != nil {
+		return unavailable(libraryWarningPermission, fmt.Sprintf("No permission to read %s", staticPath))
+	}
+	if isUnmountedMountDir(staticPath) && len(known) > 0 {
+		return unavailable(libraryWarningUnmounted, fmt.Sprintf("Drive for %s appears not to be mounted", staticPath))
+	}
+
+	// Individual playlists that can't be read
+	present := make(map[string]bool)
+	for _, entry := range entries {
+		if !entry.IsDir() || a.isIgnoredScanEntry(entry.Name()) {
+			continue
+		}
+		present[entry.Name()] = true
+		playlistDir := filepath.Join(staticPath, entry.Name())
+		if _, err := os.ReadDir(playlistDir); err != nil && os.IsPermission(err) {
+			warnings = append(warnings, LibraryWarning{
+				Kind:      libraryWarningPermission,
+				Path:      playlistDir,
+				Message:   fmt.Sprintf("No permission to read playlist %s, check the folder's permissions", entry.Name()),
+				Playlists: []string{entry.Name()},
+			})
+		}
+	}
+
+	// Playlists that were indexed before but are gone now
+	var missing []string
+	for _, name := range known {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		warnings = append(warnings, LibraryWarning{
+			Kind:      libraryWarningMissing,
+			Path:      staticPath,
+			Message:   fmt.Sprintf("%s from the last scan no longer found in %s", pluralize(len(missing), "playlist"), staticPath),
+			Playlists: missing,
+		})
+	}
+	return warnings
+}
+
+// runLibraryPreflight checks the library on startup and tells the frontend about problems
+func (a *App) runLibraryPreflight() {
+	warnings := a.checkLibrary()
+
+	a.preflight.mutex.Lock()
+	a.preflight.warnings = warnings
+	a.preflight.mutex.Unlock()
+
+	for _, warning := range warnings {
+		logWarn("Library warning: %s", warning.Message)
+	}
+	if len(warnings) > 0 {
+		a.emitEvent(eventLibraryWarnings, warnings)
+	}
+}
+
+// GetLibraryWarnings returns the problems found with the library, rechecking it when recheck is set
+func (a *App) GetLibraryWarnings(recheck bool) []LibraryWarning {
+	if recheck {
+		a.runLibraryPreflight()
+	}
+
+	a.preflight.mutex.Lock()
+	defer a.preflight.mutex.Unlock()
+	if a.preflight.warnings == nil {
+		return []LibraryWarning{}
+	}
+	return a.preflight.warnings
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Default presence templates, matching how Spotify shows a track
+const (
+	defaultPresenceDetailsTemplate   = "{title}"
+	defaultPresenceStateTemplate     = "by {artist}"
+	defaultPresenceLargeTextTemplate = "{album}"
+)
+
+// How Discord shows the song's time: a progress bar counting down to the end, or only
+// the time elapsed since the song started
+const (
+	presenceTimeRemaining = "remaining"
+	presenceTimeElapsed   = "elapsed"
+)
+
+// Discord rejects activity strings shorter than 2 or longer than 128 characters
+const (
+	presenceMinTextLength = 2
+	presenceMaxTextLength = 128
+)
+
+// presenceFields returns the placeholder values available to presence templates
+func presenceFields(song *Song) map[string]string {
+	album := song.Album
+	if album == "Unknown Album" {
+		album = ""
+	}
+	artist := song.Artist
+	if artist == "Unknown Artist" {
+		artist = ""
+	}
+
+	return map[string]string{
+		"title":    song.Title,
+		"artist":   artist,
+		"album":    album,
+		"duration": song.Duration,
+	}
+}
+
+// renderPresenceTemplate fills a template like "{title} • {album}" for a song,
+// clamped to Discord's length limits
+func renderPresenceTemplate(template string, song *Song) string {
+	return fitPresenceText(expandSongTemplate(template, song))
+}
+
+// expandSongTemplate replaces song placeholders in a template.
+// Separators left dangling by empty fields are trimmed.
+func expandSongTemplate(template string, song *Song) string {
+	if song == nil {
+		return ""
+	}
+
+	text := template
+	for name, value := range presenceFields(song) {
+		text = strings.ReplaceAll(text, "{"+name+"}", value)
+	}
+
+	// Clean up "by " or " • " left behind when a field is empty
+	text = strings.TrimSpace(text)
+	for _, sep := range []string{"•", "-", "|", "·"} {
+		text = strings.TrimSpace(strings.Trim(text, sep))
+		text = strings.ReplaceAll(text, sep+"  "+sep, sep)
+	}
+	if text == "by" {
+		text = ""
+	}
+
+	return text
+}
+
+// fitPresenceText clamps a string to the length limits Discord accepts
+func fitPresenceText(text string) string {
+	if text == "" {
+		return ""
+	}
+	if utf8.RuneCountInString(text) > presenceMaxTextLength {
+		runes := []rune(text)
+		text = string(runes[:presenceMaxTextLength-1]) + "…"
+	}
+	for utf8.RuneCountInString(text) < presenceMinTextLength {
+		text += " "
+	}
+	return text
+}
+
+// presenceText renders the details, state and large image text for a song from the settings templates
+func (a *App) presenceText(song *Song) (details string, state string, largeText string) {
+	details = renderPresenceTemplate(a.settings.PresenceDetailsTemplate, song)
+	state = renderPresenceTemplate(a.settings.PresenceStateTemplate, song)
+	largeText = renderPresenceTemplate(a.settings.PresenceLargeTextTemplate, song)
+
+	// Details is the headline, never leave it empty
+	if details == "" {
+		details = fitPresenceText(song.Title)
+	}
+	if largeText == "" {
+		largeText = fitPresenceText(song.Artist + " - " + song.Title)
+	}
+	return details, state, largeText
+}
+
+// presenceTimestamps returns the Discord timestamps for a song that started at start and
+// ends at end. The end is left out for elapsed-only display, Discord then counts up.
+func (a *App) presenceTimestamps(start time.Time, end time.Time) *CustomTimestamps {
+	timestamps := &CustomTimestamps{Start: start.UnixMilli()}
+	if a.settings.DiscordTimeDisplay != presenceTimeElapsed {
+		timestamps.End = end.UnixMilli()
+	}
+	return timestamps
+}
+
+// presenceProgress returns the progress bar timestamps for a song that started playing at
+// start, nil while paused. Discord would keep counting through a pause, so the bar is
+// cleared and comes back from the elapsed time the playback clock kept.
+func (a *App) presenceProgress(song *Song, isPlaying bool, start time.Time) *CustomTimestamps {
+	if song == nil || !isPlaying || song.DurationSec <= 0 {
+		return nil
+	}
+	end := start.Add(a.effectiveDuration(song))
+	if !start.Before(end) {
+		return nil
+	}
+	return a.presenceTimestamps(start, end)
+}
+
+// presenceStateText is the small image tooltip, with where a paused song was left
+func (a *App) presenceStateText(song *Song, isPlaying bool) string {
+	if song == nil {
+		return "Ready"
+	}
+	if isPlaying {
+		return "Playing"
+	}
+	return "Paused at " + a.formatDuration(time.Duration(a.playbackPosition()*float64(time.Second)))
+}
+
+// PresenceSink is a non-Discord target that shows the current track, such as a chat status
+type PresenceSink interface {
+	Name() string
+	Enabled(settings *Settings) bool
+	MinInterval() time.Duration // Minimum time between updates to respect the service's rate limits
+	Update(song *Song, isPlaying bool) error
+	Clear() error
+}
+
+// sinkUpdate is a presence change waiting to be sent to a sink
+type sinkUpdate struct {
+	song      *Song
+	isPlaying bool
+}
+
+// sinkRunner rate-limits a sink, coalescing updates that arrive too quickly
+type sinkRunner struct {
+	sink     PresenceSink
+	lastSent time.Time
+	pending  *sinkUpdate
+	timer    *time.Timer
+	cleared  bool // True when the sink currently shows nothing
+	mutex    sync.Mutex
+}
+
+// registerPresenceSink adds a sink to the presence dispatcher
+func (a *App) registerPresenceSink(sink PresenceSink) {
+	a.sinkMutex.Lock()
+	defer a.sinkMutex.Unlock()
+	a.presenceSinks = append(a.presenceSinks, &sinkRunner{sink: sink, cleared: true})
+}
+
+// updatePresenceSinks forwards a playback change to every enabled sink
+func (a *App) updatePresenceSinks(song *Song, isPlaying bool) {
+	a.sinkMutex.Lock()
+	runners := append([]*sinkRunner(nil), a.presenceSinks...)
+	a.sinkMutex.Unlock()
+
+	inSchedule := a.inPresenceSchedule(time.Now())
+	for _, runner := range runners {
+		if !runner.sink.Enabled(a.settings) {
+			continue
+		}
+		if !inSchedule {
+			// Outside the configured hours, make sure nothing lingers
+			go runner.clear()
+			continue
+		}
+		runner.submit(sinkUpdate{song: song, isPlaying: isPlaying})
+	}
+}
+
+// submit sends an update now, or schedules it once the rate limit allows
+func (r *sinkRunner) submit(update sinkUpdate) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	wait := r.sink.MinInterval() - time.Since(r.lastSent)
+	if wait <= 0 && r.timer == nil {
+		r.lastSent = time.Now()
+		go r.send(update)
+		return
+	}
+
+	// Keep only the newest update, the old one is stale anyway
+	r.pending = &update
+	if r.timer == nil {
+		if wait < 0 {
+			wait = 0
+		}
+		r.timer = time.AfterFunc(wait, r.flush)
+	}
+}
+
+// flush sends the pending update after the rate limit window
+func (r *sinkRunner) flush() {
+	r.mutex.Lock()
+	update := r.pending
+	r.pending = nil
+	r.timer = nil
+	if update != nil {
+		r.lastSent = time.Now()
+	}
+	r.mutex.Unlock()
+
+	if update != nil {
+		r.send(*update)
+	}
+}
+
+// send pushes an update to the sink, clearing it when nothing is playing
+func (r *sinkRunner) send(update sinkUpdate) {
+	var err error
+	if update.song == nil || !update.isPlaying {
+		err = r.sink.Clear()
+	} else {
+		err = r.sink.Update(update.song, update.isPlaying)
+	}
+	if err != nil {
+		logWarn("Presence sink %s: %v", r.sink.Name(), err)
+		return
+	}
+
+	r.mutex.Lock()
+	r.cleared = update.song == nil || !update.isPlaying
+	r.mutex.Unlock()
+}
+
+// clear removes the presence from the sink if it's showing anything
+func (r *sinkRunner) clear() {
+	r.mutex.Lock()
+	if r.cleared {
+		r.mutex.Unlock()
+		return
+	}
+	r.pending = nil
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	r.mutex.Unlock()
+
+	r.send(sinkUpdate{})
+}
+
+// inPresenceSchedule reports whether presence sinks may be updated at the given time,
+// based on the "HH:MM-HH:MM" schedule and the weekdays-only option
+func (a *App) inPresenceSchedule(now time.Time) bool {
+	if a.settings.PresenceSinkWeekdaysOnly && (now.Weekday() == time.Saturday || now.Weekday() == time.Sunday) {
+		return false
+	}
+
+	start, end, ok := parseTimeWindow(a.settings.PresenceSinkSchedule)
+	if !ok {
+		return true
+	}
+
+	minutes := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minutes >= start && minutes < end
+	}
+	// Windows crossing midnight, e.g. 22:00-02:00
+	return minutes >= start || minutes < end
+}
+
+// parseTimeWindow parses "HH:MM-HH:MM" into minutes since midnight
+func parseTimeWindow(window string) (int, int, bool) {
+	parts := strings.Split(strings.TrimSpace(window), "-")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	parse := func(s string) (int, bool) {
+		t, err := time.Parse("15:04", strings.TrimSpace(s))
+		if err != nil {
+			return 0, false
+		}
+		return t.Hour()*60 + t.Minute(), true
+	}
+
+	start, ok1 := parse(parts[0])
+	end, ok2 := parse(parts[1])
+	return start, end, ok1 && ok2
+}
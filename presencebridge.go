@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// presenceBridgeMinInterval keeps bridges from being flooded when skipping through songs
+const presenceBridgeMinInterval = 2 * time.Second
+
+// PresenceStatus is the Discord-style listening status written for RPC alternatives,
+// e.g. arRPC bridging to Discord web clients, or scripts and status bars
+type PresenceStatus struct {
+	ClientID  string          `json:"clientId"` // Discord application the activity belongs to
+	Playing   bool            `json:"playing"`
+	Activity  *CustomActivity `json:"activity"` // nil when nothing is playing
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// PresenceBridgeSink writes the listening status to a JSON file and, when a socket is
+// set, sends it as SET_ACTIVITY to an RPC bridge listening there
+type PresenceBridgeSink struct {
+	app    *App
+	bridge CustomDiscordRPC
+}
+
+func (b *PresenceBridgeSink) Name() string { return "RPC bridge" }
+
+func (b *PresenceBridgeSink) Enabled(settings *Settings) bool {
+	return settings.PresenceBridge
+}
+
+func (b *PresenceBridgeSink) MinInterval() time.Duration { return presenceBridgeMinInterval }
+
+// Update publishes the song as a Listening activity
+func (b *PresenceBridgeSink) Update(song *Song, isPlaying bool) error {
+	details, state, largeText := b.app.presenceText(song)
+
+	b.app.coverMutex.RLock()
+	largeImage := b.app.currentCoverURL
+	b.app.coverMutex.RUnlock()
+	if largeImage == "" {
+		largeImage = "music_icon"
+	}
+
+	activity := &CustomActivity{
+		Type:    2, // Listening
+		Details: details,
+		State:   state,
+		Assets:  &CustomAssets{LargeImage: largeImage, LargeText: largeText, SmallImage: "play_icon", SmallText: "Playing"},
+	}
+	activity.Timestamps = b.app.presenceProgress(song, isPlaying, b.app.playbackStarted())
+	return b.publish(activity)
+}
+
+// Clear publishes that nothing is playing
+func (b *PresenceBridgeSink) Clear() error {
+	return b.publish(nil)
+}
+
+// publish writes the status file, then forwards the activity to the bridge socket
+func (b *PresenceBridgeSink) publish(activity *CustomActivity) error {
+	status := PresenceStatus{ClientID: discordAppID, Playing: activity != nil, Activity: activity, UpdatedAt: time.Now()}
+	if err := writeJSONFile(presenceStatusPath(b.app.settings), status); err != nil {
+		return err
+	}
+
+	socket := b.app.settings.PresenceBridgeSocket
+	if socket == "" {
+		return nil
+	}
+	if !b.bridge.isActive() {
+		if err := b.bridge.connectPath(discordAppID, socket); err != nil {
+			return fmt.Errorf("error connecting to RPC bridge at %s: %v", socket, err)
+		}
+	}
+	// A failed command drops the connection, the next update reconnects
+	return b.bridge.setActivity(activity)
+}
+
+// presenceStatusPath returns where the listening status JSON is written: the configured
+// file, or static-presence.json in the runtime directory
+func presenceStatusPath(settings *Settings) string {
+	if settings.PresenceBridgeFile != "" {
+		return settings.PresenceBridgeFile
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "static-presence.json")
+}
+
+// GetPresenceStatusPath returns where the listening status for RPC bridges is written
+func (a *App) GetPresenceStatusPath() string {
+	return presenceStatusPath(a.settings)
+}
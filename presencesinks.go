@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Rate limits for the built-in presence sinks
+const (
+	slackMinInterval    = 20 * time.Second
+	telegramMinInterval = 60 * time.Second // Telegram flood-limits profile edits aggressively
+)
+
+// defaultSlackStatusEmoji is used when no emoji is configured
+const defaultSlackStatusEmoji = ":headphones:"
+
+// SlackSink shows the current track as the user's Slack status
+type SlackSink struct {
+	app *App
+}
+
+func (s *SlackSink) Name() string { return "Slack" }
+
+func (s *SlackSink) Enabled(settings *Settings) bool {
+	return settings.SlackPresence && settings.SlackToken != ""
+}
+
+func (s *SlackSink) MinInterval() time.Duration { return slackMinInterval }
+
+// Update sets the Slack status to the song, expiring when the song would end
+func (s *SlackSink) Update(song *Song, isPlaying bool) error {
+	_, state, _ := s.app.presenceText(song)
+	text := fmt.Sprintf("%s %s", song.Title, state)
+
+	var expiration int64
+	if song.DurationSec > 0 {
+		expiration = time.Now().Add(s.app.effectiveDuration(song)).Unix()
+	}
+
+	emoji := s.app.settings.SlackStatusEmoji
+	if emoji == "" {
+		emoji = defaultSlackStatusEmoji
+	}
+	return s.setStatus(text, emoji, expiration)
+}
+
+// Clear removes the Slack status
+func (s *SlackSink) Clear() error {
+	return s.setStatus("", "", 0)
+}
+
+// setStatus calls users.profile.set with a user token
+func (s *SlackSink) setStatus(text string, emoji string, expiration int64) error {
+	// Slack truncates status text at 100 characters
+	if len([]rune(text)) > 100 {
+		text = string([]rune(text)[:99]) + "…"
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"profile": map[string]interface{}{
+			"status_text":       text,
+			"status_emoji":      emoji,
+			"status_expiration": expiration,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Slack status: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", "https://slack.com/api/users.profile.set", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.app.settings.SlackToken)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update Slack status: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse Slack response: %v", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("Slack API error: %s", result.Error)
+	}
+
+	logInfo("Slack status updated: %q", text)
+	return nil
+}
+
+// TelegramSink shows the current track in the user's Telegram bio.
+// Bios can only be edited from a user account over MTProto (not the Bot API),
+// so the update is delegated to a helper command such as a Telethon script,
+// which receives the new bio as its only argument.
+type TelegramSink struct {
+	app *App
+}
+
+func (t *TelegramSink) Name() string { return "Telegram" }
+
+func (t *TelegramSink) Enabled(settings *Settings) bool {
+	return settings.TelegramPresence && settings.TelegramBioCommand != ""
+}
+
+func (t *TelegramSink) MinInterval() time.Duration { return telegramMinInterval }
+
+// Update sets the bio to the current song, keeping it within Telegram's 70 character limit
+func (t *TelegramSink) Update(song *Song, isPlaying bool) error {
+	_, state, _ := t.app.presenceText(song)
+	bio := strings.TrimSpace(fmt.Sprintf("🎧 %s %s", song.Title, state))
+	if len([]rune(bio)) > 70 {
+		bio = string([]rune(bio)[:69]) + "…"
+	}
+	return t.setBio(bio)
+}
+
+// Clear restores the user's regular bio
+func (t *TelegramSink) Clear() error {
+	return t.setBio(t.app.settings.TelegramDefaultBio)
+}
+
+// setBio runs the configured helper command with the new bio
+func (t *TelegramSink) setBio(bio string) error {
+	fields := strings.Fields(t.app.settings.TelegramBioCommand)
+	if len(fields) == 0 {
+		return fmt.Errorf("no Telegram bio command configured")
+	}
+
+	args := append(fields[1:], bio)
+	cmd := exec.Command(fields[0], args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Telegram bio command failed: %v\nOutput: %s", err, string(output))
+	}
+
+	logInfo("Telegram bio updated: %q", bio)
+	return nil
+}
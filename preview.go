@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Effect preview snippet lengths in seconds
+const (
+	defaultPreviewSeconds = 10
+	maxPreviewSeconds     = 30
+)
+
+// EffectPreview holds the same excerpt of a song processed with two effect chains,
+// as MP3 data URLs the frontend can play side by side
+type EffectPreview struct {
+	A        string  `json:"a"`
+	B        string  `json:"b"`
+	StartSec float64 `json:"startSec"`
+	Seconds  float64 `json:"seconds"`
+}
+
+// GetEffectPreview renders a short excerpt of a song with two effect chains, so settings
+// like EQ presets or loudness normalization can be compared before processing the whole
+// track. A seconds of 0 uses the default length, longer excerpts are capped.
+func (a *App) GetEffectPreview(filePath string, chainA EffectChain, chainB EffectChain, startSec float64, seconds float64) (EffectPreview, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return EffectPreview{}, err
+	}
+	if err := chainA.validate(); err != nil {
+		return EffectPreview{}, fmt.Errorf("chain A: %v", err)
+	}
+	if err := chainB.validate(); err != nil {
+		return EffectPreview{}, fmt.Errorf("chain B: %v", err)
+	}
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return EffectPreview{}, fmt.Errorf("song file not found: %s", filePath)
+	}
+	if !a.checkFFmpegAvailable() {
+		return EffectPreview{}, fmt.Errorf("FFmpeg is required for effect previews")
+	}
+
+	if startSec < 0 {
+		startSec = 0
+	}
+	if seconds <= 0 {
+		seconds = defaultPreviewSeconds
+	}
+	if seconds > maxPreviewSeconds {
+		seconds = maxPreviewSeconds
+	}
+
+	// Both excerpts render at once, the job scheduler bounds the FFmpeg processes
+	chains := []EffectChain{chainA, chainB}
+	snippets := make([]string, len(chains))
+	errs := make([]error, len(chains))
+	var wg sync.WaitGroup
+	for i, chain := range chains {
+		wg.Add(1)
+		go func(i int, chain EffectChain) {
+			defer wg.Done()
+			snippets[i], errs[i] = a.renderPreview(filePath, chain, startSec, seconds)
+		}(i, chain)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return EffectPreview{}, fmt.Errorf("chain %c: %v", 'A'+i, err)
+		}
+	}
+	return EffectPreview{A: snippets[0], B: snippets[1], StartSec: startSec, Seconds: seconds}, nil
+}
+
+// renderPreview encodes an excerpt of a song through a chain's filters into an MP3 data
+// URL. Chains without filters still go through FFmpeg so A and B are encoded alike.
+func (a *App) renderPreview(filePath string, chain EffectChain, startSec float64, seconds float64) (string, error) {
+	sourcePath := a.chainSource(filePath, chain)
+
+	output, stderr, err := runPreview(sourcePath, chain.filters(true), startSec, seconds)
+	if err != nil && chain.needsRubberband() && strings.Contains(stderr, "rubberband") {
+		output, stderr, err = runPreview(sourcePath, chain.filters(false), startSec, seconds)
+	}
+	if err != nil {
+		return "", fmt.Errorf("FFmpeg error: %v\nOutput: %s", err, stderr)
+	}
+	if len(output) == 0 {
+		return "", fmt.Errorf("start is past the end of the song")
+	}
+	return "data:audio/mpeg;base64," + base64.StdEncoding.EncodeToString(output), nil
+}
+
+// runPreview runs FFmpeg on an excerpt and returns the MP3 it writes to stdout
+func runPreview(sourcePath string, filters []string, startSec float64, seconds float64) ([]byte, string, error) {
+	args := []string{
+		"-ss", strconv.FormatFloat(startSec, 'f', 3, 64),
+		"-t", strconv.FormatFloat(seconds, 'f', 3, 64),
+		"-i", sourcePath,
+	}
+	if len(filters) > 0 {
+		args = append(args, "-af", strings.Join(filters, ","))
+	}
+	args = append(args, processedCodecArgs...)
+	cmd := exec.Command("ffmpeg", append(args, "-f", "mp3", "pipe:1")...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	var err error
+	mediaJobs.do(jobForeground, func() { err = cmd.Run() })
+	return stdout.Bytes(), stderr.String(), err
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of collections playback progress is kept for
+const (
+	collectionAlbum    = "album"
+	collectionPlaylist = "playlist"
+)
+
+const (
+	// minCollectionProgress keeps albums from showing up after one song of them played
+	// in a shuffle
+	minCollectionProgress = 2
+	// collectionProgressMaxAge is how long an unfinished collection is offered
+	collectionProgressMaxAge = 60 * 24 * time.Hour
+	// maxInProgressCollections limits how many collections GetInProgressCollections returns
+	maxInProgressCollections = 12
+)
+
+// collectionProgress is which songs of an album or playlist were played since it was
+// last finished
+type collectionProgress struct {
+	Kind       string          `json:"kind"`
+	Ref        string          `json:"ref"` // Album key or playlist folder
+	Played     map[string]bool `json:"played"`
+	LastPlayed string          `json:"lastPlayed"` // File path of the song played last
+	UpdatedAt  time.Time       `json:"updatedAt"`
+}
+
+// InProgressCollection is an album or playlist the user is partway through, e.g. 6 of
+// its 14 songs played
+type InProgressCollection struct {
+	Kind         string    `json:"kind"` // "album" or "playlist"
+	Name         string    `json:"name"`
+	Artist       string    `json:"artist,omitempty"`     // Album artist
+	FolderPath   string    `json:"folderPath,omitempty"` // Playlist folder
+	CoverHash    string    `json:"coverHash,omitempty"`  // See GetCoverByHash
+	Played       int       `json:"played"`
+	Total        int       `json:"total"`
+	Songs        []Song    `json:"songs"`     // In play order
+	NextIndex    int       `json:"nextIndex"` // Song to continue with, for SetQueue
+	LastPlayedAt time.Time `json:"lastPlayedAt"`
+}
+
+// progressStore keeps collection progress in a JSON file
+type progressStore struct {
+	path        string
+	collections map[string]*collectionProgress // Kind + ref -> progress
+	mutex       sync.Mutex
+}
+
+// newProgressStore creates a progress store backed by the given file
+func newProgressStore(path string) *progressStore {
+	return &progressStore{
+		path:        path,
+		collections: make(map[string]*collectionProgress),
+	}
+}
+
+// load reads the progress file, keeping an empty store if it doesn't exist
+func (p *progressStore) load() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading progress file: %v", err)
+	}
+
+	collections := make(map[string]*collectionProgress)
+	if err := json.Unmarshal(data, &collections); err != nil {
+		return fmt.Errorf("error parsing progress file: %v", err)
+	}
+	p.collections = collections
+	return nil
+}
+
+// record marks a song of a collection as played. Once every song of it has played the
+// collection starts over. total is its current number of songs.
+func (p *progressStore) record(kind string, ref string, filePath string, total int) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := kind + "|" + ref
+	progress, exists := p.collections[key]
+	if !exists || time.Since(progress.UpdatedAt) > collectionProgressMaxAge {
+		progress = &collectionProgress{Kind: kind, Ref: ref, Played: make(map[string]bool)}
+		p.collections[key] = progress
+	}
+	progress.Played[pathKey(filePath)] = true
+	progress.LastPlayed = filePath
+	progress.UpdatedAt = time.Now()
+	if len(progress.Played) >= total {
+		delete(p.collections, key)
+	}
+	return writeJSONFile(p.path, p.collections)
+}
+
+// snapshot returns a copy of the progress of every collection
+func (p *progressStore) snapshot() []collectionProgress {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	result := make([]collectionProgress, 0, len(p.collections))
+	for _, progress := range p.collections {
+		played := make(map[string]bool, len(progress.Played))
+		for key := range progress.Played {
+			played[key] = true
+		}
+		copied := *progress
+		copied.Played = played
+		result = append(result, copied)
+	}
+	return result
+}
+
+// queuedPlaylist returns the folder of the cached playlist whose songs are exactly the
+// given queue, "" when the queue wasn't started from a playlist
+func (a *App) queuedPlaylist(songs []Song) string {
+	if len(songs) == 0 {
+		return ""
+	}
+	queued := make(map[string]bool, len(songs))
+	for _, song := range songs {
+		queued[pathKey(song.FilePath)] = true
+	}
+
+	a.playlistMutex.RLock()
+	defer a.playlistMutex.RUnlock()
+	for folder, playlist := range a.playlistCache {
+		if len(playlist.Songs) != len(songs) {
+			continue
+		}
+		matches := true
+		for _, song := range playlist.Songs {
+			if !queued[pathKey(song.FilePath)] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return folder
+		}
+	}
+	return ""
+}
+
+// albumSongs returns the library songs of a song's album in track order
+func (a *App) albumSongs(song Song) ([]Song, error) {
+	songs, err := a.uniqueLibrarySongs()
+	if err != nil {
+		return nil, err
+	}
+	key := albumKey(song)
+	var album []Song
+	for _, other := range songs {
+		if albumKey(other) == key {
+			album = append(album, other)
+		}
+	}
+	sortAlbumSongs(album)
+	return album, nil
+}
+
+// recordCollectionProgress counts a play towards the song's album and, when the queue
+// was started from a playlist, towards that playlist
+func (a *App) recordCollectionProgress(song Song) {
+	if song.Album != "" && song.Album != "Unknown Album" {
+		if album, err := a.albumSongs(song); err == nil && len(album) > 1 {
+			if err := a.progress.record(collectionAlbum, albumKey(song), song.FilePath, len(album)); err != nil {
+				logError("Failed to save album progress: %v", err)
+			}
+		}
+	}
+
+	a.queueMutex.Lock()
+	folder := a.queuePlaylist
+	a.queueMutex.Unlock()
+	if folder == "" {
+		return
+	}
+	playlist, err := a.getCachedPlaylist(folder)
+	if err != nil {
+		return
+	}
+	for _, other := range playlist.Songs {
+		if pathKey(other.FilePath) == pathKey(song.FilePath) {
+			if err := a.progress.record(collectionPlaylist, folder, song.FilePath, len(playlist.Songs)); err != nil {
+				logError("Failed to save playlist progress: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// songSetKey identifies a set of songs whatever their order
+func songSetKey(songs []Song) string {
+	keys := make([]string, len(songs))
+	for i, song := range songs {
+		keys[i] = pathKey(song.FilePath)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "\x00")
+}
+
+// inProgressCollection builds the card for a collection's progress, false when it's gone
+// from the library or barely started
+func (a *App) inProgressCollection(progress collectionProgress, albums map[string][]Song) (InProgressCollection, bool) {
+	collection := InProgressCollection{Kind: progress.Kind, LastPlayedAt: progress.UpdatedAt}
+	switch progress.Kind {
+	case collectionAlbum:
+		songs := albums[progress.Ref]
+		if len(songs) == 0 {
+			return InProgressCollection{}, false
+		}
+		sortAlbumSongs(songs)
+		collection.Name, collection.Artist = songs[0].Album, songs[0].Artist
+		for _, song := range songs {
+			if !strings.EqualFold(song.Artist, collection.Artist) {
+				collection.Artist = variousArtists
+				break
+			}
+		}
+		collection.Songs = songs
+	case collectionPlaylist:
+		playlist, err := a.getCachedPlaylist(progress.Ref)
+		if err != nil || len(playlist.Songs) == 0 {
+			return InProgressCollection{}, false
+		}
+		collection.Name, collection.FolderPath = playlist.Name, playlist.FolderPath
+		collection.Songs = playlist.Songs
+	default:
+		return InProgressCollection{}, false
+	}
+
+	collection.Total = len(collection.Songs)
+	collection.CoverHash = commonCover(collection.Songs)
+	for i, song := range collection.Songs {
+		if progress.Played[pathKey(song.FilePath)] {
+			collection.Played++
+		}
+		if pathKey(song.FilePath) == pathKey(progress.LastPlayed) {
+			collection.NextIndex = (i + 1) % collection.Total
+		}
+	}
+	// Continue with the first song not played yet from where the user left off
+	for offset := 0; offset < collection.Total; offset++ {
+		index := (collection.NextIndex + offset) % collection.Total
+		if !progress.Played[pathKey(collection.Songs[index].FilePath)] {
+			collection.NextIndex = index
+			break
+		}
+	}
+	if collection.Played < minCollectionProgress || collection.Played >= collection.Total {
+		return InProgressCollection{}, false
+	}
+	collection.Songs = listSongs(collection.Songs)
+	return collection, true
+}
+
+// GetInProgressCollections returns the albums and playlists the user is partway through,
+// most recently played first, for "continue listening" cards. Queue a card's songs with
+// SetQueue(songs, nextIndex) to pick up where it was left.
+func (a *App) GetInProgressCollections() ([]InProgressCollection, error) {
+	songs, err := a.uniqueLibrarySongs()
+	if err != nil {
+		return nil, err
+	}
+	albums := make(map[string][]Song)
+	for _, song := range songs {
+		key := albumKey(song)
+		albums[key] = append(albums[key], song)
+	}
+
+	var found []InProgressCollection
+	playlistSongs := make(map[string]bool) // Song sets of the playlists found
+	for _, progress := range a.progress.snapshot() {
+		if time.Since(progress.UpdatedAt) > collectionProgressMaxAge {
+			continue
+		}
+		if collection, ok := a.inProgressCollection(progress, albums); ok {
+			found = append(found, collection)
+			if collection.Kind == collectionPlaylist {
+				playlistSongs[songSetKey(collection.Songs)] = true
+			}
+		}
+	}
+
+	// A playlist holding just one album is shown once, as the playlist
+	collections := []InProgressCollection{}
+	for _, collection := range found {
+		if collection.Kind == collectionAlbum && playlistSongs[songSetKey(collection.Songs)] {
+			continue
+		}
+		collections = append(collections, collection)
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].LastPlayedAt.After(collections[j].LastPlayedAt)
+	})
+	if len(collections) > maxInProgressCollections {
+		collections = collections[:maxInProgressCollections]
+	}
+	return collections, nil
+}
@@ -0,0 +1,598 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Queue events emitted to the frontend
+const (
+	eventQueueChanged         = "queue:changed"
+	eventQueueCleared         = "queue:cleared"
+	eventPlaybackModesChanged = "playback:modes-changed"
+	eventStoppedAfterCurrent  = "playback:stopped-after-current"
+	eventDuplicateSkipped     = "queue:duplicate-skipped"
+)
+
+// PlaybackModes holds the one-shot and end-of-queue playback modes
+type PlaybackModes struct {
+	StopAfterCurrent        bool `json:"stopAfterCurrent"`        // Stop when the current song ends (resets after use)
+	ClearQueueAfterPlayback bool `json:"clearQueueAfterPlayback"` // Clear the queue once its last song finishes
+}
+
+// QueueState is the queue as reported to the frontend
+type QueueState struct {
+	Songs []Song        `json:"songs"`
+	Index int           `json:"index"` // Index of the current song, -1 if nothing is playing
+	Modes PlaybackModes `json:"modes"`
+	Gain  PlaybackGain  `json:"gain"` // Smart gain for the current song
+}
+
+// queueStateLocked builds the current queue state, caller must hold queueMutex
+func (a *App) queueStateLocked() QueueState {
+	return QueueState{
+		Songs: listSongs(a.queue),
+		Index: a.queueIndex,
+		Modes: a.playbackModes,
+		Gain:  smartGain(a.queue, a.queueIndex, a.settings.SmartGain),
+	}
+}
+
+// GetQueue returns the current playback queue
+func (a *App) GetQueue() QueueState {
+	a.queueMutex.Lock()
+	defer a.queueMutex.Unlock()
+	return a.queueStateLocked()
+}
+
+// SetQueue replaces the queue and marks startIndex as the current song
+func (a *App) SetQueue(songs []Song, startIndex int) error {
+	if startIndex < -1 || startIndex >= len(songs) {
+		return fmt.Errorf("invalid start index: %d", startIndex)
+	}
+	for i := range songs {
+		a.resolveSongPath(&songs[i])
+	}
+	if startIndex >= 0 {
+		if reason := a.scheduleBlock(songs[startIndex].FilePath, time.Now()); reason != "" {
+			return fmt.Errorf("%s", reason)
+		}
+	}
+
+	playlist := a.queuedPlaylist(songs)
+
+	a.queueMutex.Lock()
+	a.queue = songs
+	a.queueIndex = startIndex
+	a.queuePlaylist = playlist
+	state := a.queueStateLocked()
+	a.queueMutex.Unlock()
+
+	a.emitEvent(eventQueueChanged, state)
+	return nil
+}
+
+// QueueStatus tells which of a set of songs, e.g. an album, are queued
+type QueueStatus struct {
+	Queued []string `json:"queued"` // File paths of the songs playing or still to come
+	All    bool     `json:"all"`    // Every song is queued
+}
+
+// EnqueueResult reports which songs EnqueueAll added and which were already queued
+type EnqueueResult struct {
+	Queue   QueueState `json:"queue"`
+	Added   int        `json:"added"`
+	Skipped []Song     `json:"skipped"`
+}
+
+// sameSong reports whether two songs are the same track, by track ID or path
+func sameSong(first Song, second Song) bool {
+	if first.TrackID != "" && first.TrackID == second.TrackID {
+		return true
+	}
+	return pathKey(first.FilePath) == pathKey(second.FilePath)
+}
+
+// isQueuedLocked reports whether a song is playing or still to come in the queue. Songs
+// already played don't count. Caller must hold queueMutex.
+func (a *App) isQueuedLocked(song Song) bool {
+	start := a.queueIndex
+	if start < 0 {
+		start = 0
+	}
+	for _, queued := range a.queue[min(start, len(a.queue)):] {
+		if sameSong(queued, song) {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueLocked appends songs, leaving out queued ones when SkipQueuedDuplicates is on.
+// Returns the skipped songs, caller must hold queueMutex.
+func (a *App) enqueueLocked(songs []Song) []Song {
+	skipped := []Song{}
+	for _, song := range songs {
+		a.resolveSongPath(&song)
+		if a.settings.SkipQueuedDuplicates && a.isQueuedLocked(song) {
+			skipped = append(skipped, listSong(song))
+			continue
+		}
+		a.queue = append(a.queue, song)
+	}
+	return skipped
+}
+
+// Enqueue appends a song to the end of the queue. With SkipQueuedDuplicates on, a song
+// that's already coming up isn't added again.
+func (a *App) Enqueue(song Song) QueueState {
+	a.queueMutex.Lock()
+	skipped := a.enqueueLocked([]Song{song})
+	state := a.queueStateLocked()
+	a.queueMutex.Unlock()
+
+	if len(skipped) > 0 {
+		logDebug("Queue: %s is already queued, not adding it again", song.Title)
+		a.emitEvent(eventDuplicateSkipped, skipped)
+		return state
+	}
+	a.emitEvent(eventQueueChanged, state)
+	return state
+}
+
+// EnqueueAll appends songs, e.g. an album, to the end of the queue. With
+// SkipQueuedDuplicates on, songs that are already coming up are skipped.
+func (a *App) EnqueueAll(songs []Song) EnqueueResult {
+	a.queueMutex.Lock()
+	skipped := a.enqueueLocked(songs)
+	state := a.queueStateLocked()
+	a.queueMutex.Unlock()
+
+	if len(skipped) > 0 {
+		a.emitEvent(eventDuplicateSkipped, skipped)
+	}
+	if len(skipped) < len(songs) {
+		a.emitEvent(eventQueueChanged, state)
+	}
+	return EnqueueResult{Queue: state, Added: len(songs) - len(skipped), Skipped: skipped}
+}
+
+// IsQueued reports whether a song is playing or still to come in the queue
+func (a *App) IsQueued(filePath string) (bool, error) {
+	status, err := a.GetQueueStatus([]string{filePath})
+	return status.All, err
+}
+
+// GetQueueStatus reports which of the songs are playing or still to come in the queue,
+// for "already in queue" badges on tracks and albums
+func (a *App) GetQueueStatus(filePaths []string) (QueueStatus, error) {
+	songs := make([]Song, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		resolved, err := a.resolveTrackRef(filePath)
+		if err != nil {
+			return QueueStatus{}, err
+		}
+		songs = append(songs, Song{FilePath: resolved})
+	}
+
+	status := QueueStatus{Queued: []string{}}
+	a.queueMutex.Lock()
+	for _, song := range songs {
+		if a.isQueuedLocked(song) {
+			status.Queued = append(status.Queued, song.FilePath)
+		}
+	}
+	a.queueMutex.Unlock()
+	status.All = len(songs) > 0 && len(status.Queued) == len(songs)
+	return status, nil
+}
+
+// ClearQueue removes every song from the queue
+func (a *App) ClearQueue() {
+	a.queueMutex.Lock()
+	a.queue = nil
+	a.queueIndex = -1
+	state := a.queueStateLocked()
+	a.queueMutex.Unlock()
+
+	a.emitEvent(eventQueueCleared, state)
+}
+
+// removeFromQueue drops every queued copy of a song, e.g. after its file was deleted
+func (a *App) removeFromQueue(filePath string) {
+	a.removeFromQueueWhere(func(song Song) bool { return song.FilePath == filePath })
+}
+
+// removeFromQueueWhere drops every queued song match returns true for
+func (a *App) removeFromQueueWhere(match func(Song) bool) {
+	a.queueMutex.Lock()
+	var kept []Song
+	index := a.queueIndex
+	for i, song := range a.queue {
+		if !match(song) {
+			kept = append(kept, song)
+			continue
+		}
+		// Keep the current song pointing at the same place, the next song still follows
+		if i <= a.queueIndex {
+			index--
+		}
+	}
+	if len(kept) == len(a.queue) {
+		a.queueMutex.Unlock()
+		return
+	}
+	a.queue = kept
+	if index < -1 {
+		index = -1
+	}
+	a.queueIndex = index
+	state := a.queueStateLocked()
+	a.queueMutex.Unlock()
+
+	a.emitEvent(eventQueueChanged, state)
+}
+
+// GetPlaybackModes returns the current stop/clear playback modes
+func (a *App) GetPlaybackModes() PlaybackModes {
+	a.queueMutex.Lock()
+	defer a.queueMutex.Unlock()
+	return a.playbackModes
+}
+
+// SetStopAfterCurrent enables or disables stopping when the current song ends
+func (a *App) SetStopAfterCurrent(enabled bool) {
+	a.queueMutex.Lock()
+	a.playbackModes.StopAfterCurrent = enabled
+	modes := a.playbackModes
+	a.queueMutex.Unlock()
+
+	a.publishPlaybackModes(modes)
+}
+
+// SetClearQueueAfterPlayback enables or disables clearing the queue when it finishes
+func (a *App) SetClearQueueAfterPlayback(enabled bool) {
+	a.queueMutex.Lock()
+	a.playbackModes.ClearQueueAfterPlayback = enabled
+	modes := a.playbackModes
+	a.queueMutex.Unlock()
+
+	a.publishPlaybackModes(modes)
+}
+
+// publishPlaybackModes notifies the frontend and MPRIS clients about mode changes
+func (a *App) publishPlaybackModes(modes PlaybackModes) {
+	a.emitEvent(eventPlaybackModesChanged, modes)
+
+	if a.mprisProps != nil {
+		a.mprisProps.SetMust(staticInterface, "StopAfterCurrent", modes.StopAfterCurrent)
+		a.mprisProps.SetMust(staticInterface, "ClearQueueAfterPlayback", modes.ClearQueueAfterPlayback)
+	}
+}
+
+// onMPRISPlaybackModeChange applies playback mode changes written by MPRIS clients
+func (a *App) onMPRISPlaybackModeChange(c *prop.Change) *dbus.Error {
+	enabled, ok := c.Value.(bool)
+	if !ok {
+		return prop.ErrInvalidArg
+	}
+
+	a.queueMutex.Lock()
+	switch c.Name {
+	case "StopAfterCurrent":
+		a.playbackModes.StopAfterCurrent = enabled
+	case "ClearQueueAfterPlayback":
+		a.playbackModes.ClearQueueAfterPlayback = enabled
+	}
+	modes := a.playbackModes
+	a.queueMutex.Unlock()
+
+	logDebug("MPRIS: %s set to %t", c.Name, enabled)
+	a.emitEvent(eventPlaybackModesChanged, modes)
+	return nil
+}
+
+// TrackEnded is called by the frontend when the current song finishes naturally.
+// It returns the next song to play, or nil if playback should stop.
+func (a *App) TrackEnded() (*Song, error) {
+	a.queueMutex.Lock()
+
+	// Stop after current is a one-shot mode
+	if a.playbackModes.StopAfterCurrent {
+		a.playbackModes.StopAfterCurrent = false
+		modes := a.playbackModes
+		state := a.queueStateLocked()
+		a.queueMutex.Unlock()
+
+		logInfo("Playback stopped after current track")
+		a.emitEvent(eventStoppedAfterCurrent, state)
+		a.publishPlaybackModes(modes)
+		return nil, nil
+	}
+
+	if len(a.queue) == 0 {
+		a.queueMutex.Unlock()
+		return nil, nil
+	}
+
+	// The finished song counts against its skip score and its playlists' daily limits
+	now := time.Now()
+	var finished *Song
+	if a.queueIndex >= 0 && a.queueIndex < len(a.queue) {
+		finished = &a.queue[a.queueIndex]
+		go a.stats.recordCompletion(finished.FilePath)
+		a.recordScheduledListening(*finished, now)
+	}
+	stopped := TrackTransition{Reason: transitionFinished, At: now}
+	if finished != nil {
+		stopped.From, stopped.PositionSec = finished.FilePath, float64(finished.DurationSec)
+	}
+
+	next := a.queueIndex + 1
+	switch {
+	case a.settings.Repeat == "one" && a.queueIndex >= 0:
+		next = a.queueIndex
+	case next >= len(a.queue) && a.settings.Repeat == "all":
+		next = 0
+	}
+
+	if next >= len(a.queue) {
+		// Reached the end of the queue
+		clearQueue := a.playbackModes.ClearQueueAfterPlayback
+		a.queueIndex = -1
+		if clearQueue {
+			a.queue = nil
+		}
+		state := a.queueStateLocked()
+		a.queueMutex.Unlock()
+
+		if clearQueue {
+			logInfo("Queue finished, clearing queue")
+			a.emitEvent(eventQueueCleared, state)
+		} else {
+			a.emitEvent(eventQueueChanged, state)
+		}
+		a.publishTransition(stopped)
+		return nil, nil
+	}
+
+	// Bedtime: the next song's playlist is outside its schedule
+	if reason := a.scheduleBlock(a.queue[next].FilePath, now); reason != "" {
+		a.queueIndex = -1
+		state := a.queueStateLocked()
+		a.queueMutex.Unlock()
+
+		logInfo("Playback stopped: %s", reason)
+		a.emitEvent(eventQueueChanged, state)
+		a.emitEvent(eventScheduleStopped, reason)
+		a.publishTransition(stopped)
+		return nil, nil
+	}
+
+	a.queueIndex = next
+	song := a.queue[next]
+	state := a.queueStateLocked()
+	a.queueMutex.Unlock()
+
+	// The frontend plays the next song through SetCurrentSong, which takes the reason
+	a.expectTransition(transitionFinished, stopped.From, song.FilePath, stopped.PositionSec)
+
+	a.emitEvent(eventQueueChanged, state)
+	return &song, nil
+}
+
+// queueFileVersion is the current format version of exported queue files
+const queueFileVersion = 1
+
+// queueFileExtension is used for exported queue files
+const queueFileExtension = ".staticqueue"
+
+// QueueFileItem references a song in an exported queue, by path, track ID and tags
+type QueueFileItem struct {
+	Path        string `json:"path"`              // Relative to the static folder when possible, slash-separated
+	TrackID     string `json:"trackId,omitempty"` // Finds the song again after it was moved or renamed
+	Title       string `json:"title"`             // Tags let another library find the song when the path doesn't exist
+	Artist      string `json:"artist"`
+	Album       string `json:"album"`
+	DurationSec int    `json:"durationSec,omitempty"`
+	Note        string `json:"note,omitempty"` // Personal note, given to songs without one on import
+}
+
+// QueueFile is the shareable queue format
+type QueueFile struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Index     int             `json:"index"`
+	Items     []QueueFileItem `json:"items"`
+}
+
+// ImportQueueResult reports how an imported queue was resolved against the library
+type ImportQueueResult struct {
+	Queue    QueueState      `json:"queue"`
+	Resolved int             `json:"resolved"`
+	Missing  []QueueFileItem `json:"missing"`
+}
+
+// ExportQueue saves the current queue to a file and returns its path.
+// When path is empty a save dialog is shown.
+func (a *App) ExportQueue(path string) (string, error) {
+	path = normalizePath(path)
+
+	if path == "" {
+		if a.ctx == nil {
+			return "", fmt.Errorf("no file path given")
+		}
+		chosen, err := wailsruntime.SaveFileDialog(a.ctx, wailsruntime.SaveDialogOptions{
+			Title:           "Export Queue",
+			DefaultFilename: "queue" + queueFileExtension,
+			Filters: []wailsruntime.FileFilter{
+				{DisplayName: "Static Queue (*" + queueFileExtension + ")", Pattern: "*" + queueFileExtension},
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("error choosing export file: %v", err)
+		}
+		if chosen == "" {
+			return "", nil // Cancelled
+		}
+		path = chosen
+	}
+
+	file := a.queueFileFromState(a.GetQueue())
+	if err := writeJSONFile(path, file); err != nil {
+		return "", err
+	}
+
+	logInfo("Exported %d queued songs to %s", len(file.Items), path)
+	return path, nil
+}
+
+// queueFileFromState converts a queue to its file form, storing paths relative to
+// the static folder when possible so queues survive moving the library
+func (a *App) queueFileFromState(state QueueState) QueueFile {
+	staticPath := a.GetStaticFolderPath()
+
+	file := QueueFile{
+		Version:   queueFileVersion,
+		CreatedAt: time.Now(),
+		Index:     state.Index,
+		Items:     make([]QueueFileItem, 0, len(state.Songs)),
+	}
+	for _, song := range state.Songs {
+		itemPath := song.FilePath
+		if rel, err := filepath.Rel(staticPath, song.FilePath); err == nil && !strings.HasPrefix(rel, "..") {
+			itemPath = rel
+		}
+		file.Items = append(file.Items, QueueFileItem{
+			Path:        filepath.ToSlash(itemPath),
+			TrackID:     song.TrackID,
+			Title:       song.Title,
+			Artist:      song.Artist,
+			Album:       song.Album,
+			DurationSec: song.DurationSec,
+			Note:        a.notes.get(userDataKey(song)),
+		})
+	}
+	return file
+}
+
+// ImportQueue loads a queue file, resolving each song by path first and by tags
+// second, and replaces the current queue with the songs that were found
+func (a *App) ImportQueue(path string) (ImportQueueResult, error) {
+	path = normalizePath(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ImportQueueResult{}, fmt.Errorf("error reading queue file: %v", err)
+	}
+
+	var file QueueFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return ImportQueueResult{}, fmt.Errorf("error parsing queue file: %v", err)
+	}
+	if file.Version > queueFileVersion {
+		return ImportQueueResult{}, fmt.Errorf("queue file version %d is newer than supported (%d)", file.Version, queueFileVersion)
+	}
+
+	queue, index, missing, err := a.resolveQueueFile(file)
+	if err != nil {
+		return ImportQueueResult{}, err
+	}
+	if err := a.SetQueue(queue, index); err != nil {
+		return ImportQueueResult{}, err
+	}
+	result := ImportQueueResult{
+		Queue:    a.GetQueue(),
+		Resolved: len(queue),
+		Missing:  missing,
+	}
+
+	logInfo("Imported queue from %s: %d resolved, %d missing", path, result.Resolved, len(result.Missing))
+	return result, nil
+}
+
+// resolveQueueFile matches the items of a queue file against the library, returning
+// the songs found, the index of the current song among them and the items not found
+func (a *App) resolveQueueFile(file QueueFile) ([]Song, int, []QueueFileItem, error) {
+	resolve, err := a.queueItemResolver()
+	if err != nil {
+		return nil, -1, nil, err
+	}
+
+	missing := []QueueFileItem{}
+	var queue []Song
+	index := -1
+
+	for i, item := range file.Items {
+		song, found := resolve(item)
+		if !found {
+			missing = append(missing, item)
+			continue
+		}
+		a.importTrackNote(song, item.Note)
+		if i == file.Index {
+			index = len(queue)
+		}
+		queue = append(queue, song)
+	}
+	return queue, index, missing, nil
+}
+
+// queueItemResolver indexes the library and returns a function finding the song an
+// exported item refers to
+func (a *App) queueItemResolver() (func(QueueFileItem) (Song, bool), error) {
+	songs, err := a.librarySongs()
+	if err != nil {
+		return nil, fmt.Errorf("error scanning library: %v", err)
+	}
+	byPath := make(map[string]Song, len(songs))
+	byID := make(map[string]Song, len(songs))
+	byTags := make(map[string][]Song)
+	for _, song := range songs {
+		byPath[filepath.Clean(song.FilePath)] = song
+		if song.TrackID != "" {
+			byID[song.TrackID] = song
+		}
+		key := matchKey(song.Artist, song.Title)
+		byTags[key] = append(byTags[key], song)
+	}
+
+	staticPath := a.GetStaticFolderPath()
+	return func(item QueueFileItem) (Song, bool) {
+		return resolveQueueItem(item, staticPath, byPath, byID, byTags)
+	}, nil
+}
+
+// resolveQueueItem finds the library song an exported queue item refers to
+func resolveQueueItem(item QueueFileItem, staticPath string, byPath map[string]Song, byID map[string]Song, byTags map[string][]Song) (Song, bool) {
+	itemPath := filepath.FromSlash(item.Path)
+	if !filepath.IsAbs(itemPath) {
+		itemPath = filepath.Join(staticPath, itemPath)
+	}
+	if song, ok := byPath[filepath.Clean(itemPath)]; ok {
+		return song, true
+	}
+	if song, ok := byID[item.TrackID]; ok && item.TrackID != "" {
+		return song, true
+	}
+
+	// Fall back to tags, preferring the same album when several songs match
+	candidates := byTags[matchKey(item.Artist, item.Title)]
+	if len(candidates) == 0 {
+		return Song{}, false
+	}
+	for _, song := range candidates {
+		if strings.EqualFold(song.Album, item.Album) {
+			return song, true
+		}
+	}
+	return candidates[0], true
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+// Virtual playlists built from ratings, their FolderPath identifies them
+const (
+	favoritesPlaylistPath = "virtual:favorites"
+	topRatedPlaylistPath  = "virtual:top-rated"
+)
+
+// topRatedMinStars is the rating a song needs to be in Top Rated
+const topRatedMinStars = 4
+
+// songRating is what the user rated a song
+type songRating struct {
+	Stars       int       `json:"stars,omitempty"` // 1-5, songs without use the file's POPM rating
+	Favorite    bool      `json:"favorite,omitempty"`
+	FavoritedAt time.Time `json:"favoritedAt,omitempty"`
+}
+
+// ratingStore keeps star ratings and favorites
+type ratingStore struct {
+	path    string
+	ratings map[string]songRating // track ID, or path for files without one -> rating
+	mutex   sync.Mutex
+}
+
+// newRatingStore creates a rating store backed by the given file
+func newRatingStore(path string) *ratingStore {
+	return &ratingStore{path: path, ratings: make(map[string]songRating)}
+}
+
+// load reads the ratings from disk
+func (r *ratingStore) load() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading ratings: %v", err)
+	}
+	if err := json.Unmarshal(data, &r.ratings); err != nil {
+		return fmt.Errorf("error parsing ratings: %v", err)
+	}
+	if r.ratings == nil {
+		r.ratings = make(map[string]songRating)
+	}
+	return nil
+}
+
+// get returns a song's rating
+func (r *ratingStore) get(key string) songRating {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.ratings[key]
+}
+
+// update changes a song's rating and saves the store, returning the new rating
+func (r *ratingStore) update(key string, change func(*songRating)) (songRating, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	rating := r.ratings[key]
+	change(&rating)
+	if rating == (songRating{}) {
+		delete(r.ratings, key)
+	} else {
+		r.ratings[key] = rating
+	}
+	return rating, writeJSONFile(r.path, r.ratings)
+}
+
+// move gives a song's rating to a new key, after the song's track ID changed
+func (r *ratingStore) move(oldKey string, newKey string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	rating, ok := r.ratings[oldKey]
+	if !ok || oldKey == newKey {
+		return nil
+	}
+	delete(r.ratings, oldKey)
+	r.ratings[newKey] = rating
+	return writeJSONFile(r.path, r.ratings)
+}
+
+// ratingFromTags reads the star rating of an ID3 POPM frame, 0 when there's none
+func ratingFromTags(metadata tag.Metadata) int {
+	if metadata == nil {
+		return 0
+	}
+	for key, value := range metadata.Raw() {
+		if frame, ok := value.([]byte); ok && strings.HasPrefix(key, "POPM") {
+			if stars := popmStars(frame); stars > 0 {
+				return stars
+			}
+		}
+	}
+	return 0
+}
+
+// withRating returns a song with the user's rating in place of the tagged one
+func (a *App) withRating(song Song) Song {
+	rating := a.ratings.get(userDataKey(song))
+	if rating.Stars > 0 {
+		song.Rating = rating.Stars
+	}
+	song.Favorite = rating.Favorite
+	return song
+}
+
+// SetRating rates a song 1-5 stars, 0 goes back to the file's rating. With
+// WriteRatingTags on, MP3 files get the rating as an ID3 POPM frame.
+func (a *App) SetRating(filePath string, stars int) (Song, error) {
+	filePath, err := a.resolveTrackRef(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+	if stars < 0 || stars > 5 {
+		return Song{}, fmt.Errorf("rating must be between 0 and 5 stars")
+	}
+	song, err := a.songMetadata(filePath, nil)
+	if err != nil {
+		return Song{}, fmt.Errorf("error reading metadata: %v", err)
+	}
+	key := userDataKey(song)
+	if _, err := a.ratings.update(key, func(rating *songRating) { rating.Stars = stars }); err != nil {
+		return Song{}, err
+	}
+
+	if a.settings.WriteRatingTags && strings.EqualFold(filepath.Ext(filePath), ".mp3") {
+		if err := writePOPMRating(filePath, stars); err != nil {
+			logWarn("Could not write rating to %s: %v", filePath, err)
+		} else if song, err = a.reloadSong(filePath); err == nil {
+			// The new tag changes the track ID the user data is kept under
+			a.moveUserData(key, userDataKey(song))
+		}
+	}
+
+	song, err = a.songMetadata(filePath, nil)
+	if err != nil {
+		return Song{}, fmt.Errorf("error reading metadata: %v", err)
+	}
+	a.refreshSong(song)
+	a.emitEvent(eventSongUpdated, song)
+	return listSong(song), nil
+}
+
+// ToggleFavorite adds a song to Favorites, or takes it out
+func (a *App) ToggleFavorite(filePath string) (Song, error) {
+	filePath, err := a.resolveTrackRef(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+	song, err := a.songMetadata(filePath, nil)
+	if err != nil {
+		return Song{}, fmt.Errorf("error reading metadata: %v", err)
+	}
+	rating, err := a.ratings.update(userDataKey(song), func(rating *songRating) {
+		rating.Favorite = !rating.Favorite
+		rating.FavoritedAt = time.Time{}
+		if rating.Favorite {
+			rating.FavoritedAt = time.Now()
+		}
+	})
+	if err != nil {
+		return Song{}, err
+	}
+
+	song.Favorite = rating.Favorite
+	a.refreshSong(song)
+	a.emitEvent(eventSongUpdated, song)
+	return listSong(song), nil
+}
+
+// GetRatingPlaylists returns the virtual Favorites and Top Rated playlists. Favorites are
+// newest first, Top Rated has the 4 and 5 star songs, best first.
+func (a *App) GetRatingPlaylists() ([]Playlist, error) {
+	songs, err := a.uniqueLibrarySongs()
+	if err != nil {
+		return nil, err
+	}
+
+	favoritedAt := make(map[string]time.Time)
+	var favorites, topRated []Song
+	for _, song := range songs {
+		if song.Favorite {
+			favoritedAt[song.FilePath] = a.ratings.get(userDataKey(song)).FavoritedAt
+			favorites = append(favorites, song)
+		}
+		if song.Rating >= topRatedMinStars {
+			topRated = append(topRated, song)
+		}
+	}
+	sort.SliceStable(favorites, func(i, j int) bool {
+		return favoritedAt[favorites[i].FilePath].After(favoritedAt[favorites[j].FilePath])
+	})
+	sort.SliceStable(topRated, func(i, j int) bool {
+		if topRated[i].Rating != topRated[j].Rating {
+			return topRated[i].Rating > topRated[j].Rating
+		}
+		return strings.ToLower(topRated[i].Title) < strings.ToLower(topRated[j].Title)
+	})
+
+	playlists := []Playlist{
+		{Name: "Favorites", Description: "Songs you marked as favorites", FolderPath: favoritesPlaylistPath, Songs: favorites, Virtual: true},
+		{Name: "Top Rated", Description: "Songs rated 4 stars or more", FolderPath: topRatedPlaylistPath, Songs: topRated, Virtual: true},
+	}
+	for i := range playlists {
+		if playlists[i].Songs == nil {
+			playlists[i].Songs = []Song{}
+		}
+		for position := range playlists[i].Songs {
+			playlists[i].Songs[position].Position = position + 1
+		}
+	}
+	return listPlaylists(playlists), nil
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eventRemoteCommand forwards playback commands from the web remote to the frontend player
+const eventRemoteCommand = "remote:command"
+
+// defaultRemotePort is used when no web remote port is configured
+const defaultRemotePort = 8765
+
+// remoteCommands are the playback commands the web remote may send
+var remoteCommands = map[string]bool{"play": true, "pause": true, "toggle": true, "next": true, "previous": true}
+
+// RemoteInfo describes the running web remote
+type RemoteInfo struct {
+	Running   bool   `json:"running"`
+	URL       string `json:"url"`
+	Token     string `json:"token"` // Host token, required for playback control
+	PartyMode bool   `json:"partyMode"`
+}
+
+// RemoteSong is the song view exposed over the web remote, without cover data or the
+// host's file paths
+type RemoteSong struct {
+	TrackID  string `json:"trackId"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Album    string `json:"album"`
+	Duration string `json:"duration"`
+}
+
+// toRemoteSong strips a song down to what remote clients need
+func toRemoteSong(song Song) RemoteSong {
+	return RemoteSong{
+		TrackID:  song.TrackID,
+		Title:    song.Title,
+		Artist:   song.Artist,
+		Album:    song.Album,
+		Duration: song.Duration,
+	}
+}
+
+// randomToken returns a random hex string of n bytes
+func randomToken(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand failing is unrecoverable in practice, fall back to the clock
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// localIP returns the machine's LAN address for the remote URL
+func localIP() string {
+	conn, err := net.Dial("udp", "192.0.2.1:80") // No packets are sent, this only picks the outbound interface
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// remotePort returns the configured web remote port
+func (a *App) remotePort() int {
+	if a.settings.WebRemotePort > 0 {
+		return a.settings.WebRemotePort
+	}
+	return defaultRemotePort
+}
+
+// StartWebRemote starts the web remote server on the local network
+func (a *App) StartWebRemote() (RemoteInfo, error) {
+	a.remoteMutex.Lock()
+	defer a.remoteMutex.Unlock()
+
+	if a.remoteServer != nil {
+		return a.remoteInfoLocked(), nil
+	}
+
+	// The host token is created once and kept so paired devices stay paired
+	if a.settings.WebRemoteToken == "" {
+		a.settings.WebRemoteToken = randomToken(16)
+		if err := a.saveSettings(); err != nil {
+			return RemoteInfo{}, err
+		}
+	}
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(a.remotePort()))
+	if err != nil {
+		return RemoteInfo{}, fmt.Errorf("failed to start web remote: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", a.serveRemotePage)
+	mux.HandleFunc("/api/now-playing", a.requireRemoteReader(a.handleRemoteNowPlaying))
+	mux.HandleFunc("/api/queue", a.requireRemoteReader(a.handleRemoteQueue))
+	mux.HandleFunc("/api/control", a.requireRemoteRole(remoteRoleFull, a.handleRemoteControl))
+	mux.HandleFunc("/api/pair", a.handleRemotePair)
+	mux.HandleFunc("/api/search", a.requirePartyMode(a.handlePartySearch))
+	mux.HandleFunc("/api/requests", a.requirePartyMode(a.handlePartyRequests))
+	mux.HandleFunc("/api/requests/vote", a.requirePartyMode(a.handlePartyVote))
+	mux.HandleFunc("/api/uploads", a.requirePartyMode(a.handlePartyUpload))
+
+	a.remoteServer = &http.Server{Handler: mux}
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logError("Web remote error: %v", err)
+		}
+	}(a.remoteServer)
+	a.announceRemoteLocked()
+
+	info := a.remoteInfoLocked()
+	logInfo("Web remote running at %s", info.URL)
+	return info, nil
+}
+
+// StopWebRemote shuts the web remote server down
+func (a *App) StopWebRemote() error {
+	a.remoteMutex.Lock()
+	server := a.remoteServer
+	if server == nil {
+		a.remoteMutex.Unlock()
+		return nil
+	}
+	a.unannounceRemoteLocked()
+	a.remoteServer = nil
+	a.remoteMutex.Unlock()
+
+	// Shut down without the lock, requests being finished may need it to check their token
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to stop web remote: %v", err)
+	}
+	logInfo("Web remote stopped")
+	return nil
+}
+
+// GetWebRemoteInfo returns the web remote status and address
+func (a *App) GetWebRemoteInfo() RemoteInfo {
+	a.remoteMutex.Lock()
+	defer a.remoteMutex.Unlock()
+	return a.remoteInfoLocked()
+}
+
+// remoteInfoLocked builds the remote info; remoteMutex must be held
+func (a *App) remoteInfoLocked() RemoteInfo {
+	return RemoteInfo{
+		Running:   a.remoteServer != nil,
+		URL:       fmt.Sprintf("http://%s:%d/", localIP(), a.remotePort()),
+		Token:     a.settings.WebRemoteToken,
+		PartyMode: a.settings.PartyMode,
+	}
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes an error as {"error": "..."}
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// remoteToken extracts the token from the Authorization header or the token query parameter
+func remoteToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
+
+// requirePartyMode rejects guest endpoints unless party mode is on
+func (a *App) requirePartyMode(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.settings.PartyMode {
+			writeJSONError(w, http.StatusForbidden, "party mode is off")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleRemoteNowPlaying returns the current song and playback state
+func (a *App) handleRemoteNowPlaying(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{"isPlaying": a.isPlaying, "role": a.remoteRole(r)}
+	if song := a.currentSong; song != nil {
+		response["song"] = toRemoteSong(*song)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleRemoteQueue returns the upcoming songs, POST adds one for queue tokens
+func (a *App) handleRemoteQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		a.requireRemoteRole(remoteRoleQueue, a.handleRemoteEnqueue)(w, r)
+		return
+	}
+	state := a.GetQueue()
+	songs := make([]RemoteSong, 0, len(state.Songs))
+	for _, song := range state.Songs {
+		songs = append(songs, toRemoteSong(song))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"songs": songs, "index": state.Index})
+}
+
+// handleRemoteControl forwards a playback command to the player
+func (a *App) handleRemoteControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	var body struct {
+		Action string `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !remoteCommands[body.Action] {
+		writeJSONError(w, http.StatusBadRequest, "unknown action")
+		return
+	}
+
+	a.emitEvent(eventRemoteCommand, body.Action)
+	writeJSON(w, http.StatusOK, map[string]string{"action": body.Action})
+}
+
+// serveRemotePage serves the single-page web remote
+func (a *App) serveRemotePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(remotePageHTML))
+}
+
+// remotePageHTML is the web remote UI. Guests see the party request tools when party mode is on;
+// the page is opened with ?token=... or ?pair=... from a pairing QR code, full role tokens get
+// playback controls.
+const remotePageHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Static Remote</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #1b2636; color: #eee; margin: 0; padding: 16px; max-width: 640px; margin: auto; }
+h1 { font-size: 1.2em; } h2 { font-size: 1em; margin-top: 24px; color: #9ab; }
+button { background: #3b82f6; color: #fff; border: 0; border-radius: 6px; padding: 8px 12px; margin: 2px; }
+input { width: 100%; box-sizing: border-box; padding: 8px; border-radius: 6px; border: 0; margin-bottom: 8px; }
+.song { display: flex; justify-content: space-between; align-items: center; padding: 6px 0; border-bottom: 1px solid #2c3a4f; }
+.meta { color: #9ab; font-size: 0.85em; } .hidden { display: none; }
+</style>
+</head>
+<body>
+<h1 id="now">Nothing playing</h1>
+<div id="controls" class="hidden">
+<button onclick="control('previous')">Prev</button><button onclick="control('toggle')">Play/Pause</button><button onclick="control('next')">Next</button>
+</div>
+<div id="party" class="hidden">
+<h2>Request a song</h2>
+<input id="name" placeholder="Your name">
+<input id="search" placeholder="Search the library" oninput="search()">
+<div id="results"></div>
+<h2>Requests</h2>
+<div id="requests"></div>
+<h2>Send the host a song</h2>
+<input id="upload" type="file" accept=".mp3,.flac,.ogg,.m4a,.wav">
+<button onclick="upload()">Upload</button>
+</div>
+<script>
+const pairCode = new URLSearchParams(location.search).get('pair');
+if (pairCode) fetch('/api/pair', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify({ code: pairCode }) })
+  .then(r => r.json()).then(r => { if (r.token) location.replace('/?token=' + encodeURIComponent(r.token)); });
+const token = new URLSearchParams(location.search).get('token') || '';
+const headers = token ? { 'Authorization': 'Bearer ' + token } : {};
+const api = (path, opts = {}) => fetch(path, { ...opts, headers: { ...headers, 'Content-Type': 'application/json' } }).then(r => r.json());
+const esc = s => String(s).replace(/[&<>"]/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;' }[c]));
+function control(action) { api('/api/control', { method: 'POST', body: JSON.stringify({ action }) }); }
+async function refresh() {
+  const now = await api('/api/now-playing');
+  document.getElementById('now').textContent = now.song ? now.song.title + ' — ' + now.song.artist : 'Nothing playing';
+  document.getElementById('controls').classList.toggle('hidden', now.role !== 'full');
+  const res = await fetch('/api/requests');
+  if (!res.ok) return;
+  document.getElementById('party').classList.remove('hidden');
+  const requests = await res.json();
+  document.getElementById('requests').innerHTML = requests.map(q =>
+    '<div class="song"><div>' + esc(q.title) + '<div class="meta">' + esc(q.artist) + ' · requested by ' + esc(q.guestName) + '</div></div>' +
+    '<button onclick="vote(' + q.id + ')">▲ ' + q.votes + '</button></div>').join('');
+}
+let timer;
+function search() {
+  clearTimeout(timer);
+  timer = setTimeout(async () => {
+    const q = document.getElementById('search').value;
+    const songs = q ? await api('/api/search?q=' + encodeURIComponent(q)) : [];
+    document.getElementById('results').innerHTML = songs.map(s =>
+      '<div class="song"><div>' + esc(s.title) + '<div class="meta">' + esc(s.artist) + '</div></div>' +
+      (s.trackId ? '<button data-track="' + esc(s.trackId) + '" onclick="request(this.dataset.track)">Request</button>' : '') + '</div>').join('');
+  }, 250);
+}
+async function request(trackId) {
+  const result = await api('/api/requests', { method: 'POST', body: JSON.stringify({ trackId, guestName: document.getElementById('name').value }) });
+  if (result.error) alert(result.error);
+  refresh();
+}
+async function upload() {
+  const input = document.getElementById('upload');
+  if (!input.files.length) return;
+  const form = new FormData();
+  form.append('file', input.files[0]);
+  form.append('guestName', document.getElementById('name').value);
+  const result = await fetch('/api/uploads', { method: 'POST', body: form }).then(r => r.json());
+  alert(result.error || 'Sent! The host will review your song.');
+  input.value = '';
+}
+async function vote(id) { await api('/api/requests/vote', { method: 'POST', body: JSON.stringify({ id }) }); refresh(); }
+refresh(); setInterval(refresh, 5000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+	"github.com/skip2/go-qrcode"
+)
+
+// remoteServiceType is the mDNS service the web remote is announced as
+const remoteServiceType = "_static._tcp"
+
+// remotePairingTTL is how long a pairing code can be exchanged for a device token
+const remotePairingTTL = 5 * time.Minute
+
+// remoteQRSize is the width and height of pairing QR codes in pixels
+const remoteQRSize = 256
+
+// remotePairing holds the mDNS announcement, guarded by remoteMutex, and the pending
+// pairing code
+type remotePairing struct {
+	announcer *zeroconf.Server
+	code      string
+	role      string // Role of the token the code is traded for
+	expires   time.Time
+	mutex     sync.Mutex // Guards code, role and expires, pair requests don't wait on remoteMutex
+}
+
+// RemotePairing is what a mobile client needs to pair: the URL in the QR code opens the
+// web remote, which trades the one-time code for a token of its own
+type RemotePairing struct {
+	URL       string    `json:"url"`
+	QRCode    string    `json:"qrCode"` // PNG data URL
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// remoteInstanceName is the name the web remote is announced under, e.g. "Static on desk"
+func remoteInstanceName() string {
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return "Static on " + host
+	}
+	return "Static"
+}
+
+// announceRemoteLocked advertises the web remote over mDNS so clients find it without
+// typing an address. Failing is logged, the remote still works by URL. remoteMutex must
+// be held.
+func (a *App) announceRemoteLocked() {
+	if a.pairing.announcer != nil {
+		return
+	}
+	text := []string{"path=/", "pair=/api/pair", "version=1"}
+	announcer, err := zeroconf.Register(remoteInstanceName(), remoteServiceType, "local.", a.remotePort(), text, nil)
+	if err != nil {
+		logWarn("Could not announce web remote over mDNS: %v", err)
+		return
+	}
+	a.pairing.announcer = announcer
+	logInfo("Web remote announced as %s", remoteServiceType)
+}
+
+// unannounceRemoteLocked withdraws the mDNS announcement and any pending pairing code,
+// remoteMutex must be held
+func (a *App) unannounceRemoteLocked() {
+	if a.pairing.announcer != nil {
+		a.pairing.announcer.Shutdown()
+		a.pairing.announcer = nil
+	}
+	a.pairing.mutex.Lock()
+	a.pairing.code = ""
+	a.pairing.mutex.Unlock()
+}
+
+// issuePairing creates a one-time pairing code for the running web remote and returns
+// the URL that redeems it, as a PNG QR code too. A new code replaces the previous one.
+func (a *App) issuePairing(role string) (string, []byte, time.Time, error) {
+	if remoteRoleRank[role] == 0 {
+		return "", nil, time.Time{}, fmt.Errorf("invalid role: %s", role)
+	}
+
+	a.remoteMutex.Lock()
+	defer a.remoteMutex.Unlock()
+
+	if a.remoteServer == nil {
+		return "", nil, time.Time{}, fmt.Errorf("web remote is not running")
+	}
+	code := randomToken(16)
+	expires := time.Now().Add(remotePairingTTL)
+	a.pairing.mutex.Lock()
+	a.pairing.code, a.pairing.role, a.pairing.expires = code, role, expires
+	a.pairing.mutex.Unlock()
+
+	pairURL := fmt.Sprintf("http://%s:%d/?pair=%s", localIP(), a.remotePort(), url.QueryEscape(code))
+	png, err := qrcode.Encode(pairURL, qrcode.Medium, remoteQRSize)
+	if err != nil {
+		return "", nil, time.Time{}, fmt.Errorf("error creating QR code: %v", err)
+	}
+	return pairURL, png, expires, nil
+}
+
+// StartRemotePairing issues a pairing code for a device token with the given role ("read",
+// "queue" or "full") and returns its URL, QR code and expiry
+func (a *App) StartRemotePairing(role string) (RemotePairing, error) {
+	pairURL, png, expires, err := a.issuePairing(role)
+	if err != nil {
+		return RemotePairing{}, err
+	}
+	return RemotePairing{
+		URL:       pairURL,
+		QRCode:    "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		ExpiresAt: expires,
+	}, nil
+}
+
+// GeneratePairingQR issues a pairing code and returns only its QR code as PNG bytes, for
+// UIs that render the image themselves
+func (a *App) GeneratePairingQR(role string) ([]byte, error) {
+	_, png, _, err := a.issuePairing(role)
+	return png, err
+}
+
+// handleRemotePair trades a pairing code for a new token with the code's role, which is
+// listed and revoked like the other extra tokens. Codes work once.
+func (a *App) handleRemotePair(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Code == "" {
+		writeJSONError(w, http.StatusBadRequest, "pairing code required")
+		return
+	}
+
+	a.pairing.mutex.Lock()
+	code, role, expires := a.pairing.code, a.pairing.role, a.pairing.expires
+	valid := code != "" && time.Now().Before(expires) && subtle.ConstantTimeCompare([]byte(body.Code), []byte(code)) == 1
+	if valid {
+		a.pairing.code = ""
+	}
+	a.pairing.mutex.Unlock()
+
+	if !valid {
+		writeJSONError(w, http.StatusUnauthorized, "invalid or expired pairing code")
+		return
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	access, err := a.CreateRemoteToken("Paired device at "+host, role)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "pairing failed")
+		return
+	}
+	logInfo("Web remote paired with %s", r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]string{"token": access.Token, "role": access.Role})
+}
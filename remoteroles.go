@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Web remote roles, each allowed everything the ones before it are
+const (
+	remoteRoleRead  = "read"  // Now playing and the queue
+	remoteRoleQueue = "queue" // Adding songs to the queue
+	remoteRoleFull  = "full"  // Playback control, what the host token has
+)
+
+// remoteRoleRank orders the roles for requireRemoteRole
+var remoteRoleRank = map[string]int{remoteRoleRead: 1, remoteRoleQueue: 2, remoteRoleFull: 3}
+
+// maxRemoteTokenName keeps token names to labels
+const maxRemoteTokenName = 64
+
+// RemoteAccessToken is an extra web remote token with a limited role, e.g. for a party
+// guest who may queue songs
+type RemoteAccessToken struct {
+	Token     string    `json:"token"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"` // "read", "queue" or "full"
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// remoteRole returns the role of a request's token, "" for requests without a valid one
+func (a *App) remoteRole(r *http.Request) string {
+	token := remoteToken(r)
+	if token == "" {
+		return ""
+	}
+
+	// Tokens are created and revoked while requests come in
+	a.remoteMutex.Lock()
+	defer a.remoteMutex.Unlock()
+	if subtle.ConstantTimeCompare([]byte(token), []byte(a.settings.WebRemoteToken)) == 1 {
+		return remoteRoleFull
+	}
+	for _, access := range a.settings.WebRemoteTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(access.Token)) == 1 {
+			return access.Role
+		}
+	}
+	return ""
+}
+
+// requireRemoteRole only lets requests through whose token has at least the given role
+func (a *App) requireRemoteRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		granted := a.remoteRole(r)
+		if granted == "" {
+			writeJSONError(w, http.StatusUnauthorized, "token required")
+			return
+		}
+		if remoteRoleRank[granted] < remoteRoleRank[role] {
+			writeJSONError(w, http.StatusForbidden, "token not allowed to do this")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireRemoteReader lets party guests read without a token, everyone else needs at
+// least a read-only one
+func (a *App) requireRemoteReader(next http.HandlerFunc) http.HandlerFunc {
+	withRole := a.requireRemoteRole(remoteRoleRead, next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.settings.PartyMode && remoteToken(r) == "" {
+			next(w, r)
+			return
+		}
+		withRole(w, r)
+	}
+}
+
+// handleRemoteEnqueue adds a library song to the end of the queue
+func (a *App) handleRemoteEnqueue(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		TrackID string `json:"trackId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !isTrackID(body.TrackID) {
+		writeJSONError(w, http.StatusBadRequest, "trackId required")
+		return
+	}
+	// Only songs from the library can be queued
+	song, found := a.findLibraryTrack(body.TrackID)
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "song not in library")
+		return
+	}
+	result := a.EnqueueAll([]Song{song})
+	if result.Added == 0 {
+		writeJSONError(w, http.StatusConflict, "song already in queue")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"song": toRemoteSong(song), "length": len(result.Queue.Songs)})
+}
+
+// CreateRemoteToken issues a web remote token with a role: "read", "queue" or "full"
+func (a *App) CreateRemoteToken(name string, role string) (RemoteAccessToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return RemoteAccessToken{}, fmt.Errorf("token name can't be empty")
+	}
+	if len([]rune(name)) > maxRemoteTokenName {
+		return RemoteAccessToken{}, fmt.Errorf("token names are limited to %d characters", maxRemoteTokenName)
+	}
+	if remoteRoleRank[role] == 0 {
+		return RemoteAccessToken{}, fmt.Errorf("invalid role: %s", role)
+	}
+
+	a.remoteMutex.Lock()
+	defer a.remoteMutex.Unlock()
+	access := RemoteAccessToken{Token: randomToken(16), Name: name, Role: role, CreatedAt: time.Now()}
+	a.settings.WebRemoteTokens = append(a.settings.WebRemoteTokens, access)
+	if err := a.saveSettings(); err != nil {
+		return RemoteAccessToken{}, err
+	}
+	logInfo("Created %s web remote token %q", role, name)
+	return access, nil
+}
+
+// GetRemoteTokens lists the extra web remote tokens
+func (a *App) GetRemoteTokens() []RemoteAccessToken {
+	a.remoteMutex.Lock()
+	defer a.remoteMutex.Unlock()
+	return append([]RemoteAccessToken{}, a.settings.WebRemoteTokens...)
+}
+
+// RevokeRemoteToken removes an extra web remote token, its holder loses access right away
+func (a *App) RevokeRemoteToken(token string) error {
+	a.remoteMutex.Lock()
+	defer a.remoteMutex.Unlock()
+
+	kept := make([]RemoteAccessToken, 0, len(a.settings.WebRemoteTokens))
+	for _, access := range a.settings.WebRemoteTokens {
+		if access.Token != token {
+			kept = append(kept, access)
+		}
+	}
+	if len(kept) == len(a.settings.WebRemoteTokens) {
+		return fmt.Errorf("token not found")
+	}
+	a.settings.WebRemoteTokens = kept
+	return a.saveSettings()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dhowden/tag"
+)
+
+// Gain modes smart gain resolves a song to
+const (
+	gainModeOff   = "off"   // No ReplayGain data or smart gain disabled
+	gainModeTrack = "track" // Level each song on its own
+	gainModeAlbum = "album" // Keep an album's dynamics between its songs
+)
+
+// ReplayGainInfo is a song's ReplayGain data. Gains are in dB, peaks linear with 1.0
+// at full scale.
+type ReplayGainInfo struct {
+	TrackGain *float64 `json:"trackGain,omitempty"`
+	TrackPeak float64  `json:"trackPeak,omitempty"`
+	AlbumGain *float64 `json:"albumGain,omitempty"`
+	AlbumPeak float64  `json:"albumPeak,omitempty"`
+}
+
+// PlaybackGain is the volume adjustment smart gain picked for a song in the queue
+type PlaybackGain struct {
+	Mode   string  `json:"mode"` // "off", "track" or "album"
+	GainDB float64 `json:"gainDb"`
+	Factor float64 `json:"factor"` // Multiply the playback volume by this, lowered so peaks don't clip
+}
+
+// replayGainValue finds a ReplayGain value in ID3 TXXX frames, Vorbis comments or MP4
+// custom atoms
+func replayGainValue(raw map[string]interface{}, name string) (float64, bool) {
+	for key, value := range raw {
+		text := ""
+		if comm, ok := value.(*tag.Comm); ok && strings.EqualFold(comm.Description, name) {
+			text = comm.Text
+		} else if strings.EqualFold(key, name) {
+			text = fmt.Sprint(value)
+		} else {
+			continue
+		}
+		// Gains are written as "-6.52 dB"
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.ToLower(text)), "db"))
+		if number, err := strconv.ParseFloat(text, 64); err == nil && !math.IsNaN(number) && !math.IsInf(number, 0) {
+			return number, true
+		}
+	}
+	return 0, false
+}
+
+// replayGainFromTags reads a song's ReplayGain tags, nil when it has none
+func replayGainFromTags(metadata tag.Metadata) *ReplayGainInfo {
+	if metadata == nil {
+		return nil
+	}
+	raw := metadata.Raw()
+	info := &ReplayGainInfo{}
+	if gain, ok := replayGainValue(raw, "replaygain_track_gain"); ok {
+		info.TrackGain = &gain
+	}
+	if gain, ok := replayGainValue(raw, "replaygain_album_gain"); ok {
+		info.AlbumGain = &gain
+	}
+	if info.TrackGain == nil && info.AlbumGain == nil {
+		return nil
+	}
+	info.TrackPeak, _ = replayGainValue(raw, "replaygain_track_peak")
+	info.AlbumPeak, _ = replayGainValue(raw, "replaygain_album_peak")
+	return info
+}
+
+// inAlbumRun reports whether the queued song next to index on either side is from the
+// same album, so the album plays as a run even in a shuffled queue
+func inAlbumRun(queue []Song, index int) bool {
+	song := queue[index]
+	if song.Album == "" || song.Album == "Unknown Album" {
+		return false
+	}
+	for _, neighbor := range []int{index - 1, index + 1} {
+		if neighbor < 0 || neighbor >= len(queue) {
+			continue
+		}
+		other := queue[neighbor]
+		if strings.EqualFold(other.Album, song.Album) && pathKey(filepath.Dir(other.FilePath)) == pathKey(filepath.Dir(song.FilePath)) {
+			return true
+		}
+	}
+	return false
+}
+
+// smartGain picks album gain for songs in an album run and track gain otherwise, falling
+// back to whichever the song has
+func smartGain(queue []Song, index int, enabled bool) PlaybackGain {
+	off := PlaybackGain{Mode: gainModeOff, Factor: 1}
+	if !enabled || index < 0 || index >= len(queue) || queue[index].ReplayGain == nil {
+		return off
+	}
+	info := queue[index].ReplayGain
+
+	gain := PlaybackGain{Mode: gainModeTrack}
+	peak := info.TrackPeak
+	switch {
+	case info.AlbumGain != nil && (info.TrackGain == nil || inAlbumRun(queue, index)):
+		gain.Mode, gain.GainDB, peak = gainModeAlbum, *info.AlbumGain, info.AlbumPeak
+	case info.TrackGain != nil:
+		gain.GainDB = *info.TrackGain
+	default:
+		return off
+	}
+	gain.Factor = math.Pow(10, gain.GainDB/20)
+	if peak > 0 && gain.Factor*peak > 1 {
+		gain.Factor = 1 / peak
+	}
+	return gain
+}
+
+// GetPlaybackGain returns the gain to play a song with. Queued songs get the gain for
+// their place in the queue, other songs are leveled on their own.
+func (a *App) GetPlaybackGain(filePath string) (PlaybackGain, error) {
+	filePath, err := a.resolveTrackRef(filePath)
+	if err != nil {
+		return PlaybackGain{}, err
+	}
+
+	a.queueMutex.Lock()
+	index := -1
+	if a.queueIndex >= 0 && a.queueIndex < len(a.queue) && pathKey(a.queue[a.queueIndex].FilePath) == pathKey(filePath) {
+		index = a.queueIndex
+	} else {
+		for i, song := range a.queue {
+			if pathKey(song.FilePath) == pathKey(filePath) {
+				index = i
+				break
+			}
+		}
+	}
+	if index >= 0 {
+		gain := smartGain(a.queue, index, a.settings.SmartGain)
+		a.queueMutex.Unlock()
+		return gain, nil
+	}
+	a.queueMutex.Unlock()
+
+	song, err := a.songMetadata(filePath, nil)
+	if err != nil {
+		return PlaybackGain{}, fmt.Errorf("error reading metadata: %v", err)
+	}
+	return smartGain([]Song{song}, 0, a.settings.SmartGain), nil
+}
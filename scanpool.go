@@ -0,0 +1,72 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// metadataResult is the outcome of reading one song's metadata
+type metadataResult struct {
+	song Song
+	err  error
+}
+
+// scanWorkers returns how many files are read in parallel during a scan. Automatic
+// parallelism uses every core, or a few workers on spinning disks and network shares.
+func (a *App) scanWorkers(files int) int {
+	workers := a.settings.ScanParallelism
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+		if storage := a.staticFolderStorage(); storage == "hdd" || storage == "network" {
+			workers = slowStorageScanWorkers
+		}
+	}
+	if workers > files {
+		workers = files
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+// songMetadataBatch reads the metadata of many files on a bounded worker pool. Results
+// are in the same order as paths, extracted songs are added to fresh.
+func (a *App) songMetadataBatch(paths []string, fresh map[string]indexEntry) []metadataResult {
+	results := make([]metadataResult, len(paths))
+	workers := a.scanWorkers(len(paths))
+
+	// Each worker collects its extracted songs separately, they're merged once all are done
+	freshByWorker := make([]map[string]indexEntry, workers)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		freshByWorker[w] = make(map[string]indexEntry)
+		wg.Add(1)
+		go func(local map[string]indexEntry) {
+			defer wg.Done()
+			a.startScanWorker()
+			for i := range jobs {
+				if a.scanCancelled() {
+					results[i] = metadataResult{err: errScanCancelled}
+					continue
+				}
+				song, err := a.songMetadata(paths[i], local)
+				results[i] = metadataResult{song: song, err: err}
+				a.scanFileDone()
+			}
+		}(freshByWorker[w])
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, local := range freshByWorker {
+		for filePath, entry := range local {
+			fresh[filePath] = entry
+		}
+	}
+	return results
+}
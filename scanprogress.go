@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// Scan progress events emitted to the frontend
+const (
+	eventScanProgress = "library:scan-progress"
+	eventScanDone     = "library:scan-done"
+)
+
+// scanProgressInterval limits how often per-file progress is emitted
+const scanProgressInterval = 100 * time.Millisecond
+
+// errScanCancelled is returned by scans stopped with CancelScan
+var errScanCancelled = errors.New("scan cancelled")
+
+// ScanProgress describes a running library scan
+type ScanProgress struct {
+	Folder         string `json:"folder"` // Playlist folder being read
+	FilesProcessed int    `json:"filesProcessed"`
+	FilesTotal     int    `json:"filesTotal"` // Audio files found so far, grows as each folder is listed
+	PlaylistsDone  int    `json:"playlistsDone"`
+	PlaylistsTotal int    `json:"playlistsTotal"`
+	Cancelled      bool   `json:"cancelled,omitempty"`
+}
+
+// setScanPlaylists records how many playlist folders the running scan will read
+func (a *App) setScanPlaylists(staticPath string) {
+	entries, err := os.ReadDir(staticPath)
+	if err != nil {
+		return
+	}
+	total := 0
+	for _, entry := range entries {
+		if entry.IsDir() && !a.isIgnoredScanEntry(entry.Name()) {
+			total++
+		}
+	}
+
+	a.scanStats.mutex.Lock()
+	a.scanStats.progress.PlaylistsTotal = total
+	progress := a.scanStats.progress
+	a.scanStats.mutex.Unlock()
+	a.emitEvent(eventScanProgress, progress)
+}
+
+// scanFolderStarted reports that a playlist folder was listed and its files are being read
+func (a *App) scanFolderStarted(folder string, files int) {
+	a.scanStats.mutex.Lock()
+	if a.scanStats.running == 0 {
+		a.scanStats.mutex.Unlock()
+		return // Single playlist load outside a full scan
+	}
+	a.scanStats.progress.Folder = folder
+	a.scanStats.progress.FilesTotal += files
+	a.scanStats.lastProgress = time.Now()
+	progress := a.scanStats.progress
+	a.scanStats.mutex.Unlock()
+	a.emitEvent(eventScanProgress, progress)
+}
+
+// scanFileDone counts one file read by the running scan, emitting progress at most
+// every scanProgressInterval
+func (a *App) scanFileDone() {
+	a.scanStats.mutex.Lock()
+	if a.scanStats.running == 0 {
+		a.scanStats.mutex.Unlock()
+		return
+	}
+	a.scanStats.progress.FilesProcessed++
+	now := time.Now()
+	if now.Sub(a.scanStats.lastProgress) < scanProgressInterval {
+		a.scanStats.mutex.Unlock()
+		return
+	}
+	a.scanStats.lastProgress = now
+	progress := a.scanStats.progress
+	a.scanStats.mutex.Unlock()
+	a.emitEvent(eventScanProgress, progress)
+}
+
+// scanCancelled reports whether CancelScan stopped the running scan
+func (a *App) scanCancelled() bool {
+	a.scanStats.mutex.Lock()
+	defer a.scanStats.mutex.Unlock()
+	return a.scanStats.running > 0 && a.scanStats.cancelled
+}
+
+// CancelScan stops the running library scan. GetPlaylists returns an error instead of a
+// partial library, and songs read so far stay in the index for the next scan. Returns
+// false when no scan was running.
+func (a *App) CancelScan() bool {
+	a.scanStats.mutex.Lock()
+	defer a.scanStats.mutex.Unlock()
+	if a.scanStats.running == 0 {
+		return false
+	}
+	a.scanStats.cancelled = true
+	return true
+}
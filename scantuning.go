@@ -0,0 +1,81 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// slowStorageScanWorkers is the default parallelism for libraries on spinning disks and
+// network shares, where parallel reads mostly add seeks
+const slowStorageScanWorkers = 2
+
+// ScanTuning reports the scan settings in effect, with autodetected values filled in
+type ScanTuning struct {
+	Workers        int    `json:"workers"`
+	SlowStorage    bool   `json:"slowStorage"`    // Library on a spinning disk or network share
+	Storage        string `json:"storage"`        // What was detected: "ssd", "hdd", "network" or "unknown"
+	FilesPerSecond int    `json:"filesPerSecond"` // Files read from disk per second, 0 for no limit
+	LowPriority    bool   `json:"lowPriority"`    // Scan threads run at low CPU and IO priority
+}
+
+// scanThrottle spaces out file reads during scans to a rate
+type scanThrottle struct {
+	next  time.Time
+	mutex sync.Mutex
+}
+
+// wait blocks until the next read is allowed at perSecond reads a second, shared by all
+// workers of a scan
+func (t *scanThrottle) wait(perSecond int) {
+	if perSecond <= 0 {
+		return
+	}
+	t.mutex.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	slot := t.next
+	t.next = t.next.Add(time.Second / time.Duration(perSecond))
+	t.mutex.Unlock()
+	time.Sleep(slot.Sub(now))
+}
+
+// storageKinds caches libraryStorage by static folder, the storage doesn't change while
+// the app runs
+var storageKinds sync.Map
+
+// staticFolderStorage returns the kind of storage the static folder is on
+func (a *App) staticFolderStorage() string {
+	staticPath := a.GetStaticFolderPath()
+	if kind, ok := storageKinds.Load(staticPath); ok {
+		return kind.(string)
+	}
+	kind := libraryStorage(staticPath)
+	storageKinds.Store(staticPath, kind)
+	return kind
+}
+
+// GetScanTuning returns the scan settings in effect, for showing what "auto" picked
+func (a *App) GetScanTuning() ScanTuning {
+	storage := a.staticFolderStorage()
+	return ScanTuning{
+		Workers:        a.scanWorkers(runtime.NumCPU() * 4),
+		SlowStorage:    storage == "hdd" || storage == "network",
+		Storage:        storage,
+		FilesPerSecond: a.settings.ScanFilesPerSecond,
+		LowPriority:    a.settings.ScanLowPriority,
+	}
+}
+
+// startScanWorker prepares the calling goroutine to read files for a scan. With
+// ScanLowPriority it gets an OS thread of its own at low priority. The thread is never
+// unlocked, so it exits with the goroutine instead of carrying the priority elsewhere.
+func (a *App) startScanWorker() {
+	if !a.settings.ScanLowPriority {
+		return
+	}
+	runtime.LockOSThread()
+	lowerThreadPriority()
+}
@@ -0,0 +1,75 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Linux file system magic numbers of network and FUSE mounts (statfs(2))
+var networkFileSystems = map[uint32]bool{
+	0x6969:     true, // NFS
+	0xFF534D42: true, // CIFS
+	0xFE534D42: true, // SMB2
+	0x517B:     true, // SMB
+	0x65735546: true, // FUSE, sshfs and most remote mounts
+}
+
+// I/O priority of scan threads: best effort class, lowest level (ionice -c2 -n7)
+const (
+	ioprioWhoProcess = 1
+	ioprioClassBE    = 2
+	ioprioClassShift = 13
+	scanIOPriority   = 7
+	scanNiceness     = 10
+)
+
+// lowerThreadPriority renices the calling thread and lowers its I/O priority, like
+// nice and ionice do for a process
+func lowerThreadPriority() {
+	tid := syscall.Gettid()
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, scanNiceness); err != nil {
+		logWarn("Scan: could not lower CPU priority: %v", err)
+	}
+	prio := ioprioClassBE<<ioprioClassShift | scanIOPriority
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio)); errno != 0 {
+		logWarn("Scan: could not lower IO priority: %v", errno)
+	}
+}
+
+// libraryStorage detects whether a folder is on a network share ("network"), a spinning
+// disk ("hdd") or a solid state drive ("ssd") from statfs and sysfs
+func libraryStorage(path string) string {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return "unknown"
+	}
+	if networkFileSystems[uint32(fs.Type)] {
+		return "network"
+	}
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return "unknown"
+	}
+	major := (st.Dev >> 8) & 0xfff
+	minor := (st.Dev & 0xff) | ((st.Dev >> 12) & 0xfff00)
+	device, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/dev/block/%d:%d", major, minor))
+	if err != nil {
+		return "unknown"
+	}
+	// Partitions have no queue of their own, the disk they're on does
+	for _, dir := range []string{device, filepath.Dir(device)} {
+		if data, err := os.ReadFile(filepath.Join(dir, "queue", "rotational")); err == nil {
+			if strings.TrimSpace(string(data)) == "1" {
+				return "hdd"
+			}
+			return "ssd"
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+// lowerThreadPriority is only implemented on Linux
+func lowerThreadPriority() {}
+
+// libraryStorage can't tell storage kinds apart outside Linux, scans use every core
+func libraryStorage(path string) string {
+	return "unknown"
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// scrobblerLogHeader starts an Audioscrobbler 1.1 log as written by Rockbox. Timestamps
+// are UTC Unix times.
+const scrobblerLogHeader = "#AUDIOSCROBBLER/1.1\n#TZ/UTC\n#CLIENT/Static 1.0\n"
+
+// Ratings of a scrobbler log line
+const (
+	scrobbleListened = "L" // Played for at least half its length or four minutes
+	scrobbleSkipped  = "S" // Uploaders leave skipped plays out
+)
+
+// scrobbleMinListen is how long a play counts as listened, whatever the song's length
+const scrobbleMinListen = 240
+
+// scrobblerLogPath returns where plays are logged continuously: the configured file, or
+// .scrobbler.log in the config dir
+func scrobblerLogPath(settings *Settings) string {
+	if settings.ScrobblerLogPath != "" {
+		return settings.ScrobblerLogPath
+	}
+	return filepath.Join(getConfigDir(), ".scrobbler.log")
+}
+
+// scrobbleField makes a value safe for the tab-separated log
+func scrobbleField(value string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(strings.TrimSpace(value))
+}
+
+// scrobbleLine formats one play: artist, album, title, track number, duration in
+// seconds, rating, timestamp and MusicBrainz track ID, which we don't keep
+func scrobbleLine(song Song, rating string, playedAt time.Time) string {
+	artist, album := song.Artist, song.Album
+	if artist == "Unknown Artist" {
+		artist = ""
+	}
+	if album == "Unknown Album" {
+		album = ""
+	}
+	track := ""
+	if song.TrackNumber > 0 {
+		track = strconv.Itoa(song.TrackNumber)
+	}
+	return strings.Join([]string{
+		scrobbleField(artist),
+		scrobbleField(album),
+		scrobbleField(song.Title),
+		track,
+		strconv.Itoa(song.DurationSec),
+		rating,
+		strconv.FormatInt(playedAt.Unix(), 10),
+		"",
+	}, "\t") + "\n"
+}
+
+// scrobbleRating rates a play that ended at positionSec
+func scrobbleRating(reason string, positionSec float64, durationSec int) string {
+	if reason == transitionFinished || positionSec >= scrobbleMinListen || (durationSec > 0 && positionSec >= float64(durationSec)/2) {
+		return scrobbleListened
+	}
+	return scrobbleSkipped
+}
+
+// appendScrobbles adds lines to a scrobbler log, writing the header first when it's new
+func appendScrobbles(path string, lines []string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening scrobbler log: %v", err)
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		lines = append([]string{scrobblerLogHeader}, lines...)
+	}
+	if _, err := file.WriteString(strings.Join(lines, "")); err != nil {
+		return fmt.Errorf("error writing scrobbler log: %v", err)
+	}
+	return nil
+}
+
+// logScrobble appends the song a transition left to the scrobbler log, when continuous
+// logging is enabled
+func (a *App) logScrobble(transition TrackTransition) {
+	if !a.settings.ScrobblerLog || transition.From == "" {
+		return
+	}
+	song, ok := a.findLibrarySong(transition.From)
+	if !ok {
+		var err error
+		if song, err = a.songMetadata(transition.From, nil); err != nil {
+			return
+		}
+	}
+
+	// Logged with the time the song started, like Rockbox does
+	playedAt := transition.At.Add(-time.Duration(transition.PositionSec * float64(time.Second)))
+	history := a.history.snapshot()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].FilePath == transition.From {
+			playedAt = history[i].PlayedAt
+			break
+		}
+	}
+
+	line := scrobbleLine(song, scrobbleRating(transition.Reason, transition.PositionSec, song.DurationSec), playedAt)
+	a.scrobblerLogMutex.Lock()
+	defer a.scrobblerLogMutex.Unlock()
+	if err := appendScrobbles(scrobblerLogPath(a.settings), []string{line}); err != nil {
+		logError("Failed to log scrobble: %v", err)
+	}
+}
+
+// ExportScrobblerLog writes the listening history since sinceUnix (0 for all of it) as a
+// Rockbox-style .scrobbler.log for offline scrobble uploaders and returns the file's
+// path. Plays followed by a skip are marked skipped. When path is empty a save dialog
+// is shown.
+func (a *App) ExportScrobblerLog(path string, sinceUnix int64) (string, error) {
+	path = normalizePath(path)
+
+	if path == "" {
+		if a.ctx == nil {
+			return "", fmt.Errorf("no file path given")
+		}
+		chosen, err := wailsruntime.SaveFileDialog(a.ctx, wailsruntime.SaveDialogOptions{
+			Title:           "Export Scrobbler Log",
+			DefaultFilename: ".scrobbler.log",
+			Filters: []wailsruntime.FileFilter{
+				{DisplayName: "Scrobbler Log (*.log)", Pattern: "*.log"},
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("error choosing export file: %v", err)
+		}
+		if chosen == "" {
+			return "", nil // Cancelled
+		}
+		path = chosen
+	}
+
+	// Track numbers aren't in the history
+	library := make(map[string]Song)
+	if songs, err := a.librarySongs(); err == nil {
+		for _, song := range songs {
+			library[song.FilePath] = song
+		}
+	}
+
+	history := a.history.snapshot()
+	lines := []string{scrobblerLogHeader}
+	for i, entry := range history {
+		if entry.PlayedAt.Unix() < sinceUnix {
+			continue
+		}
+		song, ok := library[entry.FilePath]
+		if !ok {
+			song = Song{FilePath: entry.FilePath, Title: entry.Title, Artist: entry.Artist, Album: entry.Album, DurationSec: entry.DurationSec}
+		}
+		rating := scrobbleListened
+		if i+1 < len(history) && history[i+1].Reason == transitionSkipped {
+			rating = scrobbleSkipped
+		}
+		lines = append(lines, scrobbleLine(song, rating, entry.PlayedAt))
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0644); err != nil {
+		return "", fmt.Errorf("error writing scrobbler log: %v", err)
+	}
+	logInfo("Exported %d plays to %s", len(lines)-1, path)
+	return path, nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// settingsFileVersion is the current format version of exported settings files
+const settingsFileVersion = 1
+
+// settingsFileExtension is used for exported settings files
+const settingsFileExtension = ".staticsettings"
+
+// Sections of a settings file
+const (
+	settingsSectionSettings      = "settings"
+	settingsSectionShortcuts     = "shortcuts"
+	settingsSectionEffectPresets = "effectPresets"
+	settingsSectionSavedSearches = "savedSearches"
+)
+
+// settingsFileSections are the sections this version can export and import. Effect
+// presets and saved searches are reserved, there's nothing to export for them yet.
+var settingsFileSections = []string{settingsSectionSettings, settingsSectionShortcuts}
+
+// shareableSettingKeys are the settings a settings file carries: appearance, playback
+// and library preferences. Anything else stays out, paths of this machine, credentials,
+// where tokens are sent, what's published about the user's listening and what's exposed
+// on the network, so importing a shared file can't redirect or open up the user's setup.
+var shareableSettingKeys = []string{
+	"theme", "volume", "showNotifications", "autoPlay", "shuffle", "repeat", "language", "accentColor",
+	"minimizeToTray", "startMinimized", "showLyrics", "mediaSessionPolicy", "excludeSkippedFromShuffle",
+	"scanHiddenFiles", "presenceDetailsTemplate", "presenceStateTemplate", "presenceLargeTextTemplate",
+	"slackStatusEmoji", "presenceSinkSchedule", "presenceSinkWeekdaysOnly", "shareTemplate", "shareIncludeCover",
+	"maxVolume", "volumeLimiter", "duckOnVoiceChat", "duckOnNotifications", "duckingAmount",
+	"autoMix", "autoMixTransitionSec", "taskbarProgress", "startupBehavior", "mediaJobCPUFraction",
+	"metadataCacheSize", "scanParallelism", "thumbnailCacheSize", "notifyDuringDND", "gameMode",
+	"audioCacheMaxMB", "discordTimeDisplay", "scanFilesPerSecond", "scanLowPriority", "writeRatingTags",
+	"smartGain", "fetchedCoverLocation", "skipQueuedDuplicates", "transcodeCodec", "transcodeBitrate",
+	"transcodeCache", "logLevel",
+}
+
+// SettingsFile is a shareable export of the app's setup, split into sections that can be
+// imported separately
+type SettingsFile struct {
+	Version   int                        `json:"version"`
+	CreatedAt time.Time                  `json:"createdAt"`
+	Sections  map[string]json.RawMessage `json:"sections"`
+}
+
+// ShortcutSettings is the shortcuts section. The bindings themselves are fixed.
+type ShortcutSettings struct {
+	Enabled bool `json:"enabled"`
+}
+
+// ImportSettingsResult reports which sections of a settings file were applied
+type ImportSettingsResult struct {
+	Imported []string `json:"imported"`
+	Skipped  []string `json:"skipped"` // In the file but not supported by this version
+}
+
+// settingsSections checks requested sections, nil or empty meaning all of them
+func settingsSections(sections []string) ([]string, error) {
+	if len(sections) == 0 {
+		return settingsFileSections, nil
+	}
+	for _, section := range sections {
+		supported := false
+		for _, name := range settingsFileSections {
+			supported = supported || name == section
+		}
+		if !supported {
+			return nil, fmt.Errorf("unsupported settings section: %s", section)
+		}
+	}
+	return sections, nil
+}
+
+// shareableSettings returns the settings as JSON fields for the settings section
+func shareableSettings(settings *Settings) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	keepShareableSettings(fields)
+	return fields, nil
+}
+
+// keepShareableSettings removes every field that isn't in shareableSettingKeys, the
+// shortcut toggle included, which has its own section
+func keepShareableSettings(fields map[string]json.RawMessage) {
+	shareable := make(map[string]bool, len(shareableSettingKeys))
+	for _, key := range shareableSettingKeys {
+		shareable[key] = true
+	}
+	for key := range fields {
+		if !shareable[key] {
+			delete(fields, key)
+		}
+	}
+}
+
+// ExportSettings writes the chosen sections ("settings", "shortcuts", none for all) to a
+// settings file picked with a save dialog and returns its path, "" when cancelled.
+// Only the settings in shareableSettingKeys are exported.
+func (a *App) ExportSettings(sections []string) (string, error) {
+	sections, err := settingsSections(sections)
+	if err != nil {
+		return "", err
+	}
+
+	file := SettingsFile{Version: settingsFileVersion, CreatedAt: time.Now(), Sections: make(map[string]json.RawMessage)}
+	for _, section := range sections {
+		var value interface{}
+		switch section {
+		case settingsSectionSettings:
+			if value, err = shareableSettings(a.settings); err != nil {
+				return "", fmt.Errorf("error exporting settings: %v", err)
+			}
+		case settingsSectionShortcuts:
+			value = ShortcutSettings{Enabled: a.settings.KeyboardShortcuts}
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("error exporting %s: %v", section, err)
+		}
+		file.Sections[section] = data
+	}
+
+	if a.ctx == nil {
+		return "", fmt.Errorf("no file path given")
+	}
+	path, err := wailsruntime.SaveFileDialog(a.ctx, wailsruntime.SaveDialogOptions{
+		Title:           "Export Settings",
+		DefaultFilename: "static" + settingsFileExtension,
+		Filters: []wailsruntime.FileFilter{
+			{DisplayName: "Static Settings (*" + settingsFileExtension + ")", Pattern: "*" + settingsFileExtension},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error choosing export file: %v", err)
+	}
+	if path == "" {
+		return "", nil // Cancelled
+	}
+	if err := writeJSONFile(path, file); err != nil {
+		return "", err
+	}
+
+	logInfo("Exported settings sections %v to %s", sections, path)
+	return path, nil
+}
+
+// ImportSettings applies the chosen sections of a settings file, none for every section
+// in it. Imported settings are validated like UpdateSettings does. Only the settings in
+// shareableSettingKeys are taken from the file, everything else keeps its current value.
+func (a *App) ImportSettings(path string, sections []string) (ImportSettingsResult, error) {
+	path = normalizePath(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ImportSettingsResult{}, fmt.Errorf("error reading settings file: %v", err)
+	}
+
+	var file SettingsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return ImportSettingsResult{}, fmt.Errorf("error parsing settings file: %v", err)
+	}
+	if file.Version > settingsFileVersion {
+		return ImportSettingsResult{}, fmt.Errorf("settings file version %d is newer than supported (%d)", file.Version, settingsFileVersion)
+	}
+
+	result := ImportSettingsResult{Imported: []string{}, Skipped: []string{}}
+	if len(sections) == 0 {
+		for section := range file.Sections {
+			sections = append(sections, section)
+		}
+		sort.Strings(sections)
+	}
+
+	updated := *a.settings
+	for _, section := range sections {
+		raw, ok := file.Sections[section]
+		if !ok {
+			return ImportSettingsResult{}, fmt.Errorf("settings file has no %s section", section)
+		}
+		switch section {
+		case settingsSectionSettings:
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				return ImportSettingsResult{}, fmt.Errorf("error parsing settings section: %v", err)
+			}
+			keepShareableSettings(fields)
+			filtered, _ := json.Marshal(fields)
+			if err := json.Unmarshal(filtered, &updated); err != nil {
+				return ImportSettingsResult{}, fmt.Errorf("error parsing settings section: %v", err)
+			}
+		case settingsSectionShortcuts:
+			var shortcuts ShortcutSettings
+			if err := json.Unmarshal(raw, &shortcuts); err != nil {
+				return ImportSettingsResult{}, fmt.Errorf("error parsing shortcuts section: %v", err)
+			}
+			updated.KeyboardShortcuts = shortcuts.Enabled
+		default:
+			result.Skipped = append(result.Skipped, section)
+			continue
+		}
+		result.Imported = append(result.Imported, section)
+	}
+
+	if len(result.Imported) > 0 {
+		if err := a.UpdateSettings(updated); err != nil {
+			return ImportSettingsResult{}, err
+		}
+	}
+	logInfo("Imported settings sections %v from %s", result.Imported, path)
+	return result, nil
+}
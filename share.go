@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Share targets
+const (
+	shareTargetMastodon = "mastodon"
+	shareTargetBluesky  = "bluesky"
+)
+
+// defaultShareTemplate is the post text used when none is configured
+const defaultShareTemplate = "#NowPlaying {title} by {artist}"
+
+// Posts longer than this are truncated (Bluesky's limit, Mastodon allows 500)
+const shareMaxLength = 300
+
+// defaultBlueskyService is the PDS used to log in to Bluesky
+const defaultBlueskyService = "https://bsky.social"
+
+// shareHTTPClient is used for all social network requests
+var shareHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// decodeDataURL splits a base64 data URL into its bytes and MIME type
+func decodeDataURL(dataURL string) ([]byte, string, error) {
+	parts := strings.SplitN(dataURL, ",", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "data:") {
+		return nil, "", fmt.Errorf("invalid data URL")
+	}
+
+	mimeType := strings.TrimSuffix(strings.TrimPrefix(parts[0], "data:"), ";base64")
+	data, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode data URL: %v", err)
+	}
+	return data, mimeType, nil
+}
+
+// shareText renders the share template for a song
+func (a *App) shareText(song *Song) string {
+	template := a.settings.ShareTemplate
+	if template == "" {
+		template = defaultShareTemplate
+	}
+
+	text := expandSongTemplate(template, song)
+	if len([]rune(text)) > shareMaxLength {
+		text = string([]rune(text)[:shareMaxLength-1]) + "…"
+	}
+	return text
+}
+
+// ShareNowPlaying posts the current song to Mastodon or Bluesky and returns the post URL
+func (a *App) ShareNowPlaying(target string) (string, error) {
+	song := a.currentSong
+	if song == nil {
+		return "", fmt.Errorf("nothing is playing")
+	}
+	return a.shareSong(song, target)
+}
+
+// shareSong posts a song to the given target
+func (a *App) shareSong(song *Song, target string) (string, error) {
+	text := a.shareText(song)
+
+	var cover []byte
+	var coverType string
+	if coverData := a.songCoverData(*song); a.settings.ShareIncludeCover && coverData != "" {
+		data, mimeType, err := a.sharedCover(coverData)
+		if err != nil {
+			logWarn("Share: skipping cover: %v", err)
+		} else {
+			cover, coverType = data, mimeType
+		}
+	}
+
+	var url string
+	var err error
+	switch target {
+	case shareTargetMastodon:
+		url, err = a.postToMastodon(text, cover, coverType, song)
+	case shareTargetBluesky:
+		url, err = a.postToBluesky(text, cover, coverType, song)
+	default:
+		return "", fmt.Errorf("unknown share target: %s", target)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	logInfo("Shared now playing to %s: %s", target, url)
+	return url, nil
+}
+
+// maybeAutoShare shares every N new tracks when auto-share is enabled
+func (a *App) maybeAutoShare(song *Song) {
+	target := a.settings.AutoShareTarget
+	every := a.settings.AutoShareEveryTracks
+	if target == "" || every <= 0 {
+		return
+	}
+
+	a.shareMutex.Lock()
+	a.tracksSinceShare++
+	due := a.tracksSinceShare >= every
+	if due {
+		a.tracksSinceShare = 0
+	}
+	a.shareMutex.Unlock()
+
+	if due {
+		go func() {
+			if _, err := a.shareSong(song, target); err != nil {
+				logError("Auto-share to %s failed: %v", target, err)
+			}
+		}()
+	}
+}
+
+// postToMastodon uploads the optional cover and posts a status
+func (a *App) postToMastodon(text string, cover []byte, coverType string, song *Song) (string, error) {
+	if a.settings.MastodonInstance == "" || a.settings.MastodonToken == "" {
+		return "", fmt.Errorf("Mastodon account is not configured")
+	}
+	instance, err := mastodonInstanceURL(a.settings.MastodonInstance)
+	if err != nil {
+		return "", err
+	}
+
+	var mediaIDs []string
+	if len(cover) > 0 {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="file"; filename="cover"`)
+		header.Set("Content-Type", coverType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return "", fmt.Errorf("failed to create form field: %v", err)
+		}
+		part.Write(cover)
+		writer.WriteField("description", fmt.Sprintf("Cover art of %s by %s", song.Title, song.Artist))
+		writer.Close()
+
+		var media struct {
+			ID string `json:"id"`
+		}
+		if err := a.mastodonRequest(instance+"/api/v2/media", writer.FormDataContentType(), &buf, &media); err != nil {
+			return "", fmt.Errorf("failed to upload cover to Mastodon: %v", err)
+		}
+		mediaIDs = append(mediaIDs, media.ID)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"status":    text,
+		"media_ids": mediaIDs,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal status: %v", err)
+	}
+
+	var status struct {
+		URL string `json:"url"`
+	}
+	if err := a.mastodonRequest(instance+"/api/v1/statuses", "application/json", bytes.NewReader(body), &status); err != nil {
+		return "", fmt.Errorf("failed to post to Mastodon: %v", err)
+	}
+	return status.URL, nil
+}
+
+// mastodonInstanceURL returns the base URL of a Mastodon instance given as a host name or
+// URL. Only https is accepted, the access token is sent along with every request.
+func mastodonInstanceURL(instance string) (string, error) {
+	instance = strings.TrimSuffix(strings.TrimSpace(instance), "/")
+	if !strings.Contains(instance, "://") {
+		instance = "https://" + instance
+	}
+	parsed, err := url.Parse(instance)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return "", fmt.Errorf("Mastodon instance must be an https address: %s", instance)
+	}
+	return instance, nil
+}
+
+// mastodonRequest sends an authenticated POST to a Mastodon instance and decodes the JSON reply
+func (a *App) mastodonRequest(url string, contentType string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+a.settings.MastodonToken)
+
+	resp, err := shareHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(data))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// postToBluesky logs in with an app password, uploads the optional cover and creates a post
+func (a *App) postToBluesky(text string, cover []byte, coverType string, song *Song) (string, error) {
+	if a.settings.BlueskyHandle == "" || a.settings.BlueskyAppPassword == "" {
+		return "", fmt.Errorf("Bluesky account is not configured")
+	}
+	service := defaultBlueskyService
+
+	// Log in
+	loginBody, _ := json.Marshal(map[string]string{
+		"identifier": a.settings.BlueskyHandle,
+		"password":   a.settings.BlueskyAppPassword,
+	})
+	var session struct {
+		AccessJwt string `json:"accessJwt"`
+		Did       string `json:"did"`
+		Handle    string `json:"handle"`
+	}
+	if err := blueskyRequest(service+"/xrpc/com.atproto.server.createSession", "", "application/json", bytes.NewReader(loginBody), &session); err != nil {
+		return "", fmt.Errorf("failed to log in to Bluesky: %v", err)
+	}
+
+	record := map[string]interface{}{
+		"$type":     "app.bsky.feed.post",
+		"text":      text,
+		"createdAt": time.Now().UTC().Format(time.RFC3339),
+	}
+
+	// Hashtags need facets with UTF-8 byte offsets to become clickable
+	if facets := blueskyTagFacets(text); len(facets) > 0 {
+		record["facets"] = facets
+	}
+
+	if len(cover) > 0 {
+		var blob struct {
+			Blob json.RawMessage `json:"blob"`
+		}
+		if err := blueskyRequest(service+"/xrpc/com.atproto.repo.uploadBlob", session.AccessJwt, coverType, bytes.NewReader(cover), &blob); err != nil {
+			return "", fmt.Errorf("failed to upload cover to Bluesky: %v", err)
+		}
+		record["embed"] = map[string]interface{}{
+			"$type": "app.bsky.embed.images",
+			"images": []map[string]interface{}{
+				{"alt": fmt.Sprintf("Cover art of %s by %s", song.Title, song.Artist), "image": blob.Blob},
+			},
+		}
+	}
+
+	postBody, err := json.Marshal(map[string]interface{}{
+		"repo":       session.Did,
+		"collection": "app.bsky.feed.post",
+		"record":     record,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal post: %v", err)
+	}
+
+	var created struct {
+		URI string `json:"uri"`
+	}
+	if err := blueskyRequest(service+"/xrpc/com.atproto.repo.createRecord", session.AccessJwt, "application/json", bytes.NewReader(postBody), &created); err != nil {
+		return "", fmt.Errorf("failed to post to Bluesky: %v", err)
+	}
+
+	// at://did/app.bsky.feed.post/<rkey> -> web URL
+	rkey := created.URI[strings.LastIndex(created.URI, "/")+1:]
+	return fmt.Sprintf("https://bsky.app/profile/%s/post/%s", session.Handle, rkey), nil
+}
+
+// blueskyTagFacets returns hashtag facets for a post's text
+func blueskyTagFacets(text string) []map[string]interface{} {
+	var facets []map[string]interface{}
+	offset := 0
+	for _, word := range strings.SplitAfter(text, " ") {
+		trimmed := strings.TrimRight(word, " .,!?")
+		if strings.HasPrefix(trimmed, "#") && len(trimmed) > 1 {
+			facets = append(facets, map[string]interface{}{
+				"index": map[string]int{"byteStart": offset, "byteEnd": offset + len(trimmed)},
+				"features": []map[string]string{
+					{"$type": "app.bsky.richtext.facet#tag", "tag": trimmed[1:]},
+				},
+			})
+		}
+		offset += len(word)
+	}
+	return facets
+}
+
+// blueskyRequest sends a POST to an XRPC endpoint and decodes the JSON reply
+func blueskyRequest(url string, token string, contentType string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := shareHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(data))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Startup behaviors
+const (
+	startupNothing  = "nothing"
+	startupResume   = "resume"
+	startupPlaylist = "playlist"
+	startupDailyMix = "dailymix"
+)
+
+// eventStartupAction tells the frontend what was loaded on startup
+const eventStartupAction = "startup:action"
+
+// Session saving and daily mix sizes
+const (
+	sessionSaveInterval  = 15 * time.Second
+	dailyMixSize         = 30
+	dailyMixFavorites    = 15 // Songs picked from the most played, the rest are rediscoveries
+	dailyMixFavoritePool = 60
+)
+
+// StartupAction describes what the backend did when the window became ready
+type StartupAction struct {
+	Behavior    string       `json:"behavior"`           // One of the startup behaviors
+	Playlist    string       `json:"playlist,omitempty"` // Folder of the opened playlist
+	Queue       QueueState   `json:"queue"`
+	PositionSec float64      `json:"positionSec"`        // Where to seek the current song to when resuming
+	Continue    *LastSession `json:"continue,omitempty"` // Session to offer continuing, when it wasn't resumed
+	Error       string       `json:"error,omitempty"`
+}
+
+// LastSession is what a "Continue where you left off" prompt shows about the saved session
+type LastSession struct {
+	Song        Song      `json:"song"` // What was playing
+	QueueLength int       `json:"queueLength"`
+	PositionSec float64   `json:"positionSec"`
+	SavedAt     time.Time `json:"savedAt"`
+}
+
+// sessionState is the last session as saved to session.json
+type sessionState struct {
+	Queue       QueueFile `json:"queue"`
+	PositionSec float64   `json:"positionSec"`
+	SavedAt     time.Time `json:"savedAt"`
+	CleanExit   bool      `json:"cleanExit"` // Saved on shutdown, false after a crash
+}
+
+// sessionTracker throttles session saves and holds the startup result
+type sessionTracker struct {
+	positionSec float64
+	lastSaved   time.Time
+	ready       bool // Set once the startup action ran, so an empty queue can't overwrite the last session
+	exiting     bool // Set on shutdown, the last save marks a clean exit
+	action      StartupAction
+	mutex       sync.Mutex
+}
+
+// isValidStartupBehavior reports whether a startup behavior is known
+func isValidStartupBehavior(behavior string) bool {
+	switch behavior {
+	case startupNothing, startupResume, startupPlaylist, startupDailyMix:
+		return true
+	}
+	return false
+}
+
+// getSessionPath returns the path of the saved session
+func getSessionPath() string {
+	return filepath.Join(getConfigDir(), "session.json")
+}
+
+// saveSession writes the current queue and position so the next start can resume
+func (a *App) saveSession() {
+	a.session.mutex.Lock()
+	if !a.session.ready {
+		a.session.mutex.Unlock()
+		return
+	}
+	positionSec := a.session.positionSec
+	exiting := a.session.exiting
+	a.session.lastSaved = time.Now()
+	a.session.mutex.Unlock()
+
+	state := a.GetQueue()
+	if len(state.Songs) == 0 && a.currentSong != nil {
+		// Songs played straight from a playlist view aren't queued
+		state.Songs = []Song{*a.currentSong}
+		state.Index = 0
+	}
+	if len(state.Songs) == 0 {
+		// Nothing was played, the last session can still be continued next time
+		return
+	}
+
+	session := sessionState{
+		Queue:       a.queueFileFromState(state),
+		PositionSec: positionSec,
+		SavedAt:     time.Now(),
+		CleanExit:   exiting,
+	}
+	if err := writeJSONFile(getSessionPath(), session); err != nil {
+		logError("Failed to save session: %v", err)
+	}
+}
+
+// trackSessionPosition records the playback position, saving the session periodically
+func (a *App) trackSessionPosition(currentTimeSeconds float64) {
+	a.session.mutex.Lock()
+	a.session.positionSec = currentTimeSeconds
+	due := time.Since(a.session.lastSaved) >= sessionSaveInterval
+	a.session.mutex.Unlock()
+
+	if due {
+		a.saveSession()
+	}
+}
+
+// loadSession reads the last saved session
+func loadSession() (sessionState, error) {
+	var session sessionState
+	data, err := os.ReadFile(getSessionPath())
+	if err != nil {
+		return session, err
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return session, fmt.Errorf("error parsing session file: %v", err)
+	}
+	return session, nil
+}
+
+// restoreSession resolves the saved session's queue against the library. The position
+// is 0 when the song that was playing is gone. Returns no songs without a saved session.
+func (a *App) restoreSession() (sessionState, []Song, int, error) {
+	session, err := loadSession()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return session, nil, 0, nil
+		}
+		return session, nil, 0, err
+	}
+	songs, index, missing, err := a.resolveQueueFile(session.Queue)
+	if err != nil {
+		return session, nil, 0, err
+	}
+	if len(missing) > 0 {
+		logWarn("Resume: %d songs from the last session are no longer in the library", len(missing))
+	}
+	// Only seek if the song that was playing is still there
+	if index < 0 {
+		index = 0
+		session.PositionSec = 0
+	}
+	return session, songs, index, nil
+}
+
+// expectResumeTransition marks the song a session resumes with as crash-recovered when
+// Static didn't exit cleanly
+func (a *App) expectResumeTransition(session sessionState, song Song) {
+	if !session.CleanExit {
+		a.expectTransition(transitionCrashRecovered, "", song.FilePath, session.PositionSec)
+	}
+}
+
+// GetLastSession returns the saved session for a "Continue where you left off" prompt,
+// nil when there's none or none of its songs are left
+func (a *App) GetLastSession() (*LastSession, error) {
+	session, songs, index, err := a.restoreSession()
+	if err != nil || len(songs) == 0 {
+		return nil, err
+	}
+	return &LastSession{
+		Song:        listSong(songs[index]),
+		QueueLength: len(songs),
+		PositionSec: session.PositionSec,
+		SavedAt:     session.SavedAt,
+	}, nil
+}
+
+// ResumeLastSession loads the saved session's queue. The frontend seeks the current
+// song to the returned PositionSec.
+func (a *App) ResumeLastSession() (StartupAction, error) {
+	session, songs, index, err := a.restoreSession()
+	if err != nil {
+		return StartupAction{}, err
+	}
+	if len(songs) == 0 {
+		return StartupAction{}, fmt.Errorf("no session to continue")
+	}
+	if err := a.SetQueue(songs, index); err != nil {
+		return StartupAction{}, err
+	}
+	a.expectResumeTransition(session, songs[index])
+	logInfo("Continued last session with %d songs", len(songs))
+	return StartupAction{Behavior: startupResume, Queue: a.GetQueue(), PositionSec: session.PositionSec}, nil
+}
+
+// domReady probes the webview's formats, checks the library and runs the configured startup behavior once the frontend has loaded
+func (a *App) domReady(ctx context.Context) {
+	a.ProbePlaybackSupport()
+	a.runLibraryPreflight()
+	action := a.runStartupAction()
+
+	a.session.mutex.Lock()
+	a.session.action = action
+	a.session.ready = true
+	a.session.mutex.Unlock()
+
+	if action.Error != "" {
+		logError("Startup action %s failed: %s", action.Behavior, action.Error)
+	}
+	a.emitEvent(eventStartupAction, action)
+}
+
+// shutdown saves the session and stops background servers when the app closes
+func (a *App) shutdown(ctx context.Context) {
+	a.session.mutex.Lock()
+	a.session.exiting = true
+	a.session.mutex.Unlock()
+	a.saveSession()
+	a.Cleanup()
+}
+
+// runStartupAction loads the queue for the configured startup behavior
+func (a *App) runStartupAction() StartupAction {
+	action := StartupAction{Behavior: a.settings.StartupBehavior, Queue: a.GetQueue()}
+	if !isValidStartupBehavior(action.Behavior) {
+		action.Behavior = startupNothing
+	}
+
+	if action.Behavior != startupResume {
+		// Offered instead, the configured behavior still runs
+		if last, err := a.GetLastSession(); err == nil {
+			action.Continue = last
+		}
+	}
+
+	var songs []Song
+	index := 0
+	switch action.Behavior {
+	case startupNothing:
+		return action
+
+	case startupResume:
+		session, resolved, resolvedIndex, err := a.restoreSession()
+		if err != nil {
+			action.Error = err.Error()
+			return action
+		}
+		songs, index = resolved, resolvedIndex
+		action.PositionSec = session.PositionSec
+		if len(songs) > 0 {
+			a.expectResumeTransition(session, songs[index])
+		}
+
+	case startupPlaylist:
+		if a.settings.StartupPlaylist == "" {
+			action.Error = "no startup playlist selected"
+			return action
+		}
+		playlist, err := a.getCachedPlaylist(a.settings.StartupPlaylist)
+		if err != nil {
+			action.Error = err.Error()
+			return action
+		}
+		action.Playlist = playlist.FolderPath
+		songs = playlist.Songs
+		if playlist.Position >= 0 && playlist.Position < len(songs) {
+			index = playlist.Position
+		}
+
+	case startupDailyMix:
+		mix, err := a.buildDailyMix(time.Now())
+		if err != nil {
+			action.Error = err.Error()
+			return action
+		}
+		songs = mix
+	}
+
+	if len(songs) == 0 {
+		return action
+	}
+	if index < 0 || index >= len(songs) {
+		index = 0
+	}
+	if err := a.SetQueue(songs, index); err != nil {
+		action.Error = err.Error()
+		return action
+	}
+	action.Queue = a.GetQueue()
+
+	logInfo("Startup: %s loaded %d songs", action.Behavior, len(songs))
+	return action
+}
+
+// GetStartupAction returns what was loaded on startup, for frontends that
+// subscribe after the startup:action event was sent
+func (a *App) GetStartupAction() StartupAction {
+	a.session.mutex.Lock()
+	defer a.session.mutex.Unlock()
+	return a.session.action
+}
+
+// buildDailyMix picks a mix of favorites and rarely played songs. The mix is seeded by
+// the date so it stays the same throughout the day.
+func (a *App) buildDailyMix(day time.Time) ([]Song, error) {
+	library, err := a.librarySongs()
+	if err != nil {
+		return nil, fmt.Errorf("error scanning library: %v", err)
+	}
+
+	excluded := make(map[string]bool)
+	for _, track := range a.GetShuffleExclusionSuggestions() {
+		excluded[track.FilePath] = true
+	}
+
+	// Songs can appear in several playlists
+	seen := make(map[string]bool)
+	var songs []Song
+	for _, song := range library {
+		if seen[song.FilePath] || excluded[song.FilePath] {
+			continue
+		}
+		seen[song.FilePath] = true
+		songs = append(songs, song)
+	}
+
+	a.stats.mutex.Lock()
+	playCounts := make(map[string]int, len(songs))
+	for _, song := range songs {
+		if stats, ok := a.stats.tracks[song.FilePath]; ok {
+			playCounts[song.FilePath] = stats.PlayCount
+		}
+	}
+	a.stats.mutex.Unlock()
+
+	// Sort first so the seeded shuffle doesn't depend on scan order
+	sort.Slice(songs, func(i, j int) bool {
+		if playCounts[songs[i].FilePath] != playCounts[songs[j].FilePath] {
+			return playCounts[songs[i].FilePath] > playCounts[songs[j].FilePath]
+		}
+		return songs[i].FilePath < songs[j].FilePath
+	})
+
+	year, month, date := day.Date()
+	rng := rand.New(rand.NewSource(int64(year*10000 + int(month)*100 + date)))
+
+	var favorites, others []Song
+	for i, song := range songs {
+		if i < dailyMixFavoritePool && playCounts[song.FilePath] > 0 {
+			favorites = append(favorites, song)
+		} else {
+			others = append(others, song)
+		}
+	}
+	rng.Shuffle(len(favorites), func(i, j int) { favorites[i], favorites[j] = favorites[j], favorites[i] })
+	rng.Shuffle(len(others), func(i, j int) { others[i], others[j] = others[j], others[i] })
+
+	if len(favorites) > dailyMixFavorites {
+		favorites = favorites[:dailyMixFavorites]
+	}
+	mix := append([]Song{}, favorites...)
+	for _, song := range others {
+		if len(mix) >= dailyMixSize {
+			break
+		}
+		mix = append(mix, song)
+	}
+	rng.Shuffle(len(mix), func(i, j int) { mix[i], mix[j] = mix[j], mix[i] })
+	return mix, nil
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Skip tracking tuning
+const (
+	earlySkipSeconds        = 20.0 // Skips before this point count as a full "dislike"
+	skipScoreHalfLifeDays   = 30.0 // Skip scores halve every 30 days without new skips
+	completedPlayScoreBonus = 0.5  // Finishing a song reduces its skip score by this much
+	frequentSkipMinEarly    = 3    // Minimum early skips before a song is reported
+	shuffleExclusionScore   = 2.5  // Skip score at which a song is suggested for shuffle exclusion
+)
+
+// TrackStats holds per-song listening statistics
+type TrackStats struct {
+	SkipCount      int       `json:"skipCount"`
+	EarlySkipCount int       `json:"earlySkipCount"`
+	SkipScore      float64   `json:"skipScore"`                // Weighted, time-decayed skip score
+	ScoreUpdatedAt time.Time `json:"scoreUpdatedAt,omitempty"` // When SkipScore was last changed, it decays from here
+	LastSkipped    time.Time `json:"lastSkipped,omitempty"`
+	PlayCount      int       `json:"playCount"`
+	LastPlayed     time.Time `json:"lastPlayed,omitempty"`
+	ImportedPlays  int       `json:"importedPlays,omitempty"` // Plays seeded from a Last.fm import, included in PlayCount
+}
+
+// SkippedTrack is a frequently skipped song as reported to the frontend
+type SkippedTrack struct {
+	FilePath       string    `json:"filePath"`
+	SkipCount      int       `json:"skipCount"`
+	EarlySkipCount int       `json:"earlySkipCount"`
+	SkipScore      float64   `json:"skipScore"`
+	LastSkipped    time.Time `json:"lastSkipped"`
+}
+
+// PlayedTrack is a song's play count as reported to the frontend
+type PlayedTrack struct {
+	FilePath   string    `json:"filePath"`
+	PlayCount  int       `json:"playCount"`
+	LastPlayed time.Time `json:"lastPlayed"`
+}
+
+// statsStore persists per-song statistics to stats.json in the config dir
+type statsStore struct {
+	path   string
+	tracks map[string]*TrackStats
+	mutex  sync.Mutex
+}
+
+// newStatsStore creates a stats store backed by the given file
+func newStatsStore(path string) *statsStore {
+	return &statsStore{
+		path:   path,
+		tracks: make(map[string]*TrackStats),
+	}
+}
+
+// load reads the stats file, keeping an empty store if it doesn't exist
+func (s *statsStore) load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading stats file: %v", err)
+	}
+
+	tracks := make(map[string]*TrackStats)
+	if err := json.Unmarshal(data, &tracks); err != nil {
+		return fmt.Errorf("error parsing stats file: %v", err)
+	}
+	s.tracks = tracks
+	return nil
+}
+
+// saveLocked writes the stats file, caller must hold the mutex
+func (s *statsStore) saveLocked() error {
+	return writeJSONFile(s.path, s.tracks)
+}
+
+// getLocked returns the stats entry for a song, creating it if needed
+func (s *statsStore) getLocked(filePath string) *TrackStats {
+	stats, exists := s.tracks[filePath]
+	if !exists {
+		stats = &TrackStats{}
+		s.tracks[filePath] = stats
+	}
+	return stats
+}
+
+// decayedSkipScore returns the skip score decayed to the given time
+func decayedSkipScore(stats *TrackStats, now time.Time) float64 {
+	updatedAt := stats.ScoreUpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = stats.LastSkipped // Stats saved before scores had their own timestamp
+	}
+	if updatedAt.IsZero() || stats.SkipScore <= 0 {
+		return math.Max(stats.SkipScore, 0)
+	}
+	days := now.Sub(updatedAt).Hours() / 24
+	return stats.SkipScore * math.Pow(0.5, days/skipScoreHalfLifeDays)
+}
+
+// skipWeight weighs a skip by how early in the song it happened
+func skipWeight(positionSeconds float64, durationSec int) float64 {
+	if positionSeconds < earlySkipSeconds {
+		return 1.0
+	}
+	if durationSec <= 0 {
+		return 0.25
+	}
+	// Later skips matter less; skipping the outro barely counts
+	remaining := 1 - positionSeconds/float64(durationSec)
+	return math.Max(remaining, 0) * 0.5
+}
+
+// recordSkip adds a weighted skip for a song
+func (s *statsStore) recordSkip(filePath string, positionSeconds float64, durationSec int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	stats := s.getLocked(filePath)
+	stats.SkipScore = decayedSkipScore(stats, now) + skipWeight(positionSeconds, durationSec)
+	stats.SkipCount++
+	if positionSeconds < earlySkipSeconds {
+		stats.EarlySkipCount++
+	}
+	stats.LastSkipped = now
+	stats.ScoreUpdatedAt = now
+
+	return s.saveLocked()
+}
+
+// recordPlay counts a started song and remembers when it was played
+func (s *statsStore) recordPlay(filePath string, playedAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := s.getLocked(filePath)
+	stats.PlayCount++
+	if playedAt.After(stats.LastPlayed) {
+		stats.LastPlayed = playedAt
+	}
+	return s.saveLocked()
+}
+
+// recordCompletion lowers the skip score of a song that was played to the end
+func (s *statsStore) recordCompletion(filePath string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats, exists := s.tracks[filePath]
+	if !exists || stats.SkipScore <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	stats.SkipScore = math.Max(decayedSkipScore(stats, now)-completedPlayScoreBonus, 0)
+	stats.ScoreUpdatedAt = now
+	return s.saveLocked()
+}
+
+// resetSkips clears the skip history of a song
+func (s *statsStore) resetSkips(filePath string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats, exists := s.tracks[filePath]
+	if !exists {
+		return nil
+	}
+	stats.SkipCount = 0
+	stats.EarlySkipCount = 0
+	stats.SkipScore = 0
+	stats.ScoreUpdatedAt = time.Time{}
+	stats.LastSkipped = time.Time{}
+	return s.saveLocked()
+}
+
+// snapshot returns a copy of a song's stats with the skip score decayed to now, zero
+// stats for songs without any
+func (s *statsStore) snapshot(filePath string) TrackStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats, exists := s.tracks[filePath]
+	if !exists {
+		return TrackStats{}
+	}
+	snapshot := *stats
+	snapshot.SkipScore = decayedSkipScore(stats, time.Now())
+	return snapshot
+}
+
+// playedTracks returns the songs played at least once, most played first
+func (s *statsStore) playedTracks() []PlayedTrack {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := []PlayedTrack{}
+	for filePath, stats := range s.tracks {
+		if stats.PlayCount > 0 {
+			result = append(result, PlayedTrack{FilePath: filePath, PlayCount: stats.PlayCount, LastPlayed: stats.LastPlayed})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].PlayCount != result[j].PlayCount {
+			return result[i].PlayCount > result[j].PlayCount
+		}
+		return result[i].LastPlayed.After(result[j].LastPlayed)
+	})
+	return result
+}
+
+// skippedTracks returns songs whose decayed skip score is at least minScore, highest first
+func (s *statsStore) skippedTracks(minScore float64, minEarly int) []SkippedTrack {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	result := []SkippedTrack{}
+	for filePath, stats := range s.tracks {
+		score := decayedSkipScore(stats, now)
+		if score < minScore || stats.EarlySkipCount < minEarly {
+			continue
+		}
+		result = append(result, SkippedTrack{
+			FilePath:       filePath,
+			SkipCount:      stats.SkipCount,
+			EarlySkipCount: stats.EarlySkipCount,
+			SkipScore:      score,
+			LastSkipped:    stats.LastSkipped,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SkipScore > result[j].SkipScore
+	})
+	return result
+}
+
+// writeJSONFile atomically writes v as indented JSON to path
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling %s: %v", filepath.Base(path), err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("error writing %s: %v", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("error replacing %s: %v", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// RecordSkip records that the user skipped a song at the given position
+func (a *App) RecordSkip(filePath string, positionSeconds float64, durationSec int) error {
+	filePath, err := a.resolveTrackRef(filePath)
+	if err != nil {
+		return err
+	}
+
+	if filePath == "" {
+		return fmt.Errorf("file path is required")
+	}
+	if err := a.stats.recordSkip(filePath, positionSeconds, durationSec); err != nil {
+		logError("Failed to save skip stats: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetSongStats returns a song's play count, last played time and skip history
+func (a *App) GetSongStats(filePath string) (TrackStats, error) {
+	filePath, err := a.resolveTrackRef(filePath)
+	if err != nil {
+		return TrackStats{}, err
+	}
+	return a.stats.snapshot(filePath), nil
+}
+
+// GetMostPlayed returns the most played songs, limit <= 0 returns every played song
+func (a *App) GetMostPlayed(limit int) []PlayedTrack {
+	tracks := a.stats.playedTracks()
+	if limit > 0 && len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks
+}
+
+// GetFrequentlySkipped returns songs the user repeatedly skips early, most skipped first
+func (a *App) GetFrequentlySkipped(limit int) []SkippedTrack {
+	tracks := a.stats.skippedTracks(0, frequentSkipMinEarly)
+	if limit > 0 && len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks
+}
+
+// GetShuffleExclusionSuggestions returns songs suggested for removal from shuffle
+func (a *App) GetShuffleExclusionSuggestions() []SkippedTrack {
+	return a.stats.skippedTracks(shuffleExclusionScore, frequentSkipMinEarly)
+}
+
+// DismissSkipSuggestion forgets the skip history of a song so it's no longer suggested
+func (a *App) DismissSkipSuggestion(filePath string) error {
+	filePath, err := a.resolveTrackRef(filePath)
+	if err != nil {
+		return err
+	}
+
+	return a.stats.resetSkips(filePath)
+}
+
+// isExcludedFromShuffle reports whether a song should be kept out of shuffle
+func (a *App) isExcludedFromShuffle(filePath string, excluded map[string]bool) bool {
+	return a.settings.ExcludeSkippedFromShuffle && excluded[filePath]
+}
+
+// ShuffleQueue shuffles the songs after the current one, leaving out
+// frequently skipped songs when ExcludeSkippedFromShuffle is enabled
+func (a *App) ShuffleQueue() QueueState {
+	excluded := make(map[string]bool)
+	for _, track := range a.GetShuffleExclusionSuggestions() {
+		excluded[track.FilePath] = true
+	}
+
+	a.queueMutex.Lock()
+	start := a.queueIndex + 1
+	var upcoming []Song
+	for _, song := range a.queue[start:] {
+		if a.isExcludedFromShuffle(song.FilePath, excluded) {
+			logDebug("Shuffle: leaving out frequently skipped song %s", song.Title)
+			continue
+		}
+		upcoming = append(upcoming, song)
+	}
+	rand.Shuffle(len(upcoming), func(i, j int) {
+		upcoming[i], upcoming[j] = upcoming[j], upcoming[i]
+	})
+	a.queue = append(a.queue[:start:start], upcoming...)
+	state := a.queueStateLocked()
+	a.queueMutex.Unlock()
+
+	a.emitEvent(eventQueueChanged, state)
+	return state
+}
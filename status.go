@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// statusFilters are the FFmpeg filters effects, auto-mix and normalization rely on
+var statusFilters = []string{"rubberband", "atempo", "asetrate", "aresample", "bass", "loudnorm", "acrossfade"}
+
+// SystemStatus reports the external tools and background services the app depends on
+type SystemStatus struct {
+	FFmpeg      FFmpegStatus           `json:"ffmpeg"`
+	MPRIS       MPRISStatus            `json:"mpris"`
+	Discord     DiscordStatus          `json:"discord"`
+	CoverServer CoverServerStatus      `json:"coverServer"`
+	Cache       map[string]interface{} `json:"cache"` // As returned by GetCacheInfo
+	Watchers    WatcherStatus          `json:"watchers"`
+	CheckedAt   time.Time              `json:"checkedAt"`
+}
+
+// FFmpegStatus is whether FFmpeg can process audio and which filters it has
+type FFmpegStatus struct {
+	Available bool            `json:"available"`
+	Version   string          `json:"version"`
+	Filters   map[string]bool `json:"filters"` // Filters from statusFilters, false when missing
+}
+
+// MPRISStatus is the state of the D-Bus session connection and MPRIS interface
+type MPRISStatus struct {
+	Supported bool   `json:"supported"` // Only Linux has MPRIS
+	Connected bool   `json:"connected"` // Connected to the D-Bus session bus
+	Exported  bool   `json:"exported"`  // MPRIS interface is published
+	BusName   string `json:"busName"`
+}
+
+// DiscordStatus is the state of the Discord IPC connection
+type DiscordStatus struct {
+	Enabled       bool   `json:"enabled"`
+	Connected     bool   `json:"connected"`
+	Reconnecting  bool   `json:"reconnecting"`
+	Yielded       bool   `json:"yielded"` // Presence left to another media session
+	ApplicationID string `json:"applicationId"`
+}
+
+// CoverServerStatus is the state of the local cover art server used for Discord
+type CoverServerStatus struct {
+	Running    bool   `json:"running"`
+	Port       int    `json:"port"`
+	TestURL    string `json:"testURL"`
+	CoverURL   string `json:"coverURL"` // Cover shown for the current song
+	UsingImgur bool   `json:"usingImgur"`
+	CachedURLs int    `json:"cachedURLs"` // Uploaded covers remembered
+}
+
+// WatcherStatus is the state of the file watchers
+type WatcherStatus struct {
+	TagWatcher    bool   `json:"tagWatcher"`    // Watching the playing song for tag edits
+	WatchedFile   string `json:"watchedFile"`   // Song watched for tag edits
+	LibraryOnline bool   `json:"libraryOnline"` // Static folder's drive is reachable
+	StaticFolder  string `json:"staticFolder"`
+}
+
+// ffmpegFilters returns which of the wanted filters the installed FFmpeg has
+func ffmpegFilters(wanted []string) map[string]bool {
+	filters := make(map[string]bool, len(wanted))
+	for _, name := range wanted {
+		filters[name] = false
+	}
+	output, err := exec.Command("ffmpeg", "-hide_banner", "-filters").Output()
+	if err != nil {
+		return filters
+	}
+	// Lines look like " TSC rubberband        A->A       Apply time-stretching..."
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		if _, ok := filters[fields[1]]; ok {
+			filters[fields[1]] = true
+		}
+	}
+	return filters
+}
+
+// ffmpegStatus checks the installed FFmpeg
+func (a *App) ffmpegStatus() FFmpegStatus {
+	status := FFmpegStatus{Available: a.checkFFmpegAvailable()}
+	if status.Available {
+		status.Version = ffmpegVersion()
+	}
+	status.Filters = ffmpegFilters(statusFilters)
+	return status
+}
+
+// GetSystemStatus reports FFmpeg, D-Bus/MPRIS, Discord, the cover server, the audio
+// cache and the file watchers in one response, for the settings screen and bug reports
+func (a *App) GetSystemStatus() SystemStatus {
+	status := SystemStatus{
+		FFmpeg: a.ffmpegStatus(),
+		MPRIS: MPRISStatus{
+			Supported: runtime.GOOS == "linux",
+			Connected: a.dbusConn != nil,
+			Exported:  a.mprisProps != nil,
+			BusName:   busName,
+		},
+		CheckedAt: time.Now(),
+	}
+
+	a.discordRetry.mutex.Lock()
+	reconnecting := a.discordRetry.running
+	a.discordRetry.mutex.Unlock()
+	status.Discord = DiscordStatus{
+		Enabled:       a.settings.DiscordRPC,
+		Connected:     a.discordActive,
+		Reconnecting:  reconnecting,
+		Yielded:       a.shouldYieldSession(),
+		ApplicationID: discordAppID,
+	}
+
+	a.coverMutex.RLock()
+	coverURL := a.currentCoverURL
+	a.coverMutex.RUnlock()
+	status.CoverServer = CoverServerStatus{
+		Running:    a.coverServer != nil,
+		Port:       a.coverServerPort,
+		TestURL:    a.coverServerURL("/test"),
+		CoverURL:   coverURL,
+		UsingImgur: strings.Contains(coverURL, "imgur.com"),
+		CachedURLs: a.coverURLs.size(),
+	}
+
+	cache, err := a.GetCacheInfo()
+	if err != nil {
+		cache["error"] = err.Error()
+	}
+	status.Cache = cache
+
+	a.tagWatch.mutex.Lock()
+	status.Watchers.TagWatcher = a.tagWatch.watcher != nil && a.tagWatch.dir != ""
+	status.Watchers.WatchedFile = a.tagWatch.file
+	a.tagWatch.mutex.Unlock()
+	a.playlistMutex.Lock()
+	status.Watchers.LibraryOnline = !a.libraryOffline
+	a.playlistMutex.Unlock()
+	status.Watchers.StaticFolder = a.GetStaticFolderPath()
+
+	return status
+}
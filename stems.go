@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Stem separation events emitted to the frontend
+const (
+	eventStemsStarted = "stems:started"
+	eventStemsDone    = "stems:done"
+)
+
+// Stems that can replace a song's audio
+const (
+	stemInstrumental = "instrumental"
+	stemVocals       = "vocals"
+)
+
+// Stem job states
+const (
+	stemStateNone    = "none"
+	stemStateRunning = "running"
+	stemStateReady   = "ready"
+	stemStateFailed  = "failed"
+)
+
+// StemStatus reports the separation state of a song
+type StemStatus struct {
+	FilePath string `json:"filePath"`
+	State    string `json:"state"` // "none", "running", "ready" or "failed"
+	Tool     string `json:"tool,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// stemJobs tracks running and failed separations; finished ones are found on disk
+type stemJobs struct {
+	jobs  map[string]*StemStatus
+	mutex sync.Mutex
+}
+
+// stemTool returns the installed separation tool, preferring demucs for quality
+func stemTool() (string, bool) {
+	for _, tool := range []string{"demucs", "spleeter"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			return tool, true
+		}
+	}
+	return "", false
+}
+
+// stemCacheDir returns the directory holding a song's separated stems
+func stemCacheDir(filePath string) string {
+	hash := md5.Sum([]byte(pathKey(filePath) + "|" + fileVersion(filePath)))
+	return filepath.Join(audioCacheDir(), "stems", hex.EncodeToString(hash[:]))
+}
+
+// stemFile returns the path of a separated stem, if it has been generated
+func (a *App) stemFile(filePath string, stem string) (string, error) {
+	if stem != stemInstrumental && stem != stemVocals {
+		return "", fmt.Errorf("unknown stem: %s", stem)
+	}
+	path := filepath.Join(stemCacheDir(filePath), stem+".wav")
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("stems not generated yet")
+	}
+	return path, nil
+}
+
+// IsStemSeparationAvailable reports whether demucs or spleeter is installed
+func (a *App) IsStemSeparationAvailable() bool {
+	_, ok := stemTool()
+	return ok
+}
+
+// GetStemStatus returns whether stems exist or are being generated for a song
+func (a *App) GetStemStatus(filePath string) StemStatus {
+	resolved, err := a.resolveTrackRef(filePath)
+	if err != nil {
+		return StemStatus{FilePath: filePath, State: stemStateFailed, Error: err.Error()}
+	}
+	filePath = resolved
+
+	a.stems.mutex.Lock()
+	defer a.stems.mutex.Unlock()
+
+	if job, ok := a.stems.jobs[filePath]; ok {
+		return *job
+	}
+	if _, err := a.stemFile(filePath, stemInstrumental); err == nil {
+		return StemStatus{FilePath: filePath, State: stemStateReady}
+	}
+	return StemStatus{FilePath: filePath, State: stemStateNone}
+}
+
+// SeparateStems starts generating instrumental and vocals-only versions of a song in the background.
+// Progress is reported with stems:started and stems:done events.
+func (a *App) SeparateStems(filePath string) (StemStatus, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return StemStatus{}, err
+	}
+
+	tool, ok := stemTool()
+	if !ok {
+		return StemStatus{}, fmt.Errorf("install demucs or spleeter to generate instrumentals")
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		return StemStatus{}, fmt.Errorf("song file not found: %s", filePath)
+	}
+
+	status := a.GetStemStatus(filePath)
+	if status.State == stemStateRunning || status.State == stemStateReady {
+		return status, nil
+	}
+
+	status = StemStatus{FilePath: filePath, State: stemStateRunning, Tool: tool}
+	a.stems.mutex.Lock()
+	if a.stems.jobs == nil {
+		a.stems.jobs = make(map[string]*StemStatus)
+	}
+	a.stems.jobs[filePath] = &status
+	a.stems.mutex.Unlock()
+	a.emitEvent(eventStemsStarted, status)
+
+	go func() {
+		result := StemStatus{FilePath: filePath, State: stemStateReady, Tool: tool}
+		if err := runStemSeparation(tool, filePath); err != nil {
+			logError("Stem separation failed for %s: %v", filePath, err)
+			result.State = stemStateFailed
+			result.Error = err.Error()
+		}
+
+		a.stems.mutex.Lock()
+		if result.State == stemStateReady {
+			delete(a.stems.jobs, filePath)
+		} else {
+			a.stems.jobs[filePath] = &result
+		}
+		a.stems.mutex.Unlock()
+		a.emitEvent(eventStemsDone, result)
+	}()
+
+	return status, nil
+}
+
+// runStemSeparation runs the tool into a work directory and moves the two stems into the cache
+func runStemSeparation(tool string, filePath string) error {
+	cacheDir := stemCacheDir(filePath)
+	workDir := filepath.Join(cacheDir, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return fmt.Errorf("failed to create stem directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	var cmd *exec.Cmd
+	var vocalsName, instrumentalName string
+	switch tool {
+	case "demucs":
+		cmd = exec.Command("demucs", "--two-stems=vocals", "-o", workDir, filePath)
+		vocalsName, instrumentalName = "vocals.wav", "no_vocals.wav"
+	case "spleeter":
+		cmd = exec.Command("spleeter", "separate", "-p", "spleeter:2stems", "-o", workDir, filePath)
+		vocalsName, instrumentalName = "vocals.wav", "accompaniment.wav"
+	default:
+		return fmt.Errorf("unsupported stem tool: %s", tool)
+	}
+
+	logDebug("Running %s: %s", tool, cmd.String())
+	var output []byte
+	var err error
+	mediaJobs.do(jobBackground, func() { output, err = cmd.CombinedOutput() })
+	if err != nil {
+		return fmt.Errorf("%s error: %v\nOutput: %s", tool, err, string(output))
+	}
+
+	// Output lands in <work>/<model>/<name>/ for demucs and <work>/<name>/ for spleeter
+	var vocals, instrumental string
+	filepath.WalkDir(workDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(d.Name()) {
+		case vocalsName:
+			vocals = path
+		case instrumentalName:
+			instrumental = path
+		}
+		return nil
+	})
+	if vocals == "" || instrumental == "" {
+		return fmt.Errorf("%s did not produce both stems", tool)
+	}
+
+	if err := os.Rename(vocals, filepath.Join(cacheDir, stemVocals+".wav")); err != nil {
+		return fmt.Errorf("failed to store vocals stem: %v", err)
+	}
+	if err := os.Rename(instrumental, filepath.Join(cacheDir, stemInstrumental+".wav")); err != nil {
+		return fmt.Errorf("failed to store instrumental stem: %v", err)
+	}
+	return nil
+}
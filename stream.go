@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// audioStreamLimit caps how many stream URLs stay valid, older ones are forgotten first
+const audioStreamLimit = 64
+
+// audioStream is a file registered for streaming
+type audioStream struct {
+	path     string
+	mimeType string
+	render   *liveRender // Set while path is an effect render that isn't cached yet
+}
+
+// audioServer serves song files over loopback HTTP so the webview can stream and seek
+// with Range requests instead of loading whole files as data URLs
+type audioServer struct {
+	server  *http.Server
+	port    int
+	streams map[string]audioStream // Random stream ID -> file
+	order   []string               // Stream IDs, oldest first
+	mutex   sync.Mutex
+}
+
+// startAudioServerLocked starts the streaming server on a random loopback port,
+// caller must hold the audio mutex
+func (a *App) startAudioServerLocked() error {
+	if a.audio.server != nil {
+		return nil
+	}
+
+	// Loopback only, the files are never exposed to the network
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to start audio server: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/audio/", a.serveAudioStream)
+
+	a.audio.port = listener.Addr().(*net.TCPAddr).Port
+	a.audio.server = &http.Server{Handler: mux}
+	a.audio.streams = make(map[string]audioStream)
+	a.audio.order = nil
+
+	server := a.audio.server
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logError("Audio server error: %v", err)
+		}
+	}()
+
+	logInfo("Audio streaming server listening on 127.0.0.1:%d", a.audio.port)
+	return nil
+}
+
+// stopAudioServer shuts down the streaming server
+func (a *App) stopAudioServer() {
+	a.audio.mutex.Lock()
+	defer a.audio.mutex.Unlock()
+
+	if a.audio.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.audio.server.Shutdown(ctx); err != nil {
+		logError("Error shutting down audio server: %v", err)
+	}
+	a.audio.server = nil
+}
+
+// registerAudioStream makes a file available for streaming and returns its URL
+func (a *App) registerAudioStream(path string, mimeType string) (string, error) {
+	return a.registerStream(audioStream{path: path, mimeType: mimeType})
+}
+
+// registerStream adds a stream to the registry and returns its URL
+func (a *App) registerStream(stream audioStream) (string, error) {
+	a.audio.mutex.Lock()
+	defer a.audio.mutex.Unlock()
+
+	if err := a.startAudioServerLocked(); err != nil {
+		return "", err
+	}
+
+	id := randomToken(16)
+	a.audio.streams[id] = stream
+	a.audio.order = append(a.audio.order, id)
+	for len(a.audio.order) > audioStreamLimit {
+		delete(a.audio.streams, a.audio.order[0])
+		a.audio.order = a.audio.order[1:]
+	}
+
+	return fmt.Sprintf("http://127.0.0.1:%d/audio/%s", a.audio.port, id), nil
+}
+
+// serveAudioStream serves a registered file, http.ServeContent handles Range and HEAD requests
+func (a *App) serveAudioStream(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/audio/")
+
+	a.audio.mutex.Lock()
+	stream, exists := a.audio.streams[id]
+	a.audio.mutex.Unlock()
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Effects still rendering are piped from FFmpeg, once cached the file is served
+	if stream.render != nil {
+		if _, err := os.Stat(stream.path); err != nil {
+			a.serveLiveRender(w, r, stream.render)
+			return
+		}
+		touchCacheEntry(stream.path)
+	}
+
+	file, err := os.Open(stream.path)
+	if err != nil {
+		http.Error(w, "song file not available", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "song file not available", http.StatusNotFound)
+		return
+	}
+
+	// The webview origin differs from the loopback server
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", stream.mimeType)
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
+
+// GetSongStreamURL returns a local HTTP URL that streams the song with an effect chain
+// applied. Unlike GetSongFileURLWithEffects the file is never loaded into memory, and
+// seeking only fetches the requested range. Effects that aren't cached yet are streamed
+// while FFmpeg encodes them, seeking works once the render is cached.
+func (a *App) GetSongStreamURL(filePath string, chain EffectChain) (string, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	if err := chain.validate(); err != nil {
+		return "", err
+	}
+	chain = a.playableChain(filePath, chain)
+
+	if chain.hasFilters() && a.checkFFmpegAvailable() {
+		source := a.chainSource(filePath, chain)
+		cachedFile := processedCacheFile(source, chain)
+		stream := audioStream{path: cachedFile, mimeType: chain.outputMIME()}
+		if !reuseProcessedCache(cachedFile, source, chain) {
+			stream.render = &liveRender{source: source, chain: chain, cachedFile: cachedFile}
+		}
+		return a.registerStream(stream)
+	}
+
+	audioPath, mimeType, err := a.playbackSource(filePath, chain)
+	if err != nil {
+		return "", err
+	}
+	return a.registerAudioStream(audioPath, mimeType)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SongTags are the tags EditSongTags can change, empty fields are left as they are
+type SongTags struct {
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+}
+
+// SongMetadataEdit are the changes EditSongMetadata makes, empty fields are left as they are
+type SongMetadataEdit struct {
+	Title       string `json:"title"`
+	Artist      string `json:"artist"`
+	Album       string `json:"album"`
+	Cover       string `json:"cover,omitempty"` // Data URL of a JPEG or PNG to embed as the front cover
+	RemoveCover bool   `json:"removeCover,omitempty"`
+}
+
+// writeSongTags rewrites a file's tags with FFmpeg. Audio and cover art are copied as is
+// into a temporary file next to the song, which then replaces it.
+func writeSongTags(filePath string, tags map[string]string) error {
+	return writeSongFile(filePath, tags, "", false)
+}
+
+// writeSongFile rewrites a file's tags and, with coverPath set, its embedded cover, or
+// drops the cover with removeCover. Audio is copied as is into a temporary file next to
+// the song, which then replaces it, so a failed write leaves the song untouched.
+func writeSongFile(filePath string, tags map[string]string, coverPath string, removeCover bool) error {
+	tmpPath := tempPathFor(filePath, "tags")
+	isMP3 := strings.EqualFold(filepath.Ext(filePath), ".mp3")
+
+	args := []string{"-v", "error", "-i", filePath}
+	switch {
+	case coverPath != "":
+		args = append(args, "-i", coverPath, "-map", "0:a", "-map", "1:0", "-c", "copy", "-map_metadata", "0", "-disposition:v:0", "attached_pic")
+		if isMP3 {
+			args = append(args, "-metadata:s:v", "title=Album cover", "-metadata:s:v", "comment=Cover (front)")
+		}
+	case removeCover:
+		args = append(args, "-map", "0:a", "-c", "copy", "-map_metadata", "0")
+	default:
+		args = append(args, "-map", "0", "-c", "copy", "-map_metadata", "0")
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "-metadata", key+"="+tags[key])
+	}
+	if isMP3 {
+		args = append(args, "-id3v2_version", "3") // Most widely read ID3 version
+	}
+	cmd := exec.Command("ffmpeg", append(args, "-y", tmpPath)...)
+
+	var output []byte
+	var err error
+	mediaJobs.do(jobForeground, func() { output, err = cmd.CombinedOutput() })
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("FFmpeg error: %v\nOutput: %s", err, string(output))
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("error replacing %s: %v", filepath.Base(filePath), err)
+	}
+	return nil
+}
+
+// EditSongTags writes new tags to a song file and returns the re-read song
+func (a *App) EditSongTags(filePath string, tags SongTags) (Song, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+	if !a.checkFFmpegAvailable() {
+		return Song{}, fmt.Errorf("FFmpeg is required to edit tags")
+	}
+
+	changes := make(map[string]string)
+	if tags.Title != "" {
+		changes["title"] = tags.Title
+	}
+	if tags.Artist != "" {
+		changes["artist"] = tags.Artist
+	}
+	if tags.Album != "" {
+		changes["album"] = tags.Album
+	}
+	if len(changes) == 0 {
+		return a.songMetadata(filePath, nil)
+	}
+	return a.retagSong(filePath, changes)
+}
+
+// EditSongMetadata writes new title, artist, album and cover to a song's ID3, Vorbis or
+// MP4 tags and returns the re-read song. Covers can't be embedded in Ogg files.
+func (a *App) EditSongMetadata(filePath string, edit SongMetadataEdit) (Song, error) {
+	filePath, err := a.resolveLibraryFile(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+	if !a.checkFFmpegAvailable() {
+		return Song{}, fmt.Errorf("FFmpeg is required to edit tags")
+	}
+
+	changes := make(map[string]string)
+	for key, value := range map[string]string{"title": edit.Title, "artist": edit.Artist, "album": edit.Album} {
+		if value = strings.TrimSpace(value); value != "" {
+			changes[key] = value
+		}
+	}
+	if edit.Cover == "" && !edit.RemoveCover {
+		if len(changes) == 0 {
+			return a.songMetadata(filePath, nil)
+		}
+		return a.retagSong(filePath, changes)
+	}
+
+	coverPath := ""
+	if edit.Cover != "" {
+		switch strings.ToLower(filepath.Ext(filePath)) {
+		case ".ogg", ".oga", ".opus":
+			return Song{}, fmt.Errorf("covers can't be embedded in Ogg files")
+		}
+		data, mimeType, err := decodeDataURL(edit.Cover)
+		if err != nil {
+			return Song{}, err
+		}
+		if mimeType != "image/jpeg" && mimeType != "image/png" {
+			return Song{}, fmt.Errorf("cover must be a JPEG or PNG image")
+		}
+		if data, mimeType, err = shrinkCover(data, mimeType); err != nil {
+			return Song{}, err
+		}
+		cover, err := os.CreateTemp("", "static-cover-*"+artworkExtensions[mimeType])
+		if err != nil {
+			return Song{}, fmt.Errorf("error saving cover: %v", err)
+		}
+		coverPath = cover.Name()
+		defer os.Remove(coverPath)
+		_, err = cover.Write(data)
+		cover.Close()
+		if err != nil {
+			return Song{}, fmt.Errorf("error saving cover: %v", err)
+		}
+	}
+	return a.rewriteSong(filePath, func() error {
+		return writeSongFile(filePath, changes, coverPath, edit.RemoveCover && coverPath == "")
+	})
+}
+
+// retagSong writes tags to a song file and returns the re-read song
+func (a *App) retagSong(filePath string, changes map[string]string) (Song, error) {
+	return a.rewriteSong(filePath, func() error { return writeSongTags(filePath, changes) })
+}
+
+// rewriteSong changes a song file with write and returns the re-read song. New tags
+// change the track ID, so the song's language, note and rating move along.
+func (a *App) rewriteSong(filePath string, write func() error) (Song, error) {
+	oldKey := pathKey(filePath)
+	if song, err := a.songMetadata(filePath, nil); err == nil {
+		oldKey = userDataKey(song)
+	}
+
+	if err := write(); err != nil {
+		return Song{}, err
+	}
+	song, err := a.reloadSong(filePath)
+	if err != nil {
+		return Song{}, fmt.Errorf("error reading metadata: %v", err)
+	}
+	if newKey := userDataKey(song); newKey != oldKey {
+		a.moveUserData(oldKey, newKey)
+		song = a.withUserData(song)
+		a.refreshSong(song)
+	}
+	a.emitEvent(eventSongUpdated, song)
+	return listSong(song), nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// eventSongUpdated is emitted when a song's tags were re-read after an external edit
+const eventSongUpdated = "library:song-updated"
+
+// Taggers like Picard and Mp3tag rewrite files in several steps (temp file, rename,
+// padding rewrite), so tags are only re-read once the file stops changing
+const (
+	tagWriteSettleDelay = 1500 * time.Millisecond
+	tagStableCheckDelay = 500 * time.Millisecond
+)
+
+// tagWatcher watches the folder of the playing song for external writes
+type tagWatcher struct {
+	watcher *fsnotify.Watcher
+	dir     string // Watched folder, taggers replace files so the folder is watched rather than the file
+	file    string // Song being watched
+	timer   *time.Timer
+	mutex   sync.Mutex
+}
+
+// fileVersion identifies a file's content by size and modification time, for cache keys
+func fileVersion(filePath string) string {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return ""
+	}
+	return statVersion(info)
+}
+
+// statVersion is fileVersion for an already stat'ed file
+func statVersion(info os.FileInfo) string {
+	return fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano())
+}
+
+// watchSongFile starts watching a song for external tag edits, replacing the previous song
+func (a *App) watchSongFile(filePath string) {
+	a.tagWatch.mutex.Lock()
+	defer a.tagWatch.mutex.Unlock()
+
+	if a.tagWatch.watcher == nil {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			logWarn("Tag watcher unavailable: %v", err)
+			return
+		}
+		a.tagWatch.watcher = watcher
+		go a.runTagWatcher(watcher)
+	}
+
+	dir := filepath.Dir(filePath)
+	if dir != a.tagWatch.dir {
+		if a.tagWatch.dir != "" {
+			a.tagWatch.watcher.Remove(a.tagWatch.dir)
+		}
+		if err := a.tagWatch.watcher.Add(dir); err != nil {
+			logWarn("Tag watcher: can't watch %s: %v", dir, err)
+			a.tagWatch.dir = ""
+			a.tagWatch.file = ""
+			return
+		}
+		a.tagWatch.dir = dir
+	}
+	a.tagWatch.file = filePath
+	if a.tagWatch.timer != nil {
+		a.tagWatch.timer.Stop()
+	}
+}
+
+// runTagWatcher waits for writes to the watched song and schedules a re-read once they settle
+func (a *App) runTagWatcher(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+
+			a.tagWatch.mutex.Lock()
+			if filepath.Clean(event.Name) == a.tagWatch.file {
+				// Every further write pushes the re-read back
+				if a.tagWatch.timer != nil {
+					a.tagWatch.timer.Stop()
+				}
+				filePath := a.tagWatch.file
+				a.tagWatch.timer = time.AfterFunc(tagWriteSettleDelay, func() {
+					a.songFileChanged(filePath)
+				})
+			}
+			a.tagWatch.mutex.Unlock()
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logWarn("Tag watcher: %v", err)
+		}
+	}
+}
+
+// songFileChanged re-reads a song's tags after an external write and refreshes every copy of it
+func (a *App) songFileChanged(filePath string) {
+	// Wait until the size and mtime hold still, the tagger may still be writing
+	version := fileVersion(filePath)
+	for {
+		time.Sleep(tagStableCheckDelay)
+		current := fileVersion(filePath)
+		if current == version {
+			break
+		}
+		version = current
+	}
+	if version == "" {
+		return // Moved or deleted rather than retagged
+	}
+
+	song, err := a.reloadSong(filePath)
+	if err != nil {
+		logError("Tag watcher: failed to re-read %s: %v", filePath, err)
+		return
+	}
+	logDebug("Re-read tags of %s after an external edit", filePath)
+	a.emitEvent(eventSongUpdated, song)
+}
+
+// reloadSong re-reads a song's tags into the index and refreshes every copy of it
+func (a *App) reloadSong(filePath string) (Song, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+	song, err := a.extractMetadata(filePath)
+	if err != nil {
+		return Song{}, err
+	}
+	fresh := map[string]indexEntry{filePath: {Size: info.Size(), ModTime: info.ModTime().UnixNano(), Song: song}}
+	if err := a.storeSongs(fresh); err != nil {
+		logWarn("Could not update library index: %v", err)
+	}
+
+	song = a.withUserData(song)
+	a.refreshSong(song)
+	return song, nil
+}
+
+// refreshSong replaces a song's metadata in cached playlists, the queue and now playing,
+// keeping each copy's playlist position
+func (a *App) refreshSong(song Song) {
+	a.playlistMutex.Lock()
+	for key, playlist := range a.playlistCache {
+		for i := range playlist.Songs {
+			if playlist.Songs[i].FilePath == song.FilePath {
+				updated := song
+				updated.Position = playlist.Songs[i].Position
+				playlist.Songs[i] = updated
+			}
+		}
+		a.playlistCache[key] = playlist
+	}
+	a.playlistMutex.Unlock()
+
+	a.queueMutex.Lock()
+	for i := range a.queue {
+		if a.queue[i].FilePath == song.FilePath {
+			updated := song
+			updated.Position = a.queue[i].Position
+			a.queue[i] = updated
+		}
+	}
+	a.queueMutex.Unlock()
+
+	if current := a.currentSong; current != nil && current.FilePath == song.FilePath {
+		updated := song
+		updated.Position = current.Position
+		a.currentSong = &updated
+		a.updateCoverURL()
+		if err := a.updateOSMediaControls(&updated, a.isPlaying); err != nil {
+			logError("Failed to update OS media controls: %v", err)
+		}
+		if a.discordActive && !a.shouldYieldSession() {
+			a.UpdateDiscordPresence(&updated, a.isPlaying)
+		}
+	}
+}
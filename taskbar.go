@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Unity LauncherEntry identifies the application by its desktop file
+const (
+	launcherEntryAppURI    = "application://static.desktop"
+	launcherEntryPath      = "/com/static/LauncherEntry"
+	launcherEntryInterface = "com.canonical.Unity.LauncherEntry"
+)
+
+// taskbarMinInterval limits how often progress is pushed to the dock/taskbar
+const taskbarMinInterval = time.Second
+
+// taskbarProgress tracks what was last shown so unchanged progress isn't resent
+type taskbarProgress struct {
+	lastSent     time.Time
+	lastProgress float64
+	visible      bool
+	mutex        sync.Mutex
+}
+
+// publishTaskbarProgress shows the current song's progress on the dock or taskbar icon
+func (a *App) publishTaskbarProgress(currentTimeSeconds float64) {
+	if !a.settings.TaskbarProgress {
+		return
+	}
+
+	song := a.currentSong
+	if song == nil || song.DurationSec <= 0 {
+		a.hideTaskbarProgress()
+		return
+	}
+	progress := math.Max(0, math.Min(1, currentTimeSeconds/a.effectiveDuration(song).Seconds()))
+
+	a.taskbar.mutex.Lock()
+	if a.taskbar.visible && time.Since(a.taskbar.lastSent) < taskbarMinInterval {
+		a.taskbar.mutex.Unlock()
+		return
+	}
+	a.taskbar.lastSent = time.Now()
+	a.taskbar.lastProgress = progress
+	a.taskbar.visible = true
+	a.taskbar.mutex.Unlock()
+
+	a.setLauncherProgress(progress, true)
+	setTaskbarProgress(progress, true, a.isPlaying)
+}
+
+// hideTaskbarProgress removes the progress bar, e.g. when playback stops
+func (a *App) hideTaskbarProgress() {
+	a.taskbar.mutex.Lock()
+	wasVisible := a.taskbar.visible
+	a.taskbar.visible = false
+	a.taskbar.mutex.Unlock()
+
+	if wasVisible {
+		a.setLauncherProgress(0, false)
+		setTaskbarProgress(0, false, false)
+	}
+}
+
+// setLauncherProgress emits a Unity LauncherEntry update, understood by Ubuntu Dock,
+// Dash to Dock, Plank and the KDE Plasma task manager
+func (a *App) setLauncherProgress(progress float64, visible bool) {
+	if a.dbusConn == nil {
+		return
+	}
+
+	properties := map[string]dbus.Variant{
+		"progress":         dbus.MakeVariant(progress),
+		"progress-visible": dbus.MakeVariant(visible),
+	}
+	if err := a.dbusConn.Emit(dbus.ObjectPath(launcherEntryPath), launcherEntryInterface+".Update", launcherEntryAppURI, properties); err != nil {
+		logDebug("Launcher progress: %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !windows
+
+package main
+
+// setTaskbarProgress is only implemented on Windows, other desktops use the Unity LauncherEntry
+func setTaskbarProgress(progress float64, visible bool, playing bool) {}
@@ -0,0 +1,117 @@
+//go:build windows
+
+package main
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	ole32                = syscall.NewLazyDLL("ole32.dll")
+	user32               = syscall.NewLazyDLL("user32.dll")
+	procCoInitializeEx   = ole32.NewProc("CoInitializeEx")
+	procCoCreateInstance = ole32.NewProc("CoCreateInstance")
+	procFindWindowW      = user32.NewProc("FindWindowW")
+)
+
+// comGUID mirrors the Windows GUID struct
+type comGUID struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
+var (
+	clsidTaskbarList = comGUID{0x56FDF344, 0xFD6D, 0x11D0, [8]byte{0x95, 0x8A, 0x00, 0x60, 0x97, 0xC9, 0xA0, 0x90}}
+	iidTaskbarList3  = comGUID{0xEA1AFB91, 0x9E28, 0x4B86, [8]byte{0x90, 0xE9, 0x9E, 0x9F, 0x8A, 0x5E, 0xEF, 0xAF}}
+)
+
+// ITaskbarList3 vtable slots and progress states
+const (
+	taskbarHrInit           = 3
+	taskbarSetProgressValue = 9
+	taskbarSetProgressState = 10
+
+	tbpfNoProgress = 0x0
+	tbpfNormal     = 0x2
+	tbpfPaused     = 0x8
+
+	clsctxInprocServer      = 0x1
+	coinitApartmentThreaded = 0x2
+)
+
+// taskbarUpdate is a progress change for the COM worker
+type taskbarUpdate struct {
+	progress float64
+	visible  bool
+	playing  bool
+}
+
+// taskbarUpdates feeds the COM worker, which must keep the same OS thread
+var taskbarUpdates = make(chan taskbarUpdate, 1)
+
+func init() {
+	go taskbarWorker()
+}
+
+// setTaskbarProgress shows progress on the Windows taskbar button, dropping stale updates
+func setTaskbarProgress(progress float64, visible bool, playing bool) {
+	update := taskbarUpdate{progress: progress, visible: visible, playing: playing}
+	select {
+	case taskbarUpdates <- update:
+	default:
+		// Replace the queued update with the newer one
+		select {
+		case <-taskbarUpdates:
+		default:
+		}
+		taskbarUpdates <- update
+	}
+}
+
+// taskbarWorker owns the ITaskbarList3 instance on a COM apartment thread
+func taskbarWorker() {
+	runtime.LockOSThread()
+	procCoInitializeEx.Call(0, coinitApartmentThreaded)
+
+	var taskbar unsafe.Pointer
+	hr, _, _ := procCoCreateInstance.Call(
+		uintptr(unsafe.Pointer(&clsidTaskbarList)), 0, clsctxInprocServer,
+		uintptr(unsafe.Pointer(&iidTaskbarList3)), uintptr(unsafe.Pointer(&taskbar)))
+	if hr != 0 || taskbar == nil {
+		logWarn("Taskbar progress unavailable: HRESULT 0x%x", hr)
+		return
+	}
+	comCall(taskbar, taskbarHrInit)
+
+	title, _ := syscall.UTF16PtrFromString("Static")
+	for update := range taskbarUpdates {
+		hwnd, _, _ := procFindWindowW.Call(0, uintptr(unsafe.Pointer(title)))
+		if hwnd == 0 {
+			continue
+		}
+
+		state := uintptr(tbpfNoProgress)
+		if update.visible {
+			state = tbpfNormal
+			if !update.playing {
+				state = tbpfPaused
+			}
+		}
+		comCall(taskbar, taskbarSetProgressState, hwnd, state)
+		if update.visible {
+			comCall(taskbar, taskbarSetProgressValue, hwnd, uintptr(update.progress*1000), 1000)
+		}
+	}
+}
+
+// comCall invokes a method from a COM object's vtable
+func comCall(object unsafe.Pointer, slot int, args ...uintptr) uintptr {
+	vtable := *(*unsafe.Pointer)(object)
+	method := *(*uintptr)(unsafe.Add(vtable, uintptr(slot)*unsafe.Sizeof(uintptr(0))))
+	hr, _, _ := syscall.SyscallN(method, append([]uintptr{uintptr(object)}, args...)...)
+	return hr
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	_ "image/jpeg" // Register JPEG decoding for embedded covers
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// XDG thumbnail sizes (freedesktop Thumbnail Managing Standard), directory name -> max edge
+var xdgThumbnailSizes = []struct {
+	dir  string
+	size int
+}{
+	{"normal", 128},
+	{"large", 256},
+	{"x-large", 512},
+}
+
+// thumbnailIndex remembers which audio files Static created thumbnails for, so they can be pruned
+type thumbnailIndex struct {
+	path  string
+	files map[string]bool
+	mutex sync.Mutex
+}
+
+// newThumbnailIndex creates a thumbnail index backed by the given file
+func newThumbnailIndex(path string) *thumbnailIndex {
+	return &thumbnailIndex{path: path, files: make(map[string]bool)}
+}
+
+// load reads the index from disk
+func (t *thumbnailIndex) load() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading thumbnail index: %v", err)
+	}
+	var files []string
+	if err := json.Unmarshal(data, &files); err != nil {
+		return fmt.Errorf("error parsing thumbnail index: %v", err)
+	}
+	for _, file := range files {
+		t.files[file] = true
+	}
+	return nil
+}
+
+// saveLocked writes the index, caller must hold the mutex
+func (t *thumbnailIndex) saveLocked() error {
+	files := make([]string, 0, len(t.files))
+	for file := range t.files {
+		files = append(files, file)
+	}
+	return writeJSONFile(t.path, files)
+}
+
+// xdgThumbnailDir returns $XDG_CACHE_HOME/thumbnails
+func xdgThumbnailDir() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		cacheHome = filepath.Join(homeDir, ".cache")
+	}
+	return filepath.Join(cacheHome, "thumbnails")
+}
+
+// xdgThumbnailPath returns where the thumbnail of a file lives for a size directory
+func xdgThumbnailPath(filePath string, sizeDir string) string {
+	hash := md5.Sum([]byte(fileURI(filePath)))
+	return filepath.Join(xdgThumbnailDir(), sizeDir, hex.EncodeToString(hash[:])+".png")
+}
+
+// scaleImage shrinks an image to fit within maxSize using area averaging
+func scaleImage(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return src
+	}
+
+	newWidth, newHeight := maxSize, maxSize
+	if width > height {
+		newHeight = height * maxSize / width
+	} else {
+		newWidth = width * maxSize / height
+	}
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0 := bounds.Min.Y + y*height/newHeight
+		y1 := bounds.Min.Y + (y+1)*height/newHeight
+		for x := 0; x < newWidth; x++ {
+			x0 := bounds.Min.X + x*width/newWidth
+			x1 := bounds.Min.X + (x+1)*width/newWidth
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// encodeThumbnailPNG encodes a PNG with the tEXt chunks the thumbnail spec requires
+func encodeThumbnailPNG(img image.Image, text map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+
+	// Go's encoder can't write text chunks, insert them right after IHDR (8 byte signature + 25 byte chunk)
+	const ihdrEnd = 8 + 25
+	var chunks bytes.Buffer
+	for key, value := range text {
+		payload := append([]byte(key+"\x00"), value...)
+		binary.Write(&chunks, binary.BigEndian, uint32(len(payload)))
+		body := append([]byte("tEXt"), payload...)
+		chunks.Write(body)
+		binary.Write(&chunks, binary.BigEndian, crc32.ChecksumIEEE(body))
+	}
+
+	result := make([]byte, 0, len(data)+chunks.Len())
+	result = append(result, data[:ihdrEnd]...)
+	result = append(result, chunks.Bytes()...)
+	result = append(result, data[ihdrEnd:]...)
+	return result, nil
+}
+
+// exportCoverThumbnail writes a song's cover, read from its artwork store file, to the
+// XDG thumbnail cache so file managers show it too
+func (a *App) exportCoverThumbnail(filePath string, artworkPath string) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return
+	}
+
+	// Thumbnails newer than the file are still valid
+	largest := xdgThumbnailSizes[len(xdgThumbnailSizes)-1].dir
+	if thumbInfo, err := os.Stat(xdgThumbnailPath(filePath, largest)); err == nil && !thumbInfo.ModTime().Before(info.ModTime()) {
+		return
+	}
+
+	data, err := os.ReadFile(artworkPath)
+	if err != nil {
+		return
+	}
+	cover, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		logWarn("Thumbnails: unsupported cover format for %s: %v", filePath, err)
+		return
+	}
+
+	text := map[string]string{
+		"Thumb::URI":   fileURI(filePath),
+		"Thumb::MTime": strconv.FormatInt(info.ModTime().Unix(), 10),
+		"Software":     "Static",
+	}
+	for _, size := range xdgThumbnailSizes {
+		data, err := encodeThumbnailPNG(scaleImage(cover, size.size), text)
+		if err != nil {
+			logError("Thumbnails: failed to encode: %v", err)
+			return
+		}
+
+		path := xdgThumbnailPath(filePath, size.dir)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			logWarn("Thumbnails: %v", err)
+			return
+		}
+		// Write to a temp file first so readers never see a partial thumbnail
+		tmpPath := path + ".tmp"
+		if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+			logWarn("Thumbnails: %v", err)
+			return
+		}
+		if err := os.Rename(tmpPath, path); err != nil {
+			os.Remove(tmpPath)
+			logWarn("Thumbnails: %v", err)
+			return
+		}
+	}
+
+	a.thumbnails.mutex.Lock()
+	if !a.thumbnails.files[filePath] {
+		a.thumbnails.files[filePath] = true
+		if err := a.thumbnails.saveLocked(); err != nil {
+			logWarn("Thumbnails: %v", err)
+		}
+	}
+	a.thumbnails.mutex.Unlock()
+
+}
+
+// pruneCoverThumbnails removes thumbnails Static created for songs no longer in the library
+func (a *App) pruneCoverThumbnails(playlists []Playlist) {
+	library := make(map[string]bool)
+	for _, playlist := range playlists {
+		for _, song := range playlist.Songs {
+			library[song.FilePath] = true
+		}
+	}
+
+	a.thumbnails.mutex.Lock()
+	defer a.thumbnails.mutex.Unlock()
+
+	removed := 0
+	for filePath := range a.thumbnails.files {
+		if library[filePath] {
+			continue
+		}
+		for _, size := range xdgThumbnailSizes {
+			os.Remove(xdgThumbnailPath(filePath, size.dir))
+		}
+		delete(a.thumbnails.files, filePath)
+		removed++
+	}
+
+	if removed > 0 {
+		logInfo("Thumbnails: pruned %d songs removed from the library", removed)
+		if err := a.thumbnails.saveLocked(); err != nil {
+			logWarn("Thumbnails: %v", err)
+		}
+	}
+}
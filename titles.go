@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Title cleanup rules, applied in order by cleanTitle
+var (
+	// Upload junk in brackets: (Official Video), [HQ], (Lyric Video), [4K] ...
+	titleJunkPattern = regexp.MustCompile(`(?i)\s*[\(\[](official\s*)?(music\s*|lyrics?\s*|audio\s*)?(video|audio|visuali[sz]er|lyrics?|mv|m/v|hq|hd|4k|official)(\s*(hq|hd|4k))?[\)\]]`)
+	// Leading track numbers with a separator: "01 - ", "1. ", "03_", "2) ". "7 Rings" stays.
+	titleTrackNumberPattern = regexp.MustCompile(`^\d{1,3}\s*[-._)]\s*(\D)`)
+	// Featured artists in any common spelling, bracketed or trailing
+	titleFeatPattern  = regexp.MustCompile(`(?i)\s*[\(\[]?\s*\b(?:feat|ft|featuring)\b\.?\s+([^\)\]]+?)\s*[\)\]]?$`)
+	titleSpacePattern = regexp.MustCompile(`\s+`)
+)
+
+// TitleChange is one song's title before and after cleanup. After may be edited before
+// the change is applied.
+type TitleChange struct {
+	FilePath string `json:"filePath"`
+	Before   string `json:"before"`
+	After    string `json:"after"`
+	Error    string `json:"error,omitempty"` // Set by ApplyTitleCleanup when writing failed
+}
+
+// cleanTitle applies the cleanup rules to a title. The artist is used to strip
+// "Artist - Title" prefixes left by video rips.
+func cleanTitle(title string, artist string) string {
+	cleaned := title
+
+	// file_names_with_underscores, only when the title has no spaces at all
+	if !strings.Contains(cleaned, " ") {
+		cleaned = strings.ReplaceAll(cleaned, "_", " ")
+	}
+
+	cleaned = titleJunkPattern.ReplaceAllString(cleaned, "")
+	cleaned = titleTrackNumberPattern.ReplaceAllString(cleaned, "$1")
+
+	if artist != "" && artist != "Unknown Artist" {
+		for _, separator := range []string{" - ", " – ", " — "} {
+			if prefix := artist + separator; len(cleaned) > len(prefix) && strings.EqualFold(cleaned[:len(prefix)], prefix) {
+				cleaned = cleaned[len(prefix):]
+				break
+			}
+		}
+	}
+
+	// Featured artists always end up as " (feat. Name)"
+	if match := titleFeatPattern.FindStringSubmatchIndex(cleaned); match != nil {
+		featured := strings.TrimSpace(cleaned[match[2]:match[3]])
+		cleaned = cleaned[:match[0]] + " (feat. " + featured + ")"
+	}
+
+	cleaned = titleSpacePattern.ReplaceAllString(cleaned, " ")
+	cleaned = strings.Trim(cleaned, " -–—")
+	if cleaned == "" {
+		return title // Nothing left, the rules were wrong about this one
+	}
+	return cleaned
+}
+
+// PreviewTitleCleanup returns the title changes cleanup would make to songs, songs whose
+// titles are already clean are left out
+func (a *App) PreviewTitleCleanup(filePaths []string) ([]TitleChange, error) {
+	changes := []TitleChange{}
+	for _, ref := range filePaths {
+		filePath, err := a.resolveLibraryFile(ref)
+		if err != nil {
+			return nil, err
+		}
+		song, err := a.songMetadata(filePath, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error reading metadata of %s: %v", filePath, err)
+		}
+		if cleaned := cleanTitle(song.Title, song.Artist); cleaned != song.Title {
+			changes = append(changes, TitleChange{FilePath: filePath, Before: song.Title, After: cleaned})
+		}
+	}
+	return changes, nil
+}
+
+// ApplyTitleCleanup writes the titles of a preview to the files' tags. Every change is
+// attempted, the result carries the error of those that failed.
+func (a *App) ApplyTitleCleanup(changes []TitleChange) ([]TitleChange, error) {
+	if !a.checkFFmpegAvailable() {
+		return nil, fmt.Errorf("FFmpeg is required to edit tags")
+	}
+
+	applied := 0
+	for i := range changes {
+		change := &changes[i]
+		change.Error = ""
+		after := strings.TrimSpace(change.After)
+		if after == "" || after == change.Before {
+			continue
+		}
+		filePath, err := a.resolveLibraryFile(change.FilePath)
+		if err == nil {
+			err = writeSongTags(filePath, map[string]string{"title": after})
+		}
+		if err == nil {
+			var song Song
+			if song, err = a.reloadSong(filePath); err == nil {
+				a.emitEvent(eventSongUpdated, song)
+			}
+		}
+		if err != nil {
+			change.Error = err.Error()
+			continue
+		}
+		applied++
+	}
+	logInfo("Title cleanup: rewrote %d of %d titles", applied, len(changes))
+	return changes, nil
+}
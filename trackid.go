@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// trackIDPrefix marks track IDs so APIs can tell them apart from paths
+const trackIDPrefix = "trk_"
+
+// trackHashChunk is how much of the start and end of a file goes into its track ID
+const trackHashChunk = 64 * 1024
+
+// indexTracksBucket maps track IDs to file paths
+var indexTracksBucket = []byte("tracks")
+
+// trackRegistry maps the track IDs of songs seen this session to their current paths
+type trackRegistry struct {
+	paths map[string]string
+	mutex sync.RWMutex
+}
+
+// computeTrackID derives a stable ID from a file's size and the content of its first and
+// last 64 KiB, so a song keeps its ID when it's moved or renamed
+func computeTrackID(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	hasher := sha256.New()
+	binary.Write(hasher, binary.BigEndian, info.Size())
+	if _, err := io.CopyN(hasher, file, trackHashChunk); err != nil && err != io.EOF {
+		return "", err
+	}
+	if info.Size() > 2*trackHashChunk {
+		if _, err := file.Seek(-trackHashChunk, io.SeekEnd); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(hasher, file); err != nil {
+			return "", err
+		}
+	}
+	return trackIDPrefix + hex.EncodeToString(hasher.Sum(nil)[:16]), nil
+}
+
+// isTrackID reports whether an API argument is a track ID rather than a path
+func isTrackID(ref string) bool {
+	return strings.HasPrefix(ref, trackIDPrefix)
+}
+
+// registerTrack remembers where a track currently lives
+func (a *App) registerTrack(song Song) {
+	if song.TrackID == "" {
+		return
+	}
+	a.tracks.mutex.Lock()
+	if a.tracks.paths == nil {
+		a.tracks.paths = make(map[string]string)
+	}
+	a.tracks.paths[song.TrackID] = song.FilePath
+	a.tracks.mutex.Unlock()
+}
+
+// resolveTrackID returns the path of a track, from this session's scans or the index
+func (a *App) resolveTrackID(trackID string) (string, error) {
+	a.tracks.mutex.RLock()
+	filePath, ok := a.tracks.paths[trackID]
+	a.tracks.mutex.RUnlock()
+	if ok {
+		return filePath, nil
+	}
+
+	if filePath := a.library.trackPath(trackID); filePath != "" {
+		return filePath, nil
+	}
+	return "", fmt.Errorf("unknown track: %s", trackID)
+}
+
+// resolveTrackRef turns an API argument that's either a track ID or a path into a path
+func (a *App) resolveTrackRef(ref string) (string, error) {
+	if isTrackID(ref) {
+		return a.resolveTrackID(ref)
+	}
+	return normalizePath(ref), nil
+}
+
+// resolveSongPath fills in or normalizes the path of a song sent by the frontend,
+// which may only carry the track ID
+func (a *App) resolveSongPath(song *Song) {
+	if song == nil {
+		return
+	}
+	if song.FilePath == "" && song.TrackID != "" {
+		if filePath, err := a.resolveTrackID(song.TrackID); err == nil {
+			song.FilePath = filePath
+		}
+		return
+	}
+	song.FilePath = normalizePath(song.FilePath)
+}
+
+// trackPath looks a track ID up in the index
+func (l *libraryIndex) trackPath(trackID string) string {
+	if l == nil {
+		return ""
+	}
+	var filePath string
+	l.db.View(func(tx *bolt.Tx) error {
+		filePath = string(tx.Bucket(indexTracksBucket).Get([]byte(trackID)))
+		return nil
+	})
+	return filePath
+}
+
+// ResolveTrack returns the file path of a track ID
+func (a *App) ResolveTrack(trackID string) (string, error) {
+	return a.resolveTrackID(trackID)
+}
+
+// GetTrackID returns the track ID of a file
+func (a *App) GetTrackID(filePath string) (string, error) {
+	song, err := a.songMetadata(normalizePath(filePath), nil)
+	if err != nil {
+		return "", err
+	}
+	return song.TrackID, nil
+}
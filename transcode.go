@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Codecs songs the webview can't decode are transcoded to
+const (
+	transcodeMP3  = "mp3"
+	transcodeOpus = "opus"
+)
+
+const (
+	defaultTranscodeBitrate = 320
+	minTranscodeBitrate     = 64
+	maxTranscodeBitrate     = 320
+)
+
+// TranscodeProfile is what songs the webview can't decode are re-encoded to
+type TranscodeProfile struct {
+	Codec       string `json:"codec"` // "mp3" or "opus", "" for no transcoding
+	BitrateKbps int    `json:"bitrateKbps"`
+	Persistent  bool   `json:"persistent"` // Kept in the audio cache, otherwise only for this session
+}
+
+// key identifies the profile in processed audio cache keys. Persistence isn't part of
+// it, the audio is the same.
+func (p TranscodeProfile) key() string {
+	return fmt.Sprintf("%s-%d", p.Codec, p.BitrateKbps)
+}
+
+// codecArgs returns the FFmpeg output settings for the profile
+func (p TranscodeProfile) codecArgs() []string {
+	bitrate := fmt.Sprintf("%dk", p.BitrateKbps)
+	if p.Codec == transcodeOpus {
+		return []string{"-acodec", "libopus", "-b:a", bitrate, "-ar", "48000", "-ac", "2", "-f", "ogg"}
+	}
+	return []string{"-acodec", "libmp3lame", "-b:a", bitrate, "-ar", "44100", "-ac", "2", "-f", "mp3"}
+}
+
+// codecArgs returns the FFmpeg output settings for audio processed with the chain
+func (c EffectChain) codecArgs() []string {
+	if c.Transcode.Codec != "" {
+		return c.Transcode.codecArgs()
+	}
+	return processedCodecArgs
+}
+
+// outputMIME returns the type of audio processed with the chain
+func (c EffectChain) outputMIME() string {
+	if c.Transcode.Codec == transcodeOpus {
+		return "audio/ogg"
+	}
+	return "audio/mpeg"
+}
+
+// outputExt returns the file extension of audio processed with the chain
+func (c EffectChain) outputExt() string {
+	if c.Transcode.Codec == transcodeOpus {
+		return ".ogg"
+	}
+	return ".mp3"
+}
+
+// validateTranscodeSettings fills in defaults for the transcoding settings and checks them
+func validateTranscodeSettings(settings *Settings) error {
+	if settings.TranscodeCodec == "" {
+		settings.TranscodeCodec = transcodeMP3
+	}
+	if settings.TranscodeCodec != transcodeMP3 && settings.TranscodeCodec != transcodeOpus {
+		return fmt.Errorf("invalid transcode codec: %s", settings.TranscodeCodec)
+	}
+	if settings.TranscodeBitrate == 0 {
+		settings.TranscodeBitrate = defaultTranscodeBitrate
+	}
+	if settings.TranscodeBitrate < minTranscodeBitrate || settings.TranscodeBitrate > maxTranscodeBitrate {
+		return fmt.Errorf("transcode bitrate must be between %d and %d kbps", minTranscodeBitrate, maxTranscodeBitrate)
+	}
+	return nil
+}
+
+// transcodeProfile returns the configured profile, falling back to MP3 when the webview
+// can't decode Opus either
+func (a *App) transcodeProfile(formats map[string]bool) TranscodeProfile {
+	profile := TranscodeProfile{
+		Codec:       a.settings.TranscodeCodec,
+		BitrateKbps: a.settings.TranscodeBitrate,
+		Persistent:  a.settings.TranscodeCache,
+	}
+	if profile.Codec == "" {
+		profile.Codec = transcodeMP3
+	}
+	if profile.BitrateKbps == 0 {
+		profile.BitrateKbps = defaultTranscodeBitrate
+	}
+	if profile.Codec == transcodeOpus && !formats[formatOpus] {
+		profile.Codec = transcodeMP3
+	}
+	return profile
+}
+
+// transcodeSessionDir holds transcodes that aren't kept between sessions
+func transcodeSessionDir() string {
+	return filepath.Join(os.TempDir(), "static-transcodes")
+}
+
+// clearTranscodeSession deletes this session's transcodes
+func clearTranscodeSession() {
+	if err := os.RemoveAll(transcodeSessionDir()); err != nil {
+		logError("Error clearing session transcodes: %v", err)
+	}
+}
+
+// GetTranscodeProfile returns what songs the webview can't decode are transcoded to
+func (a *App) GetTranscodeProfile() TranscodeProfile {
+	formats, _ := a.playbackFormats()
+	return a.transcodeProfile(formats)
+}
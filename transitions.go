@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// eventTrackTransition is emitted with a TrackTransition whenever a different song starts
+const eventTrackTransition = "playback:transition"
+
+// Why playback moved from one song to another
+const (
+	transitionFinished       = "finished"        // The song played to the end and the queue moved on
+	transitionSkipped        = "skipped"         // The user skipped ahead
+	transitionPrevious       = "previous"        // The user went back
+	transitionCrashRecovered = "crash-recovered" // The session was restored after Static didn't exit cleanly
+	transitionQueueJumped    = "queue-jumped"    // The user picked a song, also used when the reason isn't known
+)
+
+// TrackTransition is one change of the playing song
+type TrackTransition struct {
+	Reason      string    `json:"reason"`
+	From        string    `json:"from,omitempty"`        // File path of the song left, "" when nothing played
+	To          string    `json:"to,omitempty"`          // File path of the song started, "" when playback stopped
+	PositionSec float64   `json:"positionSec,omitempty"` // Where the song left was at
+	At          time.Time `json:"at"`
+}
+
+// transitionTracker holds the reason announced for the next song change
+type transitionTracker struct {
+	pending *TrackTransition // Applies when its To song starts
+	mutex   sync.Mutex
+}
+
+// isValidTransitionReason reports whether a reason code is known
+func isValidTransitionReason(reason string) bool {
+	switch reason {
+	case transitionFinished, transitionSkipped, transitionPrevious, transitionCrashRecovered, transitionQueueJumped:
+		return true
+	}
+	return false
+}
+
+// expectTransition announces why the song at to is about to start
+func (a *App) expectTransition(reason string, from string, to string, positionSec float64) {
+	a.transitions.mutex.Lock()
+	a.transitions.pending = &TrackTransition{Reason: reason, From: from, To: to, PositionSec: positionSec}
+	a.transitions.mutex.Unlock()
+}
+
+// takeTransition returns the transition to a newly started song, with the announced
+// reason when it was for this song
+func (a *App) takeTransition(previous *Song, song *Song) TrackTransition {
+	transition := TrackTransition{Reason: transitionQueueJumped, To: song.FilePath, At: time.Now()}
+	if previous != nil {
+		transition.From = previous.FilePath
+	}
+
+	a.transitions.mutex.Lock()
+	pending := a.transitions.pending
+	a.transitions.pending = nil
+	a.transitions.mutex.Unlock()
+
+	if pending != nil && pathKey(pending.To) == pathKey(song.FilePath) {
+		transition.Reason = pending.Reason
+		transition.PositionSec = pending.PositionSec
+		if pending.From != "" {
+			transition.From = pending.From
+		}
+	}
+	return transition
+}
+
+// publishTransition feeds a transition to the skip statistics and the scrobbler log and
+// tells the frontend
+func (a *App) publishTransition(transition TrackTransition) {
+	if transition.Reason == transitionSkipped && transition.From != "" {
+		durationSec := 0
+		if song, ok := a.findLibrarySong(transition.From); ok {
+			durationSec = song.DurationSec
+		}
+		if err := a.stats.recordSkip(transition.From, transition.PositionSec, durationSec); err != nil {
+			logError("Failed to save skip stats: %v", err)
+		}
+	}
+	a.logScrobble(transition)
+	a.emitEvent(eventTrackTransition, transition)
+}
+
+// TransitionTo plays a song and records why playback moved to it: "skipped", "previous"
+// or "queue-jumped". positionSec is where the song left was at. Skips recorded this way
+// count in the skip statistics, so RecordSkip isn't needed for them.
+func (a *App) TransitionTo(song *Song, reason string, positionSec float64) error {
+	if song == nil {
+		return fmt.Errorf("song is required")
+	}
+	if !isValidTransitionReason(reason) {
+		return fmt.Errorf("invalid transition reason: %s", reason)
+	}
+	a.resolveSongPath(song)
+
+	from := ""
+	if a.currentSong != nil {
+		from = a.currentSong.FilePath
+	}
+	a.expectTransition(reason, from, song.FilePath, positionSec)
+	return a.SetCurrentSong(song, true)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// eventSongDeleted is emitted after a song file was deleted or moved to the trash
+const eventSongDeleted = "library:song-deleted"
+
+// DeleteResult describes what happened to a deleted song file
+type DeleteResult struct {
+	FilePath  string `json:"filePath"`
+	Trashed   bool   `json:"trashed"`             // False when the file was deleted permanently
+	TrashPath string `json:"trashPath,omitempty"` // Where the file went, when the platform reports it
+}
+
+// playlistDirOf returns the playlist folder a library file belongs to
+func playlistDirOf(staticPath string, filePath string) (string, bool) {
+	rel, err := filepath.Rel(staticPath, filePath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	parts := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+	if len(parts) < 2 {
+		return "", false // Files directly in the static folder aren't part of a playlist
+	}
+	return filepath.Join(staticPath, parts[0]), true
+}
+
+// DeleteSongFile deletes a song from the library, moving it to the OS trash (freedesktop
+// Trash, Windows Recycle Bin, macOS Trash) unless toTrash is false
+func (a *App) DeleteSongFile(filePath string, toTrash bool) (DeleteResult, error) {
+	filePath, err := a.resolveTrackRef(filePath)
+	if err != nil {
+		return DeleteResult{}, err
+	}
+
+	filePath = filepath.Clean(filePath)
+	playlistDir, ok := playlistDirOf(a.GetStaticFolderPath(), filePath)
+	if !ok {
+		return DeleteResult{}, fmt.Errorf("only songs in the library can be deleted: %s", filePath)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return DeleteResult{}, fmt.Errorf("song file not found: %s", filePath)
+	}
+	if info.IsDir() || !isSupportedAudioFile(filePath) {
+		return DeleteResult{}, fmt.Errorf("not a song file: %s", filePath)
+	}
+
+	result := DeleteResult{FilePath: filePath, Trashed: toTrash}
+	if toTrash {
+		trashPath, err := moveToTrash(filePath)
+		if err != nil {
+			return DeleteResult{}, fmt.Errorf("error moving %s to the trash: %v", filepath.Base(filePath), err)
+		}
+		result.TrashPath = trashPath
+	} else if err := os.Remove(filePath); err != nil {
+		return DeleteResult{}, fmt.Errorf("error deleting %s: %v", filepath.Base(filePath), err)
+	}
+
+	a.forgetDeletedSong(playlistDir, filePath)
+
+	logInfo("Deleted song %s (trash: %v)", filePath, toTrash)
+	a.emitEvent(eventSongDeleted, result)
+	return result, nil
+}
+
+// forgetDeletedSong removes a deleted song from its playlist, the library index and the queue.
+// Listening stats and history are kept.
+func (a *App) forgetDeletedSong(playlistDir string, filePath string) {
+	// Drop the song's position from playlist.toml
+	playlistFile := filepath.Join(playlistDir, "playlist.toml")
+	var config PlaylistConfig
+	if _, err := toml.DecodeFile(playlistFile, &config); err == nil {
+		filename := filepath.Base(filePath)
+		if _, exists := config.Songs[filename]; exists {
+			delete(config.Songs, filename)
+			if err := a.savePlaylistConfig(playlistDir, config); err != nil {
+				logWarn("%v", err)
+			}
+		}
+	}
+
+	if err := a.library.remove(filePath); err != nil {
+		logWarn("Could not update library index: %v", err)
+	}
+
+	// The playlist is rescanned the next time it's requested
+	a.playlistMutex.Lock()
+	delete(a.playlistCache, filepath.Clean(playlistDir))
+	a.playlistMutex.Unlock()
+
+	a.removeFromQueue(filePath)
+}
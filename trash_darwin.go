@@ -0,0 +1,19 @@
+//go:build darwin
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// moveToTrash asks Finder to move a file to the Trash, so "Put Back" works
+func moveToTrash(filePath string) (string, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(filePath)
+	script := fmt.Sprintf(`tell application "Finder" to delete POSIX file "%s"`, escaped)
+	if output, err := exec.Command("osascript", "-e", script).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return "", nil
+}
@@ -0,0 +1,87 @@
+//go:build !windows && !darwin
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// xdgDataHome returns $XDG_DATA_HOME, defaulting to ~/.local/share
+func xdgDataHome() string {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return dataHome
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".local", "share")
+}
+
+// moveToTrash moves a file to the trash following the freedesktop Trash specification
+func moveToTrash(filePath string) (string, error) {
+	trashPath, err := trashInto(filepath.Join(xdgDataHome(), "Trash"), filePath, filePath)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return trashPath, err
+	}
+
+	// Files on other drives go to that drive's own trash, with paths relative to its top directory
+	topDir := mountPointOf(filePath)
+	if topDir == "" {
+		return "", err
+	}
+	rel, relErr := filepath.Rel(topDir, filePath)
+	if relErr != nil {
+		return "", err
+	}
+	return trashInto(filepath.Join(topDir, fmt.Sprintf(".Trash-%d", os.Getuid())), filePath, rel)
+}
+
+// trashInto moves a file into a trash directory, writing the .trashinfo file that lets
+// file managers restore it. infoPath is the path recorded for restoring.
+func trashInto(trashDir string, filePath string, infoPath string) (string, error) {
+	filesDir := filepath.Join(trashDir, "files")
+	infoDir := filepath.Join(trashDir, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", err
+		}
+	}
+
+	base := filepath.Base(filePath)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	name := base
+	for i := 2; ; i++ {
+		// Creating the info file exclusively reserves the name, as the spec requires
+		infoFile := filepath.Join(infoDir, name+".trashinfo")
+		file, err := os.OpenFile(infoFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			name = fmt.Sprintf("%s.%d%s", stem, i, ext)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+			(&url.URL{Path: infoPath}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+		_, err = file.WriteString(info)
+		file.Close()
+		if err != nil {
+			os.Remove(infoFile)
+			return "", err
+		}
+
+		dest := filepath.Join(filesDir, name)
+		if err := os.Rename(filePath, dest); err != nil {
+			os.Remove(infoFile)
+			return "", err
+		}
+		return dest, nil
+	}
+}
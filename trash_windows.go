@@ -0,0 +1,59 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	shell32              = syscall.NewLazyDLL("shell32.dll")
+	procSHFileOperationW = shell32.NewProc("SHFileOperationW")
+)
+
+// SHFileOperation constants
+const (
+	foDelete          = 0x3
+	fofSilent         = 0x4
+	fofNoConfirmation = 0x10
+	fofAllowUndo      = 0x40
+	fofNoErrorUI      = 0x400
+)
+
+// shFileOpStruct mirrors SHFILEOPSTRUCTW
+type shFileOpStruct struct {
+	hwnd                  uintptr
+	wFunc                 uint32
+	pFrom                 *uint16
+	pTo                   *uint16
+	fFlags                uint16
+	fAnyOperationsAborted int32
+	hNameMappings         uintptr
+	lpszProgressTitle     *uint16
+}
+
+// moveToTrash moves a file to the Recycle Bin
+func moveToTrash(filePath string) (string, error) {
+	// pFrom is a list of paths terminated by an extra NUL
+	from, err := syscall.UTF16FromString(filePath)
+	if err != nil {
+		return "", err
+	}
+	from = append(from, 0)
+
+	op := shFileOpStruct{
+		wFunc:  foDelete,
+		pFrom:  &from[0],
+		fFlags: fofAllowUndo | fofNoConfirmation | fofSilent | fofNoErrorUI,
+	}
+	ret, _, _ := procSHFileOperationW.Call(uintptr(unsafe.Pointer(&op)))
+	if ret != 0 {
+		return "", fmt.Errorf("SHFileOperation failed with code 0x%x", ret)
+	}
+	if op.fAnyOperationsAborted != 0 {
+		return "", fmt.Errorf("moving to the Recycle Bin was cancelled")
+	}
+	return "", nil
+}
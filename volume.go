@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// eventVolumeChanged tells the frontend the backend changed the volume, e.g. after an output device switch
+const eventVolumeChanged = "volume:changed"
+
+// outputDevicePollInterval is how often the default audio output is checked for changes
+const outputDevicePollInterval = 3 * time.Second
+
+// The volume limiter raises the volume by at most volumeRampStep every volumeRampInterval
+const (
+	volumeRampStep     = 0.02
+	volumeRampInterval = 100 * time.Millisecond
+)
+
+// VolumeState is the current volume and the output device it applies to
+type VolumeState struct {
+	Volume float64 `json:"volume"`
+	Device string  `json:"device"` // Empty when the output device can't be detected
+}
+
+// deviceVolumeStore remembers the last volume used on each audio output device
+type deviceVolumeStore struct {
+	path    string
+	volumes map[string]float64 // device name -> volume
+	mutex   sync.Mutex
+}
+
+// newDeviceVolumeStore creates a device volume store backed by the given file
+func newDeviceVolumeStore(path string) *deviceVolumeStore {
+	return &deviceVolumeStore{path: path, volumes: make(map[string]float64)}
+}
+
+// load reads the remembered volumes from disk
+func (d *deviceVolumeStore) load() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading device volumes: %v", err)
+	}
+	if err := json.Unmarshal(data, &d.volumes); err != nil {
+		return fmt.Errorf("error parsing device volumes: %v", err)
+	}
+	if d.volumes == nil {
+		d.volumes = make(map[string]float64)
+	}
+	return nil
+}
+
+// get returns the remembered volume for a device
+func (d *deviceVolumeStore) get(device string) (float64, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	volume, ok := d.volumes[device]
+	return volume, ok
+}
+
+// set remembers the volume for a device and saves it
+func (d *deviceVolumeStore) set(device string, volume float64) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if current, ok := d.volumes[device]; ok && current == volume {
+		return nil
+	}
+	d.volumes[device] = volume
+	return writeJSONFile(d.path, d.volumes)
+}
+
+// currentOutputDevice returns the name of the default audio output device.
+// Only PulseAudio and PipeWire (through pipewire-pulse) are supported for now.
+func currentOutputDevice() (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", nil
+	}
+
+	output, err := exec.Command("pactl", "get-default-sink").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to query default sink: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetVolume returns the current volume and output device
+func (a *App) GetVolume() VolumeState {
+	a.volumeMutex.Lock()
+	defer a.volumeMutex.Unlock()
+	return VolumeState{Volume: a.settings.Volume, Device: a.outputDevice}
+}
+
+// SetVolume changes the playback volume, remembering it for the current output device.
+// The volume is capped at the maximum volume setting, and with the limiter enabled
+// increases are ramped in gradually; the returned state is the volume applied right now.
+func (a *App) SetVolume(volume float64) (VolumeState, error) {
+	if volume < 0 || volume > 1 {
+		return VolumeState{}, fmt.Errorf("volume must be between 0 and 1")
+	}
+
+	a.volumeMutex.Lock()
+	volume = capVolume(volume, a.settings.MaxVolume)
+	a.volumeTarget = volume
+	if a.settings.VolumeLimiter && volume-a.settings.Volume > volumeRampStep && !a.volumeRamping {
+		a.volumeRamping = true
+		go a.rampVolume()
+	}
+	if a.volumeRamping {
+		// The ramp picks up the new target
+		state := VolumeState{Volume: a.settings.Volume, Device: a.outputDevice}
+		a.volumeMutex.Unlock()
+		return state, nil
+	}
+	a.volumeMutex.Unlock()
+
+	state := a.applyVolume(volume)
+	a.rememberDeviceVolume(state)
+	return state, a.saveSettings()
+}
+
+// capVolume clamps a volume to the configured ceiling
+func capVolume(volume float64, maxVolume float64) float64 {
+	if maxVolume > 0 && volume > maxVolume {
+		return maxVolume
+	}
+	return volume
+}
+
+// applyVolume sets the current volume without saving it
+func (a *App) applyVolume(volume float64) VolumeState {
+	a.volumeMutex.Lock()
+	a.settings.Volume = volume
+	state := VolumeState{Volume: volume, Device: a.outputDevice}
+	a.volumeMutex.Unlock()
+
+	a.publishVolume(volume)
+	return state
+}
+
+// rememberDeviceVolume stores the volume for the device it was set on
+func (a *App) rememberDeviceVolume(state VolumeState) {
+	if state.Device == "" {
+		return
+	}
+	if err := a.deviceVolumes.set(state.Device, state.Volume); err != nil {
+		logError("Failed to remember device volume: %v", err)
+	}
+}
+
+// rampVolume moves the volume towards the target in small steps, notifying the frontend of each one
+func (a *App) rampVolume() {
+	ticker := time.NewTicker(volumeRampInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.volumeMutex.Lock()
+		next := a.volumeTarget
+		done := next-a.settings.Volume <= volumeRampStep
+		if done {
+			a.volumeRamping = false
+		} else {
+			next = a.settings.Volume + volumeRampStep
+		}
+		a.volumeMutex.Unlock()
+
+		state := a.applyVolume(next)
+		a.emitEvent(eventVolumeChanged, state)
+
+		if done {
+			a.rememberDeviceVolume(state)
+			if err := a.saveSettings(); err != nil {
+				logError("Failed to save volume: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// publishVolume mirrors the volume to MPRIS
+func (a *App) publishVolume(volume float64) {
+	if a.mprisProps != nil {
+		a.mprisProps.SetMust(playerInterface, "Volume", dbus.MakeVariant(volume))
+	}
+}
+
+// onMPRISVolumeChange handles MPRIS clients setting the volume, e.g. `playerctl volume`.
+// The write goes through SetVolume so the cap and limiter apply. It runs once the property
+// call returns, since publishing the capped volume needs the properties lock the call
+// holds, and replaces the raw value the call stores.
+func (a *App) onMPRISVolumeChange(c *prop.Change) *dbus.Error {
+	volume, ok := c.Value.(float64)
+	if !ok {
+		return prop.ErrInvalidArg
+	}
+	volume = math.Min(math.Max(volume, 0), 1) // MPRIS allows values above 1, Static doesn't
+
+	go func() {
+		state, err := a.SetVolume(volume)
+		if err != nil {
+			logError("MPRIS: Failed to set volume: %v", err)
+		}
+		a.publishVolume(state.Volume)
+		a.emitEvent(eventVolumeChanged, state)
+	}()
+	return nil
+}
+
+// watchOutputDevice restores the remembered volume whenever the default output device changes
+func (a *App) watchOutputDevice() {
+	a.checkOutputDevice()
+
+	ticker := time.NewTicker(outputDevicePollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.checkOutputDevice()
+	}
+}
+
+// checkOutputDevice detects an output device switch and applies that device's volume
+func (a *App) checkOutputDevice() {
+	device, err := currentOutputDevice()
+	if err != nil || device == "" {
+		return
+	}
+
+	a.volumeMutex.Lock()
+	changed := device != a.outputDevice
+	previous := a.outputDevice
+	a.outputDevice = device
+	a.volumeMutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	volume, remembered := a.deviceVolumes.get(device)
+	if !remembered {
+		// First time on this device, start from the current volume
+		a.volumeMutex.Lock()
+		volume = a.settings.Volume
+		a.volumeMutex.Unlock()
+		a.rememberDeviceVolume(VolumeState{Volume: volume, Device: device})
+		return
+	}
+
+	logInfo("Output device changed from %q to %q, restoring volume %.2f", previous, device, volume)
+	state, err := a.SetVolume(volume)
+	if err != nil {
+		logError("Failed to restore device volume: %v", err)
+		return
+	}
+	a.emitEvent(eventVolumeChanged, state)
+}
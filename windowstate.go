@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/options"
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// eventMiniPlayerChanged tells the frontend to switch layouts when the mini player is toggled
+const eventMiniPlayerChanged = "window:mini-player"
+
+const (
+	defaultWindowWidth  = 1200
+	defaultWindowHeight = 800
+	minWindowWidth      = 400 // Saved sizes below this are treated as broken
+	minWindowHeight     = 300
+	miniPlayerWidth     = 380
+	miniPlayerHeight    = 140
+	// windowVisibleMargin is how much of a restored window has to be on its monitor
+	windowVisibleMargin = 80
+)
+
+// WindowMonitor identifies the monitor a window was on. Wails only tells monitors apart
+// by their order and size.
+type WindowMonitor struct {
+	Index   int  `json:"index"`
+	Width   int  `json:"width"`
+	Height  int  `json:"height"`
+	Primary bool `json:"primary"`
+}
+
+// WindowState is the main window's placement, restored on the next launch. X and Y are
+// relative to the monitor, as Wails positions windows. While maximized or in the mini
+// player they keep the normal placement to return to.
+type WindowState struct {
+	X          int           `json:"x"`
+	Y          int           `json:"y"`
+	Width      int           `json:"width"`
+	Height     int           `json:"height"`
+	Monitor    WindowMonitor `json:"monitor"`
+	Maximised  bool          `json:"maximised"`
+	MiniPlayer bool          `json:"miniPlayer"`
+	Positioned bool          `json:"positioned"` // X and Y were saved, otherwise the window is centered
+}
+
+// windowStateStore persists the window placement in the config dir
+type windowStateStore struct {
+	path  string
+	state WindowState
+	mutex sync.Mutex
+}
+
+// getWindowStatePath returns where the window placement is saved
+func getWindowStatePath() string {
+	return filepath.Join(getConfigDir(), "window.json")
+}
+
+// loadWindowState reads the saved window placement, the defaults when there's none. It's
+// called before the window is created so the size and maximized state apply right away.
+func (a *App) loadWindowState() WindowState {
+	a.window.mutex.Lock()
+	defer a.window.mutex.Unlock()
+
+	a.window.path = getWindowStatePath()
+	state := WindowState{Width: defaultWindowWidth, Height: defaultWindowHeight}
+	if data, err := os.ReadFile(a.window.path); err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			logWarn("Discarding window state: %v", err)
+			state = WindowState{Width: defaultWindowWidth, Height: defaultWindowHeight}
+		}
+	}
+	if state.Width < minWindowWidth || state.Height < minWindowHeight {
+		state.Width, state.Height = defaultWindowWidth, defaultWindowHeight
+		state.Positioned = false
+	}
+	a.window.state = state
+	return state
+}
+
+// windowOptions returns the initial size and state for the Wails options
+func (state WindowState) windowOptions() (int, int, options.WindowStartState) {
+	if state.MiniPlayer {
+		return miniPlayerWidth, miniPlayerHeight, options.Normal
+	}
+	if state.Maximised {
+		return state.Width, state.Height, options.Maximised
+	}
+	return state.Width, state.Height, options.Normal
+}
+
+// currentMonitor returns the monitor the window is on
+func (a *App) currentMonitor() (WindowMonitor, bool) {
+	screens, err := wailsruntime.ScreenGetAll(a.ctx)
+	if err != nil {
+		return WindowMonitor{}, false
+	}
+	for i, screen := range screens {
+		if screen.IsCurrent {
+			return WindowMonitor{Index: i, Width: screen.Size.Width, Height: screen.Size.Height, Primary: screen.IsPrimary}, true
+		}
+	}
+	return WindowMonitor{}, false
+}
+
+// restoreWindowPosition moves the window back where it was, when it opened on the monitor
+// it was saved on and would still be visible there. Otherwise it's centered.
+func (a *App) restoreWindowPosition() {
+	if a.ctx == nil {
+		return
+	}
+	a.window.mutex.Lock()
+	state := a.window.state
+	a.window.mutex.Unlock()
+	if !state.Positioned || state.Maximised || state.MiniPlayer {
+		return
+	}
+
+	monitor, ok := a.currentMonitor()
+	if !ok || monitor != state.Monitor {
+		logInfo("Window was saved on another monitor, centering it")
+		wailsruntime.WindowCenter(a.ctx)
+		return
+	}
+	visible := state.X+state.Width >= windowVisibleMargin && state.X <= monitor.Width-windowVisibleMargin &&
+		state.Y >= 0 && state.Y <= monitor.Height-windowVisibleMargin
+	if !visible {
+		wailsruntime.WindowCenter(a.ctx)
+		return
+	}
+	wailsruntime.WindowSetPosition(a.ctx, state.X, state.Y)
+}
+
+// captureWindowStateLocked records the window's current placement. Maximized and minimized
+// windows and the mini player keep the last normal size and position. Caller must hold
+// the mutex.
+func (a *App) captureWindowStateLocked() {
+	if a.ctx == nil {
+		return
+	}
+	if a.window.state.MiniPlayer || wailsruntime.WindowIsMinimised(a.ctx) {
+		return
+	}
+	a.window.state.Maximised = wailsruntime.WindowIsMaximised(a.ctx)
+	if a.window.state.Maximised {
+		return
+	}
+	width, height := wailsruntime.WindowGetSize(a.ctx)
+	if width >= minWindowWidth && height >= minWindowHeight {
+		a.window.state.Width, a.window.state.Height = width, height
+	}
+	a.window.state.X, a.window.state.Y = wailsruntime.WindowGetPosition(a.ctx)
+	if monitor, ok := a.currentMonitor(); ok {
+		a.window.state.Monitor = monitor
+		a.window.state.Positioned = true
+	}
+}
+
+// saveWindowStateLocked writes the placement. Caller must hold the mutex.
+func (a *App) saveWindowStateLocked() {
+	if a.window.path == "" {
+		return
+	}
+	if err := writeJSONFile(a.window.path, a.window.state); err != nil {
+		logError("Failed to save window state: %v", err)
+	}
+}
+
+// beforeClose saves the window placement while the window still exists
+func (a *App) beforeClose(ctx context.Context) bool {
+	a.window.mutex.Lock()
+	defer a.window.mutex.Unlock()
+	a.captureWindowStateLocked()
+	a.saveWindowStateLocked()
+	return false
+}
+
+// GetWindowState returns the saved window placement and whether the mini player is on,
+// so the frontend can pick its layout on load
+func (a *App) GetWindowState() WindowState {
+	a.window.mutex.Lock()
+	defer a.window.mutex.Unlock()
+	return a.window.state
+}
+
+// SetMiniPlayer shrinks the window to the mini player or returns it to its previous size,
+// position and maximized state. The mode is remembered across launches.
+func (a *App) SetMiniPlayer(enabled bool) WindowState {
+	a.window.mutex.Lock()
+	defer a.window.mutex.Unlock()
+	if a.window.state.MiniPlayer == enabled {
+		return a.window.state
+	}
+
+	if a.ctx != nil {
+		if enabled {
+			a.captureWindowStateLocked()
+			wailsruntime.WindowUnmaximise(a.ctx)
+			wailsruntime.WindowSetSize(a.ctx, miniPlayerWidth, miniPlayerHeight)
+		} else {
+			wailsruntime.WindowSetSize(a.ctx, a.window.state.Width, a.window.state.Height)
+			if a.window.state.Positioned {
+				wailsruntime.WindowSetPosition(a.ctx, a.window.state.X, a.window.state.Y)
+			}
+			if a.window.state.Maximised {
+				wailsruntime.WindowMaximise(a.ctx)
+			}
+		}
+	}
+	a.window.state.MiniPlayer = enabled
+	a.saveWindowStateLocked()
+	a.emitEvent(eventMiniPlayerChanged, enabled)
+	return a.window.state
+}